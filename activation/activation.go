@@ -64,7 +64,7 @@ type bytesStore interface {
 }
 
 type signer interface {
-	Sign(m []byte) []byte
+	SignActivation(pubLayer uint64, m []byte) ([]byte, error)
 }
 
 const (
@@ -553,6 +553,10 @@ func SignAtx(signer signer, atx *types.ActivationTx) error {
 	if err != nil {
 		return err
 	}
-	atx.Sig = signer.Sign(bts)
+	sig, err := signer.SignActivation(uint64(atx.PubLayerID), bts)
+	if err != nil {
+		return err
+	}
+	atx.Sig = sig
 	return nil
 }
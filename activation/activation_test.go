@@ -112,6 +112,10 @@ func (ms *MockSigning) Sign(m []byte) []byte {
 	return m
 }
 
+func (ms *MockSigning) SignActivation(pubLayer uint64, m []byte) ([]byte, error) {
+	return m, nil
+}
+
 // A compile time check to ensure that postProverClientMock fully implements PostProverClient.
 var _ PostProverClient = (*postProverClientMock)(nil)
 
@@ -24,7 +24,7 @@ import (
 func createLayerWithAtx2(t require.TestingT, msh *mesh.Mesh, id types.LayerID, numOfBlocks int, atxs []*types.ActivationTx, votes []types.BlockID, views []types.BlockID) (created []types.BlockID) {
 	for i := 0; i < numOfBlocks; i++ {
 		block1 := types.NewExistingBlock(id, []byte(rand.String(8)))
-		block1.BlockVotes = append(block1.BlockVotes, votes...)
+		block1.ForDiff = append(block1.ForDiff, votes...)
 		for _, atx := range atxs {
 			block1.ATXIDs = append(block1.ATXIDs, atx.ID())
 		}
@@ -146,7 +146,7 @@ func createLayerWithAtx(t *testing.T, msh *mesh.Mesh, id types.LayerID, numOfBlo
 	}
 	for i := 0; i < numOfBlocks; i++ {
 		block1 := types.NewExistingBlock(id, []byte(rand.String(8)))
-		block1.BlockVotes = append(block1.BlockVotes, votes...)
+		block1.ForDiff = append(block1.ForDiff, votes...)
 		if i < len(atxs) {
 			block1.ATXIDs = append(block1.ATXIDs, atxs[i].ID())
 			fmt.Printf("adding i=%v bid=%v atxid=%v", i, block1.ID(), atxs[i].ShortString())
@@ -186,10 +186,11 @@ func TestATX_ActiveSetForLayerView(t *testing.T) {
 	}
 
 	poetRef := []byte{0xba, 0xb0}
-	for _, atx := range atxs {
+	for i, atx := range atxs {
 		hash, err := atx.NIPSTChallenge.Hash()
 		assert.NoError(t, err)
 		atx.Nipst = NewNIPSTWithChallenge(hash, poetRef)
+		atx.Nipst.Space = uint64(i+1) * 1024
 	}
 	id := atxs[4].ID()
 	fmt.Println("ID4 ", id.ShortString())
@@ -219,6 +220,10 @@ func TestATX_ActiveSetForLayerView(t *testing.T) {
 	assert.Equal(t, 1, len(actives))
 	_, ok := actives[id2.Key]
 	assert.True(t, ok)
+
+	weights, err := atxdb.CalcActiveSetWeights(epoch, blocksMap)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]uint64{id2.Key: atxs[3].Nipst.Space}, weights)
 }
 
 func TestMesh_ActiveSetForLayerView2(t *testing.T) {
@@ -1105,3 +1110,120 @@ func TestActivationDb_ContextuallyValidateAtx(t *testing.T) {
 	err := atxdb.ContextuallyValidateAtx(atx.ActivationTxHeader)
 	r.NoError(err)
 }
+
+func TestActivationDb_PruneAtxBodies(t *testing.T) {
+	r := require.New(t)
+
+	atxdb, _, _ := getAtxDb("t_prune")
+	id1 := types.NodeID{Key: uuid.New().String()}
+	coinbase := types.HexToAddress("aaaa")
+	var atxs []*types.ActivationTx
+	prev := *types.EmptyATXID
+	for i := 0; i < 4; i++ {
+		epoch := types.EpochID(i + 1)
+		atx := types.NewActivationTx(newChallenge(id1, uint64(i), prev, prev, epoch.FirstLayer(atxdb.LayersPerEpoch)), coinbase, uint32(10+i), []types.BlockID{}, &types.NIPST{Space: uint64(i + 1)}, nil)
+		r.NoError(atxdb.StoreAtx(epoch, atx))
+		atxs = append(atxs, atx)
+		prev = atx.ID()
+	}
+
+	pruned, err := atxdb.PruneAtxBodies(3)
+	r.NoError(err)
+	r.Equal(2, pruned)
+
+	for i, atx := range atxs {
+		_, err := atxdb.GetAtxHeader(atx.ID())
+		r.NoError(err, "header of atx %v should be kept", i)
+		_, err = atxdb.GetFullAtx(atx.ID())
+		if i < 2 {
+			r.Equal(ErrAtxBodyPruned, err)
+		} else {
+			r.NoError(err)
+		}
+		space, err := atxdb.GetAtxSpace(atx.ID())
+		r.NoError(err, "space of atx %v should be kept", i)
+		r.Equal(uint64(i+1), space)
+	}
+
+	// pruning again is a no-op
+	pruned, err = atxdb.PruneAtxBodies(3)
+	r.NoError(err)
+	r.Equal(0, pruned)
+
+	history, err := atxdb.AtxHistory(id1)
+	r.NoError(err)
+	r.Len(history, 4)
+	for i, h := range history {
+		r.Equal(atxs[i].ID().Hash32().String(), h.ID)
+		r.Equal(uint64(i), h.Sequence)
+		r.Equal(uint64(atxs[i].TargetEpoch(atxdb.LayersPerEpoch)), h.TargetEpoch)
+		r.Equal(uint32(10+i), h.ActiveSetSize)
+		r.Equal(i < 2, h.BodyPruned)
+	}
+
+	history, err = atxdb.AtxHistory(types.NodeID{Key: uuid.New().String()})
+	r.NoError(err)
+	r.Empty(history)
+}
+
+func TestActivationDb_PruneAtxBodies_StoredBeforeIndex(t *testing.T) {
+	r := require.New(t)
+
+	atxdb, _, store := getAtxDb("t_prune_old")
+	coinbase := types.HexToAddress("aaaa")
+	id1 := types.NodeID{Key: uuid.New().String()}
+	var atxs []*types.ActivationTx
+	prev := *types.EmptyATXID
+	for i := 0; i < 3; i++ {
+		epoch := types.EpochID(i + 1)
+		atx := types.NewActivationTx(newChallenge(id1, uint64(i), prev, prev, epoch.FirstLayer(atxdb.LayersPerEpoch)), coinbase, 10, []types.BlockID{}, &types.NIPST{Space: uint64(i + 1)}, nil)
+		r.NoError(atxdb.StoreAtx(epoch, atx))
+		// atxs stored by older versions have neither an epoch nor a space index
+		r.NoError(store.Delete(getAtxEpochKey(epoch, atx.ID())))
+		r.NoError(store.Delete(getAtxSpaceKey(atx.ID())))
+		atxs = append(atxs, atx)
+		prev = atx.ID()
+	}
+
+	pruned, err := atxdb.PruneAtxBodies(3)
+	r.NoError(err)
+	r.Equal(2, pruned)
+	for i, atx := range atxs {
+		_, err = atxdb.GetFullAtx(atx.ID())
+		if i < 2 {
+			r.Equal(ErrAtxBodyPruned, err)
+		} else {
+			r.NoError(err)
+		}
+		space, err := atxdb.GetAtxSpace(atx.ID())
+		r.NoError(err, "space of atx %v should be kept", i)
+		r.Equal(uint64(i+1), space)
+	}
+	has, err := store.Has([]byte(atxEpochIndexedKey))
+	r.NoError(err)
+	r.True(has)
+}
+
+func TestActivationDb_PruneAtxBodies_KeyMismatch(t *testing.T) {
+	r := require.New(t)
+
+	atxdb, _, store := getAtxDb("t_prune_mismatch")
+	id1 := types.NodeID{Key: uuid.New().String()}
+	atx := types.NewActivationTx(newChallenge(id1, 0, *types.EmptyATXID, *types.EmptyATXID, 1), types.HexToAddress("aaaa"), 10, []types.BlockID{}, &types.NIPST{Space: 1}, nil)
+	r.NoError(atxdb.StoreAtx(1, atx))
+
+	// an atx whose key doesn't match its header fails the backfill instead of being skipped
+	other := types.ATXID(types.Hash32{1})
+	header, err := store.Get(getAtxHeaderKey(atx.ID()))
+	r.NoError(err)
+	body, err := store.Get(getAtxBodyKey(atx.ID()))
+	r.NoError(err)
+	r.NoError(store.Put(getAtxHeaderKey(other), header))
+	r.NoError(store.Put(getAtxBodyKey(other), body))
+
+	_, err = atxdb.PruneAtxBodies(3)
+	r.Error(err)
+	has, err := store.Has([]byte(atxEpochIndexedKey))
+	r.NoError(err)
+	r.False(has)
+}
@@ -2,6 +2,7 @@ package activation
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"github.com/spacemeshos/go-spacemesh/common/types"
@@ -16,6 +17,10 @@ import (
 )
 
 const topAtxKey = "topAtxKey"
+const prunedEpochKey = "prunedEpochKey"
+const atxEpochIndexedKey = "atxEpochIndexedKey"
+
+var atxEpochPrefix = []byte("e_")
 
 func getNodeAtxKey(nodeID types.NodeID, targetEpoch types.EpochID) []byte {
 	return append(getNodeAtxPrefix(nodeID), util.Uint64ToBytesBigEndian(uint64(targetEpoch))...)
@@ -33,6 +38,22 @@ func getAtxBodyKey(atxID types.ATXID) []byte {
 	return []byte(fmt.Sprintf("b_%v", atxID.Bytes()))
 }
 
+// getAtxSpaceKey indexes the space committed by an atx, it is kept when the body is pruned so that eligibility weights
+// can still be calculated
+func getAtxSpaceKey(atxID types.ATXID) []byte {
+	return []byte(fmt.Sprintf("s_%v", atxID.Bytes()))
+}
+
+// getAtxEpochKey indexes atxs by publication epoch, the epoch is big endian encoded so that iteration follows epoch order
+func getAtxEpochKey(epoch types.EpochID, atxID types.ATXID) []byte {
+	key := append([]byte{}, atxEpochPrefix...)
+	key = append(key, util.Uint64ToBytesBigEndian(uint64(epoch))...)
+	return append(key, atxID.Bytes()...)
+}
+
+// ErrAtxBodyPruned is returned when the body of an atx was removed by the retention policy
+var ErrAtxBodyPruned = errors.New("atx body was pruned")
+
 var errInvalidSig = fmt.Errorf("identity not found when validating signature, invalid atx")
 
 type atxChan struct {
@@ -229,7 +250,41 @@ func (db *DB) createTraversalActiveSetCounterFunc(countedAtxs map[string]types.A
 
 // CalcActiveSetSize - returns the active set size that matches the view of the contextually valid blocks in the provided layer
 func (db *DB) CalcActiveSetSize(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
+	countedAtxs, err := db.countActiveAtxs(epoch, blocks)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]struct{}, len(countedAtxs))
+	for k := range countedAtxs {
+		result[k] = struct{}{}
+	}
+
+	return result, nil
+}
+
+// CalcActiveSetWeights returns the space committed by every identity in the active set that matches the view of the
+// contextually valid blocks in the provided layer
+func (db *DB) CalcActiveSetWeights(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]uint64, error) {
+	countedAtxs, err := db.countActiveAtxs(epoch, blocks)
+	if err != nil {
+		return nil, err
+	}
 
+	result := make(map[string]uint64, len(countedAtxs))
+	for k, id := range countedAtxs {
+		space, err := db.GetAtxSpace(id)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get space of atx %v: %v", id.ShortString(), err)
+		}
+		result[k] = space
+	}
+
+	return result, nil
+}
+
+// countActiveAtxs returns the atx of every identity in the active set of epoch, as seen by the view of blocks
+func (db *DB) countActiveAtxs(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]types.ATXID, error) {
 	if epoch == 0 {
 		return nil, errors.New("tried to retrieve active set for epoch 0")
 	}
@@ -250,12 +305,7 @@ func (db *DB) CalcActiveSetSize(epoch types.EpochID, blocks map[types.BlockID]st
 		log.Int("size", len(countedAtxs)),
 		log.String("duration", time.Now().Sub(startTime).String()))
 
-	result := make(map[string]struct{}, len(countedAtxs))
-	for k := range countedAtxs {
-		result[k] = struct{}{}
-	}
-
-	return result, nil
+	return countedAtxs, nil
 }
 
 // CalcActiveSetFromView traverses the view found in a - the activation tx and counts number of active ids published
@@ -513,6 +563,18 @@ func (db *DB) storeAtxUnlocked(atx *types.ActivationTx) error {
 		return err
 	}
 
+	if atx.Nipst != nil {
+		err = db.atxs.Put(getAtxSpaceKey(atx.ID()), util.Uint64ToBytesBigEndian(atx.Nipst.Space))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = db.atxs.Put(getAtxEpochKey(atx.PubLayerID.GetEpoch(db.LayersPerEpoch), atx.ID()), nil)
+	if err != nil {
+		return err
+	}
+
 	// notify subscribers
 	if ch, found := db.atxChannels[atx.ID()]; found {
 		close(ch.ch)
@@ -663,6 +725,9 @@ func (db *DB) GetFullAtx(id types.ATXID) (*types.ActivationTx, error) {
 	db.RLock()
 	atxBytes, err := db.atxs.Get(getAtxBodyKey(id))
 	db.RUnlock()
+	if err == database.ErrNotFound && db.isPruned(id) {
+		return nil, ErrAtxBodyPruned
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -678,6 +743,24 @@ func (db *DB) GetFullAtx(id types.ATXID) (*types.ActivationTx, error) {
 	return atx, nil
 }
 
+// GetAtxSpace returns the space committed by the atx with the given id. It is available after the body of the atx was
+// pruned.
+func (db *DB) GetAtxSpace(id types.ATXID) (uint64, error) {
+	b, err := db.atxs.Get(getAtxSpaceKey(id))
+	if err == nil {
+		return binary.BigEndian.Uint64(b), nil
+	}
+	// atxs stored before the space index was introduced
+	atx, err := db.GetFullAtx(id)
+	if err != nil {
+		return 0, err
+	}
+	if atx.Nipst == nil {
+		return 0, fmt.Errorf("atx %v has no nipst", id.ShortString())
+	}
+	return atx.Nipst.Space, nil
+}
+
 // ValidateSignedAtx extracts public key from message and verifies public key exists in idStore, this is how we validate
 // ATX signature. If this is the first ATX it is considered valid anyways and ATX syntactic validation will determine ATX validity
 func (db *DB) ValidateSignedAtx(pubKey signing.PublicKey, signedAtx *types.ActivationTx) error {
@@ -694,3 +777,178 @@ func (db *DB) ValidateSignedAtx(pubKey signing.PublicKey, signedAtx *types.Activ
 	}
 	return nil
 }
+
+// PruneAtxBodies removes the bodies (NIPST, view and commitment) of all atxs published before epoch. Headers are kept
+// so that active set sizes can still be calculated. It returns the number of pruned bodies.
+func (db *DB) PruneAtxBodies(epoch types.EpochID) (int, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	if err := db.indexExistingAtxs(); err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	it := db.atxs.Find(atxEpochPrefix)
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(atxEpochPrefix)+8+types.Hash32Length {
+			continue
+		}
+		keyEpoch := types.EpochID(binary.BigEndian.Uint64(key[len(atxEpochPrefix) : len(atxEpochPrefix)+8]))
+		if keyEpoch >= epoch {
+			break
+		}
+		id := types.ATXID(types.BytesToHash(key[len(atxEpochPrefix)+8:]))
+		if err := db.atxs.Delete(getAtxBodyKey(id)); err != nil {
+			return pruned, fmt.Errorf("failed to delete body of atx %v: %v", id.ShortString(), err)
+		}
+		if err := db.atxs.Delete(key); err != nil {
+			return pruned, fmt.Errorf("failed to delete epoch index of atx %v: %v", id.ShortString(), err)
+		}
+		pruned++
+	}
+
+	if prev, err := db.getPrunedEpoch(); err == nil && prev >= epoch {
+		return pruned, nil
+	}
+	if err := db.atxs.Put([]byte(prunedEpochKey), util.Uint64ToBytesBigEndian(uint64(epoch))); err != nil {
+		return pruned, fmt.Errorf("failed to store pruned epoch: %v", err)
+	}
+	db.log.With().Info("pruned atx bodies", log.Uint64("before_epoch", uint64(epoch)), log.Int("count", pruned))
+	return pruned, nil
+}
+
+// indexExistingAtxs adds the epoch and space indexes of the atxs stored before these indexes were introduced, so that
+// their bodies are pruned as well. It runs once per database, db must be locked.
+func (db *DB) indexExistingAtxs() error {
+	if has, err := db.atxs.Has([]byte(atxEpochIndexedKey)); err != nil || has {
+		return err
+	}
+
+	var keys, bodies [][]byte
+	it := db.atxs.Find([]byte("b_"))
+	for it.Next() {
+		keys = append(keys, append([]byte{}, it.Key()...))
+		bodies = append(bodies, append([]byte{}, it.Value()...))
+	}
+
+	indexed := 0
+	for i, key := range keys {
+		// the header and the body of an atx are keyed by the same id
+		headerKey := append([]byte("h_"), key[len("b_"):]...)
+		headerBytes, err := db.atxs.Get(headerKey)
+		if err != nil {
+			return fmt.Errorf("failed to read header of atx with key %q: %v", key, err)
+		}
+		var header types.ActivationTxHeader
+		if err := types.BytesToInterface(headerBytes, &header); err != nil {
+			return fmt.Errorf("failed to decode header of atx with key %q: %v", key, err)
+		}
+		// the id of an atx is the hash of its header, so it is recomputed rather than read back from the key
+		atx := &types.ActivationTx{InnerActivationTx: &types.InnerActivationTx{ActivationTxHeader: &header}}
+		atx.CalcAndSetID()
+		id := atx.ID()
+		if !bytes.Equal(getAtxBodyKey(id), key) {
+			return fmt.Errorf("atx key %q doesn't match the id %v of its header", key, id.ShortString())
+		}
+		epochKey := getAtxEpochKey(header.PubLayerID.GetEpoch(db.LayersPerEpoch), id)
+		if has, err := db.atxs.Has(epochKey); err != nil {
+			return err
+		} else if has {
+			continue
+		}
+		body, err := types.BytesToAtx(bodies[i])
+		if err != nil {
+			return fmt.Errorf("failed to decode body of atx %v: %v", id.ShortString(), err)
+		}
+		if body.Nipst != nil {
+			if err := db.atxs.Put(getAtxSpaceKey(id), util.Uint64ToBytesBigEndian(body.Nipst.Space)); err != nil {
+				return err
+			}
+		}
+		if err := db.atxs.Put(epochKey, nil); err != nil {
+			return err
+		}
+		indexed++
+	}
+
+	if err := db.atxs.Put([]byte(atxEpochIndexedKey), []byte{1}); err != nil {
+		return fmt.Errorf("failed to mark atxs as indexed: %v", err)
+	}
+	if indexed > 0 {
+		db.log.With().Info("indexed atxs stored before the retention policy", log.Int("count", indexed))
+	}
+	return nil
+}
+
+func (db *DB) getPrunedEpoch() (types.EpochID, error) {
+	b, err := db.atxs.Get([]byte(prunedEpochKey))
+	if err != nil {
+		return 0, err
+	}
+	return types.EpochID(binary.BigEndian.Uint64(b)), nil
+}
+
+// isPruned checks whether the body of atx id is missing because of the retention policy
+func (db *DB) isPruned(id types.ATXID) bool {
+	db.RLock()
+	epoch, err := db.getPrunedEpoch()
+	db.RUnlock()
+	if err != nil {
+		return false
+	}
+	header, err := db.GetAtxHeader(id)
+	if err != nil {
+		return false
+	}
+	return header.PubLayerID.GetEpoch(db.LayersPerEpoch) < epoch
+}
+
+// AtxHistoryEntry describes a single atx in the history of an identity
+type AtxHistoryEntry struct {
+	ID             string `json:"id"`
+	Sequence       uint64 `json:"sequence"`
+	PubLayer       uint64 `json:"pub_layer"`
+	TargetEpoch    uint64 `json:"target_epoch"`
+	PositioningATX string `json:"positioning_atx"`
+	PrevATX        string `json:"prev_atx"`
+	ActiveSetSize  uint32 `json:"active_set_size"`
+	Coinbase       string `json:"coinbase"`
+	BodyPruned     bool   `json:"body_pruned"`
+}
+
+// AtxHistory returns all the atxs published by nodeID, ordered by target epoch
+func (db *DB) AtxHistory(nodeID types.NodeID) ([]AtxHistoryEntry, error) {
+	var ids []types.ATXID
+	db.RLock()
+	it := db.atxs.Find(getNodeAtxPrefix(nodeID))
+	for it.Next() {
+		ids = append(ids, types.ATXID(types.BytesToHash(it.Value())))
+	}
+	db.RUnlock()
+
+	history := make([]AtxHistoryEntry, 0, len(ids))
+	for _, id := range ids {
+		header, err := db.GetAtxHeader(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get header of atx %v: %v", id.ShortString(), err)
+		}
+		hasBody, err := db.atxs.Has(getAtxBodyKey(id))
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, AtxHistoryEntry{
+			ID:             id.Hash32().String(),
+			Sequence:       header.Sequence,
+			PubLayer:       uint64(header.PubLayerID),
+			TargetEpoch:    uint64(header.TargetEpoch(db.LayersPerEpoch)),
+			PositioningATX: header.PositioningATX.Hash32().String(),
+			PrevATX:        header.PrevATXID.Hash32().String(),
+			ActiveSetSize:  header.ActiveSetSize,
+			Coinbase:       header.Coinbase.String(),
+			BodyPruned:     !hasBody,
+		})
+	}
+	return history, nil
+}
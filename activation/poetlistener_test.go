@@ -51,6 +51,8 @@ func (m *mockMsg) ValidationCompletedChan() chan service.MessageValidation { pan
 
 func (m *mockMsg) ReportValidation(protocol string) { m.validationReported = true }
 
+func (m *mockMsg) ReportInvalid(protocol string) { panic("implement me") }
+
 type PoetDbIMock struct {
 	validationErr error
 }
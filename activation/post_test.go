@@ -5,6 +5,7 @@ import (
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/signing"
 	"github.com/stretchr/testify/require"
+	"os"
 	"testing"
 )
 
@@ -41,3 +42,89 @@ func TestPostClient(t *testing.T) {
 	err = verifyPost(*keyID, proof, postCfg.SpacePerUnit, postCfg.NumProvenLabels, postCfg.Difficulty)
 	assert.NoError(err)
 }
+
+func TestPostClient_Verify(t *testing.T) {
+	assert := require.New(t)
+
+	id := make([]byte, 32)
+	_, err := rand.Read(id)
+	assert.NoError(err)
+
+	cfg := postCfg
+	cfg.NumFiles = 2
+	c, err := NewPostClient(&cfg, id)
+	assert.NoError(err)
+
+	_, err = c.Verify(PostVerifyOptions{})
+	assert.Error(err, "verify before initialization should fail")
+
+	_, err = c.Initialize()
+	assert.NoError(err)
+	defer func() {
+		assert.NoError(c.Reset())
+	}()
+
+	report, err := c.Verify(PostVerifyOptions{})
+	assert.NoError(err)
+	assert.True(report.Intact())
+	assert.Equal(2, report.Files)
+	assert.Equal(cfg.SpacePerUnit/32, report.CheckedGroups)
+
+	// corrupt label group 3 of the second file
+	f, err := os.OpenFile(c.dataFile(1), os.O_RDWR, 0)
+	assert.NoError(err)
+	_, err = f.WriteAt([]byte{0xde, 0xad, 0xbe, 0xef}, 3*32)
+	assert.NoError(err)
+	assert.NoError(f.Close())
+
+	report, err = c.Verify(PostVerifyOptions{})
+	assert.NoError(err)
+	assert.False(report.Intact())
+	assert.Equal(uint64(1), report.BadGroups)
+	assert.Equal([]PostBadRange{{File: 1, From: 3, To: 4}}, report.BadRanges)
+	assert.Equal([]int{1}, report.DamagedFiles())
+
+	report, err = c.Verify(PostVerifyOptions{Repair: true})
+	assert.NoError(err)
+	assert.Equal([]int{1}, report.Repaired)
+
+	report, err = c.Verify(PostVerifyOptions{Samples: 4})
+	assert.NoError(err)
+	assert.True(report.Intact())
+	assert.Equal(uint64(8), report.CheckedGroups)
+
+	// a truncated file fails the positions it no longer covers
+	assert.NoError(os.Truncate(c.dataFile(0), 10*32))
+	report, err = c.Verify(PostVerifyOptions{})
+	assert.NoError(err)
+	assert.Equal([]PostBadRange{{File: 0, From: 10, To: cfg.SpacePerUnit / 64}}, report.BadRanges)
+}
+
+func TestPostClient_VerifyDryRun(t *testing.T) {
+	assert := require.New(t)
+
+	id := make([]byte, 32)
+	_, err := rand.Read(id)
+	assert.NoError(err)
+
+	c, err := NewPostClient(&postCfg, id)
+	assert.NoError(err)
+	_, err = c.Initialize()
+	assert.NoError(err)
+	defer func() {
+		assert.NoError(c.Reset())
+	}()
+
+	report, err := c.Verify(PostVerifyOptions{Samples: 8, DryRun: true})
+	assert.NoError(err)
+	assert.True(report.DryRun)
+	assert.NoError(report.ProofErr)
+	assert.True(report.Intact())
+}
+
+func TestBadRanges(t *testing.T) {
+	bad := map[uint64]bool{2: true, 5: true, 6: true, 9: true}
+	ranges := badRanges(0, 12, []uint64{0, 2, 4, 5, 6, 8, 9}, bad)
+	require.Equal(t, []PostBadRange{{0, 1, 4}, {0, 5, 8}, {0, 9, 12}}, ranges)
+	require.Empty(t, badRanges(0, 12, []uint64{0, 2}, nil))
+}
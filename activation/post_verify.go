@@ -0,0 +1,232 @@
+package activation
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spacemeshos/post/initialization"
+	"github.com/spacemeshos/post/persistence"
+	"github.com/spacemeshos/post/shared"
+	"github.com/spacemeshos/post/validation"
+)
+
+// PostVerifyOptions controls the PoST data integrity check
+type PostVerifyOptions struct {
+	// Samples is the number of label groups sampled per data file, 0 checks every label group
+	Samples uint64
+	// Repair re-initializes the data files in which damaged label groups were found
+	Repair bool
+	// DryRun generates and validates a proof for a random challenge after the data check
+	DryRun bool
+}
+
+// PostBadRange is a range of label groups [From, To) in a PoST data file which may be damaged. The range is bounded by
+// the nearest label groups that were sampled and found intact.
+type PostBadRange struct {
+	File int
+	From uint64
+	To   uint64
+}
+
+// PostVerifyReport is the result of a PoST data integrity check
+type PostVerifyReport struct {
+	Files         int
+	CheckedGroups uint64
+	BadGroups     uint64
+	BadRanges     []PostBadRange
+	Repaired      []int
+	DryRun        bool
+	ProofErr      error
+}
+
+// Intact indicates whether no damage was found and the dry-run proof (if requested) was valid.
+func (r *PostVerifyReport) Intact() bool {
+	return r.BadGroups == 0 && r.ProofErr == nil
+}
+
+// DamagedFiles returns the indices of the data files in which bad label groups were found.
+func (r *PostVerifyReport) DamagedFiles() []int {
+	var files []int
+	for _, br := range r.BadRanges {
+		if len(files) == 0 || files[len(files)-1] != br.File {
+			files = append(files, br.File)
+		}
+	}
+	return files
+}
+
+// Verify checks the integrity of the initialized PoST data. Label groups are read from the data files and compared
+// against the labels re-derived from the commitment id, damaged files are optionally re-initialized, and optionally a
+// full proof is generated and validated for a random challenge.
+func (c *PostClient) Verify(opts PostVerifyOptions) (*PostVerifyReport, error) {
+	if opts.Repair {
+		c.Lock()
+		defer c.Unlock()
+	} else {
+		c.RLock()
+		defer c.RUnlock()
+	}
+
+	if err := c.initializer.VerifyCompleted(); err != nil {
+		return nil, err
+	}
+
+	report := &PostVerifyReport{Files: c.cfg.NumFiles}
+	groupsPerFile := shared.NumLabelGroups(c.cfg.SpacePerUnit / uint64(c.cfg.NumFiles))
+	rnd := mrand.New(mrand.NewSource(time.Now().UnixNano()))
+	for i := 0; i < c.cfg.NumFiles; i++ {
+		positions := samplePositions(rnd, groupsPerFile, opts.Samples)
+		bad, err := c.verifyFile(i, groupsPerFile, positions)
+		if err != nil {
+			return nil, err
+		}
+		report.CheckedGroups += uint64(len(positions))
+		report.BadGroups += uint64(len(bad))
+		report.BadRanges = append(report.BadRanges, badRanges(i, groupsPerFile, positions, bad)...)
+	}
+
+	if opts.Repair {
+		for _, i := range report.DamagedFiles() {
+			c.logger.Info("post verify: re-initializing damaged file %v", i)
+			if err := c.reinitFile(i, groupsPerFile); err != nil {
+				return nil, fmt.Errorf("failed to re-initialize file %v: %v", i, err)
+			}
+			report.Repaired = append(report.Repaired, i)
+		}
+	}
+
+	if opts.DryRun {
+		report.DryRun = true
+		report.ProofErr = c.dryRunProof()
+	}
+
+	return report, nil
+}
+
+func (c *PostClient) dataFile(index int) string {
+	return filepath.Join(shared.GetInitDir(c.cfg.DataDir, c.minerID), shared.InitFileName(c.minerID, index))
+}
+
+// verifyFile compares the label groups of a data file at the given positions with their derived values, and returns
+// the positions which didn't match. A missing or truncated file fails all the positions it doesn't cover.
+func (c *PostClient) verifyFile(index int, groupsPerFile uint64, positions []uint64) (map[uint64]bool, error) {
+	bad := make(map[uint64]bool)
+	f, err := os.Open(c.dataFile(index))
+	if os.IsNotExist(err) {
+		for _, pos := range positions {
+			bad[pos] = true
+		}
+		return bad, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	offset := uint64(index) * groupsPerFile
+	difficulty := initialization.Difficulty(c.cfg.Difficulty)
+	lg := make([]byte, initialization.LabelGroupSize)
+	for _, pos := range positions {
+		_, err := f.ReadAt(lg, int64(pos*initialization.LabelGroupSize))
+		if err == io.EOF {
+			bad[pos] = true
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %v at label group %v: %v", index, pos, err)
+		}
+		expected := initialization.CalcLabelGroup(c.minerID, offset+pos, difficulty)
+		if string(lg) != string(expected) {
+			bad[pos] = true
+		}
+	}
+	return bad, nil
+}
+
+// reinitFile rewrites a data file from scratch. Labels are deterministic, so the rewritten file matches the existing
+// commitment.
+func (c *PostClient) reinitFile(index int, groupsPerFile uint64) error {
+	if err := os.Remove(c.dataFile(index)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	w, err := persistence.NewLabelsWriter(c.cfg.DataDir, c.minerID, index)
+	if err != nil {
+		return err
+	}
+	offset := uint64(index) * groupsPerFile
+	difficulty := initialization.Difficulty(c.cfg.Difficulty)
+	for pos := uint64(0); pos < groupsPerFile; pos++ {
+		if err := w.Write(initialization.CalcLabelGroup(c.minerID, offset+pos, difficulty)); err != nil {
+			w.Close()
+			return err
+		}
+	}
+	_, err = w.Close()
+	return err
+}
+
+func (c *PostClient) dryRunProof() error {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return err
+	}
+	proof, err := c.prover.GenerateProof(challenge)
+	if err != nil {
+		return err
+	}
+	v, err := validation.NewValidator(c.cfg)
+	if err != nil {
+		return err
+	}
+	return v.Validate(c.minerID, proof)
+}
+
+// samplePositions draws n distinct sorted positions out of total, or returns all positions when n is 0 or not smaller
+// than total.
+func samplePositions(rnd *mrand.Rand, total, n uint64) []uint64 {
+	if n == 0 || n >= total {
+		positions := make([]uint64, total)
+		for i := range positions {
+			positions[i] = uint64(i)
+		}
+		return positions
+	}
+	set := make(map[uint64]struct{}, n)
+	for uint64(len(set)) < n {
+		set[uint64(rnd.Int63n(int64(total)))] = struct{}{}
+	}
+	positions := make([]uint64, 0, n)
+	for pos := range set {
+		positions = append(positions, pos)
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i] < positions[j] })
+	return positions
+}
+
+// badRanges merges consecutive bad sampled positions into ranges bounded by the neighbouring intact positions.
+func badRanges(file int, total uint64, positions []uint64, bad map[uint64]bool) []PostBadRange {
+	var ranges []PostBadRange
+	from := uint64(0)
+	inRange := false
+	for _, pos := range positions {
+		if bad[pos] {
+			inRange = true
+			continue
+		}
+		if inRange {
+			ranges = append(ranges, PostBadRange{File: file, From: from, To: pos})
+			inRange = false
+		}
+		from = pos + 1
+	}
+	if inRange {
+		ranges = append(ranges, PostBadRange{File: file, From: from, To: total})
+	}
+	return ranges
+}
@@ -6,15 +6,18 @@ import (
 	"fmt"
 	"github.com/golang/protobuf/proto"
 	"github.com/spacemeshos/ed25519"
+	"github.com/spacemeshos/go-spacemesh/activation"
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/common/util"
 	config2 "github.com/spacemeshos/go-spacemesh/config"
+	"github.com/spacemeshos/go-spacemesh/hare"
 	"github.com/spacemeshos/go-spacemesh/mesh"
 	"github.com/spacemeshos/go-spacemesh/miner"
 	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
 	"github.com/spacemeshos/go-spacemesh/p2p/service"
 	"github.com/spacemeshos/go-spacemesh/priorityq"
 	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/spacemeshos/go-spacemesh/tortoise"
 	"github.com/stretchr/testify/require"
 	"io/ioutil"
 	"math/big"
@@ -26,6 +29,7 @@ import (
 
 	crand "crypto/rand"
 	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/ptypes/empty"
 	"github.com/spacemeshos/go-spacemesh/api/config"
 	"github.com/spacemeshos/go-spacemesh/api/pb"
 	"github.com/spacemeshos/go-spacemesh/p2p/node"
@@ -81,9 +85,16 @@ type TxAPIMock struct {
 	mockOrigin   types.Address
 	returnTx     map[types.TransactionID]*types.Transaction
 	layerApplied map[types.TransactionID]*types.LayerID
+	blocks       map[types.TransactionID][]types.BlockID
+	atxBlocks    map[types.ATXID][]types.BlockID
+	pruned       map[types.TransactionID]struct{}
 	err          error
 }
 
+func (t *TxAPIMock) GetAggregatedLayerHash(l types.LayerID) (types.Hash32, error) {
+	return types.CalcHash32(l.Bytes()), nil
+}
+
 func (t *TxAPIMock) GetStateRoot() types.Hash32 {
 	var hash types.Hash32
 	hash.SetBytes([]byte("00000"))
@@ -107,9 +118,20 @@ func (t *TxAPIMock) GetLayerApplied(txID types.TransactionID) *types.LayerID {
 }
 
 func (t *TxAPIMock) GetTransaction(id types.TransactionID) (*types.Transaction, error) {
+	if _, found := t.pruned[id]; found {
+		return nil, mesh.ErrPruned
+	}
 	return t.returnTx[id], nil
 }
 
+func (t *TxAPIMock) GetTransactionBlocks(id types.TransactionID) ([]types.BlockID, error) {
+	return t.blocks[id], nil
+}
+
+func (t *TxAPIMock) GetAtxBlocks(id types.ATXID) ([]types.BlockID, error) {
+	return t.atxBlocks[id], nil
+}
+
 func (t *TxAPIMock) LatestLayer() types.LayerID {
 	return 10
 }
@@ -168,6 +190,61 @@ func (*MiningAPIMock) StartPost(types.Address, string, uint64) error {
 
 func (*MiningAPIMock) SetCoinbaseAccount(types.Address) {}
 
+// SmeshersAPIMock is a mock for smeshers API
+type SmeshersAPIMock struct {
+	smeshers []SmesherInfo
+}
+
+func (m *SmeshersAPIMock) Smeshers() []SmesherInfo {
+	return m.smeshers
+}
+
+func (m *SmeshersAPIMock) AddSmesher(coinbase types.Address, datadir string, space uint64) (types.NodeID, error) {
+	id := fmt.Sprintf("smesher%d", len(m.smeshers))
+	m.smeshers = append(m.smeshers, SmesherInfo{ID: id, Coinbase: coinbase.String(), DataDir: datadir})
+	return types.NodeID{Key: id}, nil
+}
+
+func (m *SmeshersAPIMock) RemoveSmesher(id string) error {
+	for i, sm := range m.smeshers {
+		if sm.ID == id {
+			m.smeshers = append(m.smeshers[:i], m.smeshers[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown smesher %v", id)
+}
+
+// HareAPIMock is a mock for hare API
+type HareAPIMock struct {
+	err       error
+	instances []hare.InstanceStatus
+}
+
+func (m *HareAPIMock) Equivocations() ([]*hare.Equivocation, error) {
+	return nil, m.err
+}
+
+func (m *HareAPIMock) Instances() []hare.InstanceStatus {
+	return m.instances
+}
+
+// TortoiseAPIMock is a mock for tortoise API
+type TortoiseAPIMock struct {
+	blocks map[types.BlockID]*tortoise.BlockInfo
+}
+
+func (m *TortoiseAPIMock) BlockInfo(id types.BlockID) (*tortoise.BlockInfo, error) {
+	if info, found := m.blocks[id]; found {
+		return info, nil
+	}
+	return nil, errors.New("block not found")
+}
+
+func (m *TortoiseAPIMock) LayerInfo(l types.LayerID) (*tortoise.LayerInfo, error) {
+	return &tortoise.LayerInfo{Layer: l, Valid: 3, Invalid: 1, Verified: l < 5}, nil
+}
+
 type OracleMock struct{}
 
 func (*OracleMock) GetEligibleLayers() []types.LayerID {
@@ -193,6 +270,17 @@ func (PostMock) Reset() error {
 	return nil
 }
 
+func (PostMock) Verify(opts activation.PostVerifyOptions) (*activation.PostVerifyReport, error) {
+	return &activation.PostVerifyReport{
+		Files:         1,
+		CheckedGroups: opts.Samples,
+		BadGroups:     1,
+		BadRanges:     []activation.PostBadRange{{File: 0, From: 3, To: 5}},
+		DryRun:        opts.DryRun,
+		ProofErr:      fmt.Errorf("bad proof"),
+	}, nil
+}
+
 const (
 	genTimeUnix      = 1000000
 	layerDuration    = 10
@@ -204,12 +292,18 @@ var (
 	ap          = NewNodeAPIMock()
 	networkMock = NetworkMock{}
 	mining      = MiningAPIMock{}
+	smeshers    = SmeshersAPIMock{}
+	hareAPI     = HareAPIMock{}
+	trtlAPI     = TortoiseAPIMock{}
 	oracle      = OracleMock{}
 	genTime     = GenesisTimeMock{time.Unix(genTimeUnix, 0)}
 	txMempool   = miner.NewTxMemPool()
 	txAPI       = &TxAPIMock{
 		returnTx:     make(map[types.TransactionID]*types.Transaction),
 		layerApplied: make(map[types.TransactionID]*types.LayerID),
+		blocks:       make(map[types.TransactionID][]types.BlockID),
+		atxBlocks:    make(map[types.ATXID][]types.BlockID),
+		pruned:       make(map[types.TransactionID]struct{}),
 	}
 )
 
@@ -218,7 +312,7 @@ func TestServersConfig(t *testing.T) {
 	port2, err := node.GetUnboundedPort()
 	require.NoError(t, err, "Should be able to establish a connection on a port")
 
-	grpcService := NewGrpcService(port1, &networkMock, ap, txAPI, nil, &mining, &oracle, nil, PostMock{}, 0, nil, nil, nil)
+	grpcService := NewGrpcService(port1, &networkMock, ap, txAPI, nil, &mining, &oracle, nil, PostMock{}, 0, nil, nil, nil, nil, nil, nil)
 	require.Equal(t, grpcService.Port, uint(port1), "Expected same port")
 
 	jsonService := NewJSONHTTPServer(port2, port1)
@@ -345,6 +439,7 @@ func TestJsonWalletApi(t *testing.T) {
 	r.Equal(uint64(10), nodeStatus.SyncedLayer)
 	r.Equal(uint64(1), nodeStatus.CurrentLayer)
 	r.Equal(uint64(8), nodeStatus.VerifiedLayer)
+	r.Equal(util.Bytes2Hex(types.CalcHash32(types.LayerID(8).Bytes()).Bytes()), nodeStatus.AggregatedHash)
 
 	// test get genesisTime
 	respBody, respStatus = callEndpoint(t, "v1/genesis", "")
@@ -420,6 +515,19 @@ func TestJsonWalletApi(t *testing.T) {
 	respBody, respStatus = callEndpoint(t, "v1/resetpost", "")
 	r.Equal(http.StatusOK, respStatus)
 
+	// test verify post
+	respBody, respStatus = callEndpoint(t, "v1/verifypost", marshalProto(t, &pb.VerifyPostRequest{Samples: 10, DryRun: true}))
+	r.Equal(http.StatusOK, respStatus)
+	var report pb.PostVerifyReport
+	r.NoError(jsonpb.UnmarshalString(respBody, &report))
+	r.Equal(uint64(10), report.CheckedGroups)
+	r.Equal(uint64(1), report.BadGroups)
+	r.Len(report.BadRanges, 1)
+	r.Equal(uint64(3), report.BadRanges[0].From)
+	r.Equal(uint64(5), report.BadRanges[0].To)
+	r.True(report.DryRun)
+	r.Equal("bad proof", report.ProofError)
+
 	// test get getStateRoot
 	respBody, respStatus = callEndpoint(t, "v1/stateroot", "")
 	r.Equal(http.StatusOK, respStatus)
@@ -429,6 +537,152 @@ func TestJsonWalletApi(t *testing.T) {
 	shutDown()
 }
 
+func TestJsonSmeshersApi(t *testing.T) {
+	r := require.New(t)
+	shutDown := launchServer(t)
+	defer shutDown()
+
+	initPostRequest := pb.InitPost{Coinbase: "0x1234", LogicalDrive: "/tmp/bbb", CommitmentSize: 2048}
+	respBody, respStatus := callEndpoint(t, "v1/addsmesher", marshalProto(t, &initPostRequest))
+	r.Equal(http.StatusOK, respStatus)
+	assertSimpleMessage(t, respBody, "smesher0")
+
+	respBody, respStatus = callEndpoint(t, "v1/smeshers", "")
+	r.Equal(http.StatusOK, respStatus)
+	var res pb.Smeshers
+	r.NoError(jsonpb.UnmarshalString(respBody, &res))
+	r.Len(res.Smeshers, 1)
+	r.Equal("smesher0", res.Smeshers[0].Id)
+	r.Equal("/tmp/bbb", res.Smeshers[0].DataDir)
+
+	respBody, respStatus = callEndpoint(t, "v1/removesmesher", marshalProto(t, &pb.SmesherId{Id: "smesher0"}))
+	r.Equal(http.StatusOK, respStatus)
+	assertSimpleMessage(t, respBody, "ok")
+
+	_, respStatus = callEndpoint(t, "v1/removesmesher", marshalProto(t, &pb.SmesherId{Id: "smesher0"}))
+	r.Equal(http.StatusInternalServerError, respStatus)
+}
+
+func TestJsonApi_HareEquivocations(t *testing.T) {
+	r := require.New(t)
+	shutDown := launchServer(t)
+	defer shutDown()
+
+	respBody, respStatus := callEndpoint(t, "v1/hareequivocations", "")
+	r.Equal(http.StatusOK, respStatus)
+	var res pb.HareEquivocations
+	r.NoError(jsonpb.UnmarshalString(respBody, &res))
+	r.Empty(res.Equivocations)
+
+	hareAPI.err = errors.New("db failure")
+	defer func() { hareAPI.err = nil }()
+	_, respStatus = callEndpoint(t, "v1/hareequivocations", "")
+	r.Equal(http.StatusInternalServerError, respStatus)
+}
+
+func TestGrpcApi_HareInstances(t *testing.T) {
+	r := require.New(t)
+	started := time.Now().Add(-time.Minute)
+	hareAPI.instances = []hare.InstanceStatus{
+		{Layer: 5, NodeID: "abc", K: 6, Ki: 3, Set: []types.BlockID{{1}}, CommitMsgs: 4, Started: started, Eligibility: []hare.RoundEligibility{{K: 5, Eligible: true}}},
+		{Layer: 4, K: 9, Started: started, Ended: started.Add(time.Second), Terminated: true, Completed: true},
+	}
+	defer func() { hareAPI.instances = nil }()
+	shutDown := launchServer(t)
+	defer shutDown()
+
+	conn, err := grpc.Dial("localhost:"+strconv.Itoa(cfg.GrpcServerPort), grpc.WithInsecure())
+	r.NoError(err)
+	defer conn.Close()
+	c := pb.NewSpacemeshServiceClient(conn)
+
+	res, err := c.GetHareInstances(context.Background(), &empty.Empty{})
+	r.NoError(err)
+	r.Len(res.Instances, 2)
+	running := res.Instances[0]
+	r.Equal(uint64(5), running.Layer)
+	r.Equal("commit", running.RoundName)
+	r.Equal(int32(1), running.Iteration)
+	r.Equal(uint32(4), running.CommitMsgs)
+	r.Equal([]string{types.Hash20(types.BlockID{1}).Hex()}, running.Set)
+	r.Equal([]*pb.HareRoundEligibility{{Round: 5, Eligible: true}}, running.Eligibility)
+	r.False(running.Terminated)
+	terminated := res.Instances[1]
+	r.True(terminated.Terminated)
+	r.True(terminated.Completed)
+	r.Equal(int32(2), terminated.Iteration)
+	r.Equal(uint64(1000), terminated.DurationMs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := c.StreamHareInstances(ctx, &empty.Empty{})
+	r.NoError(err)
+	for i := 0; i < 2; i++ {
+		update, err := stream.Recv()
+		r.NoError(err)
+		r.Len(update.Instances, 2)
+	}
+
+	respBody, respStatus := callEndpoint(t, "v1/hareinstances", "")
+	r.Equal(http.StatusOK, respStatus)
+	var jsonRes pb.HareInstances
+	r.NoError(jsonpb.UnmarshalString(respBody, &jsonRes))
+	r.Len(jsonRes.Instances, 2)
+}
+
+func TestGrpcApi_Tortoise(t *testing.T) {
+	r := require.New(t)
+	blk := types.BlockID{7}
+	trtlAPI.blocks = map[types.BlockID]*tortoise.BlockInfo{
+		blk: {
+			ID:           blk,
+			Layer:        3,
+			Good:         &tortoise.Pattern{Layer: 3, ID: 12, Blocks: []types.BlockID{{8}}},
+			Support:      2,
+			Against:      9,
+			VotesAgainst: []types.BlockID{{9}},
+			Decided:      true,
+		},
+	}
+	defer func() { trtlAPI.blocks = nil }()
+	shutDown := launchServer(t)
+	defer shutDown()
+
+	conn, err := grpc.Dial("localhost:"+strconv.Itoa(cfg.GrpcServerPort), grpc.WithInsecure())
+	r.NoError(err)
+	defer conn.Close()
+	c := pb.NewSpacemeshServiceClient(conn)
+
+	res, err := c.GetTortoiseBlock(context.Background(), &pb.TortoiseBlockRequest{Id: types.Hash20(blk).Hex()})
+	r.NoError(err)
+	r.Equal(types.Hash20(blk).Hex(), res.Id)
+	r.Equal(uint64(3), res.Layer)
+	r.Nil(res.Effective)
+	r.Equal(&pb.TortoisePattern{Layer: 3, Id: 12, Blocks: []string{types.Hash20(types.BlockID{8}).Hex()}}, res.Good)
+	r.Equal(int64(2), res.Support)
+	r.Equal(int64(9), res.Against)
+	r.Empty(res.VotesFor)
+	r.Equal([]string{types.Hash20(types.BlockID{9}).Hex()}, res.VotesAgainst)
+	r.True(res.Decided)
+	r.False(res.Valid)
+
+	_, err = c.GetTortoiseBlock(context.Background(), &pb.TortoiseBlockRequest{Id: "0x1234"})
+	r.Error(err)
+	_, err = c.GetTortoiseBlock(context.Background(), &pb.TortoiseBlockRequest{Id: types.Hash20(types.BlockID{1}).Hex()})
+	r.Error(err)
+
+	layer, err := c.GetTortoiseLayer(context.Background(), &pb.TortoiseLayerRequest{Layer: 4})
+	r.NoError(err)
+	r.Equal(&pb.TortoiseLayer{Layer: 4, Valid: 3, Invalid: 1, Verified: true}, layer)
+
+	respBody, respStatus := callEndpoint(t, "v1/tortoise/layer", `{"layer": 7}`)
+	r.Equal(http.StatusOK, respStatus)
+	var jsonRes pb.TortoiseLayer
+	r.NoError(jsonpb.UnmarshalString(respBody, &jsonRes))
+	r.Equal(uint64(7), jsonRes.Layer)
+	r.False(jsonRes.Verified)
+}
+
 func asBytes(t *testing.T, tx *types.Transaction) []byte {
 	val, err := types.InterfaceToBytes(tx)
 	require.NoError(t, err)
@@ -451,6 +705,7 @@ func TestSpacemeshGrpcService_GetTransaction(t *testing.T) {
 	txAPI.returnTx[tx2.ID()] = tx2
 	layerApplied := types.LayerID(1)
 	txAPI.layerApplied[tx2.ID()] = &layerApplied
+	txAPI.blocks[tx2.ID()] = []types.BlockID{{1}, {2}}
 
 	tx3 := genTx(t)
 	txAPI.returnTx[tx3.ID()] = tx3
@@ -467,10 +722,41 @@ func TestSpacemeshGrpcService_GetTransaction(t *testing.T) {
 	assertTx(t, respTx1, tx1, "PENDING", 0, 0)
 	assertTx(t, respTx2, tx2, "CONFIRMED", 1, genTimeUnix+layerDuration*2)
 	assertTx(t, respTx3, tx3, "REJECTED", 0, 0)
+	require.Empty(t, respTx1.Blocks)
+	require.Equal(t, []string{types.Hash20(types.BlockID{1}).Hex(), types.Hash20(types.BlockID{2}).Hex()}, respTx2.Blocks)
+
+	// the body of a transaction removed by the retention policy is reported as pruned
+	tx4 := genTx(t)
+	txAPI.pruned[tx4.ID()] = struct{}{}
+	respBody, respStatus := callEndpoint(t, "v1/gettransaction", marshalProto(t, &pb.TransactionId{Id: tx4.ID().Bytes()}))
+	require.Equal(t, http.StatusInternalServerError, respStatus)
+	require.Contains(t, respBody, "transaction pruned")
 
 	shutDown()
 }
 
+func TestSpacemeshGrpcService_GetAtxBlocks(t *testing.T) {
+	r := require.New(t)
+	shutDown := launchServer(t)
+	defer shutDown()
+
+	atx1, atx2 := types.ATXID(types.Hash32{1}), types.ATXID(types.Hash32{2})
+	txAPI.atxBlocks[atx1] = []types.BlockID{{1}, {2}}
+
+	respBody, respStatus := callEndpoint(t, "v1/atxblocks", marshalProto(t, &pb.AtxId{Id: atx1.Bytes()}))
+	r.Equal(http.StatusOK, respStatus)
+	var resp pb.AtxBlocks
+	r.NoError(jsonpb.UnmarshalString(respBody, &resp))
+	r.Equal(atx1.Bytes(), resp.AtxId.Id)
+	r.Equal([]string{types.Hash20(types.BlockID{1}).Hex(), types.Hash20(types.BlockID{2}).Hex()}, resp.Blocks)
+
+	respBody, respStatus = callEndpoint(t, "v1/atxblocks", marshalProto(t, &pb.AtxId{Id: atx2.Bytes()}))
+	r.Equal(http.StatusOK, respStatus)
+	resp = pb.AtxBlocks{}
+	r.NoError(jsonpb.UnmarshalString(respBody, &resp))
+	r.Empty(resp.Blocks)
+}
+
 func getTx(t *testing.T, tx *types.Transaction) pb.Transaction {
 	r := require.New(t)
 	idToSend := pb.TransactionId{Id: tx.ID().Bytes()}
@@ -525,7 +811,7 @@ func (SyncerMock) IsSynced() bool { return false }
 func launchServer(t *testing.T) func() {
 	networkMock.broadcasted = []byte{0x00}
 	defaultConfig := config2.DefaultConfig()
-	grpcService := NewGrpcService(cfg.GrpcServerPort, &networkMock, ap, txAPI, txMempool, &mining, &oracle, &genTime, PostMock{}, layerDuration, &SyncerMock{}, &defaultConfig, nil)
+	grpcService := NewGrpcService(cfg.GrpcServerPort, &networkMock, ap, txAPI, txMempool, &mining, &oracle, &genTime, PostMock{}, layerDuration, &SyncerMock{}, &defaultConfig, nil, &smeshers, &hareAPI, &trtlAPI)
 	jsonService := NewJSONHTTPServer(cfg.JSONServerPort, cfg.GrpcServerPort)
 	// start gRPC and json server
 	grpcService.StartService()
@@ -647,6 +933,10 @@ func (m *mockMsg) ReportValidation(protocol string) {
 	m.c <- service.NewMessageValidation(m.sender, m.msg, protocol)
 }
 
+func (m *mockMsg) ReportInvalid(protocol string) {
+	m.c <- service.NewInvalidMessageValidation(m.sender, m.msg, protocol)
+}
+
 func TestApproveAPIGossipMessages(t *testing.T) {
 	m := &mockSrv{c: make(chan service.GossipMessage, 1)}
 	ctx, cancel := context.WithCancel(context.Background())
@@ -3,6 +3,7 @@ package api
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
@@ -19,9 +20,12 @@ import (
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/common/util"
 	"github.com/spacemeshos/go-spacemesh/config"
+	"github.com/spacemeshos/go-spacemesh/hare"
 	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
 	"github.com/spacemeshos/go-spacemesh/miner"
 	"github.com/spacemeshos/go-spacemesh/p2p/peers"
+	"github.com/spacemeshos/go-spacemesh/tortoise"
 )
 
 // PeerCounter is an api to get amount of connected peers
@@ -46,12 +50,18 @@ type SpacemeshGrpcService struct {
 	Syncer        Syncer
 	Config        *config.Config
 	Logging       LoggingAPI
+	Smeshers      SmeshersAPI
+	Hare          HareAPI
+	Tortoise      TortoiseAPI
 }
 
 var _ pb.SpacemeshServiceServer = (*SpacemeshGrpcService)(nil)
 
 func (s SpacemeshGrpcService) getTransactionAndStatus(txID types.TransactionID) (*types.Transaction, *types.LayerID, pb.TxStatus, error) {
 	tx, err := s.Tx.GetTransaction(txID) // have we seen this transaction in a block?
+	if err == mesh.ErrPruned {
+		return nil, nil, 0, fmt.Errorf("transaction pruned, id: %s", util.Bytes2Hex(txID.Bytes()))
+	}
 	if err != nil {
 		tx, err = s.TxMempool.Get(txID) // do we have it in the mempool?
 		if err != nil {                 // we don't know this transaction
@@ -92,6 +102,11 @@ func (s SpacemeshGrpcService) GetTransaction(ctx context.Context, txID *pb.Trans
 		// We use layerID + 1 so the timestamp is the end of the layer.
 	}
 
+	ids, err := s.Tx.GetTransactionBlocks(id)
+	if err != nil {
+		return nil, fmt.Errorf("could not get blocks of transaction %s: %v", util.Bytes2Hex(id.Bytes()), err)
+	}
+
 	return &pb.Transaction{
 		TxId: txID,
 		Sender: &pb.AccountId{
@@ -105,9 +120,23 @@ func (s SpacemeshGrpcService) GetTransaction(ctx context.Context, txID *pb.Trans
 		Status:    status,
 		LayerId:   layerID,
 		Timestamp: timestamp,
+		Blocks:    blockIDsHex(ids),
 	}, nil
 }
 
+// GetAtxBlocks returns the ids of the blocks that reference an atx, the blocks of the pruned layers included
+func (s SpacemeshGrpcService) GetAtxBlocks(ctx context.Context, in *pb.AtxId) (*pb.AtxBlocks, error) {
+	log.Info("GRPC GetAtxBlocks msg")
+	id := types.ATXID{}
+	copy(id[:], in.Id)
+
+	ids, err := s.Tx.GetAtxBlocks(id)
+	if err != nil {
+		return nil, fmt.Errorf("could not get blocks of atx %s: %v", util.Bytes2Hex(id.Bytes()), err)
+	}
+	return &pb.AtxBlocks{AtxId: in, Blocks: blockIDsHex(ids)}, nil
+}
+
 // Echo returns the response for an echo api request
 func (s SpacemeshGrpcService) Echo(ctx context.Context, in *pb.SimpleMessage) (*pb.SimpleMessage, error) {
 	return &pb.SimpleMessage{Value: in.Value}, nil
@@ -245,13 +274,16 @@ type TxAPI interface {
 	LatestLayer() types.LayerID
 	GetLayerApplied(txID types.TransactionID) *types.LayerID
 	GetTransaction(id types.TransactionID) (*types.Transaction, error)
+	GetTransactionBlocks(id types.TransactionID) ([]types.BlockID, error)
+	GetAtxBlocks(id types.ATXID) ([]types.BlockID, error)
 	GetProjection(addr types.Address, prevNonce, prevBalance uint64) (nonce, balance uint64, err error)
 	LatestLayerInState() types.LayerID
 	GetStateRoot() types.Hash32
+	GetAggregatedLayerHash(l types.LayerID) (types.Hash32, error)
 }
 
 // NewGrpcService create a new grpc service using config data.
-func NewGrpcService(port int, net NetworkAPI, state StateAPI, tx TxAPI, txMempool *miner.TxMempool, mining MiningAPI, oracle OracleAPI, genTime GenesisTimeAPI, post PostAPI, layerDurationSec int, syncer Syncer, cfg *config.Config, logging LoggingAPI) *SpacemeshGrpcService {
+func NewGrpcService(port int, net NetworkAPI, state StateAPI, tx TxAPI, txMempool *miner.TxMempool, mining MiningAPI, oracle OracleAPI, genTime GenesisTimeAPI, post PostAPI, layerDurationSec int, syncer Syncer, cfg *config.Config, logging LoggingAPI, smeshers SmeshersAPI, hareAPI HareAPI, trtl TortoiseAPI) *SpacemeshGrpcService {
 	options := []grpc.ServerOption{
 		// XXX: this is done to prevent routers from cleaning up our connections (e.g aws load balances..)
 		// TODO: these parameters work for now but we might need to revisit or add them as configuration
@@ -281,6 +313,9 @@ func NewGrpcService(port int, net NetworkAPI, state StateAPI, tx TxAPI, txMempoo
 		Syncer:        syncer,
 		Config:        cfg,
 		Logging:       logging,
+		Smeshers:      smeshers,
+		Hare:          hareAPI,
+		Tortoise:      trtl,
 	}
 }
 
@@ -348,17 +383,236 @@ func (s SpacemeshGrpcService) GetMiningStats(ctx context.Context, empty *empty.E
 	}, nil
 }
 
+// GetSmeshers returns the smesher identities managed by this node with their post creation status
+func (s SpacemeshGrpcService) GetSmeshers(ctx context.Context, empty *empty.Empty) (*pb.Smeshers, error) {
+	log.Info("GRPC GetSmeshers msg")
+	smeshers := s.Smeshers.Smeshers()
+	res := &pb.Smeshers{Smeshers: make([]*pb.SmesherInfo, 0, len(smeshers))}
+	for _, sm := range smeshers {
+		res.Smeshers = append(res.Smeshers, &pb.SmesherInfo{
+			Id:             sm.ID,
+			Coinbase:       sm.Coinbase,
+			DataDir:        sm.DataDir,
+			Status:         int32(sm.PostStatus),
+			RemainingBytes: sm.RemainingBytes,
+		})
+	}
+	return res, nil
+}
+
+// AddSmesher creates a new smesher identity with its own post data and coinbase, and returns its id
+func (s SpacemeshGrpcService) AddSmesher(ctx context.Context, message *pb.InitPost) (*pb.SimpleMessage, error) {
+	log.Info("GRPC AddSmesher msg")
+	addr, err := types.StringToAddress(message.Coinbase)
+	if err != nil {
+		return nil, err
+	}
+	id, err := s.Smeshers.AddSmesher(addr, message.LogicalDrive, message.CommitmentSize)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.SimpleMessage{Value: id.Key}, nil
+}
+
+// RemoveSmesher stops a smesher identity that was added to this node
+func (s SpacemeshGrpcService) RemoveSmesher(ctx context.Context, id *pb.SmesherId) (*pb.SimpleMessage, error) {
+	log.Info("GRPC RemoveSmesher msg")
+	if err := s.Smeshers.RemoveSmesher(id.Id); err != nil {
+		return nil, err
+	}
+	return &pb.SimpleMessage{Value: "ok"}, nil
+}
+
+var errNoHare = errors.New("hare is not available")
+
+// hareInstancesInterval is the interval between the updates sent by StreamHareInstances
+const hareInstancesInterval = time.Second
+
+func hareInstances(statuses []hare.InstanceStatus) *pb.HareInstances {
+	res := &pb.HareInstances{}
+	for _, st := range statuses {
+		inst := &pb.HareInstance{
+			Layer:               st.Layer.Uint64(),
+			NodeId:              st.NodeID,
+			Round:               st.K,
+			RoundName:           st.Round(),
+			Iteration:           st.Iteration(),
+			Ki:                  st.Ki,
+			PreRoundMsgs:        uint32(st.PreRoundMsgs),
+			StatusMsgs:          uint32(st.StatusMsgs),
+			ProposalMsgs:        uint32(st.ProposalMsgs),
+			CommitMsgs:          uint32(st.CommitMsgs),
+			NotifyMsgs:          uint32(st.NotifyMsgs),
+			PendingMsgs:         uint32(st.PendingMsgs),
+			ConflictingProposal: st.ConflictingProposal,
+			Terminated:          st.Terminated,
+			Completed:           st.Completed,
+			StartTime:           uint64(st.Started.Unix()),
+			DurationMs:          uint64(st.Duration() / time.Millisecond),
+		}
+		for _, id := range st.Set {
+			inst.Set = append(inst.Set, types.Hash20(id).Hex())
+		}
+		for _, e := range st.Eligibility {
+			inst.Eligibility = append(inst.Eligibility, &pb.HareRoundEligibility{Round: e.K, Eligible: e.Eligible})
+		}
+		res.Instances = append(res.Instances, inst)
+	}
+	return res
+}
+
+// GetHareInstances returns the status of the hare consensus processes of the recent layers
+func (s SpacemeshGrpcService) GetHareInstances(context.Context, *empty.Empty) (*pb.HareInstances, error) {
+	log.Info("GRPC GetHareInstances msg")
+	if s.Hare == nil {
+		return nil, errNoHare
+	}
+	return hareInstances(s.Hare.Instances()), nil
+}
+
+// StreamHareInstances sends the status of the hare consensus processes of the recent layers every second until the
+// client disconnects
+func (s SpacemeshGrpcService) StreamHareInstances(_ *empty.Empty, stream pb.SpacemeshService_StreamHareInstancesServer) error {
+	log.Info("GRPC StreamHareInstances msg")
+	if s.Hare == nil {
+		return errNoHare
+	}
+	ticker := time.NewTicker(hareInstancesInterval)
+	defer ticker.Stop()
+	for {
+		if err := stream.Send(hareInstances(s.Hare.Instances())); err != nil {
+			return err
+		}
+		select {
+		case <-ticker.C:
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// GetHareEquivocations returns the evidence of the hare equivocations detected by the node
+func (s SpacemeshGrpcService) GetHareEquivocations(context.Context, *empty.Empty) (*pb.HareEquivocations, error) {
+	log.Info("GRPC GetHareEquivocations msg")
+	if s.Hare == nil {
+		return nil, errNoHare
+	}
+	all, err := s.Hare.Equivocations()
+	if err != nil {
+		return nil, err
+	}
+	res := &pb.HareEquivocations{}
+	for _, e := range all {
+		pub, err := e.Validate()
+		if err != nil {
+			return nil, err
+		}
+		first, err := types.InterfaceToBytes(e.First)
+		if err != nil {
+			return nil, err
+		}
+		second, err := types.InterfaceToBytes(e.Second)
+		if err != nil {
+			return nil, err
+		}
+		res.Equivocations = append(res.Equivocations, &pb.HareEquivocation{
+			NodeId: pub.String(),
+			Layer:  e.Layer().Uint64(),
+			Round:  e.Round(),
+			First:  first,
+			Second: second,
+		})
+	}
+	return res, nil
+}
+
+var errNoTortoise = errors.New("tortoise is not available")
+
+func tortoisePattern(p *tortoise.Pattern) *pb.TortoisePattern {
+	if p == nil {
+		return nil
+	}
+	res := &pb.TortoisePattern{Layer: p.Layer.Uint64(), Id: p.ID}
+	for _, id := range p.Blocks {
+		res.Blocks = append(res.Blocks, types.Hash20(id).Hex())
+	}
+	return res
+}
+
+func blockIDsHex(ids []types.BlockID) []string {
+	res := make([]string, 0, len(ids))
+	for _, id := range ids {
+		res = append(res, types.Hash20(id).Hex())
+	}
+	return res
+}
+
+// GetTortoiseBlock returns the votes the tortoise counted for a block and its opinion on the block
+func (s SpacemeshGrpcService) GetTortoiseBlock(ctx context.Context, in *pb.TortoiseBlockRequest) (*pb.TortoiseBlock, error) {
+	log.Info("GRPC GetTortoiseBlock msg")
+	if s.Tortoise == nil {
+		return nil, errNoTortoise
+	}
+	var id types.BlockID
+	if b := util.FromHex(in.Id); len(b) == len(id) {
+		copy(id[:], b)
+	} else {
+		return nil, fmt.Errorf("invalid block id %q", in.Id)
+	}
+	info, err := s.Tortoise.BlockInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.TortoiseBlock{
+		Id:           types.Hash20(info.ID).Hex(),
+		Layer:        info.Layer.Uint64(),
+		Effective:    tortoisePattern(info.Effective),
+		Good:         tortoisePattern(info.Good),
+		Support:      int64(info.Support),
+		Against:      int64(info.Against),
+		VotesFor:     blockIDsHex(info.VotesFor),
+		VotesAgainst: blockIDsHex(info.VotesAgainst),
+		Decided:      info.Decided,
+		Valid:        info.Valid,
+	}, nil
+}
+
+// GetTortoiseLayer returns the number of valid and invalid blocks of a layer and whether the layer is verified
+func (s SpacemeshGrpcService) GetTortoiseLayer(ctx context.Context, in *pb.TortoiseLayerRequest) (*pb.TortoiseLayer, error) {
+	log.Info("GRPC GetTortoiseLayer msg")
+	if s.Tortoise == nil {
+		return nil, errNoTortoise
+	}
+	info, err := s.Tortoise.LayerInfo(types.LayerID(in.Layer))
+	if err != nil {
+		return nil, err
+	}
+	return &pb.TortoiseLayer{
+		Layer:     info.Layer.Uint64(),
+		Valid:     uint32(info.Valid),
+		Invalid:   uint32(info.Invalid),
+		Undecided: uint32(info.Undecided),
+		Verified:  info.Verified,
+	}, nil
+}
+
 // GetNodeStatus returns a status object providing information about the connected peers, sync status,
 // current and verified layer
 func (s SpacemeshGrpcService) GetNodeStatus(context.Context, *empty.Empty) (*pb.NodeStatus, error) {
+	verified := s.Tx.LatestLayerInState()
+	var aggregatedHash string
+	if h, err := s.Tx.GetAggregatedLayerHash(verified); err == nil {
+		aggregatedHash = util.Bytes2Hex(h.Bytes())
+	}
 	return &pb.NodeStatus{
-		Peers:         s.PeerCounter.PeerCount(),
-		MinPeers:      uint64(s.Config.P2P.SwarmConfig.RandomConnections),
-		MaxPeers:      uint64(s.Config.P2P.MaxInboundPeers + s.Config.P2P.SwarmConfig.RandomConnections),
-		Synced:        s.Syncer.IsSynced(),
-		SyncedLayer:   s.Tx.LatestLayer().Uint64(),
-		CurrentLayer:  s.GenTime.GetCurrentLayer().Uint64(),
-		VerifiedLayer: s.Tx.LatestLayerInState().Uint64(),
+		Peers:          s.PeerCounter.PeerCount(),
+		MinPeers:       uint64(s.Config.P2P.SwarmConfig.RandomConnections),
+		MaxPeers:       uint64(s.Config.P2P.MaxInboundPeers + s.Config.P2P.SwarmConfig.RandomConnections),
+		Synced:         s.Syncer.IsSynced(),
+		SyncedLayer:    s.Tx.LatestLayer().Uint64(),
+		CurrentLayer:   s.GenTime.GetCurrentLayer().Uint64(),
+		VerifiedLayer:  verified.Uint64(),
+		AggregatedHash: aggregatedHash,
 	}, nil
 }
 
@@ -393,6 +647,35 @@ func (s SpacemeshGrpcService) ResetPost(ctx context.Context, empty *empty.Empty)
 	return &pb.SimpleMessage{Value: "ok"}, nil
 }
 
+// VerifyPost checks the integrity of the post data of this miner
+func (s SpacemeshGrpcService) VerifyPost(ctx context.Context, in *pb.VerifyPostRequest) (*pb.PostVerifyReport, error) {
+	log.Info("GRPC VerifyPost msg")
+	stat, _, _, _ := s.Mining.MiningStats()
+	if stat == activation.InitInProgress {
+		return nil, fmt.Errorf("cannot verify, init in progress")
+	}
+	report, err := s.Post.Verify(activation.PostVerifyOptions{Samples: in.Samples, Repair: in.Repair, DryRun: in.DryRun})
+	if err != nil {
+		return nil, err
+	}
+	res := &pb.PostVerifyReport{
+		Files:         uint32(report.Files),
+		CheckedGroups: report.CheckedGroups,
+		BadGroups:     report.BadGroups,
+		DryRun:        report.DryRun,
+	}
+	for _, br := range report.BadRanges {
+		res.BadRanges = append(res.BadRanges, &pb.PostBadRange{File: uint32(br.File), From: br.From, To: br.To})
+	}
+	for _, f := range report.Repaired {
+		res.Repaired = append(res.Repaired, uint32(f))
+	}
+	if report.ProofErr != nil {
+		res.ProofError = report.ProofErr.Error()
+	}
+	return res, nil
+}
+
 // SetLoggerLevel sets logger level for specific logger
 func (s SpacemeshGrpcService) SetLoggerLevel(ctx context.Context, msg *pb.SetLogLevel) (*pb.SimpleMessage, error) {
 	log.Info("GRPC SetLogLevel msg")
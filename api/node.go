@@ -1,10 +1,13 @@
 package api
 
 import (
+	"github.com/spacemeshos/go-spacemesh/activation"
 	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/hare"
 	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
 	"github.com/spacemeshos/go-spacemesh/p2p/service"
 	"github.com/spacemeshos/go-spacemesh/priorityq"
+	"github.com/spacemeshos/go-spacemesh/tortoise"
 	"time"
 )
 
@@ -55,4 +58,33 @@ type LoggingAPI interface {
 // PostAPI is an API for post init module
 type PostAPI interface {
 	Reset() error
+	Verify(opts activation.PostVerifyOptions) (*activation.PostVerifyReport, error)
+}
+
+// SmesherInfo describes a smesher identity managed by the node
+type SmesherInfo struct {
+	ID             string
+	Coinbase       string
+	DataDir        string
+	PostStatus     int
+	RemainingBytes uint64
+}
+
+// SmeshersAPI is an API for listing and managing the smesher identities of the node
+type SmeshersAPI interface {
+	Smeshers() []SmesherInfo
+	AddSmesher(coinbase types.Address, datadir string, space uint64) (types.NodeID, error)
+	RemoveSmesher(id string) error
+}
+
+// HareAPI is an API for the state of the hare consensus processes and the evidence they collected
+type HareAPI interface {
+	Equivocations() ([]*hare.Equivocation, error)
+	Instances() []hare.InstanceStatus
+}
+
+// TortoiseAPI is an API for inspecting the opinion of the tortoise on blocks and layers
+type TortoiseAPI interface {
+	BlockInfo(id types.BlockID) (*tortoise.BlockInfo, error)
+	LayerInfo(l types.LayerID) (*tortoise.LayerInfo, error)
 }
@@ -0,0 +1,109 @@
+package node
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/spacemeshos/go-spacemesh/activation"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	atxExportNodeID string
+	atxExportFormat string
+	atxExportOutput string
+)
+
+// AtxCmd groups the atx database commands
+var AtxCmd = &cobra.Command{
+	Use:   "atx",
+	Short: "inspect the atx database",
+}
+
+var atxExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export the atx history of an identity as json or csv, the node must not be running",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conf := keysConfig(cmd)
+		if atxExportFormat != "json" && atxExportFormat != "csv" {
+			return fmt.Errorf("unknown format %v, use json or csv", atxExportFormat)
+		}
+		nodeID := atxExportNodeID
+		if nodeID == "" {
+			// the identity file is kept in the PoST init dir, which is named after the public key
+			f, err := findIdentityFile(conf.POST.DataDir)
+			if err != nil {
+				return fmt.Errorf("no node-id provided and no identity found: %v", err)
+			}
+			nodeID = filepath.Base(filepath.Dir(f))
+		}
+
+		store, err := database.NewLDBDatabase(filepath.Join(conf.DataDir(), "atx"), 0, 0, log.NewDefault(AtxDbStoreLogger))
+		if err != nil {
+			return fmt.Errorf("failed to open atx database: %v", err)
+		}
+		defer store.Close()
+		atxdb := activation.NewDB(store, nil, nil, uint16(conf.LayersPerEpoch), nil, log.NewDefault(AtxDbLogger))
+		history, err := atxdb.AtxHistory(types.NodeID{Key: nodeID})
+		if err != nil {
+			return err
+		}
+
+		out := os.Stdout
+		if atxExportOutput != "" {
+			out, err = os.Create(atxExportOutput)
+			if err != nil {
+				return err
+			}
+			defer out.Close()
+		}
+		if atxExportFormat == "csv" {
+			return writeAtxHistoryCSV(out, history)
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(history)
+	},
+}
+
+func init() {
+	atxExportCmd.Flags().StringVar(&atxExportNodeID, "node-id", "", "hex encoded public key of the identity, defaults to the identity under the PoST data dir")
+	atxExportCmd.Flags().StringVar(&atxExportFormat, "format", "json", "output format, json or csv")
+	atxExportCmd.Flags().StringVar(&atxExportOutput, "output", "", "output file, defaults to stdout")
+	AtxCmd.AddCommand(atxExportCmd)
+	Cmd.AddCommand(AtxCmd)
+}
+
+func writeAtxHistoryCSV(w io.Writer, history []activation.AtxHistoryEntry) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"id", "sequence", "pub_layer", "target_epoch", "positioning_atx", "prev_atx", "active_set_size", "coinbase", "body_pruned"})
+	if err != nil {
+		return err
+	}
+	for _, h := range history {
+		err := cw.Write([]string{
+			h.ID,
+			strconv.FormatUint(h.Sequence, 10),
+			strconv.FormatUint(h.PubLayer, 10),
+			strconv.FormatUint(h.TargetEpoch, 10),
+			h.PositioningATX,
+			h.PrevATX,
+			strconv.FormatUint(uint64(h.ActiveSetSize), 10),
+			h.Coinbase,
+			strconv.FormatBool(h.BodyPruned),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,126 @@
+package node
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/hare"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"github.com/spacemeshos/go-spacemesh/turbohare"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	hareReplayVerbose    bool
+	hareCaptureOutput    string
+	hareCaptureFromLayer uint64
+	hareCaptureToLayer   uint64
+)
+
+// HareCmd groups the hare debugging commands
+var HareCmd = &cobra.Command{
+	Use:   "hare",
+	Short: "debug the hare protocol",
+}
+
+var hareReplayCmd = &cobra.Command{
+	Use:   "replay [recording file]",
+	Short: "replay a hare recording of a layer and print the trackers state at the end of every round",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conf := keysConfig(cmd)
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		records, err := hare.ReadRecording(f)
+		if err != nil {
+			return err
+		}
+
+		lvl := zap.NewAtomicLevelAt(zapcore.ErrorLevel)
+		if hareReplayVerbose {
+			lvl.SetLevel(zapcore.DebugLevel)
+		}
+		lg := log.NewDefault(HareLogger).SetLevel(&lvl)
+		_, err = hare.Replay(conf.HARE, uint16(conf.LayersPerEpoch), records, os.Stdout, lg)
+		return err
+	},
+}
+
+var hareCaptureCmd = &cobra.Command{
+	Use:   "capture-outputs",
+	Short: "capture the hare outputs of a run to replay with --hare-recorded-outputs, the node must not be running",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conf := keysConfig(cmd)
+		hareStore, err := database.NewLDBDatabase(filepath.Join(conf.DataDir(), "hare"), 0, 0, log.NewDefault(HareLogger))
+		if err != nil {
+			return fmt.Errorf("failed to open hare database: %v", err)
+		}
+		defer hareStore.Close()
+		mdb, err := mesh.NewPersistentMeshDB(filepath.Join(conf.DataDir(), "mesh"), conf.BlockCacheSize, log.NewDefault(MeshDBLogger))
+		if err != nil {
+			return fmt.Errorf("failed to open mesh database: %v", err)
+		}
+		defer mdb.Close()
+
+		stored, err := hare.StoredOutputs(hareStore)
+		if err != nil {
+			return err
+		}
+		outputs := captureOutputs(stored, mdb, types.LayerID(hareCaptureFromLayer), types.LayerID(hareCaptureToLayer))
+
+		var out io.Writer = os.Stdout
+		if hareCaptureOutput != "" {
+			f, err := os.Create(hareCaptureOutput)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		return turbohare.WriteOutputs(out, outputs)
+	},
+}
+
+type layerBlocksProvider interface {
+	LayerBlockIds(layerID types.LayerID) ([]types.BlockID, error)
+}
+
+// captureOutputs converts the stored outputs of the layers in [from, to] to the recorded hare format, a zero to means
+// no upper bound. The number of blocks seen in a layer is taken from the mesh, or from the output if the layer is missing.
+func captureOutputs(stored []hare.StoredOutput, blocks layerBlocksProvider, from, to types.LayerID) []turbohare.LayerOutput {
+	outputs := make([]turbohare.LayerOutput, 0, len(stored))
+	for _, s := range stored {
+		if s.Layer < from || (to != 0 && s.Layer > to) {
+			continue
+		}
+		out := turbohare.LayerOutput{Layer: s.Layer, Seen: len(s.Blocks), Blocks: make([]types.Hash20, 0, len(s.Blocks))}
+		for _, b := range s.Blocks {
+			out.Blocks = append(out.Blocks, types.Hash20(b))
+		}
+		if ids, err := blocks.LayerBlockIds(s.Layer); err == nil && len(ids) > out.Seen {
+			out.Seen = len(ids)
+		}
+		outputs = append(outputs, out)
+	}
+	return outputs
+}
+
+func init() {
+	hareReplayCmd.Flags().BoolVar(&hareReplayVerbose, "verbose", false, "also print the consensus process log")
+	HareCmd.AddCommand(hareReplayCmd)
+	hareCaptureCmd.Flags().StringVar(&hareCaptureOutput, "output", "", "output file, defaults to stdout")
+	hareCaptureCmd.Flags().Uint64Var(&hareCaptureFromLayer, "from-layer", 0, "first layer to capture")
+	hareCaptureCmd.Flags().Uint64Var(&hareCaptureToLayer, "to-layer", 0, "last layer to capture, defaults to the latest")
+	HareCmd.AddCommand(hareCaptureCmd)
+	Cmd.AddCommand(HareCmd)
+}
@@ -0,0 +1,263 @@
+package node
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cmdp "github.com/spacemeshos/go-spacemesh/cmd"
+	cfg "github.com/spacemeshos/go-spacemesh/config"
+	"github.com/spacemeshos/go-spacemesh/crypto"
+	"github.com/spacemeshos/go-spacemesh/filesystem"
+	"github.com/spacemeshos/go-spacemesh/keystore"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/spacemeshos/post/shared"
+	"github.com/spf13/cobra"
+)
+
+var newPassphraseFile string
+
+// identityKDParams are the key derivation params used when encrypting identity files
+var identityKDParams = crypto.DefaultCypherParams
+
+// KeysCmd groups the identity keystore management commands. The VRF key is derived from the ed identity key (see
+// Start), so protecting the ed key file protects both keys.
+var KeysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "manage the node identity keystore",
+}
+
+var keysCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "create a new encrypted identity under the PoST data dir",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conf := keysConfig(cmd)
+		if f, err := findIdentityFile(conf.POST.DataDir); err == nil {
+			return fmt.Errorf("identity already exists at %v", f)
+		}
+		passphrase, err := requirePassphrase(conf.KeystorePassphraseFile)
+		if err != nil {
+			return err
+		}
+		edSgn := signing.NewEdSigner()
+		f := filepath.Join(shared.GetInitDir(conf.POST.DataDir, edSgn.PublicKey().Bytes()), keystore.KeyFileName)
+		if err := writeEncryptedIdentity(f, edSgn, passphrase, identityKDParams); err != nil {
+			return err
+		}
+		fmt.Printf("created identity %v at %v\n", edSgn.PublicKey(), f)
+		return nil
+	},
+}
+
+var keysImportCmd = &cobra.Command{
+	Use:   "import <hex private key file>",
+	Short: "import a hex encoded ed25519 private key and store it encrypted under the PoST data dir",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conf := keysConfig(cmd)
+		if f, err := findIdentityFile(conf.POST.DataDir); err == nil {
+			return fmt.Errorf("identity already exists at %v", f)
+		}
+		data, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read private key: %v", err)
+		}
+		buff, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return fmt.Errorf("failed to decode private key: %v", err)
+		}
+		edSgn, err := signing.NewEdSignerFromBuffer(buff)
+		if err != nil {
+			return err
+		}
+		passphrase, err := requirePassphrase(conf.KeystorePassphraseFile)
+		if err != nil {
+			return err
+		}
+		f := filepath.Join(shared.GetInitDir(conf.POST.DataDir, edSgn.PublicKey().Bytes()), keystore.KeyFileName)
+		if err := writeEncryptedIdentity(f, edSgn, passphrase, identityKDParams); err != nil {
+			return err
+		}
+		fmt.Printf("imported identity %v to %v\n", edSgn.PublicKey(), f)
+		return nil
+	},
+}
+
+var keysExportCmd = &cobra.Command{
+	Use:   "export <output file>",
+	Short: "export the identity private key, hex encoded and unencrypted",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conf := keysConfig(cmd)
+		f, err := findIdentityFile(conf.POST.DataDir)
+		if err != nil {
+			return err
+		}
+		edSgn, err := loadIdentity(f, conf.KeystorePassphraseFile)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(args[0], []byte(hex.EncodeToString(edSgn.ToBuffer())), filesystem.OwnerReadWrite)
+		if err != nil {
+			return fmt.Errorf("failed to write private key: %v", err)
+		}
+		fmt.Printf("exported identity %v to %v\n", edSgn.PublicKey(), args[0])
+		return nil
+	},
+}
+
+var keysPasswdCmd = &cobra.Command{
+	Use:   "passwd",
+	Short: "change the identity passphrase, encrypting a plain identity file if needed",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conf := keysConfig(cmd)
+		f, err := findIdentityFile(conf.POST.DataDir)
+		if err != nil {
+			return err
+		}
+		if newPassphraseFile == "" {
+			return fmt.Errorf("new-passphrase-file must be provided")
+		}
+		passphrase, _, err := keystore.LoadPassphrase(newPassphraseFile)
+		if err != nil {
+			return err
+		}
+		if filepath.Base(f) == keystore.KeyFileName {
+			if err := changeIdentityPassphrase(f, conf.KeystorePassphraseFile, passphrase); err != nil {
+				return err
+			}
+			fmt.Printf("changed passphrase of identity file %v\n", f)
+			return nil
+		}
+
+		// the identity is stored unencrypted, encrypt it and remove the plain copy
+		edSgn, err := readPlainIdentity(f)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(filepath.Dir(f), keystore.KeyFileName)
+		if err := writeEncryptedIdentity(target, edSgn, passphrase, identityKDParams); err != nil {
+			return err
+		}
+		if err := os.Remove(f); err != nil {
+			return fmt.Errorf("failed to remove plain identity file: %v", err)
+		}
+		fmt.Printf("encrypted identity %v to %v\n", edSgn.PublicKey(), target)
+		return nil
+	},
+}
+
+func init() {
+	keysPasswdCmd.Flags().StringVar(&newPassphraseFile, "new-passphrase-file", "", "file holding the new passphrase")
+	KeysCmd.AddCommand(keysCreateCmd, keysImportCmd, keysExportCmd, keysPasswdCmd)
+	Cmd.AddCommand(KeysCmd)
+}
+
+// keysConfig returns the node config for the keys commands, so that they use the same data dir and passphrase as the node
+func keysConfig(cmd *cobra.Command) *cfg.Config {
+	conf, err := LoadConfigFromFile()
+	if err != nil {
+		fmt.Println("couldn't parse the config, using defaults:", err)
+		defaultConfig := cfg.DefaultConfig()
+		conf = &defaultConfig
+	}
+	cmdp.EnsureCLIFlags(cmd.Root(), conf)
+	return conf
+}
+
+func requirePassphrase(file string) (string, error) {
+	passphrase, ok, err := keystore.LoadPassphrase(file)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("no passphrase provided, use keystore-passphrase-file or set %v", keystore.PassphraseEnvVar)
+	}
+	return passphrase, nil
+}
+
+// loadIdentity reads a plain or encrypted identity file
+func loadIdentity(f, passphraseFile string) (*signing.EdSigner, error) {
+	if filepath.Base(f) != keystore.KeyFileName {
+		return readPlainIdentity(f)
+	}
+	passphrase, err := requirePassphrase(passphraseFile)
+	if err != nil {
+		return nil, err
+	}
+	return readEncryptedIdentity(f, passphrase)
+}
+
+func writePlainIdentity(f string, edSgn *signing.EdSigner) error {
+	err := os.MkdirAll(filepath.Dir(f), filesystem.OwnerReadWriteExec)
+	if err != nil {
+		return fmt.Errorf("failed to create directory for identity file: %v", err)
+	}
+	err = ioutil.WriteFile(f, edSgn.ToBuffer(), filesystem.OwnerReadWrite)
+	if err != nil {
+		return fmt.Errorf("failed to write identity file: %v", err)
+	}
+	return nil
+}
+
+func readPlainIdentity(f string) (*signing.EdSigner, error) {
+	buff, err := ioutil.ReadFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity from file: %v", err)
+	}
+	edSgn, err := signing.NewEdSignerFromBuffer(buff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct identity from data file: %v", err)
+	}
+	return edSgn, nil
+}
+
+func writeEncryptedIdentity(f string, edSgn *signing.EdSigner, passphrase string, params crypto.KDParams) error {
+	kf, err := keystore.Encrypt(keystore.TypeEd25519, edSgn.ToBuffer(), edSgn.PublicKey().Bytes(), passphrase, params)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt identity: %v", err)
+	}
+	return keystore.WriteKeyFile(f, kf)
+}
+
+// changeIdentityPassphrase re-encrypts the identity file f with the new passphrase
+func changeIdentityPassphrase(f, passphraseFile, newPassphrase string) error {
+	passphrase, err := requirePassphrase(passphraseFile)
+	if err != nil {
+		return err
+	}
+	kf, err := keystore.ReadKeyFile(f)
+	if err != nil {
+		return err
+	}
+	if kf.Type != keystore.TypeEd25519 {
+		return fmt.Errorf("unexpected key type %v in identity file", kf.Type)
+	}
+	changed, err := kf.ChangePassphrase(passphrase, newPassphrase)
+	if err != nil {
+		return fmt.Errorf("failed to change identity passphrase: %v", err)
+	}
+	return keystore.WriteKeyFile(f, changed)
+}
+
+func readEncryptedIdentity(f, passphrase string) (*signing.EdSigner, error) {
+	kf, err := keystore.ReadKeyFile(f)
+	if err != nil {
+		return nil, err
+	}
+	if kf.Type != keystore.TypeEd25519 {
+		return nil, fmt.Errorf("unexpected key type %v in identity file", kf.Type)
+	}
+	buff, err := kf.Decrypt(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt identity file: %v", err)
+	}
+	edSgn, err := signing.NewEdSignerFromBuffer(buff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct identity from data file: %v", err)
+	}
+	return edSgn, nil
+}
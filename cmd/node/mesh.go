@@ -0,0 +1,182 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/activation"
+	cmdp "github.com/spacemeshos/go-spacemesh/cmd"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/spacemeshos/go-spacemesh/sync"
+	"github.com/spacemeshos/go-spacemesh/timesync"
+	"github.com/spf13/cobra"
+)
+
+var (
+	meshExportFrom   uint64
+	meshExportTo     uint64
+	meshExportOutput string
+	meshImportInput  string
+)
+
+// meshImportPeerTimeout bounds the wait for the syncer to discover the archive server
+const meshImportPeerTimeout = 10 * time.Second
+
+// MeshCmd groups the mesh archive commands
+var MeshCmd = &cobra.Command{
+	Use:   "mesh",
+	Short: "export and import the mesh",
+}
+
+var meshExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export layers with their blocks, transactions, atxs and PoET proofs to a mesh archive, the node must not be running",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conf := keysConfig(cmd)
+		mdb, err := mesh.NewPersistentMeshDB(filepath.Join(conf.DataDir(), "mesh"), conf.BlockCacheSize, log.NewDefault(MeshDBLogger))
+		if err != nil {
+			return fmt.Errorf("failed to open mesh database: %v", err)
+		}
+		defer mdb.Close()
+		to := types.LayerID(meshExportTo)
+		if to == 0 {
+			if to, err = mdb.GetProcessedLayer(); err != nil {
+				return fmt.Errorf("no processed layer in mesh database: %v", err)
+			}
+		}
+
+		atxStore, err := database.NewLDBDatabase(filepath.Join(conf.DataDir(), "atx"), 0, 0, log.NewDefault(AtxDbStoreLogger))
+		if err != nil {
+			return fmt.Errorf("failed to open atx database: %v", err)
+		}
+		defer atxStore.Close()
+		poetStore, err := database.NewLDBDatabase(filepath.Join(conf.DataDir(), "poet"), 0, 0, log.NewDefault(PoetDbStoreLogger))
+		if err != nil {
+			return fmt.Errorf("failed to open poet database: %v", err)
+		}
+		defer poetStore.Close()
+		atxdb := activation.NewDB(atxStore, nil, mdb, uint16(conf.LayersPerEpoch), nil, log.NewDefault(AtxDbLogger))
+		poetDb := activation.NewPoetDb(poetStore, log.NewDefault(PoetDbLogger))
+
+		out, err := os.Create(meshExportOutput)
+		if err != nil {
+			return err
+		}
+		err = mesh.ExportLayers(out, mdb, atxdb, poetDb, uint16(conf.LayersPerEpoch), types.LayerID(meshExportFrom), to)
+		out.Close()
+		if err != nil {
+			os.Remove(meshExportOutput)
+			return err
+		}
+		fmt.Printf("exported layers %v to %v to %v\n", meshExportFrom, to, meshExportOutput)
+		return nil
+	},
+}
+
+var meshImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "import a mesh archive, validating it like layers synced from peers, the node must not be running",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		in, err := os.Open(meshImportInput)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		r, err := mesh.NewArchiveReader(in)
+		if err != nil {
+			return err
+		}
+
+		app := NewSpacemeshApp()
+		app.Config = keysConfig(cmd)
+		net, err := app.initImportServices()
+		if err != nil {
+			return err
+		}
+		defer app.stopServices()
+		return app.importArchive(net, r)
+	},
+}
+
+// initImportServices initializes the node services over a simulated network, on which the archive is served by a
+// single peer. The identity is ephemeral since nothing is built or published while importing.
+func (app *SpacemeshApp) initImportServices() (*service.Simulator, error) {
+	gTime, err := time.Parse(time.RFC3339, app.Config.GenesisTime)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse genesis time: %v", err)
+	}
+	sgn := signing.NewEdSigner()
+	vrfSigner, vrfPub, err := newVRFSigner(sgn)
+	if err != nil {
+		return nil, err
+	}
+	nodeID := types.NodeID{Key: sgn.PublicKey().String(), VRFPublicKey: vrfPub}
+	postClient, err := activation.NewPostClient(&app.Config.POST, util.Hex2Bytes(nodeID.Key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create post client: %v", err)
+	}
+	poetClient := activation.NewHTTPPoetClient(cmdp.Ctx, app.Config.PoETServer)
+	ld := time.Duration(app.Config.LayerDurationSec) * time.Second
+	clock := timesync.NewClock(timesync.RealClock{}, ld, gTime, log.NewDefault("clock"))
+
+	net := service.NewSimulator()
+	err = app.initServices(nodeID, net.NewNode(), app.Config.DataDir(), sgn, false, nil, uint32(app.Config.LayerAvgSize), postClient, poetClient, vrfSigner, uint16(app.Config.LayersPerEpoch), clock)
+	return net, err
+}
+
+// importArchive serves the archive layer by layer to the syncer, layers that were already processed are skipped
+func (app *SpacemeshApp) importArchive(net *service.Simulator, r *mesh.ArchiveReader) error {
+	timeout := time.Duration(app.Config.SyncRequestTimeout) * time.Millisecond
+	srv := sync.NewArchiveServer(net.NewNode(), timeout, app.addLogger("archive", app.log))
+	defer srv.Close()
+
+	deadline := time.Now().Add(meshImportPeerTimeout)
+	for len(app.syncer.GetPeers()) == 0 {
+		if time.Now().After(deadline) {
+			return errors.New("syncer did not discover the archive server")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	for {
+		layer, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if processed := app.mesh.ProcessedLayer(); layer.Layer <= processed {
+			fmt.Printf("skipping layer %v, already processed %v\n", layer.Layer, processed)
+			continue
+		}
+		if err := srv.SetLayer(layer); err != nil {
+			return err
+		}
+		if err := app.syncer.ImportLayer(layer.Layer); err != nil {
+			return fmt.Errorf("failed to import layer %v: %v", layer.Layer, err)
+		}
+		fmt.Printf("imported layer %v/%v\n", layer.Layer, r.To())
+	}
+}
+
+func init() {
+	meshExportCmd.Flags().Uint64Var(&meshExportFrom, "from", 1, "first layer to export")
+	meshExportCmd.Flags().Uint64Var(&meshExportTo, "to", 0, "last layer to export, defaults to the last processed layer")
+	meshExportCmd.Flags().StringVar(&meshExportOutput, "output", "", "archive file to write")
+	meshExportCmd.MarkFlagRequired("output")
+	meshImportCmd.Flags().StringVar(&meshImportInput, "input", "", "archive file to read")
+	meshImportCmd.MarkFlagRequired("input")
+	MeshCmd.AddCommand(meshExportCmd, meshImportCmd)
+	Cmd.AddCommand(MeshCmd)
+}
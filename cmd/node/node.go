@@ -5,7 +5,6 @@ import "C"
 import (
 	"context"
 	"fmt"
-	"github.com/spacemeshos/amcl"
 	"github.com/spacemeshos/amcl/BLS381"
 	"github.com/spacemeshos/go-spacemesh/activation"
 	apiCfg "github.com/spacemeshos/go-spacemesh/api/config"
@@ -16,6 +15,7 @@ import (
 	"github.com/spacemeshos/go-spacemesh/events"
 	"github.com/spacemeshos/go-spacemesh/hare"
 	"github.com/spacemeshos/go-spacemesh/hare/eligibility"
+	"github.com/spacemeshos/go-spacemesh/keystore"
 	"github.com/spacemeshos/go-spacemesh/mesh"
 	"github.com/spacemeshos/go-spacemesh/metrics"
 	"github.com/spacemeshos/go-spacemesh/miner"
@@ -23,13 +23,13 @@ import (
 	"github.com/spacemeshos/go-spacemesh/p2p/service"
 	"github.com/spacemeshos/go-spacemesh/pendingtxs"
 	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/spacemeshos/go-spacemesh/signing/remote"
 	"github.com/spacemeshos/go-spacemesh/state"
 	"github.com/spacemeshos/go-spacemesh/sync"
 	"github.com/spacemeshos/go-spacemesh/tortoise"
 	"github.com/spacemeshos/go-spacemesh/turbohare"
 	"github.com/spacemeshos/post/shared"
 	"go.uber.org/zap"
-	"io/ioutil"
 	"net/http"
 	"os"
 	"os/signal"
@@ -144,28 +144,32 @@ type TickProvider interface {
 // SpacemeshApp is the cli app singleton
 type SpacemeshApp struct {
 	*cobra.Command
-	nodeID         types.NodeID
-	P2P            p2p.Service
-	Config         *cfg.Config
-	grpcAPIService *api.SpacemeshGrpcService
-	jsonAPIService *api.JSONHTTPServer
-	syncer         *sync.Syncer
-	blockListener  *sync.BlockListener
-	state          *state.TransactionProcessor
-	blockProducer  *miner.BlockBuilder
-	oracle         *oracle.MinerBlockOracle
-	txProcessor    *state.TransactionProcessor
-	mesh           *mesh.Mesh
-	clock          TickProvider
-	hare           HareService
-	atxBuilder     *activation.Builder
-	poetListener   *activation.PoetListener
-	edSgn          *signing.EdSigner
-	closers        []interface{ Close() }
-	log            log.Log
-	txPool         *miner.TxMempool
-	loggers        map[string]*zap.AtomicLevel
-	term           chan struct{} // this channel is closed when closing services, goroutines should wait on this channel in order to terminate
+	nodeID          types.NodeID
+	P2P             p2p.Service
+	Config          *cfg.Config
+	grpcAPIService  *api.SpacemeshGrpcService
+	jsonAPIService  *api.JSONHTTPServer
+	syncer          *sync.Syncer
+	blockListener   *sync.BlockListener
+	state           *state.TransactionProcessor
+	blockProducer   *miner.BlockBuilder
+	oracle          *oracle.MinerBlockOracle
+	txProcessor     *state.TransactionProcessor
+	mesh            *mesh.Mesh
+	tortoise        tortoise.Tortoise
+	clock           TickProvider
+	hare            HareService
+	atxBuilder      *activation.Builder
+	atxDb           *activation.DB
+	poetListener    *activation.PoetListener
+	edSgn           signing.Signer
+	smeshers        smesherList
+	smesherServices *smesherServices
+	closers         []interface{ Close() }
+	log             log.Log
+	txPool          *miner.TxMempool
+	loggers         map[string]*zap.AtomicLevel
+	term            chan struct{} // this channel is closed when closing services, goroutines should wait on this channel in order to terminate
 }
 
 // LoadConfigFromFile tries to load configuration file if the config parameter was specified
@@ -243,6 +247,10 @@ func (app *SpacemeshApp) Initialize(cmd *cobra.Command, args []string) (err erro
 	// ensure cli flags are higher priority than config file
 	cmdp.EnsureCLIFlags(cmd, app.Config)
 
+	if err := app.Config.HARE.ValidateEpochTimings(); err != nil {
+		return fmt.Errorf("invalid hare config: %v", err)
+	}
+
 	// override default config in timesync since timesync is using TimeCongigValues
 	timeCfg.TimeConfigValues = app.Config.TIME
 
@@ -429,13 +437,13 @@ func (app *SpacemeshApp) SetLogLevel(name, loglevel string) error {
 func (app *SpacemeshApp) initServices(nodeID types.NodeID,
 	swarm service.Service,
 	dbStorepath string,
-	sgn hare.Signer,
+	sgn signing.Signer,
 	isFixedOracle bool,
 	rolacle hare.Rolacle,
 	layerSize uint32,
 	postClient activation.PostProverClient,
 	poetClient activation.PoetProvingServiceClient,
-	vrfSigner *BLS381.BlsSigner,
+	vrfSigner vrfMsgSigner,
 	layersPerEpoch uint16, clock TickProvider) error {
 
 	app.nodeID = nodeID
@@ -505,12 +513,24 @@ func (app *SpacemeshApp) initServices(nodeID types.NodeID,
 
 	var msh *mesh.Mesh
 	var trtl tortoise.Tortoise
+	verifying := app.Config.Tortoise == tortoise.Verifying
+	if !verifying && app.Config.Tortoise != tortoise.Ninja {
+		lg.Warning("unknown tortoise %v, using %v", app.Config.Tortoise, tortoise.Ninja)
+	}
 	if mdb.PersistentData() {
-		trtl = tortoise.NewRecoveredTortoise(mdb, app.addLogger(TrtlLogger, lg))
+		if verifying {
+			trtl = tortoise.NewRecoveredVerifyingTortoise(mdb, app.addLogger(TrtlLogger, lg))
+		} else {
+			trtl = tortoise.NewRecoveredTortoise(mdb, app.addLogger(TrtlLogger, lg))
+		}
 		msh = mesh.NewRecoveredMesh(mdb, atxdb, app.Config.REWARD, trtl, app.txPool, atxpool, processor, app.addLogger(MeshLogger, lg))
 		go msh.CacheWarmUp(app.Config.LayerAvgSize)
 	} else {
-		trtl = tortoise.NewTortoise(int(layerSize), mdb, app.Config.Hdist, app.addLogger(TrtlLogger, lg))
+		if verifying {
+			trtl = tortoise.NewVerifyingTortoise(int(layerSize), mdb, app.Config.Hdist, app.addLogger(TrtlLogger, lg))
+		} else {
+			trtl = tortoise.NewTortoise(int(layerSize), mdb, app.Config.Hdist, app.addLogger(TrtlLogger, lg))
+		}
 		msh = mesh.NewMesh(mdb, atxdb, app.Config.REWARD, trtl, app.txPool, atxpool, processor, app.addLogger(MeshLogger, lg))
 		app.setupGenesis(processor, msh)
 	}
@@ -539,14 +559,35 @@ func (app *SpacemeshApp) initServices(nodeID types.NodeID,
 
 	// TODO: we should probably decouple the apptest and the node (and duplicate as necessary) (#1926)
 	var hOracle hare.Rolacle
+	beacon := eligibility.NewBeacon(mdb, app.Config.HareEligibility.ConfidenceParam, app.addLogger(HareBeaconLogger, lg))
 	if isFixedOracle { // fixed rolacle, take the provided rolacle
 		hOracle = rolacle
 	} else { // regular oracle, build and use it
-		beacon := eligibility.NewBeacon(mdb, app.Config.HareEligibility.ConfidenceParam, app.addLogger(HareBeaconLogger, lg))
-		hOracle = eligibility.New(beacon, atxdb.CalcActiveSetSize, BLS381.Verify2, vrfSigner, uint16(app.Config.LayersPerEpoch), app.Config.GenesisActiveSet, mdb, app.Config.HareEligibility, app.addLogger(HareOracleLogger, lg))
+		o := eligibility.New(beacon, atxdb.CalcActiveSetWeights, BLS381.Verify2, vrfSigner, uint16(app.Config.LayersPerEpoch), app.Config.GenesisActiveSet, mdb, app.Config.HareEligibility, app.addLogger(HareOracleLogger, lg))
+		if err := o.SetSpaceUnit(app.Config.POST.SpacePerUnit); err != nil {
+			return err
+		}
+		o.SetBatchVerifier(eligibility.VerifyBLSBatch)
+		hOracle = o
 	}
 
-	ha := app.HareFactory(mdb, swarm, sgn, nodeID, syncer, msh, hOracle, idStore, clock, lg)
+	hareStore, err := database.NewLDBDatabase(filepath.Join(dbStorepath, "hare"), 0, 0, lg.WithName("hareStore"))
+	if err != nil {
+		return err
+	}
+	app.closers = append(app.closers, hareStore)
+	ha, err := app.HareFactory(mdb, swarm, sgn, nodeID, syncer, msh, hOracle, idStore, hareStore, clock, lg)
+	if err != nil {
+		return err
+	}
+	if outputs, ok := ha.(sync.CertifiedOutputs); ok {
+		syncer.SetCertifiedOutputs(outputs)
+	}
+	if o, ok := hOracle.(*eligibility.Oracle); ok {
+		if mp, ok := ha.(eligibility.MalfeasanceProvider); ok {
+			o.SetMalfeasanceProvider(mp)
+		}
+	}
 
 	stateAndMeshProjector := pendingtxs.NewStateAndMeshProjector(processor, msh)
 	blockProducer := miner.NewBlockBuilder(nodeID, sgn, swarm, clock.Subscribe(), app.Config.Hdist, app.txPool, atxpool, coinToss, msh, ha, blockOracle, processor, atxdb, syncer, app.Config.AtxsPerBlock, layersPerEpoch, stateAndMeshProjector, app.addLogger(BlockBuilderLogger, lg))
@@ -568,6 +609,7 @@ func (app *SpacemeshApp) initServices(nodeID types.NodeID,
 	app.blockProducer = blockProducer
 	app.blockListener = blockListener
 	app.mesh = msh
+	app.tortoise = trtl
 	app.syncer = syncer
 	app.clock = clock
 	app.state = processor
@@ -575,9 +617,35 @@ func (app *SpacemeshApp) initServices(nodeID types.NodeID,
 	app.P2P = swarm
 	app.poetListener = poetListener
 	app.atxBuilder = atxBuilder
+	app.atxDb = atxdb
 	app.oracle = blockOracle
 	app.txProcessor = processor
-	return nil
+
+	smesherRecords, err := database.NewLDBDatabase(filepath.Join(dbStorepath, "smeshers"), 0, 0, lg.WithName("smeshers"))
+	if err != nil {
+		return err
+	}
+	app.closers = append(app.closers, smesherRecords)
+	app.smesherServices = &smesherServices{
+		swarm:          swarm,
+		dbStorepath:    dbStorepath,
+		atxdb:          atxdb,
+		msh:            msh,
+		syncer:         syncer,
+		poetDb:         poetDb,
+		poetClient:     poetClient,
+		clock:          clock,
+		beaconProvider: beaconProvider,
+		hareBeacon:     beacon,
+		layerSize:      layerSize,
+		layersPerEpoch: layersPerEpoch,
+		records:        smesherRecords,
+		lg:             lg,
+	}
+	if isFixedOracle {
+		app.smesherServices.fixedRolacle = rolacle
+	}
+	return app.loadSmeshers()
 }
 
 // periodically checks that our clock is sync
@@ -601,10 +669,62 @@ func (app *SpacemeshApp) checkTimeDrifts() {
 	}
 }
 
-// HareFactory returns a hare consensus algorithm according to the parameters is app.Config.Hare.SuperHare
-func (app *SpacemeshApp) HareFactory(mdb *mesh.DB, swarm service.Service, sgn hare.Signer, nodeID types.NodeID, syncer *sync.Syncer, msh *mesh.Mesh, hOracle hare.Rolacle, idStore *activation.IdentityStore, clock TickProvider, lg log.Log) HareService {
+// pruneAtxs drops the bodies of atxs older than the configured retention once an epoch starts
+func (app *SpacemeshApp) pruneAtxs(layers timesync.LayerTimer) {
+	defer app.clock.Unsubscribe(layers)
+	retention := types.EpochID(app.Config.AtxRetentionEpochs)
+	var lastEpoch types.EpochID
+	for {
+		select {
+		case <-app.term:
+			return
+		case layer := <-layers:
+			epoch := layer.GetEpoch(uint16(app.Config.LayersPerEpoch))
+			if epoch == lastEpoch || epoch <= retention {
+				continue
+			}
+			lastEpoch = epoch
+			if _, err := app.atxDb.PruneAtxBodies(epoch - retention); err != nil {
+				app.log.With().Error("failed to prune atx bodies", epoch, log.Err(err))
+			}
+		}
+	}
+}
+
+// pruneLayers drops the bodies of the blocks and transactions of layers that fell out of the configured retention
+// behind the state
+func (app *SpacemeshApp) pruneLayers(layers timesync.LayerTimer) {
+	defer app.clock.Unsubscribe(layers)
+	retention := types.LayerID(app.Config.LayerRetention)
+	for {
+		select {
+		case <-app.term:
+			return
+		case <-layers:
+			verified := app.mesh.LatestLayerInState()
+			if verified <= retention {
+				continue
+			}
+			if _, err := app.mesh.PruneLayers(verified - retention); err != nil {
+				app.log.With().Error("failed to prune layers", verified, log.Err(err))
+			}
+		}
+	}
+}
+
+// HareFactory returns a hare consensus algorithm according to the parameters is app.Config.Hare.SuperHare and
+// app.Config.HARE.RecordedOutputs
+func (app *SpacemeshApp) HareFactory(mdb *mesh.DB, swarm service.Service, sgn hare.Signer, nodeID types.NodeID, syncer *sync.Syncer, msh *mesh.Mesh, hOracle hare.Rolacle, idStore *activation.IdentityStore, hareStore database.Database, clock TickProvider, lg log.Log) (HareService, error) {
+	if app.Config.HARE.RecordedOutputs != "" {
+		// the recorded outputs are used to reproduce a past run, running the hare instead would silently diverge
+		rh, err := turbohare.LoadRecorded(msh, app.Config.HARE.RecordedOutputs, app.addLogger(HareLogger, lg))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the recorded hare outputs: %v", err)
+		}
+		return rh, nil
+	}
 	if app.Config.HARE.SuperHare {
-		return turbohare.New(msh)
+		return turbohare.New(msh), nil
 	}
 
 	// a function to validate we know the blocks
@@ -624,8 +744,8 @@ func (app *SpacemeshApp) HareFactory(mdb *mesh.DB, swarm service.Service, sgn ha
 
 		return true
 	}
-	ha := hare.New(app.Config.HARE, swarm, sgn, nodeID, validationFunc, syncer.IsSynced, msh, hOracle, uint16(app.Config.LayersPerEpoch), idStore, hOracle, clock.Subscribe(), app.addLogger(HareLogger, lg))
-	return ha
+	ha := hare.New(app.Config.HARE, swarm, sgn, nodeID, validationFunc, syncer.IsSynced, msh, hOracle, uint16(app.Config.LayersPerEpoch), idStore, hOracle, hareStore, clock.Subscribe(), app.addLogger(HareLogger, lg))
+	return ha, nil
 }
 
 func (app *SpacemeshApp) startServices() {
@@ -653,6 +773,13 @@ func (app *SpacemeshApp) startServices() {
 		log.Info("Manual post init")
 	}
 	app.atxBuilder.Start()
+	app.startSmeshers()
+	if app.Config.AtxRetentionEpochs > 0 {
+		go app.pruneAtxs(app.clock.Subscribe())
+	}
+	if app.Config.LayerRetention > 0 {
+		go app.pruneLayers(app.clock.Subscribe())
+	}
 	app.clock.StartNotifying()
 	go app.checkTimeDrifts()
 }
@@ -694,6 +821,9 @@ func (app *SpacemeshApp) stopServices() {
 		app.atxBuilder.Stop()
 	}
 
+	app.log.Info("closing smeshers")
+	app.stopSmeshers()
+
 	if app.blockListener != nil {
 		app.log.Info("%v closing blockListener", app.nodeID.Key)
 		app.blockListener.Close()
@@ -722,33 +852,54 @@ func (app *SpacemeshApp) stopServices() {
 	}
 }
 
-// LoadOrCreateEdSigner either loads a previously created ed identity for the node or creates a new one if not exists
+// LoadOrCreateEdSigner either loads a previously created ed identity for the node or creates a new one if not exists.
+// If a keystore passphrase is configured, a newly created identity is stored encrypted.
 func (app *SpacemeshApp) LoadOrCreateEdSigner() (*signing.EdSigner, error) {
-	f, err := app.getIdentityFile()
+	return app.loadOrCreateEdSigner(app.Config.POST.DataDir)
+}
+
+// loadOrCreateEdSigner loads the identity kept in the PoST data dir postDataDir, or creates a new one if not exists.
+func (app *SpacemeshApp) loadOrCreateEdSigner(postDataDir string) (*signing.EdSigner, error) {
+	f, err := findIdentityFile(postDataDir)
 	if err != nil {
 		log.Warning("Failed to find identity file: %v", err)
 
 		edSgn := signing.NewEdSigner()
-		f = filepath.Join(shared.GetInitDir(app.Config.POST.DataDir, edSgn.PublicKey().Bytes()), edKeyFileName)
-		err := os.MkdirAll(filepath.Dir(f), filesystem.OwnerReadWriteExec)
+		dir := shared.GetInitDir(postDataDir, edSgn.PublicKey().Bytes())
+		passphrase, encrypted, err := keystore.LoadPassphrase(app.Config.KeystorePassphraseFile)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create directory for identity file: %v", err)
+			return nil, err
+		}
+		if encrypted {
+			err = writeEncryptedIdentity(filepath.Join(dir, keystore.KeyFileName), edSgn, passphrase, identityKDParams)
+		} else {
+			err = writePlainIdentity(filepath.Join(dir, edKeyFileName), edSgn)
 		}
-		err = ioutil.WriteFile(f, edSgn.ToBuffer(), filesystem.OwnerReadWrite)
 		if err != nil {
-			return nil, fmt.Errorf("failed to write identity file: %v", err)
+			return nil, err
 		}
-		log.Warning("Created new identity with public key %v", edSgn.PublicKey())
+		log.Warning("Created new identity with public key %v (encrypted: %v)", edSgn.PublicKey(), encrypted)
 		return edSgn, nil
 	}
 
-	buff, err := ioutil.ReadFile(f)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read identity from file: %v", err)
-	}
-	edSgn, err := signing.NewEdSignerFromBuffer(buff)
-	if err != nil {
-		return nil, fmt.Errorf("failed to construct identity from data file: %v", err)
+	var edSgn *signing.EdSigner
+	if filepath.Base(f) == keystore.KeyFileName {
+		passphrase, ok, err := keystore.LoadPassphrase(app.Config.KeystorePassphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("identity file ('%s') is encrypted but no passphrase was provided", f)
+		}
+		edSgn, err = readEncryptedIdentity(f, passphrase)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		edSgn, err = readPlainIdentity(f)
+		if err != nil {
+			return nil, err
+		}
 	}
 	if edSgn.PublicKey().String() != filepath.Base(filepath.Dir(f)) {
 		return nil, fmt.Errorf("identity file path ('%s') does not match public key (%s)", filepath.Dir(f), edSgn.PublicKey().String())
@@ -764,12 +915,17 @@ func (identityFileFound) Error() string {
 }
 
 func (app *SpacemeshApp) getIdentityFile() (string, error) {
+	return findIdentityFile(app.Config.POST.DataDir)
+}
+
+// findIdentityFile returns the first plain or encrypted identity file found under dir
+func findIdentityFile(dir string) (string, error) {
 	var f string
-	err := filepath.Walk(app.Config.POST.DataDir, func(path string, info os.FileInfo, err error) error {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil
 		}
-		if !info.IsDir() && info.Name() == edKeyFileName {
+		if !info.IsDir() && (info.Name() == edKeyFileName || info.Name() == keystore.KeyFileName) {
 			f = path
 			return &identityFileFound{}
 		}
@@ -836,18 +992,26 @@ func (app *SpacemeshApp) Start(cmd *cobra.Command, args []string) {
 
 	/* Create or load miner identity */
 
-	app.edSgn, err = app.LoadOrCreateEdSigner()
-	if err != nil {
-		log.Panic("Could not retrieve identity err=%v", err)
+	if app.Config.RemoteSigner != "" {
+		remoteSgn, err := remote.NewClient(app.Config.RemoteSigner, uint16(app.Config.LayersPerEpoch), log.NewDefault("remote_signer"))
+		if err != nil {
+			log.Panic("Could not connect to remote signer err=%v", err)
+		}
+		app.closers = append(app.closers, remoteSgn)
+		app.edSgn = remoteSgn
+	} else {
+		app.edSgn, err = app.LoadOrCreateEdSigner()
+		if err != nil {
+			log.Panic("Could not retrieve identity err=%v", err)
+		}
 	}
 
 	poetClient := activation.NewHTTPPoetClient(cmdp.Ctx, app.Config.PoETServer)
 
-	rng := amcl.NewRAND()
-	pub := app.edSgn.PublicKey().Bytes()
-	rng.Seed(len(pub), app.edSgn.Sign(pub)) // assuming ed.private is random, the sig can be used as seed
-	vrfPriv, vrfPub := BLS381.GenKeyPair(rng)
-	vrfSigner := BLS381.NewBlsSigner(vrfPriv)
+	vrfSigner, vrfPub, err := newVRFSigner(app.edSgn)
+	if err != nil {
+		log.Panic("Could not derive vrf key err=%v", err)
+	}
 	nodeID := types.NodeID{Key: app.edSgn.PublicKey().String(), VRFPublicKey: vrfPub}
 
 	postClient, err := activation.NewPostClient(&app.Config.POST, util.Hex2Bytes(nodeID.Key))
@@ -900,8 +1064,9 @@ func (app *SpacemeshApp) Start(cmd *cobra.Command, args []string) {
 	if apiConf.StartGrpcServer || apiConf.StartJSONServer {
 		// start grpc if specified or if json rpc specified
 		layerDuration := app.Config.LayerDurationSec
+		hareAPI, _ := app.hare.(api.HareAPI)
 		app.grpcAPIService = api.NewGrpcService(apiConf.GrpcServerPort, app.P2P, app.state, app.mesh, app.txPool,
-			app.atxBuilder, app.oracle, app.clock, postClient, layerDuration, app.syncer, app.Config, app)
+			app.atxBuilder, app.oracle, app.clock, postClient, layerDuration, app.syncer, app.Config, app, app, hareAPI, app.tortoise)
 		app.grpcAPIService.StartService()
 	}
 
@@ -2,12 +2,21 @@ package node
 
 import (
 	"fmt"
+	"github.com/spacemeshos/go-spacemesh/activation"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/go-spacemesh/crypto"
+	"github.com/spacemeshos/go-spacemesh/keystore"
 	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/spacemeshos/go-spacemesh/timesync"
 	"github.com/stretchr/testify/require"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestSpacemeshApp_getEdIdentity(t *testing.T) {
@@ -54,6 +63,69 @@ func TestSpacemeshApp_getEdIdentity(t *testing.T) {
 	r.EqualError(err, fmt.Sprintf("identity file path ('tmp/wrong name') does not match public key (%v)", sgn.PublicKey().String()))
 }
 
+func TestSpacemeshApp_getEncryptedEdIdentity(t *testing.T) {
+	r := require.New(t)
+
+	defer func() {
+		// cleanup
+		err := os.RemoveAll("tmp")
+		r.NoError(err)
+	}()
+	identityKDParams = keystore.LightKDParams
+	defer func() { identityKDParams = crypto.DefaultCypherParams }()
+
+	// setup spacemesh app with a passphrase in the environment
+	r.NoError(os.Setenv(keystore.PassphraseEnvVar, "beagles"))
+	app := NewSpacemeshApp()
+	app.Config.POST.DataDir = "tmp"
+	app.log = log.NewDefault("logger")
+
+	// get new identity, it should be stored encrypted
+	sgn, err := app.LoadOrCreateEdSigner()
+	r.NoError(err)
+	f, err := app.getIdentityFile()
+	r.NoError(err)
+	r.Equal(keystore.KeyFileName, filepath.Base(f))
+
+	// load it again with the same passphrase
+	sgn2, err := app.LoadOrCreateEdSigner()
+	r.NoError(err)
+	r.Equal(sgn.PublicKey(), sgn2.PublicKey())
+
+	// wrong passphrase
+	r.NoError(os.Setenv(keystore.PassphraseEnvVar, "poodles"))
+	_, err = app.LoadOrCreateEdSigner()
+	r.EqualError(err, "failed to decrypt identity file: "+keystore.ErrDecrypt.Error())
+
+	// no passphrase
+	r.NoError(os.Unsetenv(keystore.PassphraseEnvVar))
+	_, err = app.LoadOrCreateEdSigner()
+	r.EqualError(err, fmt.Sprintf("identity file ('%v') is encrypted but no passphrase was provided", f))
+}
+
+func TestChangeIdentityPassphrase(t *testing.T) {
+	r := require.New(t)
+	dir, err := ioutil.TempDir("", "passwd")
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+	r.NoError(os.Unsetenv(keystore.PassphraseEnvVar))
+
+	sgn := signing.NewEdSigner()
+	f := filepath.Join(dir, keystore.KeyFileName)
+	r.NoError(writeEncryptedIdentity(f, sgn, "beagles", keystore.LightKDParams))
+	passphraseFile := filepath.Join(dir, "passphrase")
+	r.NoError(ioutil.WriteFile(passphraseFile, []byte("poodles"), 0600))
+	r.Error(changeIdentityPassphrase(f, passphraseFile, "collies"))
+
+	r.NoError(ioutil.WriteFile(passphraseFile, []byte("beagles"), 0600))
+	r.NoError(changeIdentityPassphrase(f, passphraseFile, "collies"))
+	_, err = readEncryptedIdentity(f, "beagles")
+	r.Error(err)
+	sgn2, err := readEncryptedIdentity(f, "collies")
+	r.NoError(err)
+	r.Equal(sgn.PublicKey(), sgn2.PublicKey())
+}
+
 func TestSpacemeshApp_SetLoggers(t *testing.T) {
 	r := require.New(t)
 
@@ -105,3 +177,86 @@ func TestSpacemeshApp_AddLogger(t *testing.T) {
 	r.Equal("warn", app.loggers["hare"].String())
 	l.Info("not supposed to be printed")
 }
+
+func TestSpacemeshApp_HareFactoryRecordedOutputs(t *testing.T) {
+	r := require.New(t)
+	dir, err := ioutil.TempDir("", t.Name())
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	app := NewSpacemeshApp()
+	app.Config.HARE.RecordedOutputs = filepath.Join(dir, "missing")
+	_, err = app.HareFactory(nil, nil, nil, types.NodeID{}, nil, nil, nil, nil, nil, nil, log.NewDefault(t.Name()))
+	r.Error(err)
+
+	malformed := filepath.Join(dir, "malformed")
+	r.NoError(ioutil.WriteFile(malformed, []byte("not outputs"), 0600))
+	app.Config.HARE.RecordedOutputs = malformed
+	_, err = app.HareFactory(nil, nil, nil, types.NodeID{}, nil, nil, nil, nil, nil, nil, log.NewDefault(t.Name()))
+	r.Error(err)
+}
+
+func newSmeshersTestApp(t *testing.T, dir string, edSgn *signing.EdSigner) *SpacemeshApp {
+	r := require.New(t)
+	app := NewSpacemeshApp()
+	app.Config.POST = activation.DefaultConfig()
+	app.Config.POST.Difficulty = 5
+	app.Config.POST.NumProvenLabels = 10
+	app.Config.POST.SpacePerUnit = 1 << 10 // 1KB.
+	app.Config.POST.NumFiles = 1
+	app.Config.POST.DataDir = filepath.Join(dir, "post")
+	app.Config.CoinbaseAccount = "0x123"
+	app.Config.LayersPerEpoch = 3
+	app.Config.Hdist = 5
+
+	vrfSigner, vrfPub, err := newVRFSigner(edSgn)
+	r.NoError(err)
+	nodeID := types.NodeID{Key: edSgn.PublicKey().String(), VRFPublicKey: vrfPub}
+	postClient, err := activation.NewPostClient(&app.Config.POST, util.Hex2Bytes(nodeID.Key))
+	r.NoError(err)
+	clock := timesync.NewClock(timesync.RealClock{}, 10*time.Second, time.Now(), log.NewDefault("clock"))
+	swarm := service.NewSimulator().NewNode()
+	r.NoError(app.initServices(nodeID, swarm, filepath.Join(dir, "data"), edSgn, false, nil, 5, postClient, nil, vrfSigner, 3, clock))
+	return app
+}
+
+func TestSpacemeshApp_Smeshers(t *testing.T) {
+	r := require.New(t)
+	dir, err := ioutil.TempDir("", "smeshers")
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	edSgn := signing.NewEdSigner()
+	app := newSmeshersTestApp(t, dir, edSgn)
+	r.Len(app.Smeshers(), 1)
+	r.Equal(edSgn.PublicKey().String(), app.Smeshers()[0].ID)
+
+	id, err := app.AddSmesher(types.HexToAddress("0x456"), filepath.Join(dir, "post1"), 1<<10)
+	r.NoError(err)
+	r.Len(app.Smeshers(), 2)
+	r.Equal(id.Key, app.Smeshers()[1].ID)
+	r.Equal(filepath.Join(dir, "post1"), app.Smeshers()[1].DataDir)
+	r.Len(app.blockProducer.Identities(), 2)
+
+	// the data dir of each smesher must be unique
+	_, err = app.AddSmesher(types.HexToAddress("0x456"), filepath.Join(dir, "post1"), 1<<10)
+	r.Error(err)
+	_, err = app.AddSmesher(types.HexToAddress("0x456"), app.Config.POST.DataDir, 1<<10)
+	r.Error(err)
+
+	r.Equal(errPrimarySmesher, app.RemoveSmesher(edSgn.PublicKey().String()))
+	r.NoError(app.RemoveSmesher(id.Key))
+	r.Error(app.RemoveSmesher(id.Key))
+	r.Len(app.Smeshers(), 1)
+	r.Len(app.blockProducer.Identities(), 1)
+
+	// added smeshers are loaded again after a restart, with the same key
+	id, err = app.AddSmesher(types.HexToAddress("0x789"), filepath.Join(dir, "post2"), 1<<10)
+	r.NoError(err)
+	app.stopServices()
+
+	app = newSmeshersTestApp(t, dir, edSgn)
+	defer app.stopServices()
+	r.Len(app.Smeshers(), 2)
+	r.Equal(id.Key, app.Smeshers()[1].ID)
+}
@@ -0,0 +1,73 @@
+package node
+
+import (
+	"fmt"
+
+	"github.com/spacemeshos/go-spacemesh/activation"
+	"github.com/spf13/cobra"
+)
+
+var postVerifyOpts activation.PostVerifyOptions
+
+// PostCmd groups the PoST data management commands
+var PostCmd = &cobra.Command{
+	Use:   "post",
+	Short: "manage the PoST data",
+}
+
+var postVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "check the integrity of the PoST data, the node must not be running",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conf := keysConfig(cmd)
+		f, err := findIdentityFile(conf.POST.DataDir)
+		if err != nil {
+			return err
+		}
+		edSgn, err := loadIdentity(f, conf.KeystorePassphraseFile)
+		if err != nil {
+			return err
+		}
+		postClient, err := activation.NewPostClient(&conf.POST, edSgn.PublicKey().Bytes())
+		if err != nil {
+			return err
+		}
+		report, err := postClient.Verify(postVerifyOpts)
+		if err != nil {
+			return err
+		}
+		printPostReport(edSgn.PublicKey().String(), report)
+		if report.BadGroups > 0 && !postVerifyOpts.Repair {
+			return fmt.Errorf("post data of %v is damaged", edSgn.PublicKey())
+		}
+		if report.ProofErr != nil {
+			return fmt.Errorf("dry-run proof of %v failed: %v", edSgn.PublicKey(), report.ProofErr)
+		}
+		return nil
+	},
+}
+
+func init() {
+	postVerifyCmd.Flags().Uint64Var(&postVerifyOpts.Samples, "samples", 1000, "label groups sampled per data file, 0 checks all of them")
+	postVerifyCmd.Flags().BoolVar(&postVerifyOpts.Repair, "repair", false, "re-initialize the damaged data files")
+	postVerifyCmd.Flags().BoolVar(&postVerifyOpts.DryRun, "dry-run", false, "generate and validate a proof for a random challenge")
+	PostCmd.AddCommand(postVerifyCmd)
+	Cmd.AddCommand(PostCmd)
+}
+
+func printPostReport(id string, report *activation.PostVerifyReport) {
+	fmt.Printf("post data of %v: %v files, %v label groups checked, %v bad\n", id, report.Files, report.CheckedGroups, report.BadGroups)
+	for _, br := range report.BadRanges {
+		fmt.Printf("  file %v: label groups [%v, %v) may be damaged\n", br.File, br.From, br.To)
+	}
+	for _, f := range report.Repaired {
+		fmt.Printf("  file %v re-initialized\n", f)
+	}
+	if report.DryRun {
+		if report.ProofErr != nil {
+			fmt.Printf("  dry-run proof failed: %v\n", report.ProofErr)
+		} else {
+			fmt.Println("  dry-run proof is valid")
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/spacemeshos/amcl/BLS381"
+	"github.com/spacemeshos/go-spacemesh/activation"
+	"github.com/spacemeshos/go-spacemesh/api"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	cfg "github.com/spacemeshos/go-spacemesh/config"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/hare"
+	"github.com/spacemeshos/go-spacemesh/hare/eligibility"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"github.com/spacemeshos/go-spacemesh/oracle"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/spacemeshos/go-spacemesh/signing/remote"
+)
+
+var smesherPrefix = []byte("smesher_")
+
+// smesher is an additional identity managed by the node. It has its own key, PoST data, coinbase, atx builder and
+// block and hare eligibilities, and shares the mesh, sync and p2p services of the node.
+type smesher struct {
+	conf        cfg.SmesherConfig
+	nodeID      types.NodeID
+	signer      *signing.EdSigner
+	postClient  *activation.PostClient
+	atxBuilder  *activation.Builder
+	blockOracle *oracle.MinerBlockOracle
+	hareOracle  hare.Rolacle
+	store       *database.LDBDatabase
+}
+
+// smesherSyncer is the part of the syncer used by smeshers.
+type smesherSyncer interface {
+	Await() chan struct{}
+	ListenToGossip() bool
+}
+
+// smesherServices holds the node services shared by all smeshers.
+type smesherServices struct {
+	swarm          service.Service
+	dbStorepath    string
+	atxdb          *activation.DB
+	msh            *mesh.Mesh
+	syncer         smesherSyncer
+	poetDb         *activation.PoetDb
+	poetClient     activation.PoetProvingServiceClient
+	clock          TickProvider
+	beaconProvider *oracle.EpochBeaconProvider
+	hareBeacon     *eligibility.Beacon
+	fixedRolacle   hare.Rolacle // used by all smeshers instead of a real hare oracle, in tests
+	layerSize      uint32
+	layersPerEpoch uint16
+	records        database.Database // the smeshers added through the api
+	lg             log.Log
+}
+
+type hareParticipants interface {
+	AddParticipant(nid types.NodeID, sign hare.Signer, rolacle hare.Rolacle) error
+	RemoveParticipant(nid types.NodeID) error
+}
+
+// smesherList is the list of additional smeshers managed by the node.
+type smesherList struct {
+	mu   sync.RWMutex
+	list []*smesher
+}
+
+var errPrimarySmesher = errors.New("the node identity can't be removed")
+
+// vrfMsgSigner signs the vrf messages of an identity
+type vrfMsgSigner interface {
+	Sign(msg []byte) ([]byte, error)
+}
+
+// newVRFSigner returns the vrf signer of an identity and its public vrf key. The vrf key of a local identity is derived
+// from its ed key, the one of a remote identity is kept by the remote signer.
+func newVRFSigner(sgn signing.Signer) (vrfMsgSigner, []byte, error) {
+	switch s := sgn.(type) {
+	case *signing.EdSigner:
+		vrfSigner, vrfPub := signing.NewVRFSigner(s)
+		return vrfSigner, vrfPub, nil
+	case *remote.Client:
+		if len(s.VRFPublicKey()) == 0 {
+			return nil, nil, errors.New("the remote signer has no vrf key")
+		}
+		return s.VRFSigner(), s.VRFPublicKey(), nil
+	default:
+		return nil, nil, fmt.Errorf("can't get the vrf key of signer %T", sgn)
+	}
+}
+
+// loadSmeshers sets up the smeshers from the config and the ones previously added through the api.
+func (app *SpacemeshApp) loadSmeshers() error {
+	confs := append([]cfg.SmesherConfig(nil), app.Config.Smeshers...)
+	it := app.smesherServices.records.Find(smesherPrefix)
+	for it.Next() {
+		var conf cfg.SmesherConfig
+		if err := types.BytesToInterface(it.Value(), &conf); err != nil {
+			return fmt.Errorf("failed to read smesher record: %v", err)
+		}
+		confs = append(confs, conf)
+	}
+
+	for _, conf := range confs {
+		if app.findSmesherByDataDir(conf.PostDataDir) != nil {
+			continue // configured and also added through the api
+		}
+		if _, err := app.newSmesher(conf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newSmesher creates the services of an additional smesher and registers it for block building and hare
+// participation. The smesher key is loaded from its PoST data dir, or created if it doesn't exist.
+func (app *SpacemeshApp) newSmesher(conf cfg.SmesherConfig) (*smesher, error) {
+	srv := app.smesherServices
+	coinbase := types.HexToAddress(conf.Coinbase)
+	if coinbase.Big().Uint64() == 0 {
+		return nil, fmt.Errorf("invalid coinbase account for smesher with data dir %v", conf.PostDataDir)
+	}
+	if conf.PostDataDir == "" || conf.PostDataDir == app.Config.POST.DataDir || app.findSmesherByDataDir(conf.PostDataDir) != nil {
+		return nil, fmt.Errorf("smesher data dir %v must be set and unique", conf.PostDataDir)
+	}
+
+	edSgn, err := app.loadOrCreateEdSigner(conf.PostDataDir)
+	if err != nil {
+		return nil, err
+	}
+	vrfSigner, vrfPub, err := newVRFSigner(edSgn)
+	if err != nil {
+		return nil, err
+	}
+	nodeID := types.NodeID{Key: edSgn.PublicKey().String(), VRFPublicKey: vrfPub}
+	lg := srv.lg.WithFields(log.String("smesher", nodeID.ShortString()))
+
+	postConf := app.Config.POST
+	postConf.DataDir = conf.PostDataDir
+	if conf.PostSpace != 0 {
+		postConf.SpacePerUnit = conf.PostSpace
+	}
+	postClient, err := activation.NewPostClient(&postConf, util.Hex2Bytes(nodeID.Key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create post client: %v", err)
+	}
+	postClient.SetLogger(lg.WithName(PostLogger))
+
+	// the atx builder keeps its state under fixed keys, so every smesher needs its own store
+	store, err := database.NewLDBDatabase(filepath.Join(srv.dbStorepath, "store_"+nodeID.Key), 0, 0, lg.WithName(StoreLogger))
+	if err != nil {
+		return nil, err
+	}
+
+	nipstBuilder := activation.NewNIPSTBuilder(util.Hex2Bytes(nodeID.Key), postClient, srv.poetClient, srv.poetDb, store, lg.WithName(NipstBuilderLogger))
+	atxBuilder := activation.NewBuilder(nodeID, coinbase, edSgn, srv.atxdb, srv.swarm, srv.msh, srv.layersPerEpoch, nipstBuilder, postClient, srv.clock, srv.syncer, store, lg.WithName("atxBuilder"))
+	blockOracle := oracle.NewMinerBlockOracle(srv.layerSize, uint32(app.Config.GenesisActiveSet), srv.layersPerEpoch, srv.atxdb, srv.beaconProvider, vrfSigner, nodeID, srv.syncer.ListenToGossip, lg.WithName(BlockOracle))
+
+	hOracle := srv.fixedRolacle
+	if hOracle == nil {
+		o := eligibility.New(srv.hareBeacon, srv.atxdb.CalcActiveSetWeights, BLS381.Verify2, vrfSigner, srv.layersPerEpoch, app.Config.GenesisActiveSet, srv.msh, app.Config.HareEligibility, lg.WithName(HareOracleLogger))
+		if err := o.SetSpaceUnit(app.Config.POST.SpacePerUnit); err != nil {
+			return nil, err
+		}
+		o.SetBatchVerifier(eligibility.VerifyBLSBatch)
+		if mp, ok := app.hare.(eligibility.MalfeasanceProvider); ok {
+			o.SetMalfeasanceProvider(mp)
+		}
+		hOracle = o
+	}
+
+	sm := &smesher{
+		conf:        conf,
+		nodeID:      nodeID,
+		signer:      edSgn,
+		postClient:  postClient,
+		atxBuilder:  atxBuilder,
+		blockOracle: blockOracle,
+		hareOracle:  hOracle,
+		store:       store,
+	}
+
+	if err := app.blockProducer.AddIdentity(nodeID, edSgn, blockOracle); err != nil {
+		store.Close()
+		return nil, err
+	}
+	if h, ok := app.hare.(hareParticipants); ok {
+		if err := h.AddParticipant(nodeID, edSgn, hOracle); err != nil {
+			app.blockProducer.RemoveIdentity(nodeID)
+			store.Close()
+			return nil, err
+		}
+	}
+
+	app.smeshers.mu.Lock()
+	app.smeshers.list = append(app.smeshers.list, sm)
+	app.smeshers.mu.Unlock()
+	lg.With().Info("added smesher", log.String("coinbase", conf.Coinbase), log.String("post_datadir", conf.PostDataDir))
+	return sm, nil
+}
+
+// start initializes the smesher PoST data, if needed, and starts publishing atxs.
+func (sm *smesher) start() error {
+	if err := sm.atxBuilder.StartPost(types.HexToAddress(sm.conf.Coinbase), sm.conf.PostDataDir, sm.postClient.Cfg().SpacePerUnit); err != nil {
+		return fmt.Errorf("failed to initialize post for smesher %v: %v", sm.nodeID.ShortString(), err)
+	}
+	sm.atxBuilder.Start()
+	return nil
+}
+
+// stop stops publishing atxs and closes the smesher store.
+func (sm *smesher) stop() {
+	sm.atxBuilder.Stop()
+	sm.store.Close()
+}
+
+func (app *SpacemeshApp) findSmesherByDataDir(dataDir string) *smesher {
+	app.smeshers.mu.RLock()
+	defer app.smeshers.mu.RUnlock()
+	for _, sm := range app.smeshers.list {
+		if sm.conf.PostDataDir == dataDir {
+			return sm
+		}
+	}
+	return nil
+}
+
+func (app *SpacemeshApp) startSmeshers() {
+	app.smeshers.mu.RLock()
+	defer app.smeshers.mu.RUnlock()
+	for _, sm := range app.smeshers.list {
+		if err := sm.start(); err != nil {
+			log.Error("cannot start smesher: %v", err)
+		}
+	}
+}
+
+func (app *SpacemeshApp) stopSmeshers() {
+	app.smeshers.mu.Lock()
+	defer app.smeshers.mu.Unlock()
+	for _, sm := range app.smeshers.list {
+		sm.stop()
+	}
+	app.smeshers.list = nil
+}
+
+// Smeshers returns the node identity followed by the additional smeshers managed by the node.
+func (app *SpacemeshApp) Smeshers() []api.SmesherInfo {
+	status, remainingBytes, coinbase, dataDir := app.atxBuilder.MiningStats()
+	res := []api.SmesherInfo{{ID: app.nodeID.Key, Coinbase: coinbase, DataDir: dataDir, PostStatus: status, RemainingBytes: remainingBytes}}
+
+	app.smeshers.mu.RLock()
+	defer app.smeshers.mu.RUnlock()
+	for _, sm := range app.smeshers.list {
+		status, remainingBytes, coinbase, dataDir := sm.atxBuilder.MiningStats()
+		res = append(res, api.SmesherInfo{ID: sm.nodeID.Key, Coinbase: coinbase, DataDir: dataDir, PostStatus: status, RemainingBytes: remainingBytes})
+	}
+	return res
+}
+
+// AddSmesher creates and starts a new smesher. The smesher is recorded so that it's loaded again when the node restarts.
+func (app *SpacemeshApp) AddSmesher(coinbase types.Address, datadir string, space uint64) (types.NodeID, error) {
+	conf := cfg.SmesherConfig{Coinbase: coinbase.String(), PostDataDir: datadir, PostSpace: space}
+	sm, err := app.newSmesher(conf)
+	if err != nil {
+		return types.NodeID{}, err
+	}
+	bts, err := types.InterfaceToBytes(&conf)
+	if err == nil {
+		err = app.smesherServices.records.Put(append(append([]byte{}, smesherPrefix...), sm.nodeID.Key...), bts)
+	}
+	if err == nil {
+		err = sm.start()
+	}
+	if err != nil {
+		app.removeSmesher(sm)
+		return types.NodeID{}, err
+	}
+	return sm.nodeID, nil
+}
+
+// RemoveSmesher stops a smesher. Its key and PoST data are kept, so it can be added again later. A smesher that is set
+// in the config file is loaded again when the node restarts.
+func (app *SpacemeshApp) RemoveSmesher(id string) error {
+	if id == app.nodeID.Key {
+		return errPrimarySmesher
+	}
+	app.smeshers.mu.RLock()
+	var sm *smesher
+	for _, s := range app.smeshers.list {
+		if s.nodeID.Key == id {
+			sm = s
+		}
+	}
+	app.smeshers.mu.RUnlock()
+	if sm == nil {
+		return fmt.Errorf("unknown smesher %v", id)
+	}
+	app.removeSmesher(sm)
+	return nil
+}
+
+func (app *SpacemeshApp) removeSmesher(sm *smesher) {
+	if err := app.blockProducer.RemoveIdentity(sm.nodeID); err != nil {
+		log.Warning("failed to remove smesher from block builder: %v", err)
+	}
+	if h, ok := app.hare.(hareParticipants); ok {
+		if err := h.RemoveParticipant(sm.nodeID); err != nil {
+			log.Warning("failed to remove smesher from hare: %v", err)
+		}
+	}
+	if err := app.smesherServices.records.Delete(append(append([]byte{}, smesherPrefix...), sm.nodeID.Key...)); err != nil {
+		log.Warning("failed to delete smesher record: %v", err)
+	}
+
+	app.smeshers.mu.Lock()
+	for i, s := range app.smeshers.list {
+		if s == sm {
+			app.smeshers.list = append(app.smeshers.list[:i:i], app.smeshers.list[i+1:]...)
+			break
+		}
+	}
+	app.smeshers.mu.Unlock()
+	sm.stop()
+	log.Info("removed smesher %v", sm.nodeID.ShortString())
+}
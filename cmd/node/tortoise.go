@@ -0,0 +1,78 @@
+package node
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"github.com/spacemeshos/go-spacemesh/tortoise"
+	"github.com/spf13/cobra"
+)
+
+var tortoiseRecomputeDryRun bool
+
+// TortoiseCmd groups the tortoise maintenance commands
+var TortoiseCmd = &cobra.Command{
+	Use:   "tortoise",
+	Short: "maintain the tortoise state",
+}
+
+var tortoiseRecomputeCmd = &cobra.Command{
+	Use:   "recompute",
+	Short: "replay the stored layers to a fresh tortoise of the configured kind and rewrite the contextual validity and its state, the node must not be running",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conf := keysConfig(cmd)
+		mdb, err := mesh.NewPersistentMeshDB(filepath.Join(conf.DataDir(), "mesh"), conf.BlockCacheSize, log.NewDefault(MeshDBLogger))
+		if err != nil {
+			return fmt.Errorf("failed to open mesh database: %v", err)
+		}
+		defer mdb.Close()
+
+		last, err := mdb.GetProcessedLayer()
+		if err != nil {
+			return fmt.Errorf("no processed layer in mesh database: %v", err)
+		}
+		report, err := tortoise.Recompute(mdb, conf.Tortoise, conf.LayerAvgSize, conf.Hdist, last, tortoiseRecomputeDryRun, func(l, complete types.LayerID) {
+			fmt.Printf("replayed layer %v/%v latest complete %v\n", l, last, complete)
+		}, log.NewDefault(TrtlLogger))
+		if err != nil {
+			return err
+		}
+		printRecomputeReport(os.Stdout, report, tortoiseRecomputeDryRun)
+		return nil
+	},
+}
+
+func validityString(valid bool) string {
+	if valid {
+		return "valid"
+	}
+	return "invalid"
+}
+
+// printRecomputeReport prints the blocks whose validity changed and a summary of the recomputation
+func printRecomputeReport(w io.Writer, report *tortoise.RecomputeReport, dryRun bool) {
+	for _, d := range report.Diffs {
+		stored := "none"
+		if d.HasStored {
+			stored = validityString(d.Stored)
+		}
+		fmt.Fprintf(w, "block %v layer %v stored %v recomputed %v\n", types.Hash20(d.Block).Hex(), d.Layer, stored, validityString(d.Recomputed))
+	}
+	action := "rewrote"
+	if dryRun {
+		action = "dry run, did not rewrite"
+	}
+	fmt.Fprintf(w, "replayed %v layers, latest complete %v, %v of %v blocks differ, %v the contextual validity\n",
+		report.Last, report.Complete, len(report.Diffs), report.Blocks, action)
+}
+
+func init() {
+	tortoiseRecomputeCmd.Flags().BoolVar(&tortoiseRecomputeDryRun, "dry-run", false, "only report the blocks whose validity differs")
+	TortoiseCmd.AddCommand(tortoiseRecomputeCmd)
+	Cmd.AddCommand(TortoiseCmd)
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	cfg "github.com/spacemeshos/go-spacemesh/config"
+	"github.com/spacemeshos/go-spacemesh/keystore"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -40,6 +41,8 @@ func AddCommands(cmd *cobra.Command) {
 		config.LayerAvgSize, "Layer Avg size")
 	cmd.PersistentFlags().IntVar(&config.Hdist, "hdist",
 		config.Hdist, "hdist")
+	cmd.PersistentFlags().StringVar(&config.Tortoise, "tortoise",
+		config.Tortoise, "vote counting algorithm, ninja or verifying")
 	cmd.PersistentFlags().BoolVar(&config.StartMining, "start-mining",
 		config.StartMining, "start mining")
 
@@ -60,6 +63,11 @@ func AddCommands(cmd *cobra.Command) {
 	cmd.PersistentFlags().IntVar(&config.BlockCacheSize, "block-cache-size",
 		config.BlockCacheSize, "size in layers of meshdb block cache")
 
+	cmd.PersistentFlags().IntVar(&config.AtxRetentionEpochs, "atx-retention-epochs",
+		config.AtxRetentionEpochs, "number of epochs for which atx bodies (nipst and post proofs) are kept, headers are always kept. 0 keeps them forever")
+	cmd.PersistentFlags().IntVar(&config.LayerRetention, "layer-retention",
+		config.LayerRetention, "number of layers behind the state for which block and transaction bodies are kept, block headers are always kept. 0 keeps them forever")
+
 	cmd.PersistentFlags().StringVar(&config.PublishEventsURL, "events-url",
 		config.PublishEventsURL, "publish events on this url, if no url specified event will no be published")
 
@@ -69,6 +77,12 @@ func AddCommands(cmd *cobra.Command) {
 	cmd.PersistentFlags().IntVar(&config.AtxsPerBlock, "atxs-per-block",
 		100, "the number of atxs to select per block on block creation")
 
+	cmd.PersistentFlags().StringVar(&config.KeystorePassphraseFile, "keystore-passphrase-file",
+		config.KeystorePassphraseFile, "file holding the passphrase of the encrypted identity key, if not set the passphrase is read from "+keystore.PassphraseEnvVar)
+
+	cmd.PersistentFlags().StringVar(&config.RemoteSigner, "remote-signer",
+		config.RemoteSigner, "unix socket of a remote signer holding the identity key, if set no local identity key is used")
+
 	/** ======================== P2P Flags ========================== **/
 
 	cmd.PersistentFlags().IntVar(&config.P2P.TCPPort, "tcp-port",
@@ -156,6 +170,16 @@ func AddCommands(cmd *cobra.Command) {
 		config.HARE.LimitIterations, "The limit of the number of iteration per consensus process")
 	cmd.PersistentFlags().IntVar(&config.HARE.LimitConcurrent, "hare-limit-concurrent",
 		config.HARE.LimitConcurrent, "The number of consensus processes running concurrently")
+	cmd.PersistentFlags().StringVar(&config.HARE.RecordDir, "hare-record-dir",
+		config.HARE.RecordDir, "Directory to record the hare messages of every layer to, recording is disabled if empty")
+	cmd.PersistentFlags().IntVar(&config.HARE.FutureLayers, "hare-future-layers",
+		config.HARE.FutureLayers, "The number of layers ahead of the latest one to buffer early hare messages for")
+	cmd.PersistentFlags().IntVar(&config.HARE.MaxPendingMsgs, "hare-max-pending-msgs",
+		config.HARE.MaxPendingMsgs, "The max number of early hare messages buffered per sender and layer")
+	cmd.PersistentFlags().Float64Var(&config.HARE.TimingPercentile, "hare-timing-percentile",
+		config.HARE.TimingPercentile, "The fraction of the hare messages the recommended round duration lets arrive in time")
+	cmd.PersistentFlags().StringVar(&config.HARE.RecordedOutputs, "hare-recorded-outputs",
+		config.HARE.RecordedOutputs, "File of hare outputs captured with the hare capture-outputs command to replay instead of running the consensus, for test networks")
 
 	/**======================== Hare Eligibility Oracle Flags ========================== **/
 
@@ -163,6 +187,8 @@ func AddCommands(cmd *cobra.Command) {
 		config.HareEligibility.ConfidenceParam, "The relative layer (with respect to the current layer) we are confident to have consensus about")
 	cmd.PersistentFlags().IntVar(&config.HareEligibility.EpochOffset, "eligibility-epoch-offset",
 		config.HareEligibility.EpochOffset, "The constant layer (within an epoch) for which we traverse its view for the purpose of counting consensus active set")
+	cmd.PersistentFlags().BoolVar(&config.HareEligibility.EqualWeight, "eligibility-equal-weight",
+		config.HareEligibility.EqualWeight, "Give all active identities the same hare eligibility weight regardless of their committed space (for testnets)")
 
 	/**======================== PoST Flags ========================== **/
 
@@ -0,0 +1,113 @@
+// package signer is a remote signer executable holding the node identity key. The node connects to it with the
+// remote-signer flag, and the signer refuses to sign conflicting blocks or atxs even if the node is compromised.
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/keystore"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/spacemeshos/go-spacemesh/signing/remote"
+	"github.com/spf13/cobra"
+)
+
+var (
+	socketPath     string
+	keyFile        string
+	passphraseFile string
+	dataDir        string
+	layersPerEpoch int
+)
+
+var cmd = &cobra.Command{
+	Use:   "signer",
+	Short: "start a remote signer",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return run()
+	},
+}
+
+func init() {
+	cmd.PersistentFlags().StringVar(&socketPath, "socket", "signer.sock", "unix socket to listen on")
+	cmd.PersistentFlags().StringVar(&keyFile, "key-file", "", "identity key file, either encrypted (key.json) or plain (key.bin)")
+	cmd.PersistentFlags().StringVar(&passphraseFile, "keystore-passphrase-file", "",
+		"file holding the passphrase of the encrypted identity key, if not set the passphrase is read from "+keystore.PassphraseEnvVar)
+	cmd.PersistentFlags().StringVar(&dataDir, "data-folder", "signer-data", "directory of the double sign protection records")
+	cmd.PersistentFlags().IntVar(&layersPerEpoch, "layers-per-epoch", 0,
+		"number of layers in an epoch, required, the signer refuses to serve a node with a different value")
+}
+
+func run() error {
+	if keyFile == "" {
+		return fmt.Errorf("key-file is required")
+	}
+	if layersPerEpoch <= 0 || layersPerEpoch > math.MaxUint16 {
+		return fmt.Errorf("layers-per-epoch is required and must be between 1 and %v", math.MaxUint16)
+	}
+	edSgn, err := loadKey(keyFile)
+	if err != nil {
+		return err
+	}
+
+	db, err := database.NewLDBDatabase(filepath.Join(dataDir, "guard"), 0, 0, log.NewDefault("signer_db"))
+	if err != nil {
+		return fmt.Errorf("failed to open double sign protection records: %v", err)
+	}
+	defer db.Close()
+
+	srv := remote.NewServer(edSgn, remote.NewGuard(db, uint16(layersPerEpoch)), log.NewDefault("signer"))
+	if err := srv.Listen(socketPath); err != nil {
+		return err
+	}
+	defer srv.Close()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	<-sigs
+	log.Info("remote signer shutting down")
+	return nil
+}
+
+func loadKey(f string) (*signing.EdSigner, error) {
+	if filepath.Ext(f) != ".json" {
+		buff, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read identity from file: %v", err)
+		}
+		return signing.NewEdSignerFromBuffer(buff)
+	}
+	passphrase, ok, err := keystore.LoadPassphrase(passphraseFile)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("identity file %v is encrypted but no passphrase was provided", f)
+	}
+	kf, err := keystore.ReadKeyFile(f)
+	if err != nil {
+		return nil, err
+	}
+	if kf.Type != keystore.TypeEd25519 {
+		return nil, fmt.Errorf("unexpected key type %v in identity file", kf.Type)
+	}
+	buff, err := kf.Decrypt(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt identity file: %v", err)
+	}
+	return signing.NewEdSignerFromBuffer(buff)
+}
+
+func main() {
+	if err := cmd.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -94,6 +94,28 @@ type BlockEligibilityProof struct {
 	Sig []byte
 }
 
+// Vote is the opinion of a block on an earlier block.
+type Vote uint8
+
+const (
+	// Abstain means the voting block has no opinion on the block, e.g. because it has not seen it.
+	Abstain Vote = iota
+	// Support means the voting block considers the block valid.
+	Support
+	// Against means the voting block considers the block invalid.
+	Against
+)
+
+// Votes are the votes of a block on the blocks of the layers of its hdist window, given relative to the votes of its
+// base block: the block votes as its base block does, except for the blocks listed in the diffs. A zero BaseBlock means
+// the block has no base block and only votes on the blocks listed in the diffs.
+type Votes struct {
+	BaseBlock   BlockID
+	ForDiff     []BlockID
+	AgainstDiff []BlockID
+	NeutralDiff []BlockID
+}
+
 // BlockHeader includes all of a block's fields, except the list of transaction IDs, activation transaction IDs and the
 // signature.
 // TODO: consider combining this with MiniBlock, since this type isn't used independently anywhere.
@@ -104,8 +126,8 @@ type BlockHeader struct {
 	Data             []byte
 	Coin             bool
 	Timestamp        int64
-	BlockVotes       []BlockID
-	ViewEdges        []BlockID
+	Votes
+	ViewEdges []BlockID
 }
 
 // Layer returns the block's LayerID.
@@ -113,10 +135,20 @@ func (b BlockHeader) Layer() LayerID {
 	return b.LayerIndex
 }
 
-// AddVote adds a vote to the list of block votes.
+// AddVote adds a vote in support of the block.
 func (b *BlockHeader) AddVote(id BlockID) {
 	// todo: do this in a sorted manner
-	b.BlockVotes = append(b.BlockVotes, id)
+	b.ForDiff = append(b.ForDiff, id)
+}
+
+// AddVoteAgainst adds a vote against the block.
+func (b *BlockHeader) AddVoteAgainst(id BlockID) {
+	b.AgainstDiff = append(b.AgainstDiff, id)
+}
+
+// AddAbstain adds an abstention on the block, overriding the vote of the base block.
+func (b *BlockHeader) AddAbstain(id BlockID) {
+	b.NeutralDiff = append(b.NeutralDiff, id)
 }
 
 // AddView adds a block to this block's view.
@@ -139,6 +171,7 @@ type Block struct {
 	// keep id and minerID private to prevent them from being serialized
 	id        BlockID            // ⚠️ keep private
 	minerID   *signing.PublicKey // ⚠️ keep private
+	pruned    bool               // ⚠️ keep private
 	Signature []byte
 }
 
@@ -158,7 +191,10 @@ func (b *Block) Fields() []log.LoggableField {
 		b.LayerIndex,
 		b.MinerID(),
 		log.Int("view_edges", len(b.ViewEdges)),
-		log.Int("vote_count", len(b.BlockVotes)),
+		log.String("base_block", b.BaseBlock.String()),
+		log.Int("for_count", len(b.ForDiff)),
+		log.Int("against_count", len(b.AgainstDiff)),
+		log.Int("neutral_count", len(b.NeutralDiff)),
 		log.Uint32("eligibility_counter", b.EligibilityProof.J),
 		log.Int("tx_count", len(b.TxIDs)),
 		log.Int("atx_count", len(b.ATXIDs)),
@@ -203,6 +239,23 @@ func (b *Block) MinerID() *signing.PublicKey {
 	return b.minerID
 }
 
+// Pruned returns true if only the header of the block is known, its transactions and atxs were removed by the
+// retention policy.
+func (b *Block) Pruned() bool {
+	return b.pruned
+}
+
+// NewPrunedBlock returns a block that only holds the header of the block with the given id and miner. The id cannot
+// be recalculated from the header, so the block must not be initialized or forwarded to peers.
+func NewPrunedBlock(id BlockID, header BlockHeader, minerID *signing.PublicKey) *Block {
+	return &Block{
+		MiniBlock: MiniBlock{BlockHeader: header},
+		id:        id,
+		minerID:   minerID,
+		pruned:    true,
+	}
+}
+
 // BlockIDs returns a slice of BlockIDs corresponding to the given blocks.
 func BlockIDs(blocks []*Block) []BlockID {
 	ids := make([]BlockID, 0, len(blocks))
@@ -262,7 +315,7 @@ func NewExistingBlock(layerIndex LayerID, data []byte) *Block {
 	b := Block{
 		MiniBlock: MiniBlock{
 			BlockHeader: BlockHeader{
-				BlockVotes: make([]BlockID, 0, 10),
+				Votes:      Votes{ForDiff: make([]BlockID, 0, 10)},
 				ViewEdges:  make([]BlockID, 0, 10),
 				LayerIndex: layerIndex,
 				Data:       data},
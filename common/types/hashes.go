@@ -113,6 +113,12 @@ func CalcBlocksHash32(view []BlockID, additionalBytes []byte) Hash32 {
 	return CalcBlockHash32Presorted(sortedView, additionalBytes)
 }
 
+// CalcAggregateHash32 returns the 32-byte sha256 sum of the aggregated hash of the previous layer followed by the
+// hash of the layer.
+func CalcAggregateHash32(prevAggregate, layerHash Hash32) Hash32 {
+	return sha256.Sum256(append(prevAggregate.Bytes(), layerHash.Bytes()...))
+}
+
 // CalcBlockHash32Presorted returns the 32-byte sha256 sum of the block IDs, in the order given. The pre-image is
 // prefixed with additionalBytes.
 func CalcBlockHash32Presorted(sortedView []BlockID, additionalBytes []byte) Hash32 {
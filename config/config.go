@@ -72,6 +72,7 @@ type BaseConfig struct {
 	LayerAvgSize     int    `mapstructure:"layer-average-size"`
 	LayersPerEpoch   int    `mapstructure:"layers-per-epoch"`
 	Hdist            int    `mapstructure:"hdist"`
+	Tortoise         string `mapstructure:"tortoise"`
 
 	PoETServer string `mapstructure:"poet-server"`
 
@@ -100,6 +101,24 @@ type BaseConfig struct {
 	AtxsPerBlock int `mapstructure:"atxs-per-block"`
 
 	BlockCacheSize int `mapstructure:"block-cache-size"`
+
+	AtxRetentionEpochs int `mapstructure:"atx-retention-epochs"` // epochs for which atx bodies are kept, 0 keeps them forever
+
+	LayerRetention int `mapstructure:"layer-retention"` // layers behind the state for which block and tx bodies are kept, 0 keeps them forever
+
+	KeystorePassphraseFile string `mapstructure:"keystore-passphrase-file"` // file holding the passphrase of the encrypted identity key
+
+	RemoteSigner string `mapstructure:"remote-signer"` // unix socket of a remote signer holding the identity key
+
+	Smeshers []SmesherConfig `mapstructure:"smeshers"` // additional identities managed by the node
+}
+
+// SmesherConfig defines an additional smesher identity. Each identity has its own PoST data dir, where its key is kept,
+// and its own coinbase, while sharing the mesh, sync and p2p services of the node.
+type SmesherConfig struct {
+	Coinbase    string `mapstructure:"coinbase"`
+	PostDataDir string `mapstructure:"post-datadir"`
+	PostSpace   uint64 `mapstructure:"post-space"`
 }
 
 // LoggerConfig holds the logging level for each module.
@@ -159,6 +178,7 @@ func defaultBaseConfig() BaseConfig {
 		LayersPerEpoch:      3,
 		PoETServer:          "127.0.0.1",
 		Hdist:               5,
+		Tortoise:            "ninja",
 		GenesisActiveSet:    5,
 		BlockCacheSize:      20,
 		SyncRequestTimeout:  2000,
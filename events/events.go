@@ -17,6 +17,7 @@ const (
 	EventRewardReceived
 	EventCreatedBlock
 	EventCreatedAtx
+	EventHareEquivocation
 )
 
 // publisher is the event publisher singleton.
@@ -184,3 +185,17 @@ type AtxCreated struct {
 func (AtxCreated) GetChannel() ChannelID {
 	return EventCreatedAtx
 }
+
+// HareEquivocation signals that an identity signed two different hare messages for the same layer and round
+type HareEquivocation struct {
+	NodeID string
+	Layer  uint64
+	Round  int32
+	First  []byte
+	Second []byte
+}
+
+// GetChannel gets the message type which means on which this message should be sent
+func (HareEquivocation) GetChannel() ChannelID {
+	return EventHareEquivocation
+}
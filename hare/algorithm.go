@@ -33,6 +33,25 @@ type Rolacle interface {
 	IsIdentityActiveOnConsensusView(edID string, layer types.LayerID) (bool, error)
 }
 
+// weightedRolacle is implemented by oracles that can give an identity several seats in the committee of a round.
+type weightedRolacle interface {
+	EligibilityCount(layer types.LayerID, round int32, committeeSize int, id types.NodeID, sig []byte) (uint16, error)
+}
+
+// eligibilityCount returns the number of seats the identity holds in the committee of the round. Oracles that do not
+// weight identities give a single seat to every eligible identity.
+func eligibilityCount(oracle Rolacle, layer types.LayerID, round int32, committeeSize int, id types.NodeID, sig []byte) (uint16, error) {
+	if wo, ok := oracle.(weightedRolacle); ok {
+		return wo.EligibilityCount(layer, round, committeeSize, id, sig)
+	}
+
+	res, err := oracle.Eligible(layer, round, committeeSize, id, sig)
+	if err != nil || !res {
+		return 0, err
+	}
+	return 1, nil
+}
+
 // NetworkService provides the registration and broadcast abilities in the network.
 type NetworkService interface {
 	RegisterGossipProtocol(protocol string, prio priorityq.Priority) chan service.GossipMessage
@@ -51,11 +70,13 @@ const (
 )
 
 // procReport is the termination report of the CP.
-// It consists of the layer id, the set we agreed on (if available) and a flag to indicate if the CP completed.
+// It consists of the layer id, the set we agreed on (if available), a flag to indicate if the CP completed and the
+// commit certificate of the set (if available).
 type procReport struct {
-	id        instanceID
-	set       *Set
-	completed bool
+	id          instanceID
+	set         *Set
+	completed   bool
+	certificate *certificate
 }
 
 func (cpo procReport) ID() instanceID {
@@ -70,8 +91,13 @@ func (cpo procReport) Completed() bool {
 	return cpo.completed
 }
 
+func (cpo procReport) Certificate() *certificate {
+	return cpo.certificate
+}
+
 func (proc *consensusProcess) report(completed bool) {
-	proc.terminationReport <- procReport{proc.instanceID, proc.s, completed}
+	proc.markTerminated(completed)
+	proc.terminationReport <- procReport{proc.instanceID, proc.s, completed, proc.certificate}
 }
 
 var _ TerminationOutput = (*procReport)(nil)
@@ -114,6 +140,9 @@ func (m *Msg) Bytes() []byte {
 	return w.Bytes()
 }
 
+// errInvalidSignature is returned by newMsg when no public key can be extracted from the signature of the message
+var errInvalidSignature = errors.New("invalid signature")
+
 // Upon receiving a protocol's message, we try to build the full message.
 // The full message consists of the original message and the extracted public key.
 // An extracted public key is considered valid if it represents an active identity for a consensus view.
@@ -122,7 +151,7 @@ func newMsg(hareMsg *Message, querier StateQuerier) (*Msg, error) {
 	pubKey, err := ed25519.ExtractPublicKey(hareMsg.InnerMsg.Bytes(), hareMsg.Sig)
 	if err != nil {
 		log.With().Error("newMsg construction failed: could not extract public key", log.Err(err), log.Int("sig len", len(hareMsg.Sig)))
-		return nil, err
+		return nil, errInvalidSignature
 	}
 	// query if identity is active
 	pub := signing.NewPublicKey(pubKey)
@@ -170,6 +199,11 @@ type consensusProcess struct {
 	notifySent        bool            // flag to set in case a notification had already been sent by this instance
 	mTracker          *msgsTracker    // tracks valid messages
 	terminating       bool
+	eligibilityCount  uint16           // the number of seats we hold in the committee of the current round
+	clock             roundClock       // ends the rounds
+	roundStart        time.Time        // the time the current round started
+	timing            *roundTiming     // optional, collects the lateness of the received messages
+	monitor           *instanceMonitor // publishes the state of the process
 }
 
 // newConsensusProcess creates a new consensus process instance.
@@ -192,6 +226,8 @@ func newConsensusProcess(cfg config.Config, instanceID instanceID, s *Set, oracl
 		pending:           make(map[string]*Msg, cfg.N),
 		Log:               logger,
 		mTracker:          msgsTracker,
+		monitor:           newInstanceMonitor(instanceID, nid),
+		clock:             wallClock{},
 	}
 	proc.validator = newSyntaxContextValidator(signing, cfg.F+1, proc.statusValidator(), stateQuerier, layersPerEpoch, ev, msgsTracker, logger)
 
@@ -251,8 +287,12 @@ func (proc *consensusProcess) eventLoop() {
 		log.Int("Hare-N", proc.cfg.N), log.Int("f", proc.cfg.F), log.String("duration", (time.Duration(proc.cfg.RoundDuration)*time.Second).String()),
 		log.LayerID(uint64(proc.instanceID)), log.Int("exp_leaders", proc.cfg.ExpectedLeaders), log.String("current_set", proc.s.String()), log.Int("set_size", proc.s.Size()))
 
-	// start the timer
-	timer := time.NewTimer(time.Duration(proc.cfg.RoundDuration) * time.Second)
+	proc.updateStatus()
+
+	// start the round ticker, the first tick ends the pre-round
+	proc.roundStart = time.Now()
+	ticker := proc.clock.newTicker(time.Duration(proc.cfg.RoundDuration) * time.Second)
+	defer ticker.stop()
 
 	// check participation and send message
 	go func() {
@@ -275,7 +315,7 @@ PreRound:
 		// listen to pre-round Messages
 		case msg := <-proc.inbox:
 			proc.handleMessage(msg)
-		case <-timer.C:
+		case <-ticker.c():
 			break PreRound
 		case <-proc.CloseChannel():
 			return
@@ -291,8 +331,6 @@ PreRound:
 
 	// start first iteration
 	proc.onRoundBegin()
-	ticker := time.NewTicker(time.Duration(proc.cfg.RoundDuration) * time.Second)
-	defer ticker.Stop()
 
 	for {
 		select {
@@ -301,7 +339,7 @@ PreRound:
 			if proc.terminating {
 				return
 			}
-		case <-ticker.C: // next round event
+		case <-ticker.c(): // next round event
 			proc.onRoundEnd()
 			proc.advanceToNextRound()
 
@@ -350,8 +388,10 @@ func (proc *consensusProcess) onEarlyMessage(m *Msg) {
 // the very first step of handling a message
 func (proc *consensusProcess) handleMessage(m *Msg) {
 	// Note: instanceID is already verified by the broker
+	defer proc.updateCounters()
 
 	proc.With().Debug("Received message", log.String("msg_type", m.InnerMsg.Type.String()))
+	proc.observeLateness(m)
 
 	// validate context
 	err := proc.validator.ContextuallyValidateMessage(m, proc.k)
@@ -436,6 +476,14 @@ func (proc *consensusProcess) sendMessage(msg *Msg) bool {
 		return false
 	}
 
+	// the signer failed, peers would reject the message and penalize us for relaying it
+	if len(msg.Sig) == 0 {
+		proc.With().Error("not sending unsigned message",
+			log.String("msg_type", msg.InnerMsg.Type.String()),
+			log.Uint64("layer_id", uint64(proc.instanceID)))
+		return false
+	}
+
 	if err := proc.network.Broadcast(protoName, msg.Bytes()); err != nil {
 		proc.Error("Could not broadcast round message ", err.Error())
 		return false
@@ -474,6 +522,7 @@ func (proc *consensusProcess) onRoundEnd() {
 // advances the state to the next round
 func (proc *consensusProcess) advanceToNextRound() {
 	proc.k++
+	proc.roundStart = time.Now()
 	if proc.k >= 4 && proc.k%4 == 0 {
 		proc.Event().Warning("Starting new iteration", log.Int32("round_counter", proc.k),
 			log.Uint64("layer_id", uint64(proc.instanceID)))
@@ -626,6 +675,8 @@ func (proc *consensusProcess) onRoundBegin() {
 		proc.Panic("Current round out of bounds. Expected: 0-3, Found: ", proc.currentRound())
 	}
 
+	proc.updateStatus()
+
 	if len(proc.pending) == 0 { // no pending messages
 		return
 	}
@@ -644,7 +695,7 @@ func (proc *consensusProcess) initDefaultBuilder(s *Set) (*messageBuilder, error
 		proc.Error("Could not initialize default builder err=%v", err)
 		return nil, err
 	}
-	builder.SetRoleProof(proof)
+	builder.SetRoleProof(proof).SetEligibilityCount(proc.eligibilityCount)
 
 	return builder, nil
 }
@@ -699,7 +750,8 @@ func (proc *consensusProcess) processNotifyMsg(msg *Msg) {
 	}
 
 	// enough notifications, should terminate
-	proc.s = s // update to the agreed set
+	proc.s = s                           // update to the agreed set
+	proc.certificate = msg.InnerMsg.Cert // the notification carries the commit certificate of the agreed set
 	proc.Event().Info("Consensus process terminated", log.String("current_set", proc.s.String()),
 		log.LayerID(uint64(proc.instanceID)), log.Int("set_size", proc.s.Size()))
 	proc.report(completed)
@@ -749,9 +801,16 @@ func (proc *consensusProcess) endOfStatusRound() {
 		log.Uint64("layer_id", uint64(proc.instanceID)), log.String("analyze_duration", time.Since(before).String()))
 }
 
-// checks if we should participate in the current round
+// checks if we should participate in the current round and records the result
 // returns true if we should participate, false otherwise
 func (proc *consensusProcess) shouldParticipate() bool {
+	k := proc.k
+	res := proc.checkParticipation()
+	proc.monitor.recordEligibility(k, res)
+	return res
+}
+
+func (proc *consensusProcess) checkParticipation() bool {
 	// query if identity is active
 	res, err := proc.oracle.IsIdentityActiveOnConsensusView(proc.signing.PublicKey().String(), types.LayerID(proc.instanceID))
 	if err != nil {
@@ -787,13 +846,14 @@ func (proc *consensusProcess) currentRole() role {
 		return passive
 	}
 
-	res, err := proc.oracle.Eligible(types.LayerID(proc.instanceID), proc.k, expectedCommitteeSize(proc.k, proc.cfg.N, proc.cfg.ExpectedLeaders), proc.nid, proof)
+	count, err := eligibilityCount(proc.oracle, types.LayerID(proc.instanceID), proc.k, expectedCommitteeSize(proc.k, proc.cfg.N, proc.cfg.ExpectedLeaders), proc.nid, proof)
 	if err != nil {
 		proc.With().Error("Could not check our eligibility", log.Err(err))
 		return passive
 	}
 
-	if res { // eligible
+	proc.eligibilityCount = count
+	if count > 0 { // eligible
 		if proc.currentRound() == proposalRound {
 			return leader
 		}
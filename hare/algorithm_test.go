@@ -309,6 +309,19 @@ func TestConsensusProcess_InitDefaultBuilder(t *testing.T) {
 	assert.Equal(t, instanceID(builder.inner.InstanceID), proc.instanceID)
 }
 
+func TestConsensusProcess_EligibilityCount(t *testing.T) {
+	proc := generateConsensusProcess(t)
+	oracle := &mockWeightedRolacle{mockRolacle{MockStateQuerier: MockStateQuerier{true, nil}}, 3}
+	proc.oracle = oracle
+	assert.True(t, proc.shouldParticipate())
+	builder, err := proc.initDefaultBuilder(proc.s)
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(3), builder.Build().InnerMsg.EligibilityCount)
+
+	oracle.count = 0
+	assert.False(t, proc.shouldParticipate())
+}
+
 func TestConsensusProcess_isEligible(t *testing.T) {
 	proc := generateConsensusProcess(t)
 	oracle := &mockRolacle{MockStateQuerier: MockStateQuerier{true, nil}}
@@ -345,6 +358,13 @@ func TestConsensusProcess_sendMessage(t *testing.T) {
 	net.err = nil
 	b = proc.sendMessage(msg)
 	r.True(b)
+	r.Equal(2, net.count)
+
+	// a message the signer failed to sign is not sent
+	msg.Sig = nil
+	b = proc.sendMessage(msg)
+	r.False(b)
+	r.Equal(2, net.count)
 }
 
 func TestConsensusProcess_procPre(t *testing.T) {
@@ -478,13 +498,13 @@ func TestConsensusProcess_onEarlyMessage(t *testing.T) {
 }
 
 func TestProcOutput_Id(t *testing.T) {
-	po := procReport{instanceID1, nil, false}
+	po := procReport{instanceID1, nil, false, nil}
 	assert.Equal(t, po.ID(), instanceID1)
 }
 
 func TestProcOutput_Set(t *testing.T) {
 	es := NewDefaultEmptySet()
-	po := procReport{instanceID1, es, false}
+	po := procReport{instanceID1, es, false, nil}
 	assert.True(t, es.Equals(po.Set()))
 }
 
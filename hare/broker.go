@@ -2,6 +2,7 @@ package hare
 
 import (
 	"errors"
+	"github.com/spacemeshos/go-spacemesh/hare/metrics"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/p2p/service"
 	"github.com/spacemeshos/go-spacemesh/priorityq"
@@ -10,6 +11,27 @@ import (
 
 const inboxCapacity = 1024 // inbox size per instance
 
+const validationBatchSize = 64 // max number of queued messages whose eligibility is validated together
+
+const (
+	defaultFutureLayers        = 1  // by default only messages of the next layer are buffered
+	defaultMaxPendingPerSender = 10 // an honest participant sends a message per round
+)
+
+// the reasons for dropping a received message, reported in the metrics
+const (
+	dropMalformed    = "malformed"
+	dropInactive     = "inactive"
+	dropBadSignature = "bad_signature"
+	dropIneligible   = "ineligible"
+	dropLate         = "late"
+	dropFuture       = "future"
+	dropNotSynced    = "not_synced"
+	dropInstanceFull = "instance_full"
+	dropSenderLimit  = "sender_limit"
+	dropConflicting  = "conflicting"
+)
+
 type startInstanceError error
 
 type syncStateFunc func() bool
@@ -18,6 +40,11 @@ type validator interface {
 	Validate(m *Msg) bool
 }
 
+// batchValidator is implemented by validators that can prepare the eligibility validation of several messages at once.
+type batchValidator interface {
+	prevalidate(msgs []*Msg)
+}
+
 // Closer adds the ability to close objects.
 type Closer struct {
 	channel chan struct{} // closeable go routines listen to this channel
@@ -52,12 +79,18 @@ type Broker struct {
 	inbox          chan service.GossipMessage
 	syncState      map[instanceID]bool
 	outbox         map[instanceID]chan *Msg
-	pending        map[instanceID][]*Msg // the buffer of pending messages for the next layer
-	tasks          chan func()           // a channel to synchronize tasks (register/unregister) with incoming messages handling
-	latestLayer    instanceID            // the latest layer to attempt register (successfully or unsuccessfully)
+	pending        map[instanceID][]*Msg         // the buffer of pending messages for the next layers
+	pendingSenders map[instanceID]map[string]int // the number of pending messages of every sender
+	futureLayers   instanceID                    // the number of layers after the latest one to buffer messages for
+	maxPending     int                           // max number of pending messages of a sender in a layer
+	tasks          chan func()                   // a channel to synchronize tasks (register/unregister) with incoming messages handling
+	latestLayer    instanceID                    // the latest layer to attempt register (successfully or unsuccessfully)
 	isStarted      bool
 	minDeleted     instanceID
-	limit          int // max number of consensus processes simultaneously
+	limit          int       // max number of consensus processes simultaneously
+	recorder       *recorder // optional, records the received messages
+	equivocations  *equivocationTracker
+	onEquivocation func(*Equivocation) // optional, called with the evidence of detected equivocations
 }
 
 func newBroker(networkService NetworkService, eValidator validator, stateQuerier StateQuerier, syncState syncStateFunc, layersPerEpoch uint16, limit int, closer Closer, log log.Log) *Broker {
@@ -72,10 +105,14 @@ func newBroker(networkService NetworkService, eValidator validator, stateQuerier
 		syncState:      make(map[instanceID]bool),
 		outbox:         make(map[instanceID]chan *Msg),
 		pending:        make(map[instanceID][]*Msg),
+		pendingSenders: make(map[instanceID]map[string]int),
+		futureLayers:   defaultFutureLayers,
+		maxPending:     defaultMaxPendingPerSender,
 		tasks:          make(chan func()),
 		latestLayer:    0,
 		minDeleted:     0,
 		limit:          limit,
+		equivocations:  newEquivocationTracker(),
 	}
 }
 
@@ -122,7 +159,7 @@ func (b *Broker) validate(m *Message) error {
 		}
 
 		// early msg
-		if msgInstID == b.latestLayer+1 {
+		if msgInstID <= b.latestLayer+b.futureLayers {
 			return errEarlyMsg
 		}
 
@@ -144,93 +181,250 @@ func (b *Broker) eventLoop() {
 	for {
 		select {
 		case msg := <-b.inbox:
-			if msg == nil {
-				b.With().Error("Broker message validation failed: called with nil",
-					log.Uint64("latest_layer", uint64(b.latestLayer)))
-				continue
+			b.handleMessages(b.drainInbox(msg))
+		case task := <-b.tasks:
+			task()
+		case <-b.CloseChannel():
+			b.Warning("Broker exiting")
+			if b.recorder != nil {
+				b.recorder.close()
 			}
+			return
+		}
+	}
+}
 
-			hareMsg, err := MessageFromBuffer(msg.Bytes())
-			if err != nil {
-				b.Error("Could not build message err=%v", err)
-				continue
-			}
+// drainInbox returns the message along with the messages already queued behind it, up to the validation batch size.
+func (b *Broker) drainInbox(msg service.GossipMessage) []service.GossipMessage {
+	msgs := []service.GossipMessage{msg}
+	for len(msgs) < validationBatchSize {
+		select {
+		case m := <-b.inbox:
+			msgs = append(msgs, m)
+		default:
+			return msgs
+		}
+	}
+	return msgs
+}
 
-			if hareMsg.InnerMsg == nil {
-				b.With().Error("Broker message validation failed",
-					log.Err(errNilInner), log.Uint64("latest_layer", uint64(b.latestLayer)))
-				continue
-			}
+// inboundMsg is a received message that passed the checks preceding its eligibility validation.
+type inboundMsg struct {
+	gossip  service.GossipMessage
+	msg     *Msg
+	isEarly bool
+}
 
-			msgInstID := hareMsg.InnerMsg.InstanceID
-			// TODO: fix metrics
-			//metrics.MessageTypeCounter.With("type_id", hareMsg.InnerMsg.Type.String(), "layer", strconv.FormatUint(uint64(msgInstID), 10), "reporter", "brokerHandler").Add(1)
-			isEarly := false
-			if err := b.validate(hareMsg); err != nil {
-				if err != errEarlyMsg {
-					// not early, validation failed
-					b.With().Debug("Broker received a message to a CP that is not registered",
-						log.Err(err),
-						log.Uint64("msg_layer_id", uint64(msgInstID)),
-						log.Uint64("latest_layer", uint64(b.latestLayer)))
-					continue
-				}
+// handleMessages validates and dispatches the received messages in order. When many messages arrive together, as in
+// the status and commit rounds of large committees, the eligibility of all of them is prepared at once before they are
+// validated one by one.
+func (b *Broker) handleMessages(msgs []service.GossipMessage) {
+	inbound := make([]inboundMsg, 0, len(msgs))
+	for _, msg := range msgs {
+		if in, ok := b.prepare(msg); ok {
+			inbound = append(inbound, in)
+		}
+	}
 
-				b.With().Debug("early message detected",
-					log.Err(err),
-					log.Uint64("msg_layer_id", uint64(msgInstID)),
-					log.Uint64("latest_layer", uint64(b.latestLayer)))
+	if bv, ok := b.eValidator.(batchValidator); ok && len(inbound) > 1 {
+		batch := make([]*Msg, len(inbound))
+		for i, in := range inbound {
+			batch[i] = in.msg
+		}
+		bv.prevalidate(batch)
+	}
 
-				isEarly = true
-			}
+	for _, in := range inbound {
+		b.dispatch(in)
+	}
+}
 
-			// the msg is either early or has instance
+// prepare decodes the message and checks that it targets a registered or an early layer.
+func (b *Broker) prepare(msg service.GossipMessage) (inboundMsg, bool) {
+	if msg == nil {
+		b.With().Error("Broker message validation failed: called with nil",
+			log.Uint64("latest_layer", uint64(b.latestLayer)))
+		return inboundMsg{}, false
+	}
 
-			// create msg
-			iMsg, err := newMsg(hareMsg, b.stateQuerier)
-			if err != nil {
-				b.Warning("Message validation failed: could not construct msg err=%v", err)
-				continue
-			}
+	hareMsg, err := MessageFromBuffer(msg.Bytes())
+	if err != nil {
+		b.Error("Could not build message err=%v", err)
+		b.reject(msg, dropMalformed)
+		return inboundMsg{}, false
+	}
 
-			// validate msg
-			if !b.eValidator.Validate(iMsg) {
-				b.Warning("Message validation failed: eValidator returned false %v", hareMsg)
-				continue
-			}
+	if hareMsg.InnerMsg == nil {
+		b.With().Error("Broker message validation failed",
+			log.Err(errNilInner), log.Uint64("latest_layer", uint64(b.latestLayer)))
+		b.reject(msg, dropMalformed)
+		return inboundMsg{}, false
+	}
 
-			// validation passed, report
-			msg.ReportValidation(protoName)
+	if b.recorder != nil {
+		b.recorder.recordMessage(RecordReceived, hareMsg)
+	}
 
-			if isEarly {
-				if _, exist := b.pending[msgInstID]; !exist { // create buffer if first msg
-					b.pending[msgInstID] = make([]*Msg, 0)
-				}
-				// we want to write all buffered messages to a chan with InboxCapacity len
-				// hence, we limit the buffer for pending messages
-				if len(b.pending[msgInstID]) == inboxCapacity {
-					b.Error("Reached %v pending messages. Ignoring message for layer %v sent from %v",
-						inboxCapacity, msgInstID, iMsg.PubKey.ShortString())
-					continue
-				}
-				b.pending[msgInstID] = append(b.pending[msgInstID], iMsg)
-				continue
-			}
+	msgInstID := hareMsg.InnerMsg.InstanceID
+	// TODO: fix metrics
+	//metrics.MessageTypeCounter.With("type_id", hareMsg.InnerMsg.Type.String(), "layer", strconv.FormatUint(uint64(msgInstID), 10), "reporter", "brokerHandler").Add(1)
+	isEarly := false
+	if err := b.validate(hareMsg); err != nil {
+		if err != errEarlyMsg {
+			// not early, validation failed
+			b.With().Debug("Broker received a message to a CP that is not registered",
+				log.Err(err),
+				log.Uint64("msg_layer_id", uint64(msgInstID)),
+				log.Uint64("latest_layer", uint64(b.latestLayer)))
+			b.drop(dropReason(err))
+			return inboundMsg{}, false
+		}
 
-			// has instance, just send
-			out, exist := b.outbox[msgInstID]
-			if !exist {
-				b.Panic("broker should have had an instance for layer %v", msgInstID)
-			}
-			out <- iMsg
+		b.With().Debug("early message detected",
+			log.Err(err),
+			log.Uint64("msg_layer_id", uint64(msgInstID)),
+			log.Uint64("latest_layer", uint64(b.latestLayer)))
 
-		case task := <-b.tasks:
-			task()
-		case <-b.CloseChannel():
-			b.Warning("Broker exiting")
-			return
+		isEarly = true
+	}
+
+	// the msg is either early or has instance
+
+	// create msg
+	iMsg, err := newMsg(hareMsg, b.stateQuerier)
+	if err != nil {
+		b.Warning("Message validation failed: could not construct msg err=%v", err)
+		if err == errInvalidSignature {
+			b.reject(msg, dropBadSignature)
+		} else {
+			// the activeness of the sender is checked against the local state, the relaying peer is not at fault
+			b.drop(dropInactive)
+		}
+		return inboundMsg{}, false
+	}
+
+	// bound the buffer before the expensive eligibility validation
+	if isEarly && !b.canBuffer(iMsg) {
+		return inboundMsg{}, false
+	}
+
+	return inboundMsg{msg, iMsg, isEarly}, true
+}
+
+// dispatch validates the eligibility of the message and sends it to its consensus process, or buffers it if it is
+// early.
+func (b *Broker) dispatch(in inboundMsg) {
+	msg, iMsg := in.gossip, in.msg
+	msgInstID := iMsg.InnerMsg.InstanceID
+
+	// the earlier messages of the batch may have filled the buffer
+	if in.isEarly && !b.canBuffer(iMsg) {
+		return
+	}
+
+	// validate msg
+	if !b.eValidator.Validate(iMsg) {
+		b.Warning("Message validation failed: eValidator returned false %v", iMsg.Message)
+		// the eligibility is checked against the local state and oracle, the relaying peer is not at fault
+		b.drop(dropIneligible)
+		return
+	}
+
+	// validation passed, report
+	msg.ReportValidation(protoName)
+
+	evidence, ok := b.equivocations.track(iMsg)
+	if evidence != nil {
+		b.With().Warning("Broker detected conflicting messages",
+			log.String("sender_id", iMsg.PubKey.ShortString()),
+			log.Uint64("msg_layer_id", uint64(msgInstID)), log.Int32("round", iMsg.InnerMsg.K))
+		if b.onEquivocation != nil {
+			b.onEquivocation(evidence)
 		}
 	}
+	if !ok {
+		b.drop(dropConflicting)
+		return
+	}
+
+	if in.isEarly {
+		b.buffer(iMsg)
+		return
+	}
+
+	// has instance, just send
+	out, exist := b.outbox[msgInstID]
+	if !exist {
+		b.Panic("broker should have had an instance for layer %v", msgInstID)
+	}
+	out <- iMsg
+}
+
+// drop records a message dropped for the reason.
+func (b *Broker) drop(reason string) {
+	metrics.DroppedMessages.With("reason", reason).Add(1)
+}
+
+// reject drops a malformed or badly signed message and reports it to the p2p layer, which penalizes the peer that
+// relayed it. It must not be used for messages that fail checks depending on the local state.
+func (b *Broker) reject(msg service.GossipMessage, reason string) {
+	b.drop(reason)
+	msg.ReportInvalid(protoName)
+}
+
+func dropReason(err error) string {
+	switch err {
+	case errUnregistered, errRegistration:
+		return dropLate
+	case errNotSynced:
+		return dropNotSynced
+	default:
+		return dropFuture
+	}
+}
+
+// canBuffer returns true if there is room in the buffer of the message's layer for another message of its sender.
+func (b *Broker) canBuffer(m *Msg) bool {
+	id := m.InnerMsg.InstanceID
+	// we want to write all buffered messages to a chan with InboxCapacity len
+	// hence, we limit the buffer for pending messages
+	if len(b.pending[id]) >= inboxCapacity {
+		b.With().Warning("Reached max pending messages, ignoring message",
+			log.Int("max_pending", inboxCapacity),
+			log.Uint64("msg_layer_id", uint64(id)),
+			log.String("sender_id", m.PubKey.ShortString()))
+		b.drop(dropInstanceFull)
+		return false
+	}
+	if b.pendingSenders[id][m.PubKey.String()] >= b.maxPending {
+		b.With().Debug("Reached max pending messages of sender, ignoring message",
+			log.Int("max_pending", b.maxPending),
+			log.Uint64("msg_layer_id", uint64(id)),
+			log.String("sender_id", m.PubKey.ShortString()))
+		b.drop(dropSenderLimit)
+		return false
+	}
+	return true
+}
+
+// buffer keeps an early message until the consensus process of its layer is registered.
+func (b *Broker) buffer(m *Msg) {
+	id := m.InnerMsg.InstanceID
+	senders, exist := b.pendingSenders[id]
+	if !exist {
+		senders = make(map[string]int)
+		b.pendingSenders[id] = senders
+	}
+	senders[m.PubKey.String()]++
+	b.pending[id] = append(b.pending[id], m)
+	metrics.PendingMessages.Add(1)
+}
+
+// deletePending drops the buffered messages of the layer.
+func (b *Broker) deletePending(id instanceID) {
+	metrics.PendingMessages.Add(-float64(len(b.pending[id])))
+	delete(b.pending, id)
+	delete(b.pendingSenders, id)
 }
 
 func (b *Broker) updateLatestLayer(id instanceID) {
@@ -246,6 +440,8 @@ func (b *Broker) cleanOldLayers() {
 	for i := b.minDeleted + 1; i < b.latestLayer; i++ {
 		if _, exist := b.outbox[i]; !exist { // unregistered
 			delete(b.syncState, i) // clean sync state
+			b.deletePending(i)     // the layer was never registered
+			b.equivocations.forget(i)
 			b.minDeleted++
 		} else { // encountered first still running layer
 			break
@@ -302,8 +498,8 @@ func (b *Broker) Register(id instanceID) (chan *Msg, error) {
 				for _, mOut := range pendingForInstance {
 					b.outbox[id] <- mOut
 				}
-				delete(b.pending, id)
 			}
+			b.deletePending(id)
 
 			resErr <- nil
 			resCh <- b.outbox[id]
@@ -333,7 +529,11 @@ func (b *Broker) Unregister(id instanceID) {
 	wg.Add(1)
 	b.tasks <- func() {
 		delete(b.outbox, id) // delete matching outbox
+		b.equivocations.forget(id)
 		b.cleanOldLayers()
+		if b.recorder != nil {
+			b.recorder.closeLayer(id)
+		}
 		b.Info("Unregistered layer %v ", id)
 		wg.Done()
 	}
@@ -207,6 +207,10 @@ func (mgm *mockGossipMessage) ReportValidation(protocol string) {
 	mgm.vComp <- service.NewMessageValidation(mgm.sender, nil, "")
 }
 
+func (mgm *mockGossipMessage) ReportInvalid(protocol string) {
+	mgm.vComp <- service.NewInvalidMessageValidation(mgm.sender, nil, "")
+}
+
 func newMockGossipMsg(msg *Message) *mockGossipMessage {
 	return &mockGossipMessage{&Msg{msg, nil}, p2pcrypto.NewRandomPubkey(), make(chan service.MessageValidation, 10)}
 }
@@ -474,6 +478,131 @@ func Test_validate(t *testing.T) {
 	b.syncState[2] = true
 	e = b.validate(m.Message)
 	r.Nil(e)
+
+	m.InnerMsg.InstanceID = 4
+	e = b.validate(m.Message)
+	r.EqualError(e, errFutureMsg.Error())
+
+	b.futureLayers = 3
+	m.InnerMsg.InstanceID = 5
+	e = b.validate(m.Message)
+	r.EqualError(e, errEarlyMsg.Error())
+
+	m.InnerMsg.InstanceID = 6
+	e = b.validate(m.Message)
+	r.EqualError(e, errFutureMsg.Error())
+}
+
+func TestBroker_PendingLimits(t *testing.T) {
+	r := require.New(t)
+	b := buildBroker(service.NewSimulator().NewNode(), t.Name())
+	b.maxPending = 2
+
+	flooder := signing.NewEdSigner()
+	for i := 0; i < 2; i++ {
+		m := BuildStatusMsg(flooder, NewSetFromValues(value1))
+		m.InnerMsg.InstanceID = instanceID1
+		r.True(b.canBuffer(m))
+		b.buffer(m)
+	}
+	m := BuildStatusMsg(flooder, NewSetFromValues(value1))
+	m.InnerMsg.InstanceID = instanceID1
+	r.False(b.canBuffer(m))
+
+	// the limit is per layer
+	m.InnerMsg.InstanceID = instanceID2
+	r.True(b.canBuffer(m))
+
+	other := BuildStatusMsg(signing.NewEdSigner(), NewSetFromValues(value1))
+	other.InnerMsg.InstanceID = instanceID1
+	r.True(b.canBuffer(other))
+	b.buffer(other)
+
+	// the buffer of a layer is bounded regardless of the senders
+	b.maxPending = inboxCapacity + 1
+	for len(b.pending[instanceID1]) < inboxCapacity {
+		b.buffer(other)
+	}
+	r.False(b.canBuffer(other))
+
+	b.Start()
+	c, err := b.Register(instanceID1)
+	r.NoError(err)
+	r.Len(c, inboxCapacity)
+	r.True(b.Synced(instanceID1)) // synchronize with the event loop
+	r.Empty(b.pending)
+	r.Empty(b.pendingSenders)
+}
+
+func TestBroker_ReportInvalid(t *testing.T) {
+	r := require.New(t)
+	b := buildBroker(service.NewSimulator().NewNode(), t.Name())
+	b.eValidator = &mockEligibilityValidator{false}
+	b.Start()
+	_, err := b.Register(instanceID1)
+	r.NoError(err)
+
+	// the eligibility depends on the local state, ineligible messages are dropped without penalizing the relayer
+	msg := newMockGossipMsg(BuildStatusMsg(signing.NewEdSigner(), NewSetFromValues(value1)).Message)
+	b.inbox <- msg
+	r.True(b.Synced(instanceID1)) // synchronize with the event loop
+	r.Empty(msg.ValidationCompletedChan())
+
+	bad := BuildStatusMsg(signing.NewEdSigner(), NewSetFromValues(value1)).Message
+	bad.Sig = []byte{1, 2, 3}
+	msg = newMockGossipMsg(bad)
+	b.inbox <- msg
+	select {
+	case res := <-msg.ValidationCompletedChan():
+		r.True(res.Invalid())
+	case <-time.After(2 * time.Second):
+		r.FailNow("invalid message was not reported")
+	}
+}
+
+type mockBatchValidator struct {
+	mockEligibilityValidator
+	batches [][]*Msg
+}
+
+func (mbv *mockBatchValidator) prevalidate(msgs []*Msg) {
+	mbv.batches = append(mbv.batches, msgs)
+}
+
+func TestBroker_HandleMessages(t *testing.T) {
+	r := require.New(t)
+	b := buildBroker(service.NewSimulator().NewNode(), t.Name())
+	bv := &mockBatchValidator{mockEligibilityValidator: mockEligibilityValidator{true}}
+	b.eValidator = bv
+	b.maxPending = 2
+	b.inbox = make(chan service.GossipMessage, 2*validationBatchSize)
+
+	flooder := signing.NewEdSigner()
+	for i := 0; i < 2*validationBatchSize; i++ {
+		sgn := flooder
+		if i < 2 {
+			sgn = signing.NewEdSigner()
+		}
+		m := BuildStatusMsg(sgn, NewSetFromValues(value1))
+		m.InnerMsg.InstanceID = instanceID1 // early
+		b.inbox <- newMockGossipMsg(m.Message)
+	}
+
+	// the queued messages are handled in batches
+	batch := b.drainInbox(<-b.inbox)
+	r.Len(batch, validationBatchSize)
+	b.handleMessages(batch)
+	r.Len(bv.batches, 1)
+	r.Len(bv.batches[0], validationBatchSize)
+
+	// the buffer limit of the sender holds within the batch
+	r.Len(b.pending[instanceID1], 4)
+	r.Equal(2, b.pendingSenders[instanceID1][flooder.PublicKey().String()])
+
+	// the messages dropped before the eligibility validation are not prepared
+	b.handleMessages(b.drainInbox(<-b.inbox))
+	r.Len(bv.batches, 1)
+	r.Empty(b.inbox)
 }
 
 func TestBroker_clean(t *testing.T) {
@@ -53,14 +53,15 @@ type aggregatedMessages struct {
 
 // innerMessage is the actual set of fields that describe a message in the Hare protocol.
 type innerMessage struct {
-	Type       messageType
-	InstanceID instanceID
-	K          int32 // the round counter
-	Ki         int32
-	Values     []types.BlockID     // the set S. optional for commit InnerMsg in a certificate
-	RoleProof  []byte              // role is implicit by InnerMsg type, this is the proof
-	Svp        *aggregatedMessages // optional. only for proposal Messages
-	Cert       *certificate        // optional
+	Type             messageType
+	InstanceID       instanceID
+	K                int32 // the round counter
+	Ki               int32
+	Values           []types.BlockID     // the set S. optional for commit InnerMsg in a certificate
+	RoleProof        []byte              // role is implicit by InnerMsg type, this is the proof
+	EligibilityCount uint16              // the number of committee seats the sender holds in the round
+	Svp              *aggregatedMessages // optional. only for proposal Messages
+	Cert             *certificate        // optional
 }
 
 // Bytes returns the message as bytes.
@@ -86,9 +87,9 @@ type messageBuilder struct {
 }
 
 // newMessageBuilder returns a new, empty message builder.
-// One should not assume any values are pre-set.
+// One should not assume any values are pre-set, except for the eligibility count which defaults to a single seat.
 func newMessageBuilder() *messageBuilder {
-	m := &messageBuilder{&Msg{&Message{}, nil}, &innerMessage{}}
+	m := &messageBuilder{&Msg{&Message{}, nil}, &innerMessage{EligibilityCount: 1}}
 	m.msg.InnerMsg = m.inner
 
 	return m
@@ -148,6 +149,11 @@ func (builder *messageBuilder) SetRoleProof(sig []byte) *messageBuilder {
 	return builder
 }
 
+func (builder *messageBuilder) SetEligibilityCount(count uint16) *messageBuilder {
+	builder.inner.EligibilityCount = count
+	return builder
+}
+
 func (builder *messageBuilder) SetSVP(svp *aggregatedMessages) *messageBuilder {
 	builder.inner.Svp = svp
 	return builder
@@ -0,0 +1,35 @@
+package hare
+
+import (
+	"time"
+)
+
+// roundClock creates the tickers that end the rounds of the consensus processes. The hare uses the wall clock, tests
+// may replace it to advance the rounds manually.
+type roundClock interface {
+	newTicker(d time.Duration) roundTicker
+}
+
+// roundTicker delivers an event at the end of every round.
+type roundTicker interface {
+	c() <-chan time.Time
+	stop()
+}
+
+type wallClock struct{}
+
+func (wallClock) newTicker(d time.Duration) roundTicker {
+	return wallTicker{time.NewTicker(d)}
+}
+
+type wallTicker struct {
+	t *time.Ticker
+}
+
+func (w wallTicker) c() <-chan time.Time {
+	return w.t.C
+}
+
+func (w wallTicker) stop() {
+	w.t.Stop()
+}
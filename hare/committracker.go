@@ -12,7 +12,8 @@ type commitTracker struct {
 	seenSenders map[string]bool // tracks seen senders
 	commits     []*Message      // tracks Set->Commits
 	proposedSet *Set            // follows the set who has max number of commits
-	threshold   int             // the number of required commits
+	threshold   int             // the required eligibility count of the commits
+	weight      int             // the total eligibility count of the tracked commits
 }
 
 func newCommitTracker(threshold int, expectedSize int, proposedSet *Set) *commitTracker {
@@ -49,6 +50,7 @@ func (ct *commitTracker) OnCommit(msg *Msg) {
 
 	// add msg
 	ct.commits = append(ct.commits, msg.Message)
+	ct.weight += int(msg.InnerMsg.EligibilityCount)
 }
 
 // HasEnoughCommits returns true if the tracker can build a certificate, false otherwise.
@@ -57,11 +59,12 @@ func (ct *commitTracker) HasEnoughCommits() bool {
 		return false
 	}
 
-	return len(ct.commits) >= ct.threshold
+	return ct.weight >= ct.threshold
 }
 
+// CommitCount returns the total eligibility count of the tracked commits.
 func (ct *commitTracker) CommitCount() int {
-	return len(ct.commits)
+	return ct.weight
 }
 
 // BuildCertificate returns a certificate if there are enough commits, nil otherwise
@@ -74,7 +77,7 @@ func (ct *commitTracker) BuildCertificate() *certificate {
 	c := &certificate{}
 	c.Values = ct.proposedSet.ToSlice()
 	c.AggMsgs = &aggregatedMessages{}
-	c.AggMsgs.Messages = ct.commits[:ct.threshold]
+	c.AggMsgs.Messages = ct.commits // tracking stops once there are enough commits
 
 	// optimize msg size by setting Values to nil
 	for _, commit := range c.AggMsgs.Messages {
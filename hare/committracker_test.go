@@ -61,3 +61,22 @@ func TestCommitTracker_BuildCertificate(t *testing.T) {
 	assert.Nil(t, cert.AggMsgs.Messages[0].InnerMsg.Values)
 	assert.Nil(t, cert.AggMsgs.Messages[1].InnerMsg.Values)
 }
+
+func TestCommitTracker_Weighted(t *testing.T) {
+	s := NewSetFromValues(value1)
+	tracker := newCommitTracker(5, 5, s)
+	sgn := generateSigning(t)
+	tracker.OnCommit(withEligibilityCount(sgn, BuildCommitMsg(sgn, s), 3))
+	assert.False(t, tracker.HasEnoughCommits())
+	assert.Equal(t, 3, tracker.CommitCount())
+
+	sgn = generateSigning(t)
+	tracker.OnCommit(withEligibilityCount(sgn, BuildCommitMsg(sgn, s), 2))
+	assert.True(t, tracker.HasEnoughCommits())
+	assert.Equal(t, 5, tracker.CommitCount())
+
+	// commits are not tracked once the threshold is reached
+	tracker.OnCommit(BuildCommitMsg(generateSigning(t), s))
+	cert := tracker.BuildCertificate()
+	assert.Equal(t, 2, len(cert.AggMsgs.Messages))
+}
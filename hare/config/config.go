@@ -1,5 +1,14 @@
 package config
 
+import "fmt"
+
+// EpochTiming is a round timing parameter set the network agreed to switch to from an epoch on.
+type EpochTiming struct {
+	Epoch         uint64 `mapstructure:"epoch"`              // the first epoch the parameters are in effect
+	RoundDuration int    `mapstructure:"round-duration-sec"` // the duration of a single round
+	WakeupDelta   int    `mapstructure:"wakeup-delta"`       // the wakeup delta after tick
+}
+
 // Config is the configuration of the Hare.
 type Config struct {
 	N               int `mapstructure:"hare-committee-size"`     // total number of active parties
@@ -8,11 +17,38 @@ type Config struct {
 	WakeupDelta     int `mapstructure:"hare-wakeup-delta"`       // the wakeup delta after tick
 	ExpectedLeaders int `mapstructure:"hare-exp-leaders"`        // the expected number of leaders
 	SuperHare       bool
-	LimitIterations int `mapstructure:"hare-limit-iterations"` // limit on number of iterations
-	LimitConcurrent int `mapstructure:"hare-limit-concurrent"` // limit number of concurrent CPs
+	LimitIterations int    `mapstructure:"hare-limit-iterations"` // limit on number of iterations
+	LimitConcurrent int    `mapstructure:"hare-limit-concurrent"` // limit number of concurrent CPs
+	RecordDir       string `mapstructure:"hare-record-dir"`       // directory of the message recordings, empty disables recording
+	FutureLayers    int    `mapstructure:"hare-future-layers"`    // number of layers ahead of the latest one to buffer messages for
+	MaxPendingMsgs  int    `mapstructure:"hare-max-pending-msgs"` // max number of buffered messages per sender and layer
+
+	TimingPercentile float64       `mapstructure:"hare-timing-percentile"` // fraction of the messages the recommended round duration lets arrive in time
+	EpochTimings     []EpochTiming `mapstructure:"hare-epoch-timings"`     // round timings by epoch, override RoundDuration and WakeupDelta
+
+	RecordedOutputs string `mapstructure:"hare-recorded-outputs"` // file of captured outputs to replay instead of running the consensus
 }
 
 // DefaultConfig returns the default configuration for the hare.
 func DefaultConfig() Config {
-	return Config{10, 5, 2, 10, 5, false, 1000, 5}
+	return Config{10, 5, 2, 10, 5, false, 1000, 5, "", 1, 10, 0.95, nil, ""}
+}
+
+// ValidateEpochTimings checks that every epoch timing has a positive round duration, a non negative wakeup delta and
+// an epoch no other timing is set for.
+func (c Config) ValidateEpochTimings() error {
+	epochs := make(map[uint64]struct{}, len(c.EpochTimings))
+	for _, t := range c.EpochTimings {
+		if t.RoundDuration <= 0 {
+			return fmt.Errorf("round duration of epoch %v timing must be positive, got %v", t.Epoch, t.RoundDuration)
+		}
+		if t.WakeupDelta < 0 {
+			return fmt.Errorf("wakeup delta of epoch %v timing must not be negative, got %v", t.Epoch, t.WakeupDelta)
+		}
+		if _, exist := epochs[t.Epoch]; exist {
+			return fmt.Errorf("duplicate timing for epoch %v", t.Epoch)
+		}
+		epochs[t.Epoch] = struct{}{}
+	}
+	return nil
 }
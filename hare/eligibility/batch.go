@@ -0,0 +1,61 @@
+package eligibility
+
+import (
+	"crypto/rand"
+	"fmt"
+	"github.com/spacemeshos/amcl"
+	"github.com/spacemeshos/amcl/BLS381"
+)
+
+// batchCoefficientBytes is the size of the random coefficients the signatures of a batch are combined with. A batch
+// with an invalid signature passes with probability of about 2^-64.
+const batchCoefficientBytes = 8
+
+// blsHash hashes the message to a point of G1 the same way BLS381 does when signing.
+func blsHash(msg []byte) *BLS381.ECP {
+	sh := amcl.NewSHA3(amcl.SHA3_SHAKE256)
+	for _, b := range msg {
+		sh.Process(b)
+	}
+	var hm [BLS381.BFS]byte
+	sh.Shake(hm[:], BLS381.BFS)
+	return BLS381.ECP_mapit(hm[:])
+}
+
+// VerifyBLSBatch verifies that every BLS381 signature of the batch signs msg with the public key of the same index.
+// The signatures and the public keys are combined with random coefficients, so the batch costs a single double pairing
+// instead of one per signature. A failed batch does not tell which of the signatures are invalid.
+func VerifyBLSBatch(msg []byte, sigs, pubs [][]byte) (bool, error) {
+	if len(sigs) != len(pubs) {
+		return false, fmt.Errorf("batch verify failed: %v signatures but %v public keys", len(sigs), len(pubs))
+	}
+	if len(sigs) == 0 {
+		return true, nil
+	}
+
+	sigSum := BLS381.NewECP()
+	pubSum := BLS381.NewECP2()
+	var coefficient [BLS381.BFS]byte
+	for i := range sigs {
+		if uint(len(pubs[i])) != 4*BLS381.MODBYTES {
+			return false, fmt.Errorf("batch verify failed: len of public key should be %v but is %v", 4*BLS381.MODBYTES, len(pubs[i]))
+		}
+		if uint(len(sigs[i])) != 2*BLS381.MODBYTES+1 {
+			return false, fmt.Errorf("batch verify failed: len of sig should be %v but is %v", 2*BLS381.MODBYTES+1, len(sigs[i]))
+		}
+		if _, err := rand.Read(coefficient[len(coefficient)-batchCoefficientBytes:]); err != nil {
+			return false, err
+		}
+		coefficient[len(coefficient)-1] |= 1 // never zero
+		r := BLS381.FromBytes(coefficient[:])
+		// the plain multiplication is proportional to the length of the short coefficient
+		sigSum.Add(BLS381.ECP_fromBytes(sigs[i]).Mul(r))
+		pubSum.Add(BLS381.ECP2_fromBytes(pubs[i]).Mul(r))
+	}
+
+	// e(sum(r*sig), G) == e(H(msg), sum(r*pub))
+	neg := BLS381.NewECP()
+	neg.Sub(sigSum)
+	v := BLS381.Fexp(BLS381.Ate2(BLS381.ECP2_generator(), neg, pubSum, blsHash(msg)))
+	return v.Isunity(), nil
+}
@@ -0,0 +1,67 @@
+package eligibility
+
+import (
+	"github.com/spacemeshos/amcl/BLS381"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func blsBatch(t testing.TB, msg []byte, n int) ([][]byte, [][]byte) {
+	rng := BLS381.DefaultSeed()
+	sigs := make([][]byte, n)
+	pubs := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		pr, pu := BLS381.GenKeyPair(rng)
+		sig, err := BLS381.NewBlsSigner(pr).Sign(msg)
+		require.NoError(t, err)
+		sigs[i], pubs[i] = sig, pu
+	}
+	return sigs, pubs
+}
+
+func TestVerifyBLSBatch(t *testing.T) {
+	r := require.New(t)
+	msg := []byte("layer 7 round 2")
+	sigs, pubs := blsBatch(t, msg, 5)
+
+	ok, err := VerifyBLSBatch(msg, sigs, pubs)
+	r.NoError(err)
+	r.True(ok)
+
+	ok, err = VerifyBLSBatch(msg, sigs[:1], pubs[:1])
+	r.NoError(err)
+	r.True(ok)
+
+	ok, err = VerifyBLSBatch([]byte("another message"), sigs, pubs)
+	r.NoError(err)
+	r.False(ok)
+
+	// a single signature of another identity fails the batch
+	swapped := append([][]byte(nil), sigs...)
+	swapped[1], swapped[2] = swapped[2], swapped[1]
+	ok, err = VerifyBLSBatch(msg, swapped, pubs)
+	r.NoError(err)
+	r.False(ok)
+
+	_, err = VerifyBLSBatch(msg, sigs, pubs[:4])
+	r.Error(err)
+	_, err = VerifyBLSBatch(msg, [][]byte{sigs[0][1:]}, pubs[:1])
+	r.Error(err)
+}
+
+func BenchmarkVerifyBLSBatch(b *testing.B) {
+	msg := []byte("layer 7 round 2")
+	sigs, pubs := blsBatch(b, msg, 64)
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			VerifyBLSBatch(msg, sigs, pubs)
+		}
+	})
+	b.Run("single", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := range sigs {
+				BLS381.Verify2(msg, sigs[j], pubs[j])
+			}
+		}
+	})
+}
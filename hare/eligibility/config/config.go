@@ -4,9 +4,10 @@ package config
 type Config struct {
 	ConfidenceParam uint64 `mapstructure:"eligibility-confidence-param"` // the confidence interval
 	EpochOffset     int    `mapstructure:"eligibility-epoch-offset"`     // the offset from the beginning of the epoch
+	EqualWeight     bool   `mapstructure:"eligibility-equal-weight"`     // give all active identities the same weight regardless of their committed space
 }
 
 // DefaultConfig returns the default configuration for the oracle package.
 func DefaultConfig() Config {
-	return Config{25, 0}
+	return Config{25, 0, false}
 }
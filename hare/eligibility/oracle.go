@@ -18,18 +18,22 @@ import (
 const vrfMsgCacheSize = 20 // numRounds per layer is <= 2. numConcurrentLayers<=10 (typically <=2) so numRounds*numConcurrentLayers <= 2*10 = 20 is a good upper bound
 const activesCacheSize = 5 // we don't expect to handle more than two layers concurrently
 
+const verifiedCacheSize = 8192 // role proofs of about five rounds of two concurrent layers of a large committee
+const minBatchSplit = 4        // a failed batch smaller than that is left to be verified proof by proof
+
 var (
 	errGenesis            = errors.New("no data about active nodes for genesis")
 	errNoContextualBlocks = errors.New("no contextually valid blocks")
+	errZeroActiveSet      = errors.New("active set size is zero")
 )
 
 type valueProvider interface {
 	Value(layer types.LayerID) (uint32, error)
 }
 
-// a func to retrieve the active set size for the provided layer
+// a func to retrieve the active set for the provided layer along with the space committed by every active identity
 // this func is assumed to be cpu intensive and hence we cache its results
-type activeSetFunc func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error)
+type activeSetFunc func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]uint64, error)
 
 type signer interface {
 	Sign(msg []byte) ([]byte, error)
@@ -39,9 +43,25 @@ type goodBlocksProvider interface {
 	ContextuallyValidBlock(layer types.LayerID) (map[types.BlockID]struct{}, error)
 }
 
+// MalfeasanceProvider reports identities that are proven to have acted maliciously.
+type MalfeasanceProvider interface {
+	IsMalicious(edID string) bool
+}
+
 // a function to verify the message with the signature and its public key.
 type verifierFunc = func(msg, sig, pub []byte) (bool, error)
 
+// a function to verify that every signature signs the message with the public key of the same index.
+type batchVerifierFunc = func(msg []byte, sigs, pubs [][]byte) (bool, error)
+
+// verifiedKey identifies a role proof that passed verification.
+type verifiedKey struct {
+	layer types.LayerID
+	round int32
+	pub   string
+	sig   string
+}
+
 // Oracle is the hare eligibility oracle
 type Oracle struct {
 	lock                 sync.Mutex
@@ -49,11 +69,15 @@ type Oracle struct {
 	getActiveSet         activeSetFunc
 	vrfSigner            signer
 	vrfVerifier          verifierFunc
+	batchVerifier        batchVerifierFunc // optional
+	verified             addGet            // role proofs that passed a batch verification
 	layersPerEpoch       uint16
 	vrfMsgCache          addGet
 	activesCache         addGet
 	genesisActiveSetSize int
 	blocksProvider       goodBlocksProvider
+	malfeasance          MalfeasanceProvider // optional
+	spaceUnit            uint64              // the size of a space unit in bytes, zero to weight by raw space
 	cfg                  eCfg.Config
 	log.Log
 }
@@ -105,12 +129,18 @@ func New(beacon valueProvider, activeSetFunc activeSetFunc, vrfVerifier verifier
 		log.Panic("Could not create lru cache err=%v", e)
 	}
 
+	vc, e := lru.New(verifiedCacheSize)
+	if e != nil {
+		log.Panic("Could not create lru cache err=%v", e)
+	}
+
 	return &Oracle{
 		beacon:               beacon,
 		getActiveSet:         activeSetFunc,
 		vrfVerifier:          vrfVerifier,
 		vrfSigner:            vrfSigner,
 		layersPerEpoch:       layersPerEpoch,
+		verified:             vc,
 		vrfMsgCache:          vmc,
 		activesCache:         ac,
 		genesisActiveSetSize: genesisActiveSet,
@@ -120,6 +150,27 @@ func New(beacon valueProvider, activeSetFunc activeSetFunc, vrfVerifier verifier
 	}
 }
 
+// SetMalfeasanceProvider sets the provider of malicious identities, which are never eligible.
+// It must be called before the oracle is used.
+func (o *Oracle) SetMalfeasanceProvider(p MalfeasanceProvider) {
+	o.malfeasance = p
+}
+
+// SetBatchVerifier sets the verifier VerifyBatch verifies the role proofs of a round with.
+func (o *Oracle) SetBatchVerifier(verifier batchVerifierFunc) {
+	o.batchVerifier = verifier
+}
+
+// SetSpaceUnit sets the size of a space unit in bytes, the weight of an identity is the number of space units it
+// committed. It must be called before the oracle is used.
+func (o *Oracle) SetSpaceUnit(unit uint64) error {
+	if unit == 0 {
+		return errors.New("the space unit must be positive")
+	}
+	o.spaceUnit = unit
+	return nil
+}
+
 type vrfMessage struct {
 	Beacon uint32
 	Round  int32
@@ -181,37 +232,157 @@ func (o *Oracle) activeSetSize(layer types.LayerID) (uint32, error) {
 	return uint32(len(actives)), nil
 }
 
+func (o *Oracle) spaceUnits(space uint64) uint64 {
+	if o.spaceUnit == 0 {
+		return space
+	}
+	return space / o.spaceUnit
+}
+
+// activeWeight returns the weight of the identity and the total weight of the active set on the consensus view of
+// the provided layer.
+func (o *Oracle) activeWeight(layer types.LayerID, edID string) (uint64, uint64, error) {
+	actives, err := o.actives(layer)
+	if err != nil {
+		if err == errGenesis { // we are in genesis, all ids have the same weight
+			return 1, uint64(o.genesisActiveSetSize), nil
+		}
+
+		o.With().Error("activeWeight erred while calling actives func", log.Err(err), log.LayerID(uint64(layer)))
+		return 0, 0, err
+	}
+
+	total := uint64(0)
+	for _, space := range actives {
+		total += o.spaceUnits(space)
+	}
+
+	return o.spaceUnits(actives[edID]), total, nil
+}
+
 // Eligible checks if ID is eligible on the given Layer where msg is the VRF message, sig is the role proof and assuming commSize as the expected committee size
 func (o *Oracle) Eligible(layer types.LayerID, round int32, committeeSize int, id types.NodeID, sig []byte) (bool, error) {
+	count, err := o.EligibilityCount(layer, round, committeeSize, id, sig)
+	return count > 0, err
+}
+
+// EligibilityCount returns the number of committee seats ID holds on the given Layer and round, where sig is the role
+// proof and committeeSize is the expected committee size. Every space unit committed by the identity is a candidate
+// for a seat, unless the oracle is configured to give all active identities an equal weight of a single seat.
+func (o *Oracle) EligibilityCount(layer types.LayerID, round int32, committeeSize int, id types.NodeID, sig []byte) (uint16, error) {
+	if o.malfeasance != nil && o.malfeasance.IsMalicious(id.Key) {
+		o.With().Info("eligibility: identity is proven malicious", id, layer)
+		return 0, nil
+	}
+
 	msg, err := o.buildVRFMessage(layer, round)
 	if err != nil {
 		o.Error("eligibility: could not build VRF message")
-		return false, err
+		return 0, err
+	}
+
+	// validate message, unless it passed a batch verification
+	if _, verified := o.verified.Get(verifiedKey{layer, round, string(id.VRFPublicKey), string(sig)}); !verified {
+		res, err := o.vrfVerifier(msg, sig, id.VRFPublicKey)
+		if err != nil {
+			o.Error("eligibility: VRF verification failed: %v", err)
+			return 0, err
+		}
+		if !res {
+			o.With().Info("eligibility: a node did not pass VRF signature verification",
+				id,
+				layer)
+			return 0, nil
+		}
+	}
+
+	if o.cfg.EqualWeight {
+		return o.equalWeightCount(layer, round, committeeSize, id, sig)
 	}
 
-	// validate message
-	res, err := o.vrfVerifier(msg, sig, id.VRFPublicKey)
+	weight, totalWeight, err := o.activeWeight(layer, id.Key)
 	if err != nil {
-		o.Error("eligibility: VRF verification failed: %v", err)
-		return false, err
+		return 0, err
+	}
+
+	// require totalWeight > 0
+	if totalWeight == 0 {
+		o.Warning("eligibility: active set weight is zero")
+		return 0, errZeroActiveSet
 	}
-	if !res {
-		o.With().Info("eligibility: a node did not pass VRF signature verification",
+
+	count := binomialCount(weight, float64(committeeSize)/float64(totalWeight), vrfUniform(sig), uint64(committeeSize))
+	if count == 0 {
+		o.With().Info("eligibility: node did not pass VRF eligibility threshold",
 			id,
+			log.Int("committee_size", committeeSize),
+			log.Uint64("weight", weight),
+			log.Uint64("active_set_weight", totalWeight),
+			log.Int32("round", round),
 			layer)
-		return false, nil
+		return 0, nil
+	}
+	if count > math.MaxUint16 {
+		count = math.MaxUint16
 	}
 
+	return uint16(count), nil
+}
+
+// VerifyBatch verifies the role proofs of the identities in the round at once, if a batch verifier is set. The proofs
+// that pass are not verified again when the eligibility of their identities is queried. A failed batch is split in
+// halves to find the valid proofs, and the proofs left unverified are verified one by one when queried.
+func (o *Oracle) VerifyBatch(layer types.LayerID, round int32, ids []types.NodeID, sigs [][]byte) {
+	if o.batchVerifier == nil || len(ids) == 0 || len(ids) != len(sigs) {
+		return
+	}
+
+	msg, err := o.buildVRFMessage(layer, round)
+	if err != nil {
+		o.With().Error("eligibility: could not build VRF message for batch verification", layer, log.Err(err))
+		return
+	}
+
+	pubs := make([][]byte, len(ids))
+	for i, id := range ids {
+		pubs[i] = id.VRFPublicKey
+	}
+	o.verifyBatch(layer, round, msg, sigs, pubs)
+}
+
+func (o *Oracle) verifyBatch(layer types.LayerID, round int32, msg []byte, sigs, pubs [][]byte) {
+	res, err := o.batchVerifier(msg, sigs, pubs)
+	if err != nil {
+		o.With().Warning("eligibility: batch VRF verification failed", layer, log.Err(err))
+		return
+	}
+	if res {
+		for i := range sigs {
+			o.verified.Add(verifiedKey{layer, round, string(pubs[i]), string(sigs[i])}, struct{}{})
+		}
+		return
+	}
+	if len(sigs) < minBatchSplit {
+		return
+	}
+	half := len(sigs) / 2
+	o.verifyBatch(layer, round, msg, sigs[:half], pubs[:half])
+	o.verifyBatch(layer, round, msg, sigs[half:], pubs[half:])
+}
+
+// equalWeightCount returns a single seat if the role proof passes the threshold of an active set in which every
+// identity has the same weight.
+func (o *Oracle) equalWeightCount(layer types.LayerID, round int32, committeeSize int, id types.NodeID, sig []byte) (uint16, error) {
 	// get active set size
 	activeSetSize, err := o.activeSetSize(layer)
 	if err != nil {
-		return false, err
+		return 0, err
 	}
 
 	// require activeSetSize > 0
 	if activeSetSize == 0 {
 		o.Warning("eligibility: active set size is zero")
-		return false, errors.New("active set size is zero")
+		return 0, errZeroActiveSet
 	}
 
 	// calc hash & check threshold
@@ -225,11 +396,48 @@ func (o *Oracle) Eligible(layer types.LayerID, round int32, committeeSize int, i
 			log.Uint32("active_set_size", activeSetSize),
 			log.Int32("round", round),
 			layer)
-		return false, nil
+		return 0, nil
 	}
 
 	// lower or equal
-	return true, nil
+	return 1, nil
+}
+
+// vrfUniform maps the role proof to a number uniformly distributed in [0, 1)
+func vrfUniform(sig []byte) float64 {
+	sha := sha256.Sum256(sig)
+	return float64(binary.LittleEndian.Uint32(sha[:4])) / (math.MaxUint32 + 1.0)
+}
+
+// binomialCount returns the number of successes out of n trials with success probability p that matches x, which is
+// uniformly distributed in [0, 1), by inverting the binomial CDF. The terms are computed in log space so that large
+// weights do not underflow. The count is capped at limit, so that the number of terms doesn't grow with n when
+// rounding keeps the CDF below an x close to 1.
+func binomialCount(n uint64, p float64, x float64, limit uint64) uint64 {
+	max := n
+	if max > limit {
+		max = limit
+	}
+	if p >= 1 {
+		return max
+	}
+	if p <= 0 || max == 0 {
+		return 0
+	}
+
+	lp, lq := math.Log(p), math.Log1p(-p)
+	ln, _ := math.Lgamma(float64(n) + 1)
+	cdf := 0.0
+	for k := uint64(0); k < max; k++ {
+		lk, _ := math.Lgamma(float64(k) + 1)
+		lnk, _ := math.Lgamma(float64(n-k) + 1)
+		cdf += math.Exp(ln - lk - lnk + float64(k)*lp + float64(n-k)*lq)
+		if x < cdf {
+			return k
+		}
+	}
+
+	return max
 }
 
 // Proof returns the role proof for the current Layer & Round
@@ -249,8 +457,8 @@ func (o *Oracle) Proof(layer types.LayerID, round int32) ([]byte, error) {
 	return sig, nil
 }
 
-// Returns a map of all active nodes in the specified layer id
-func (o *Oracle) actives(layer types.LayerID) (map[string]struct{}, error) {
+// Returns a map of all active nodes in the specified layer id to their committed space
+func (o *Oracle) actives(layer types.LayerID) (map[string]uint64, error) {
 	sl := roundedSafeLayer(layer, types.LayerID(o.cfg.ConfidenceParam), o.layersPerEpoch, types.LayerID(o.cfg.EpochOffset))
 	safeEp := sl.GetEpoch(o.layersPerEpoch)
 
@@ -266,7 +474,7 @@ func (o *Oracle) actives(layer types.LayerID) (map[string]struct{}, error) {
 	// check cache
 	if val, exist := o.activesCache.Get(safeEp); exist {
 		o.lock.Unlock()
-		return val.(map[string]struct{}), nil
+		return val.(map[string]uint64), nil
 	}
 
 	// build a map of all blocks on the current layer
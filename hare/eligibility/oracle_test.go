@@ -51,7 +51,7 @@ type mockActiveSetProvider struct {
 	size int
 }
 
-func (m *mockActiveSetProvider) ActiveSet(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
+func (m *mockActiveSetProvider) ActiveSet(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]uint64, error) {
 	return createMapWithSize(m.size), nil
 }
 
@@ -172,9 +172,34 @@ func TestOracle_IsEligible(t *testing.T) {
 	assert.False(t, res)
 
 	o.getActiveSet = (&mockActiveSetProvider{10}).ActiveSet
-	res, err = o.Eligible(types.LayerID(50), 1, 10, types.NodeID{}, []byte{})
+	res, err = o.Eligible(types.LayerID(50), 1, 10, types.NodeID{Key: "1"}, []byte{})
 	assert.Nil(t, err)
 	assert.True(t, res)
+
+	// identities out of the active set have no weight
+	res, err = o.Eligible(types.LayerID(50), 1, 10, types.NodeID{Key: "abc"}, []byte{})
+	assert.Nil(t, err)
+	assert.False(t, res)
+}
+
+type mockMalfeasance map[string]struct{}
+
+func (m mockMalfeasance) IsMalicious(edID string) bool {
+	_, ok := m[edID]
+	return ok
+}
+
+func TestOracle_MaliciousNotEligible(t *testing.T) {
+	o := New(&mockValueProvider{1, nil}, (&mockActiveSetProvider{10}).ActiveSet, buildVerifier(true, nil), nil, 10, genActive, mockBlocksProvider{}, cfg, log.NewDefault(t.Name()))
+	o.SetMalfeasanceProvider(mockMalfeasance{"2": {}})
+
+	res, err := o.Eligible(types.LayerID(50), 1, 10, types.NodeID{Key: "1"}, []byte{})
+	assert.Nil(t, err)
+	assert.True(t, res)
+
+	res, err = o.Eligible(types.LayerID(50), 1, 10, types.NodeID{Key: "2"}, []byte{})
+	assert.Nil(t, err)
+	assert.False(t, res)
 }
 
 func Test_safeLayer(t *testing.T) {
@@ -227,7 +252,7 @@ type mockBufferedActiveSetProvider struct {
 	size map[types.EpochID]int
 }
 
-func (m *mockBufferedActiveSetProvider) ActiveSet(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
+func (m *mockBufferedActiveSetProvider) ActiveSet(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]uint64, error) {
 	v, ok := m.size[epoch]
 	if !ok {
 		return createMapWithSize(0), errors.New("no instance")
@@ -236,10 +261,10 @@ func (m *mockBufferedActiveSetProvider) ActiveSet(epoch types.EpochID, blocks ma
 	return createMapWithSize(v), nil
 }
 
-func createMapWithSize(n int) map[string]struct{} {
-	m := make(map[string]struct{})
+func createMapWithSize(n int) map[string]uint64 {
+	m := make(map[string]uint64)
 	for i := 0; i < n; i++ {
-		m[strconv.Itoa(i)] = struct{}{}
+		m[strconv.Itoa(i)] = 1
 	}
 
 	return m
@@ -260,7 +285,7 @@ func Test_ActiveSetSize(t *testing.T) {
 	assertActiveSetSize(t, o, 5, l+20)
 
 	// create error
-	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
+	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]uint64, error) {
 
 		return createMapWithSize(5), errors.New("fake err")
 	}
@@ -323,13 +348,13 @@ func TestOracle_Eligible(t *testing.T) {
 func TestOracle_activeSetSizeCache(t *testing.T) {
 	r := require.New(t)
 	o := New(&mockValueProvider{1, nil}, nil, nil, nil, 5, genActive, mockBlocksProvider{}, cfg, log.NewDefault(t.Name()))
-	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
+	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]uint64, error) {
 		return createMapWithSize(17), nil
 	}
 	v1, e := o.activeSetSize(defSafety + 100)
 	r.NoError(e)
 
-	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
+	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]uint64, error) {
 		return createMapWithSize(19), nil
 	}
 	v2, e := o.activeSetSize(defSafety + 100)
@@ -372,7 +397,7 @@ func TestOracle_actives(t *testing.T) {
 
 	o.blocksProvider = mockBlocksProvider{}
 	mp := createMapWithSize(9)
-	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
+	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]uint64, error) {
 		return mp, nil
 	}
 	o.activesCache = newMockCasher()
@@ -386,7 +411,7 @@ func TestOracle_actives(t *testing.T) {
 		r.True(exist)
 	}
 
-	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
+	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]uint64, error) {
 		return createMapWithSize(9), errFoo
 	}
 	_, err = o.actives(200)
@@ -400,7 +425,7 @@ func TestOracle_concurrentActives(t *testing.T) {
 	mc := newMockCasher()
 	o.activesCache = mc
 	mp := createMapWithSize(9)
-	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
+	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]uint64, error) {
 		return mp, nil
 	}
 
@@ -435,7 +460,7 @@ func TestOracle_activesSafeLayer(t *testing.T) {
 	o.activesCache = newMockCasher()
 	lyr := types.LayerID(10)
 	rsl := roundedSafeLayer(lyr, types.LayerID(o.cfg.ConfidenceParam), o.layersPerEpoch, types.LayerID(o.cfg.EpochOffset))
-	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
+	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]uint64, error) {
 		ep := rsl.GetEpoch(o.layersPerEpoch)
 		r.Equal(ep, epoch)
 		return mp, nil
@@ -455,23 +480,23 @@ func TestOracle_activesSafeLayer(t *testing.T) {
 func TestOracle_IsIdentityActive(t *testing.T) {
 	r := require.New(t)
 	o := New(&mockValueProvider{1, nil}, nil, nil, nil, 5, genActive, mockBlocksProvider{}, cfg, log.NewDefault(t.Name()))
-	mp := make(map[string]struct{})
+	mp := make(map[string]uint64)
 	edid := "11111"
-	mp[edid] = struct{}{}
-	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
+	mp[edid] = 1
+	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]uint64, error) {
 		return mp, nil
 	}
 	v, err := o.IsIdentityActiveOnConsensusView("22222", 1)
 	r.NoError(err)
 	r.True(v)
 
-	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
+	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]uint64, error) {
 		return mp, errFoo
 	}
 	_, err = o.IsIdentityActiveOnConsensusView("22222", 100)
 	r.Equal(errFoo, err)
 
-	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
+	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]uint64, error) {
 		return mp, nil
 	}
 
@@ -486,7 +511,7 @@ func TestOracle_IsIdentityActive(t *testing.T) {
 
 func TestOracle_Eligible2(t *testing.T) {
 	o := New(&mockValueProvider{1, nil}, nil, nil, nil, 5, genActive, mockBlocksProvider{}, cfg, log.NewDefault(t.Name()))
-	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
+	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]uint64, error) {
 		return createMapWithSize(9), errFoo
 	}
 	o.vrfVerifier = func(msg, sig, pub []byte) (bool, error) {
@@ -495,3 +520,125 @@ func TestOracle_Eligible2(t *testing.T) {
 	_, err := o.Eligible(100, 1, 1, types.NodeID{}, []byte{})
 	assert.Equal(t, errFoo, err)
 }
+
+func Test_binomialCount(t *testing.T) {
+	r := require.New(t)
+	r.Equal(uint64(0), binomialCount(0, 0.5, 0.9, 100))
+	r.Equal(uint64(0), binomialCount(10, 0, 0.9, 100))
+	r.Equal(uint64(10), binomialCount(10, 1, 0, 100))
+	r.Equal(uint64(10), binomialCount(10, 2, 0, 100))
+
+	// a single trial succeeds with probability p
+	r.Equal(uint64(0), binomialCount(1, 0.25, 0.7, 100))
+	r.Equal(uint64(1), binomialCount(1, 0.25, 0.8, 100))
+
+	// the count is monotonic in x
+	prev := uint64(0)
+	for x := 0.0; x < 1; x += 0.01 {
+		c := binomialCount(1000, 0.1, x, 1000)
+		r.True(c >= prev)
+		prev = c
+	}
+
+	// large weights do not underflow
+	c := binomialCount(100000, 0.01, 0.5, 100000)
+	r.True(c > 900 && c < 1100)
+
+	// the count is capped, the cap doesn't change the counts below it
+	r.Equal(uint64(5), binomialCount(10, 1, 0, 5))
+	r.Equal(binomialCount(1000, 0.1, 0.5, 1000), binomialCount(1000, 0.1, 0.5, 200))
+	r.Equal(uint64(200), binomialCount(1<<40, 0.5, 0.5, 200))
+}
+
+func TestOracle_EligibilityCount(t *testing.T) {
+	r := require.New(t)
+	o := New(&mockValueProvider{1, nil}, nil, buildVerifier(true, nil), nil, 10, genActive, mockBlocksProvider{}, cfg, log.NewDefault(t.Name()))
+	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]uint64, error) {
+		return map[string]uint64{"small": 3 << 10, "large": 7 << 10}, nil
+	}
+	r.NoError(o.SetSpaceUnit(1 << 10))
+	r.Error(o.SetSpaceUnit(0))
+
+	// the committee is as large as the total weight, every unit holds a seat
+	count, err := o.EligibilityCount(50, 1, 10, types.NodeID{Key: "large"}, []byte{1})
+	r.NoError(err)
+	r.Equal(uint16(7), count)
+	count, err = o.EligibilityCount(50, 1, 10, types.NodeID{Key: "small"}, []byte{1})
+	r.NoError(err)
+	r.Equal(uint16(3), count)
+	count, err = o.EligibilityCount(50, 1, 10, types.NodeID{Key: "none"}, []byte{1})
+	r.NoError(err)
+	r.Equal(uint16(0), count)
+
+	// identities hold a single seat when all have an equal weight
+	o.cfg.EqualWeight = true
+	o.activesCache = newMockCasher()
+	count, err = o.EligibilityCount(50, 1, 10, types.NodeID{Key: "large"}, []byte{1})
+	r.NoError(err)
+	r.Equal(uint16(1), count)
+}
+
+func TestOracle_VerifyBatch(t *testing.T) {
+	r := require.New(t)
+	// the proofs are only valid if they passed the batch verification
+	o := New(&mockValueProvider{1, nil}, (&mockActiveSetProvider{10}).ActiveSet, buildVerifier(false, nil), nil, 10, genActive, mockBlocksProvider{}, cfg, log.NewDefault(t.Name()))
+	o.cfg.EqualWeight = true
+	ids := make([]types.NodeID, 4)
+	sigs := make([][]byte, 4)
+	for i := range ids {
+		ids[i] = types.NodeID{Key: strconv.Itoa(i), VRFPublicKey: []byte{byte(i)}}
+		sigs[i] = []byte{byte(i), 1}
+	}
+	sigs[3] = []byte{3, 0}
+
+	// no batch verifier, nothing is verified
+	o.VerifyBatch(50, 1, ids, sigs)
+	count, err := o.EligibilityCount(50, 1, 10, ids[0], sigs[0])
+	r.NoError(err)
+	r.Equal(uint16(0), count)
+
+	batches := 0
+	o.SetBatchVerifier(func(msg []byte, sigs, pubs [][]byte) (bool, error) {
+		batches++
+		for _, sig := range sigs {
+			if sig[1] == 0 {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	o.VerifyBatch(50, 1, ids, sigs)
+	r.Equal(3, batches) // the failed batch is split in halves
+
+	for i, expected := range []uint16{1, 1, 0, 0} {
+		count, err := o.EligibilityCount(50, 1, 10, ids[i], sigs[i])
+		r.NoError(err)
+		r.Equal(expected, count, i)
+	}
+	// the proof is verified for its round only
+	count, err = o.EligibilityCount(50, 2, 10, ids[0], sigs[0])
+	r.NoError(err)
+	r.Equal(uint16(0), count)
+}
+
+func Test_ExpectedCommitteeWeight(t *testing.T) {
+	commSize := 800
+	actives := make(map[string]uint64)
+	for i := 0; i < 200; i++ {
+		actives[strconv.Itoa(i)] = uint64(1 + i%10)
+	}
+	o := New(&mockValueProvider{1, nil}, nil, buildVerifier(true, nil), &mockSigner{}, 10, genActive, mockBlocksProvider{}, cfg, log.NewDefault(t.Name()))
+	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]uint64, error) {
+		return actives, nil
+	}
+
+	total := 0
+	for id := range actives {
+		count, err := o.EligibilityCount(50, 0, commSize, types.NodeID{Key: id}, genBytes())
+		assert.NoError(t, err)
+		total += int(count)
+	}
+
+	dev := 10 * commSize / 100
+	assert.True(t, total > commSize-dev && total < commSize+dev, "total=%v", total)
+}
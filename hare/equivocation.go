@@ -0,0 +1,196 @@
+package hare
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/spacemeshos/ed25519"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/events"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/signing"
+)
+
+var equivocationPrefix = []byte("e_")
+
+// Equivocation is the evidence that an identity signed two different messages for the same consensus process and round.
+type Equivocation struct {
+	First  *Message
+	Second *Message
+}
+
+var (
+	errEquivocationMissingMsg = errors.New("equivocation evidence is missing a message")
+	errEquivocationSigner     = errors.New("equivocation messages are not signed by the same identity")
+	errEquivocationRound      = errors.New("equivocation messages are not of the same layer and round")
+	errEquivocationIdentical  = errors.New("equivocation messages are identical")
+)
+
+// Layer returns the layer of the equivocating messages.
+func (e *Equivocation) Layer() types.LayerID {
+	return types.LayerID(e.First.InnerMsg.InstanceID)
+}
+
+// Round returns the round of the equivocating messages.
+func (e *Equivocation) Round() int32 {
+	return e.First.InnerMsg.K
+}
+
+// Validate checks that the two messages are different, of the same layer and round and signed by the same identity.
+// It returns the public key of the equivocating identity.
+func (e *Equivocation) Validate() (*signing.PublicKey, error) {
+	if e.First == nil || e.First.InnerMsg == nil || e.Second == nil || e.Second.InnerMsg == nil {
+		return nil, errEquivocationMissingMsg
+	}
+	if e.First.InnerMsg.InstanceID != e.Second.InnerMsg.InstanceID || e.First.InnerMsg.K != e.Second.InnerMsg.K {
+		return nil, errEquivocationRound
+	}
+	first, second := e.First.InnerMsg.Bytes(), e.Second.InnerMsg.Bytes()
+	if bytes.Equal(first, second) {
+		return nil, errEquivocationIdentical
+	}
+	firstPub, err := ed25519.ExtractPublicKey(first, e.First.Sig)
+	if err != nil {
+		return nil, err
+	}
+	secondPub, err := ed25519.ExtractPublicKey(second, e.Second.Sig)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(firstPub, secondPub) {
+		return nil, errEquivocationSigner
+	}
+	return signing.NewPublicKey(firstPub), nil
+}
+
+type roundSender struct {
+	k   int32
+	pub string
+}
+
+// equivocationTracker keeps the first valid message of every sender in every round and detects conflicting ones.
+// It is used by the broker event loop only and is not safe for concurrent use.
+type equivocationTracker struct {
+	seen map[instanceID]map[roundSender]*Message
+}
+
+func newEquivocationTracker() *equivocationTracker {
+	return &equivocationTracker{seen: make(map[instanceID]map[roundSender]*Message)}
+}
+
+// track records the message and returns the evidence if it conflicts with a previous message of the sender in the same
+// round. The second return value is false if the message should be dropped, which is the case for conflicting messages
+// and for any message of a sender that already equivocated in the round.
+func (et *equivocationTracker) track(m *Msg) (*Equivocation, bool) {
+	id := m.InnerMsg.InstanceID
+	round, exist := et.seen[id]
+	if !exist {
+		round = make(map[roundSender]*Message)
+		et.seen[id] = round
+	}
+	key := roundSender{m.InnerMsg.K, m.PubKey.String()}
+	prev, exist := round[key]
+	if !exist {
+		round[key] = m.Message
+		return nil, true
+	}
+	if prev == nil { // already reported
+		return nil, false
+	}
+	if bytes.Equal(prev.InnerMsg.Bytes(), m.InnerMsg.Bytes()) {
+		return nil, true
+	}
+	round[key] = nil
+	return &Equivocation{First: prev, Second: m.Message}, false
+}
+
+func (et *equivocationTracker) forget(id instanceID) {
+	delete(et.seen, id)
+}
+
+func equivocationKey(pub *signing.PublicKey, layer types.LayerID, k int32) []byte {
+	key := append(equivocationIdentityPrefix(pub.String()), layer.Bytes()...)
+	kb := make([]byte, 4)
+	binary.BigEndian.PutUint32(kb, uint32(k))
+	return append(key, kb...)
+}
+
+func equivocationIdentityPrefix(edID string) []byte {
+	return append(append(append([]byte(nil), equivocationPrefix...), edID...), '_')
+}
+
+// reportEquivocation persists the evidence of a detected equivocation and publishes it as an event.
+func (h *Hare) reportEquivocation(e *Equivocation) {
+	pub, err := e.Validate()
+	if err != nil {
+		h.With().Error("invalid equivocation evidence", log.Err(err))
+		return
+	}
+	h.With().Warning("detected hare equivocation",
+		log.String("sender_id", pub.ShortString()), log.LayerID(uint64(e.Layer())), log.Int32("round", e.Round()))
+
+	data, err := types.InterfaceToBytes(e)
+	if err != nil {
+		h.With().Error("failed to encode equivocation evidence", log.Err(err))
+		return
+	}
+	if err := h.store.Put(equivocationKey(pub, e.Layer(), e.Round()), data); err != nil {
+		h.With().Error("failed to persist equivocation evidence", log.Err(err))
+	}
+	h.maliciousMu.Lock()
+	h.malicious[pub.String()] = struct{}{}
+	h.maliciousMu.Unlock()
+
+	first, _ := types.InterfaceToBytes(e.First)
+	second, _ := types.InterfaceToBytes(e.Second)
+	events.Publish(events.HareEquivocation{
+		NodeID: pub.String(),
+		Layer:  uint64(e.Layer()),
+		Round:  e.Round(),
+		First:  first,
+		Second: second,
+	})
+}
+
+// Equivocations returns the persisted evidence of all the equivocations detected by the node.
+func (h *Hare) Equivocations() ([]*Equivocation, error) {
+	it := h.store.Find(equivocationPrefix)
+	var res []*Equivocation
+	for it.Next() {
+		e := &Equivocation{}
+		if err := types.BytesToInterface(it.Value(), e); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// IsMalicious returns true if the node holds equivocation evidence against the identity with the given ed25519 public
+// key. It is a malfeasance input to the eligibility oracle.
+func (h *Hare) IsMalicious(edID string) bool {
+	h.maliciousMu.RLock()
+	defer h.maliciousMu.RUnlock()
+	_, exist := h.malicious[edID]
+	return exist
+}
+
+// loadMalicious fills the set of malicious identities from the persisted evidence.
+func (h *Hare) loadMalicious() error {
+	all, err := h.Equivocations()
+	if err != nil {
+		return err
+	}
+	malicious := make(map[string]struct{}, len(all))
+	for _, e := range all {
+		pub, err := e.Validate()
+		if err != nil {
+			return err
+		}
+		malicious[pub.String()] = struct{}{}
+	}
+	h.maliciousMu.Lock()
+	h.malicious = malicious
+	h.maliciousMu.Unlock()
+	return nil
+}
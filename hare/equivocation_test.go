@@ -0,0 +1,133 @@
+package hare
+
+import (
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/eligibility"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestEquivocation_Validate(t *testing.T) {
+	r := require.New(t)
+	signer := signing.NewEdSigner()
+	first := BuildCommitMsg(signer, NewSetFromValues(value1)).Message
+	second := BuildCommitMsg(signer, NewSetFromValues(value2)).Message
+
+	pub, err := (&Equivocation{First: first, Second: second}).Validate()
+	r.NoError(err)
+	r.Equal(signer.PublicKey().String(), pub.String())
+
+	_, err = (&Equivocation{First: first, Second: first}).Validate()
+	r.Equal(errEquivocationIdentical, err)
+
+	_, err = (&Equivocation{First: first}).Validate()
+	r.Equal(errEquivocationMissingMsg, err)
+
+	other := BuildCommitMsg(signing.NewEdSigner(), NewSetFromValues(value2)).Message
+	_, err = (&Equivocation{First: first, Second: other}).Validate()
+	r.Equal(errEquivocationSigner, err)
+
+	status := BuildStatusMsg(signer, NewSetFromValues(value2)).Message
+	_, err = (&Equivocation{First: first, Second: status}).Validate()
+	r.Equal(errEquivocationRound, err)
+}
+
+func TestEquivocationTracker_Track(t *testing.T) {
+	r := require.New(t)
+	et := newEquivocationTracker()
+	signer := signing.NewEdSigner()
+	first := BuildCommitMsg(signer, NewSetFromValues(value1))
+
+	evidence, ok := et.track(first)
+	r.Nil(evidence)
+	r.True(ok)
+
+	// duplicates are not equivocations
+	evidence, ok = et.track(first)
+	r.Nil(evidence)
+	r.True(ok)
+
+	second := BuildCommitMsg(signer, NewSetFromValues(value2))
+	evidence, ok = et.track(second)
+	r.False(ok)
+	r.Equal(&Equivocation{First: first.Message, Second: second.Message}, evidence)
+
+	// reported once, later messages of the sender in the round are dropped
+	evidence, ok = et.track(BuildCommitMsg(signer, NewSetFromValues(value3)))
+	r.Nil(evidence)
+	r.False(ok)
+
+	evidence, ok = et.track(BuildCommitMsg(signing.NewEdSigner(), NewSetFromValues(value2)))
+	r.Nil(evidence)
+	r.True(ok)
+
+	et.forget(instanceID1)
+	evidence, ok = et.track(second)
+	r.Nil(evidence)
+	r.True(ok)
+}
+
+func TestBroker_Equivocation(t *testing.T) {
+	r := require.New(t)
+	sim := service.NewSimulator()
+	broker := buildBroker(sim.NewNode(), t.Name())
+	reported := make(chan *Equivocation, 1)
+	broker.onEquivocation = func(e *Equivocation) { reported <- e }
+	broker.Start()
+	inbox, err := broker.Register(instanceID1)
+	r.NoError(err)
+
+	signer := signing.NewEdSigner()
+	first := BuildStatusMsg(signer, NewSetFromValues(value1))
+	second := BuildStatusMsg(signer, NewSetFromValues(value1, value2))
+	broker.inbox <- newMockGossipMsg(first.Message)
+	broker.inbox <- newMockGossipMsg(second.Message)
+
+	select {
+	case e := <-reported:
+		r.Equal(first.Message.Sig, e.First.Sig)
+		r.Equal(second.Message.Sig, e.Second.Sig)
+	case <-time.After(2 * time.Second):
+		r.FailNow("equivocation was not reported")
+	}
+	r.Len(inbox, 1)
+	m := <-inbox
+	r.Equal(first.Message.Sig, m.Sig)
+}
+
+func TestHare_ReportEquivocation(t *testing.T) {
+	r := require.New(t)
+	sim := service.NewSimulator()
+	h := createHare(sim.NewNode(), log.NewDefault(t.Name()))
+
+	signer := signing.NewEdSigner()
+	r.False(h.IsMalicious(signer.PublicKey().String()))
+	e := &Equivocation{
+		First:  BuildCommitMsg(signer, NewSetFromValues(value1)).Message,
+		Second: BuildCommitMsg(signer, NewSetFromValues(value2)).Message,
+	}
+	h.reportEquivocation(e)
+
+	// invalid evidence is ignored
+	other := signing.NewEdSigner()
+	h.reportEquivocation(&Equivocation{First: e.First, Second: BuildCommitMsg(other, NewSetFromValues(value2)).Message})
+
+	r.True(h.IsMalicious(signer.PublicKey().String()))
+	r.False(h.IsMalicious(other.PublicKey().String()))
+
+	// the evidence is loaded on restart
+	restarted := New(cfg, sim.NewNode(), signing.NewEdSigner(), types.NodeID{}, validateBlocks, (&mockSyncer{true}).IsSynced, new(orphanMock), eligibility.New(), 10, &mockIDProvider{}, NewMockStateQuerier(), h.store, make(chan types.LayerID), log.NewDefault(t.Name()))
+	r.True(restarted.IsMalicious(signer.PublicKey().String()))
+	r.False(restarted.IsMalicious(other.PublicKey().String()))
+	all, err := h.Equivocations()
+	r.NoError(err)
+	r.Len(all, 1)
+	r.Equal(types.LayerID(instanceID1), all[0].Layer())
+	r.Equal(int32(commitRound), all[0].Round())
+	_, err = all[0].Validate()
+	r.NoError(err)
+}
@@ -3,8 +3,11 @@ package hare
 import (
 	"errors"
 	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
 	"github.com/spacemeshos/go-spacemesh/hare/config"
+	"github.com/spacemeshos/go-spacemesh/hare/metrics"
 	"github.com/spacemeshos/go-spacemesh/log"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -13,7 +16,7 @@ import (
 // LayerBuffer is the number of layer results we keep at a given time.
 const LayerBuffer = 20
 
-type consensusFactory func(cfg config.Config, instanceId instanceID, s *Set, oracle Rolacle, signing Signer, p2p NetworkService, terminationReport chan TerminationOutput) Consensus
+type consensusFactory func(cfg config.Config, instanceId instanceID, s *Set, oracle Rolacle, signing Signer, nid types.NodeID, p2p NetworkService, terminationReport chan TerminationOutput) Consensus
 
 // Consensus represents an item that acts like a consensus process.
 type Consensus interface {
@@ -23,6 +26,7 @@ type Consensus interface {
 
 	Start() error
 	SetInbox(chan *Msg)
+	Status() InstanceStatus
 }
 
 // TerminationOutput represents an output of a consensus process.
@@ -30,6 +34,7 @@ type TerminationOutput interface {
 	ID() instanceID
 	Set() *Set
 	Completed() bool
+	Certificate() *certificate
 }
 
 type layers interface {
@@ -51,10 +56,10 @@ type Hare struct {
 
 	broker *Broker
 
-	sign Signer
+	participantsMu sync.RWMutex
+	participants   []participant
 
-	msh     layers
-	rolacle Rolacle
+	msh layers
 
 	networkDelta time.Duration
 
@@ -66,20 +71,53 @@ type Hare struct {
 	outputChan chan TerminationOutput
 	mu         sync.RWMutex
 	outputs    map[types.LayerID][]types.BlockID
+	store      database.Database // persisted outputs and their certificates
+
+	maliciousMu sync.RWMutex
+	malicious   map[string]struct{} // the identities with persisted equivocation evidence
+
+	ev             roleValidator
+	stateQ         StateQuerier
+	layersPerEpoch uint16
 
 	factory consensusFactory
+	clock   roundClock   // ends the rounds of the consensus processes
+	timing  *roundTiming // collects the lateness of the messages received by the consensus processes
 
 	validate outputValidationFunc
 
-	nid types.NodeID
+	cpsMu     sync.Mutex
+	cps       map[instanceID]*layerCPs
+	instances map[instanceID][]Consensus // the processes of the recent layers, running or terminated
 
 	totalCPs int32
 }
 
+// participant is an identity taking part in the consensus processes. Each participant runs its own consensus process
+// for every layer, with its own signer and eligibility oracle.
+type participant struct {
+	nid    types.NodeID
+	sign   Signer
+	oracle Rolacle
+}
+
+// layerCPs tracks the consensus processes started for a layer by all participants.
+type layerCPs struct {
+	running   int
+	collected bool
+	done      chan struct{}
+}
+
+// ErrParticipantExists is returned when adding a participant that already takes part in the consensus.
+var ErrParticipantExists = errors.New("participant already exists")
+
+// ErrUnknownParticipant is returned when removing a participant that doesn't take part in the consensus.
+var ErrUnknownParticipant = errors.New("unknown participant")
+
 // New returns a new Hare struct.
 func New(conf config.Config, p2p NetworkService, sign Signer, nid types.NodeID, validate outputValidationFunc,
 	syncState syncStateFunc, obp layers, rolacle Rolacle,
-	layersPerEpoch uint16, idProvider identityProvider, stateQ StateQuerier,
+	layersPerEpoch uint16, idProvider identityProvider, stateQ StateQuerier, store database.Database,
 	beginLayer chan types.LayerID, logger log.Log) *Hare {
 	h := new(Hare)
 
@@ -93,12 +131,30 @@ func New(conf config.Config, p2p NetworkService, sign Signer, nid types.NodeID,
 	h.beginLayer = beginLayer
 
 	ev := newEligibilityValidator(rolacle, layersPerEpoch, idProvider, conf.N, conf.ExpectedLeaders, logger)
+	h.ev = ev
+	h.stateQ = stateQ
+	h.layersPerEpoch = layersPerEpoch
 	h.broker = newBroker(p2p, ev, stateQ, syncState, layersPerEpoch, conf.LimitConcurrent, h.Closer, logger)
+	h.broker.onEquivocation = h.reportEquivocation
+	if conf.FutureLayers > 0 {
+		h.broker.futureLayers = instanceID(conf.FutureLayers)
+	}
+	if conf.MaxPendingMsgs > 0 {
+		h.broker.maxPending = conf.MaxPendingMsgs
+	}
+	if conf.RecordDir != "" {
+		rec, err := newRecorder(conf.RecordDir, logger.WithName("recorder"))
+		if err != nil {
+			logger.With().Error("failed to create hare recorder, not recording", log.Err(err))
+		} else {
+			h.broker.recorder = rec
+			h.network = &recordingNetwork{p2p, rec}
+		}
+	}
 
-	h.sign = sign
+	h.participants = []participant{{nid: nid, sign: sign, oracle: rolacle}}
 
 	h.msh = obp
-	h.rolacle = rolacle
 
 	h.networkDelta = time.Duration(conf.WakeupDelta) * time.Second
 	// todo: this should be loaded from global config
@@ -108,18 +164,61 @@ func New(conf config.Config, p2p NetworkService, sign Signer, nid types.NodeID,
 
 	h.outputChan = make(chan TerminationOutput, h.bufferSize)
 	h.outputs = make(map[types.LayerID][]types.BlockID, h.bufferSize) //  we keep results about LayerBuffer past layers
-
-	h.factory = func(conf config.Config, instanceId instanceID, s *Set, oracle Rolacle, signing Signer, p2p NetworkService, terminationReport chan TerminationOutput) Consensus {
-		return newConsensusProcess(conf, instanceId, s, oracle, stateQ, layersPerEpoch, signing, nid, p2p, terminationReport, ev, logger)
+	h.store = store
+	h.malicious = make(map[string]struct{})
+	h.cps = make(map[instanceID]*layerCPs)
+	h.instances = make(map[instanceID][]Consensus)
+
+	h.clock = wallClock{}
+	h.timing = newRoundTiming()
+	h.factory = func(conf config.Config, instanceId instanceID, s *Set, oracle Rolacle, signing Signer, nid types.NodeID, p2p NetworkService, terminationReport chan TerminationOutput) Consensus {
+		proc := newConsensusProcess(conf, instanceId, s, oracle, stateQ, layersPerEpoch, signing, nid, p2p, terminationReport, ev, logger)
+		proc.clock = h.clock
+		proc.timing = h.timing
+		return proc
 	}
 
 	h.validate = validate
 
-	h.nid = nid
+	if err := h.loadMalicious(); err != nil {
+		h.With().Error("failed to load equivocation evidence", log.Err(err))
+	}
 
 	return h
 }
 
+// AddParticipant adds an identity that takes part in consensus processes starting from the next layer.
+func (h *Hare) AddParticipant(nid types.NodeID, sign Signer, rolacle Rolacle) error {
+	h.participantsMu.Lock()
+	defer h.participantsMu.Unlock()
+	for _, p := range h.participants {
+		if p.nid.Key == nid.Key {
+			return ErrParticipantExists
+		}
+	}
+	h.participants = append(h.participants, participant{nid: nid, sign: sign, oracle: rolacle})
+	return nil
+}
+
+// RemoveParticipant stops an identity from taking part in consensus processes starting from the next layer.
+func (h *Hare) RemoveParticipant(nid types.NodeID) error {
+	h.participantsMu.Lock()
+	defer h.participantsMu.Unlock()
+	for i, p := range h.participants {
+		if p.nid.Key == nid.Key {
+			h.participants = append(h.participants[:i:i], h.participants[i+1:]...)
+			return nil
+		}
+	}
+	return ErrUnknownParticipant
+}
+
+func (h *Hare) getParticipants() []participant {
+	h.participantsMu.RLock()
+	defer h.participantsMu.RUnlock()
+	return append([]participant(nil), h.participants...)
+}
+
 func (h *Hare) getLastLayer() types.LayerID {
 	h.layerLock.RLock()
 	lyr := h.lastLayer
@@ -175,6 +274,10 @@ func (h *Hare) collectOutput(output TerminationOutput) error {
 
 	h.msh.HandleValidatedLayer(types.LayerID(id), blocks)
 
+	if err := h.storeOutput(types.LayerID(id), blocks, output.Certificate()); err != nil {
+		h.With().Error("failed to persist hare output", log.LayerID(uint64(id)), log.Err(err))
+	}
+
 	if h.outOfBufferRange(id) {
 		return ErrTooLate
 	}
@@ -199,17 +302,27 @@ func (h *Hare) onTick(id types.LayerID) {
 	}
 
 	h.layerLock.Unlock()
-	h.Debug("hare got tick, sleeping for %v", h.networkDelta)
+	roundDuration, wakeupDelta := h.layerTiming(id)
+	h.reportTiming()
+	h.Debug("hare got tick, sleeping for %v", wakeupDelta)
 
 	if !h.broker.Synced(instanceID(id)) { // if not synced don't start consensus
 		h.With().Info("not starting hare since the node is not synced", log.LayerID(uint64(id)))
 		return
 	}
 
+	participants := h.getParticipants()
+	if len(participants) == 0 {
+		h.With().Info("not starting hare since there are no participants", log.LayerID(uint64(id)))
+		return
+	}
+
 	// call to start the calculation of active set size beforehand
-	go h.rolacle.IsIdentityActiveOnConsensusView(h.nid.Key, id)
+	for _, p := range participants {
+		go p.oracle.IsIdentityActiveOnConsensusView(p.nid.Key, id)
+	}
 
-	ti := time.NewTimer(h.networkDelta)
+	ti := time.NewTimer(wakeupDelta)
 	select {
 	case <-ti.C:
 		break // keep going
@@ -238,19 +351,151 @@ func (h *Hare) onTick(id types.LayerID) {
 		h.Warning("Could not register CP for layer %v on broker err=%v", id, err)
 		return
 	}
-	cp := h.factory(h.config, instID, set, h.rolacle, h.sign, h.network, h.outputChan)
-	cp.SetInbox(c)
-	e := cp.Start()
-	if e != nil {
-		h.Error("Could not start consensus process %v", e.Error())
-		h.broker.Unregister(cp.ID())
-		return
+
+	if h.broker.recorder != nil {
+		h.broker.recorder.recordStart(instID, set)
+	}
+
+	lcps := &layerCPs{running: len(participants), done: make(chan struct{})}
+	h.cpsMu.Lock()
+	h.cps[instID] = lcps
+	h.cpsMu.Unlock()
+
+	// a single participant reads directly from the broker, several participants each get a copy of every message
+	inboxes := []chan *Msg{c}
+	if len(participants) > 1 {
+		inboxes = make([]chan *Msg, len(participants))
+		for i := range inboxes {
+			inboxes[i] = make(chan *Msg, inboxCapacity)
+		}
+		ids := make([]types.NodeID, len(participants))
+		for i, p := range participants {
+			ids[i] = p.nid
+		}
+		go h.fanOut(instID, c, inboxes, ids, lcps.done)
+	}
+
+	conf := h.config
+	conf.RoundDuration = roundDuration
+	for i, p := range participants {
+		cp := h.factory(conf, instID, set.Clone(), p.oracle, p.sign, p.nid, h.network, h.outputChan)
+		cp.SetInbox(inboxes[i])
+		if e := cp.Start(); e != nil {
+			h.Error("Could not start consensus process %v", e.Error())
+			if h.onTermination(instID) {
+				h.broker.Unregister(instID)
+			}
+			continue
+		}
+		h.addInstance(cp)
+		h.With().Info("number of consensus processes", log.Int32("count", atomic.AddInt32(&h.totalCPs, 1)))
 	}
-	h.With().Info("number of consensus processes", log.Int32("count", atomic.AddInt32(&h.totalCPs, 1)))
 	// TODO: fix metrics
 	//metrics.TotalConsensusProcesses.With("layer", strconv.FormatUint(uint64(id), 10)).Add(1)
 }
 
+// addInstance keeps the consensus process for the status queries and drops the processes of layers out of the buffer.
+func (h *Hare) addInstance(cp Consensus) {
+	h.cpsMu.Lock()
+	defer h.cpsMu.Unlock()
+	h.instances[cp.ID()] = append(h.instances[cp.ID()], cp)
+	for id := range h.instances {
+		if h.outOfBufferRange(id) {
+			delete(h.instances, id)
+		}
+	}
+}
+
+// Instances returns the status of the consensus processes of the recent layers, ordered by layer.
+func (h *Hare) Instances() []InstanceStatus {
+	h.cpsMu.Lock()
+	ids := make([]instanceID, 0, len(h.instances))
+	for id := range h.instances {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	var cps []Consensus
+	for _, id := range ids {
+		cps = append(cps, h.instances[id]...)
+	}
+	h.cpsMu.Unlock()
+
+	res := make([]InstanceStatus, 0, len(cps))
+	for _, cp := range cps {
+		res = append(res, cp.Status())
+	}
+	return res
+}
+
+// fanOut copies every message of a layer to the inboxes of all participants until done is closed. A participant that
+// doesn't keep up, e.g. because its consensus process already terminated, misses messages instead of blocking others.
+// The missed messages are counted, and logged when the first one is dropped and when the layer ends.
+func (h *Hare) fanOut(id instanceID, in chan *Msg, inboxes []chan *Msg, ids []types.NodeID, done chan struct{}) {
+	dropped := make([]int, len(inboxes))
+	defer func() {
+		for i, n := range dropped {
+			if n > 0 {
+				h.With().Warning("participant missed messages of layer", log.LayerID(uint64(id)),
+					log.String("participant", ids[i].ShortString()), log.Int("dropped", n))
+			}
+		}
+	}()
+	for {
+		select {
+		case m := <-in:
+			for i, inbox := range inboxes {
+				select {
+				case inbox <- m:
+				default:
+					if dropped[i] == 0 {
+						h.With().Warning("participant inbox is full, dropping messages", log.LayerID(uint64(id)),
+							log.String("participant", ids[i].ShortString()))
+					}
+					dropped[i]++
+					metrics.ParticipantDroppedMessages.Add(1)
+				}
+			}
+		case <-done:
+			return
+		case <-h.CloseChannel():
+			return
+		}
+	}
+}
+
+// onTermination records that a consensus process of the layer terminated. It returns true if this was the last running
+// process of the layer.
+func (h *Hare) onTermination(id instanceID) bool {
+	h.cpsMu.Lock()
+	defer h.cpsMu.Unlock()
+	lcps, ok := h.cps[id]
+	if !ok { // not started by onTick
+		return true
+	}
+	lcps.running--
+	if lcps.running > 0 {
+		return false
+	}
+	close(lcps.done)
+	delete(h.cps, id)
+	return true
+}
+
+// shouldCollect returns true if no output was collected yet for the layer and marks it as collected.
+func (h *Hare) shouldCollect(id instanceID) bool {
+	h.cpsMu.Lock()
+	defer h.cpsMu.Unlock()
+	lcps, ok := h.cps[id]
+	if !ok {
+		return true
+	}
+	if lcps.collected {
+		return false
+	}
+	lcps.collected = true
+	return true
+}
+
 var (
 	errTooOld   = errors.New("layer has already been evacuated from buffer")
 	errNoResult = errors.New("no result for the requested layer")
@@ -268,7 +513,12 @@ func (h *Hare) GetResult(lid types.LayerID) ([]types.BlockID, error) {
 	blks, ok := h.outputs[lid]
 	if !ok {
 		h.mu.RUnlock()
-		return nil, errNoResult
+		// the output may have been collected before a restart
+		out, err := h.loadOutput(lid)
+		if err != nil {
+			return nil, errNoResult
+		}
+		return out.Blocks, nil
 	}
 
 	h.mu.RUnlock()
@@ -280,15 +530,17 @@ func (h *Hare) outputCollectionLoop() {
 	for {
 		select {
 		case out := <-h.outputChan:
-			if out.Completed() { // CP completed, collect the output
+			if out.Completed() && h.shouldCollect(out.ID()) { // CP completed, collect the output once per layer
 				err := h.collectOutput(out)
 				if err != nil {
 					h.With().Warning("error collecting output from hare", log.Err(err))
 				}
 			}
 
-			// anyway, unregister from broker
-			h.broker.Unregister(out.ID()) // unregister from broker after termination
+			// anyway, unregister from broker once all participants terminated
+			if h.onTermination(out.ID()) {
+				h.broker.Unregister(out.ID()) // unregister from broker after termination
+			}
 			h.With().Info("number of consensus processes", log.Int32("count", atomic.AddInt32(&h.totalCPs, -1)))
 			// TODO: fix metrics
 			//metrics.TotalConsensusProcesses.With("layer", strconv.FormatUint(uint64(out.ID()), 10)).Add(-1)
@@ -3,6 +3,7 @@ package hare
 import (
 	"bytes"
 	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
 	"github.com/spacemeshos/go-spacemesh/eligibility"
 	"github.com/spacemeshos/go-spacemesh/hare/config"
 	"github.com/spacemeshos/go-spacemesh/log"
@@ -38,6 +39,10 @@ func (m mockReport) Completed() bool {
 	return m.c
 }
 
+func (m mockReport) Certificate() *certificate {
+	return nil
+}
+
 type mockConsensusProcess struct {
 	Closer
 	t    chan TerminationOutput
@@ -62,6 +67,10 @@ func (mcp *mockConsensusProcess) ID() instanceID {
 func (mcp *mockConsensusProcess) SetInbox(chan *Msg) {
 }
 
+func (mcp *mockConsensusProcess) Status() InstanceStatus {
+	return InstanceStatus{Layer: types.LayerID(mcp.id)}
+}
+
 type mockIDProvider struct {
 	err error
 }
@@ -80,7 +89,7 @@ func newMockConsensusProcess(cfg config.Config, instanceID instanceID, s *Set, o
 }
 
 func createHare(n1 p2p.Service, logger log.Log) *Hare {
-	return New(cfg, n1, signing2.NewEdSigner(), types.NodeID{}, validateBlocks, (&mockSyncer{true}).IsSynced, new(orphanMock), eligibility.New(), 10, &mockIDProvider{}, NewMockStateQuerier(), make(chan types.LayerID), logger)
+	return New(cfg, n1, signing2.NewEdSigner(), types.NodeID{}, validateBlocks, (&mockSyncer{true}).IsSynced, new(orphanMock), eligibility.New(), 10, &mockIDProvider{}, NewMockStateQuerier(), database.NewMemDatabase(), make(chan types.LayerID), logger)
 }
 
 var _ Consensus = (*mockConsensusProcess)(nil)
@@ -146,7 +155,7 @@ func TestHare_GetResult2(t *testing.T) {
 
 	h.networkDelta = 0
 
-	h.factory = func(cfg config.Config, instanceId instanceID, s *Set, oracle Rolacle, signing Signer, p2p NetworkService, outputChan chan TerminationOutput) Consensus {
+	h.factory = func(cfg config.Config, instanceId instanceID, s *Set, oracle Rolacle, signing Signer, nid types.NodeID, p2p NetworkService, outputChan chan TerminationOutput) Consensus {
 		return newMockConsensusProcess(cfg, instanceId, s, oracle, signing, p2p, outputChan)
 	}
 
@@ -279,14 +288,14 @@ func TestHare_onTick(t *testing.T) {
 		return blockset
 	}
 
-	h := New(cfg, n1, signing, types.NodeID{}, validateBlocks, (&mockSyncer{true}).IsSynced, om, oracle, 10, &mockIDProvider{}, NewMockStateQuerier(), layerTicker, log.NewDefault("Hare"))
+	h := New(cfg, n1, signing, types.NodeID{}, validateBlocks, (&mockSyncer{true}).IsSynced, om, oracle, 10, &mockIDProvider{}, NewMockStateQuerier(), database.NewMemDatabase(), layerTicker, log.NewDefault("Hare"))
 	h.networkDelta = 0
 	h.bufferSize = 1
 
 	createdChan := make(chan struct{})
 
 	var nmcp *mockConsensusProcess
-	h.factory = func(cfg config.Config, instanceId instanceID, s *Set, oracle Rolacle, signing Signer, p2p NetworkService, outputChan chan TerminationOutput) Consensus {
+	h.factory = func(cfg config.Config, instanceId instanceID, s *Set, oracle Rolacle, signing Signer, nid types.NodeID, p2p NetworkService, outputChan chan TerminationOutput) Consensus {
 		nmcp = newMockConsensusProcess(cfg, instanceId, s, oracle, signing, p2p, outputChan)
 		createdChan <- struct{}{}
 		return nmcp
@@ -447,3 +456,75 @@ func TestHare_oldestInBuffer(t *testing.T) {
 	require.True(t, lyr == 2)
 
 }
+
+func TestHare_MultipleParticipants(t *testing.T) {
+	r := require.New(t)
+	sim := service.NewSimulator()
+	n1 := sim.NewNode()
+
+	om := new(orphanMock)
+	om.f = func() []types.BlockID {
+		return []types.BlockID{value1}
+	}
+
+	h := createHare(n1, log.NewDefault(t.Name()))
+	h.msh = om
+	h.networkDelta = 0
+
+	nid := types.NodeID{Key: "other"}
+	r.NoError(h.AddParticipant(nid, signing2.NewEdSigner(), eligibility.New()))
+	r.Equal(ErrParticipantExists, h.AddParticipant(nid, signing2.NewEdSigner(), eligibility.New()))
+
+	var mu sync.Mutex
+	started := make(map[string]int)
+	h.factory = func(cfg config.Config, instanceId instanceID, s *Set, oracle Rolacle, signing Signer, nid types.NodeID, p2p NetworkService, outputChan chan TerminationOutput) Consensus {
+		mu.Lock()
+		started[nid.Key]++
+		mu.Unlock()
+		return newMockConsensusProcess(cfg, instanceId, s, oracle, signing, p2p, outputChan)
+	}
+	r.NoError(h.Start())
+	defer h.Close()
+
+	h.beginLayer <- 1
+	time.Sleep(100 * time.Millisecond)
+
+	res, err := h.GetResult(1)
+	r.NoError(err)
+	r.Equal([]types.BlockID{value1}, res)
+	mu.Lock()
+	r.Equal(map[string]int{"": 1, "other": 1}, started)
+	mu.Unlock()
+
+	h.cpsMu.Lock()
+	r.Empty(h.cps)
+	h.cpsMu.Unlock()
+
+	r.NoError(h.RemoveParticipant(nid))
+	r.Equal(ErrUnknownParticipant, h.RemoveParticipant(nid))
+}
+
+func TestHare_FanOutFullInbox(t *testing.T) {
+	r := require.New(t)
+	h := createHare(service.NewSimulator().NewNode(), log.NewDefault(t.Name()))
+
+	in := make(chan *Msg)
+	full, free := make(chan *Msg), make(chan *Msg, 2)
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		h.fanOut(1, in, []chan *Msg{full, free}, []types.NodeID{{Key: "full"}, {Key: "free"}}, done)
+		close(exited)
+	}()
+
+	// the participant whose inbox is full misses the messages without blocking the other one
+	in <- &Msg{}
+	in <- &Msg{}
+	r.Eventually(func() bool { return len(free) == 2 }, time.Second, 10*time.Millisecond)
+	close(done)
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		r.Fail("fan out did not stop")
+	}
+}
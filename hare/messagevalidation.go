@@ -2,7 +2,9 @@ package hare
 
 import (
 	"errors"
+	"github.com/hashicorp/golang-lru"
 	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/hare/metrics"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/signing"
 	"time"
@@ -17,17 +19,103 @@ type identityProvider interface {
 	GetIdentity(edID string) (types.NodeID, error)
 }
 
+// batchRolacle is implemented by oracles that can verify the role proofs of several identities in a round at once.
+type batchRolacle interface {
+	VerifyBatch(layer types.LayerID, round int32, ids []types.NodeID, sigs [][]byte)
+}
+
+const eligibilityCacheSize = 8192 // about five rounds of two concurrent layers of a large committee
+
+// roleKey identifies the role proof of a sender in a round.
+type roleKey struct {
+	pub   string
+	layer types.LayerID
+	k     int32
+	proof string
+}
+
 type eligibilityValidator struct {
 	oracle           Rolacle
 	layersPerEpoch   uint16
 	identityProvider identityProvider
-	maxExpActives    int // the maximal expected committee size
-	expLeaders       int // the expected number of leaders
+	maxExpActives    int        // the maximal expected committee size
+	expLeaders       int        // the expected number of leaders
+	results          *lru.Cache // the eligibility counts of the role proofs already validated
 	log.Log
 }
 
 func newEligibilityValidator(oracle Rolacle, layersPerEpoch uint16, idProvider identityProvider, maxExpActives, expLeaders int, logger log.Log) *eligibilityValidator {
-	return &eligibilityValidator{oracle, layersPerEpoch, idProvider, maxExpActives, expLeaders, logger}
+	results, err := lru.New(eligibilityCacheSize)
+	if err != nil {
+		logger.Panic("could not create lru cache err=%v", err)
+	}
+	return &eligibilityValidator{oracle, layersPerEpoch, idProvider, maxExpActives, expLeaders, results, logger}
+}
+
+// roleCount returns the eligibility count of the sender's role proof in the round. The results are cached, so the
+// eligibility of a message validated by the broker is not computed again when the consensus process validates it or
+// the messages aggregating it.
+func (ev *eligibilityValidator) roleCount(pub *signing.PublicKey, layer types.LayerID, k int32, proof []byte) (uint16, error) {
+	key := roleKey{pub.String(), layer, k, string(proof)}
+	if val, exist := ev.results.Get(key); exist {
+		metrics.EligibilityResults.With("result", "cached").Add(1)
+		return val.(uint16), nil
+	}
+
+	nID, err := ev.identityProvider.GetIdentity(pub.String())
+	if err != nil {
+		ev.With().Error("Eligibility validator: GetIdentity failed (ignore if the safe layer is in genesis)", log.Err(err), log.String("sender_id", pub.ShortString()))
+		return 0, err
+	}
+
+	// validate role
+	count, err := eligibilityCount(ev.oracle, layer, k, expectedCommitteeSize(k, ev.maxExpActives, ev.expLeaders), nID, proof)
+	if err != nil {
+		ev.With().Error("Eligibility validator: could not retrieve eligibility result", log.Err(err), log.String("sender_id", pub.ShortString()))
+		return 0, err
+	}
+	metrics.EligibilityResults.With("result", "computed").Add(1)
+	ev.results.Add(key, count)
+	return count, nil
+}
+
+// prevalidate verifies the role proofs of the messages together if the oracle supports it. The messages of a round
+// all prove their role on the same VRF message, so the proofs of a round can be verified at once before the messages
+// are validated one by one.
+func (ev *eligibilityValidator) prevalidate(msgs []*Msg) {
+	bo, ok := ev.oracle.(batchRolacle)
+	if !ok {
+		return
+	}
+
+	type round struct {
+		layer types.LayerID
+		k     int32
+	}
+	ids := make(map[round][]types.NodeID)
+	proofs := make(map[round][][]byte)
+	for _, m := range msgs {
+		layer := types.LayerID(m.InnerMsg.InstanceID)
+		if layer.GetEpoch(ev.layersPerEpoch).IsGenesis() {
+			continue
+		}
+		if ev.results.Contains(roleKey{m.PubKey.String(), layer, m.InnerMsg.K, string(m.InnerMsg.RoleProof)}) {
+			continue
+		}
+		nID, err := ev.identityProvider.GetIdentity(m.PubKey.String())
+		if err != nil {
+			continue // reported when the message is validated
+		}
+		r := round{layer, m.InnerMsg.K}
+		ids[r] = append(ids[r], nID)
+		proofs[r] = append(proofs[r], m.InnerMsg.RoleProof)
+	}
+
+	for r, batch := range ids {
+		if len(batch) > 1 {
+			bo.VerifyBatch(r.layer, r.k, batch, proofs[r])
+		}
+	}
 }
 
 // check eligibility of the provided message by the oracle.
@@ -45,25 +133,23 @@ func (ev *eligibilityValidator) validateRole(m *Msg) (bool, error) {
 	pub := m.PubKey
 	layer := types.LayerID(m.InnerMsg.InstanceID)
 	if layer.GetEpoch(ev.layersPerEpoch).IsGenesis() {
-		return true, nil // TODO: remove this lie after inception problem is addressed
+		// TODO: remove this lie after inception problem is addressed
+		return m.InnerMsg.EligibilityCount == 1, nil
 	}
 
-	nID, err := ev.identityProvider.GetIdentity(pub.String())
+	count, err := ev.roleCount(pub, layer, m.InnerMsg.K, m.InnerMsg.RoleProof)
 	if err != nil {
-		ev.With().Error("Eligibility validator: GetIdentity failed (ignore if the safe layer is in genesis)", log.Err(err), log.String("sender_id", pub.ShortString()))
 		return false, err
 	}
-
-	// validate role
-	res, err := ev.oracle.Eligible(layer, m.InnerMsg.K, expectedCommitteeSize(m.InnerMsg.K, ev.maxExpActives, ev.expLeaders), nID, m.InnerMsg.RoleProof)
-	if err != nil {
-		ev.With().Error("Eligibility validator: could not retrieve eligibility result", log.Err(err), log.String("sender_id", pub.ShortString()))
-		return false, err
-	}
-	if !res {
+	if count == 0 {
 		ev.With().Error("Eligibility validator: sender is not eligible to participate", log.String("sender_id", pub.ShortString()))
 		return false, nil
 	}
+	if count != m.InnerMsg.EligibilityCount {
+		ev.With().Error("Eligibility validator: sender claimed a wrong eligibility count", log.String("sender_id", pub.ShortString()),
+			log.Uint32("expected", uint32(count)), log.Uint32("actual", uint32(m.InnerMsg.EligibilityCount)))
+		return false, nil
+	}
 
 	return true, nil
 }
@@ -256,6 +342,7 @@ var (
 	errNilAggMsgs        = errors.New("aggMsg is nil")
 	errNilMsgsSlice      = errors.New("messages slice is nil")
 	errMsgsCountMismatch = errors.New("number of messages does not match the threshold")
+	errMsgsWeightTooLow  = errors.New("eligibility count of messages is below the threshold")
 	errDupSender         = errors.New("duplicate sender detected")
 	errInnerSyntax       = errors.New("invalid syntax for inner message")
 	errInnerEligibility  = errors.New("inner message is not eligible")
@@ -276,12 +363,24 @@ func (v *syntaxContextValidator) validateAggregatedMessage(aggMsg *aggregatedMes
 		return errNilMsgsSlice
 	}
 
-	if len(aggMsg.Messages) != v.threshold { // must include exactly f+1 Messages
-		v.Warning("Aggregated validation failed: number of messages does not match. Expected: %v Actual: %v",
+	if len(aggMsg.Messages) > v.threshold { // every message holds at least a seat, f+1 Messages are always enough
+		v.Warning("Aggregated validation failed: number of messages does not match. Expected at most: %v Actual: %v",
 			v.threshold, len(aggMsg.Messages))
 		return errMsgsCountMismatch
 	}
 
+	weight := 0
+	for _, innerMsg := range aggMsg.Messages {
+		if innerMsg != nil && innerMsg.InnerMsg != nil {
+			weight += int(innerMsg.InnerMsg.EligibilityCount)
+		}
+	}
+	if weight < v.threshold { // must include a total eligibility count of at least f+1
+		v.Warning("Aggregated validation failed: eligibility count of messages is too low. Expected: %v Actual: %v",
+			v.threshold, weight)
+		return errMsgsWeightTooLow
+	}
+
 	senders := make(map[string]struct{})
 	for _, innerMsg := range aggMsg.Messages {
 
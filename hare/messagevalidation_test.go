@@ -2,6 +2,7 @@ package hare
 
 import (
 	"errors"
+	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/signing"
 	"github.com/stretchr/testify/assert"
@@ -94,6 +95,7 @@ func TestEligibilityValidator_validateRole(t *testing.T) {
 
 	oracle.isEligible = true
 	m.InnerMsg.InstanceID = 111
+	m.InnerMsg.RoleProof = []byte{1} // results are cached by role proof
 	res, err = ev.validateRole(m)
 	assert.Nil(t, err)
 	assert.True(t, res)
@@ -393,3 +395,133 @@ func TestSyntaxContextValidator_NotifyContextMatrix(t *testing.T) {
 	validateMatrix(t, notify, 3, msg3)
 	validateMatrix(t, notify, 7, msg7)
 }
+
+type mockWeightedRolacle struct {
+	mockRolacle
+	count uint16
+}
+
+func (mr *mockWeightedRolacle) EligibilityCount(types.LayerID, int32, int, types.NodeID, []byte) (uint16, error) {
+	return mr.count, mr.err
+}
+
+// withEligibilityCount sets the eligibility count of the message and signs it again.
+func withEligibilityCount(signing Signer, m *Msg, count uint16) *Msg {
+	m.InnerMsg.EligibilityCount = count
+	m.Sig = signing.Sign(m.InnerMsg.Bytes())
+	return m
+}
+
+func TestEligibilityValidator_validateRoleCount(t *testing.T) {
+	r := require.New(t)
+	oracle := &mockWeightedRolacle{count: 3}
+	ev := newEligibilityValidator(oracle, 10, &mockIDProvider{}, 1, 5, log.NewDefault(t.Name()))
+	sgn := generateSigning(t)
+	m := BuildPreRoundMsg(sgn, NewDefaultEmptySet())
+	m.InnerMsg.InstanceID = 111
+
+	// the claimed count must match the count of the oracle
+	res, err := ev.validateRole(withEligibilityCount(sgn, m, 1))
+	r.NoError(err)
+	r.False(res)
+	res, err = ev.validateRole(withEligibilityCount(sgn, m, 3))
+	r.NoError(err)
+	r.True(res)
+
+	// the results are cached by role proof
+	oracle.count = 0
+	res, err = ev.validateRole(m)
+	r.NoError(err)
+	r.True(res)
+	m.InnerMsg.RoleProof = []byte{7}
+	res, err = ev.validateRole(m)
+	r.NoError(err)
+	r.False(res)
+
+	// identities hold a single seat in genesis
+	m.InnerMsg.InstanceID = 1
+	res, err = ev.validateRole(withEligibilityCount(sgn, m, 3))
+	r.NoError(err)
+	r.False(res)
+	res, err = ev.validateRole(withEligibilityCount(sgn, m, 1))
+	r.NoError(err)
+	r.True(res)
+}
+
+func TestMessageValidator_AggregatedWeight(t *testing.T) {
+	r := require.New(t)
+	validator := defaultValidator()
+	funcs := make([]func(m *Msg) bool, 0)
+
+	// a few messages holding many seats reach the threshold
+	var msgs []*Message
+	for _, count := range []uint16{uint16(validator.threshold - 4), 3} {
+		sgn := generateSigning(t)
+		msgs = append(msgs, withEligibilityCount(sgn, BuildStatusMsg(sgn, NewSetFromValues(value1)), count).Message)
+	}
+	agg := &aggregatedMessages{Messages: msgs}
+	r.Equal(errMsgsWeightTooLow, validator.validateAggregatedMessage(agg, funcs))
+
+	sgn := generateSigning(t)
+	agg.Messages = append(agg.Messages, BuildStatusMsg(sgn, NewSetFromValues(value1)).Message)
+	r.NoError(validator.validateAggregatedMessage(agg, funcs))
+}
+
+type mockBatchRolacle struct {
+	mockWeightedRolacle
+	calls   int
+	batches map[int32]int
+}
+
+func (mr *mockBatchRolacle) EligibilityCount(types.LayerID, int32, int, types.NodeID, []byte) (uint16, error) {
+	mr.calls++
+	return mr.count, mr.err
+}
+
+func (mr *mockBatchRolacle) VerifyBatch(layer types.LayerID, round int32, ids []types.NodeID, sigs [][]byte) {
+	mr.batches[round] += len(ids)
+}
+
+func TestEligibilityValidator_Cache(t *testing.T) {
+	r := require.New(t)
+	oracle := &mockBatchRolacle{mockWeightedRolacle: mockWeightedRolacle{count: 1}}
+	ev := newEligibilityValidator(oracle, 10, &mockIDProvider{}, 1, 5, log.NewDefault(t.Name()))
+	m := BuildStatusMsg(generateSigning(t), NewDefaultEmptySet())
+	m.InnerMsg.InstanceID = 111
+
+	// errors are not cached
+	oracle.err = errors.New("some error")
+	r.False(ev.Validate(m))
+	oracle.err = nil
+	r.True(ev.Validate(m))
+	r.True(ev.Validate(m))
+	r.Equal(2, oracle.calls)
+
+	// the result is of the role proof in the round
+	m.InnerMsg.K++
+	r.True(ev.Validate(m))
+	r.Equal(3, oracle.calls)
+}
+
+func TestEligibilityValidator_Prevalidate(t *testing.T) {
+	r := require.New(t)
+	oracle := &mockBatchRolacle{mockWeightedRolacle: mockWeightedRolacle{count: 1}, batches: make(map[int32]int)}
+	ev := newEligibilityValidator(oracle, 10, &mockIDProvider{}, 1, 5, log.NewDefault(t.Name()))
+
+	var msgs []*Msg
+	for i := 0; i < 6; i++ {
+		m := BuildStatusMsg(generateSigning(t), NewDefaultEmptySet())
+		m.InnerMsg.InstanceID = 111
+		msgs = append(msgs, m)
+	}
+	msgs[3].InnerMsg.K = 3          // alone in its round
+	msgs[4].InnerMsg.InstanceID = 1 // genesis
+	r.True(ev.Validate(msgs[5]))    // already validated
+
+	ev.prevalidate(msgs)
+	r.Equal(map[int32]int{msgs[0].InnerMsg.K: 3}, oracle.batches)
+
+	// oracles that do not verify batches are skipped
+	ev.oracle = &mockRolacle{}
+	ev.prevalidate(msgs)
+}
@@ -28,4 +28,53 @@ var (
 		Name:      "total_consensus_processes",
 		Help:      "The total number of current consensus processes running",
 	}, []string{"layer"})
+
+	// DroppedMessages is the number of messages dropped by the broker for each reason.
+	DroppedMessages = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: Subsystem,
+		Name:      "dropped_messages",
+		Help:      "Number of received messages dropped by the broker for each reason",
+	}, []string{"reason"})
+
+	// ParticipantDroppedMessages is the number of messages a participant missed because its inbox was full.
+	ParticipantDroppedMessages = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: Subsystem,
+		Name:      "participant_dropped_messages",
+		Help:      "Number of messages not delivered to a participant whose inbox was full",
+	}, []string{})
+
+	// MessageLateness is the time messages arrive after the start of their round.
+	MessageLateness = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: Subsystem,
+		Name:      "message_lateness_seconds",
+		Help:      "Time received messages arrive after the start of their round",
+		Buckets:   stdprometheus.ExponentialBuckets(0.05, 2, 10),
+	}, []string{"round"})
+
+	// RecommendedRoundDuration is the round duration within which the configured percentile of the messages arrive.
+	RecommendedRoundDuration = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: Subsystem,
+		Name:      "recommended_round_duration_seconds",
+		Help:      "Round duration within which the configured percentile of the messages arrive",
+	}, []string{})
+
+	// PendingMessages is the number of early messages buffered by the broker.
+	PendingMessages = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: Subsystem,
+		Name:      "pending_messages",
+		Help:      "Number of early messages buffered until their consensus process starts",
+	}, []string{})
+
+	// EligibilityResults is the number of eligibility validations by whether the result was cached or computed.
+	EligibilityResults = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: Subsystem,
+		Name:      "eligibility_results",
+		Help:      "Number of eligibility validations by whether the result was cached or computed",
+	}, []string{"result"})
 )
@@ -36,12 +36,12 @@ func (nt *notifyTracker) OnNotify(msg *Msg) bool {
 	// track that set
 	s := NewSet(msg.InnerMsg.Values)
 	nt.onCertificate(msg.InnerMsg.Cert.AggMsgs.Messages[0].InnerMsg.K, s)
-	nt.tracker.Track(s.ID())
+	nt.tracker.TrackCount(s.ID(), uint32(msg.InnerMsg.EligibilityCount))
 
 	return false
 }
 
-// NotificationsCount returns the number of notifications tracked for the provided set
+// NotificationsCount returns the total eligibility count of the notifications tracked for the provided set
 func (nt *notifyTracker) NotificationsCount(s *Set) int {
 	return int(nt.tracker.CountStatus(s.ID()))
 }
@@ -45,3 +45,13 @@ func TestNotifyTracker_NotificationsCount(t *testing.T) {
 	tracker.OnNotify(BuildNotifyMsg(generateSigning(t), s))
 	assert.Equal(t, 2, tracker.NotificationsCount(s))
 }
+
+func TestNotifyTracker_Weighted(t *testing.T) {
+	s := NewSetFromValues(value1)
+	tracker := newNotifyTracker(lowDefaultSize)
+	sgn := generateSigning(t)
+	tracker.OnNotify(withEligibilityCount(sgn, BuildNotifyMsg(sgn, s), 4))
+	assert.Equal(t, 4, tracker.NotificationsCount(s))
+	tracker.OnNotify(BuildNotifyMsg(generateSigning(t), s))
+	assert.Equal(t, 5, tracker.NotificationsCount(s))
+}
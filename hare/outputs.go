@@ -0,0 +1,136 @@
+package hare
+
+import (
+	"errors"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"sort"
+)
+
+var outputPrefix = []byte("o_")
+
+// certifiedOutput is the output set of a terminated consensus process together with the commit certificate of the set.
+type certifiedOutput struct {
+	Blocks []types.BlockID
+	Cert   *certificate
+}
+
+var (
+	errNoCertificate     = errors.New("no certificate for the requested layer")
+	errCertSetMismatch   = errors.New("certified set does not match the output set")
+	errCertLayerMismatch = errors.New("certificate commits are not of the requested layer")
+	errInvalidCert       = errors.New("certificate is not valid")
+)
+
+func outputKey(layer types.LayerID) []byte {
+	return append(outputPrefix, layer.Bytes()...)
+}
+
+// stripCertificate returns a copy of the certificate without the values of the inner commit messages, they are refilled
+// from the certified values on validation.
+func stripCertificate(cert *certificate) *certificate {
+	if cert == nil || cert.AggMsgs == nil {
+		return cert
+	}
+	stripped := &certificate{Values: cert.Values, AggMsgs: &aggregatedMessages{}}
+	for _, commit := range cert.AggMsgs.Messages {
+		inner := *commit.InnerMsg
+		inner.Values = nil
+		stripped.AggMsgs.Messages = append(stripped.AggMsgs.Messages, &Message{Sig: commit.Sig, InnerMsg: &inner})
+	}
+	return stripped
+}
+
+// storeOutput persists the output set of the layer and its certificate (if available).
+func (h *Hare) storeOutput(layer types.LayerID, blocks []types.BlockID, cert *certificate) error {
+	data, err := types.InterfaceToBytes(&certifiedOutput{Blocks: blocks, Cert: stripCertificate(cert)})
+	if err != nil {
+		return err
+	}
+	return h.store.Put(outputKey(layer), data)
+}
+
+func (h *Hare) loadOutput(layer types.LayerID) (*certifiedOutput, error) {
+	data, err := h.store.Get(outputKey(layer))
+	if err != nil {
+		return nil, err
+	}
+	out := &certifiedOutput{}
+	if err := types.BytesToInterface(data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetCertifiedOutput returns the encoded output set of the layer together with its commit certificate.
+// An error is returned if there is no output for the layer or if the output has no certificate.
+func (h *Hare) GetCertifiedOutput(layer types.LayerID) ([]byte, error) {
+	out, err := h.loadOutput(layer)
+	if err != nil {
+		return nil, err
+	}
+	if out.Cert == nil {
+		return nil, errNoCertificate
+	}
+	return types.InterfaceToBytes(out)
+}
+
+// VerifyCertifiedOutput decodes an output set and its certificate as returned by GetCertifiedOutput, validates that the
+// certificate commits to the output set of the layer and that its commits are signed by eligible participants according
+// to the hare eligibility oracle. A valid output is persisted and its blocks are returned.
+func (h *Hare) VerifyCertifiedOutput(layer types.LayerID, data []byte) ([]types.BlockID, error) {
+	out := &certifiedOutput{}
+	if err := types.BytesToInterface(data, out); err != nil {
+		return nil, err
+	}
+	if out.Cert == nil || out.Cert.AggMsgs == nil {
+		return nil, errNoCertificate
+	}
+	if !NewSet(out.Blocks).Equals(NewSet(out.Cert.Values)) {
+		return nil, errCertSetMismatch
+	}
+	for _, commit := range out.Cert.AggMsgs.Messages {
+		if commit == nil || commit.InnerMsg == nil || commit.InnerMsg.InstanceID != instanceID(layer) {
+			return nil, errCertLayerMismatch
+		}
+	}
+
+	validator := newSyntaxContextValidator(nil, h.config.F+1, nil, h.stateQ, h.layersPerEpoch, h.ev, newMsgsTracker(), h.Log)
+	if !validator.validateCertificate(out.Cert) {
+		return nil, errInvalidCert
+	}
+
+	if err := h.storeOutput(layer, out.Blocks, out.Cert); err != nil {
+		h.With().Error("failed to persist certified hare output", log.LayerID(uint64(layer)), log.Err(err))
+	}
+	return out.Blocks, nil
+}
+
+// StoredOutput is an output set persisted by the hare.
+type StoredOutput struct {
+	Layer  types.LayerID
+	Blocks []types.BlockID
+}
+
+// StoredOutputs returns the output sets persisted in the hare store, ordered by layer. It is meant for offline tools,
+// the store must not be used by a running hare.
+func StoredOutputs(store database.Database) ([]StoredOutput, error) {
+	it := store.Find(outputPrefix)
+	var res []StoredOutput
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(outputPrefix)+8 {
+			continue
+		}
+		out := &certifiedOutput{}
+		if err := types.BytesToInterface(it.Value(), out); err != nil {
+			return nil, err
+		}
+		layer := types.LayerID(util.BytesToUint64(key[len(outputPrefix):]))
+		res = append(res, StoredOutput{Layer: layer, Blocks: out.Blocks})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Layer < res[j].Layer })
+	return res, nil
+}
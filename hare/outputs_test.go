@@ -0,0 +1,107 @@
+package hare
+
+import (
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func buildTestCertificate(t *testing.T, commits int, s *Set) *certificate {
+	tracker := newCommitTracker(commits, commits, s)
+	for i := 0; i < commits; i++ {
+		tracker.OnCommit(BuildCommitMsg(generateSigning(t), s))
+	}
+	return tracker.BuildCertificate()
+}
+
+func TestHare_CertifiedOutput(t *testing.T) {
+	r := require.New(t)
+	sim := service.NewSimulator()
+	h := createHare(sim.NewNode(), log.NewDefault(t.Name()))
+	lyr := types.LayerID(instanceID1)
+
+	s := NewSetFromValues(value1, value2)
+	r.NoError(h.collectOutput(procReport{instanceID1, s, true, buildTestCertificate(t, cfg.F+1, s)}))
+	data, err := h.GetCertifiedOutput(lyr)
+	r.NoError(err)
+
+	// a syncing node verifies and adopts the output
+	h2 := createHare(sim.NewNode(), log.NewDefault(t.Name()))
+	_, err = h2.GetCertifiedOutput(lyr)
+	r.Error(err)
+	blocks, err := h2.VerifyCertifiedOutput(lyr, data)
+	r.NoError(err)
+	r.True(NewSet(blocks).Equals(s))
+	_, err = h2.GetCertifiedOutput(lyr)
+	r.NoError(err)
+
+	// the certificate is bound to the layer
+	_, err = h2.VerifyCertifiedOutput(lyr+1, data)
+	r.Equal(errCertLayerMismatch, err)
+}
+
+func TestHare_VerifyCertifiedOutput_Invalid(t *testing.T) {
+	r := require.New(t)
+	sim := service.NewSimulator()
+	h := createHare(sim.NewNode(), log.NewDefault(t.Name()))
+	lyr := types.LayerID(instanceID1)
+	s := NewSetFromValues(value1, value2)
+
+	// not enough commits
+	data, err := types.InterfaceToBytes(&certifiedOutput{Blocks: s.ToSlice(), Cert: buildTestCertificate(t, cfg.F, s)})
+	r.NoError(err)
+	_, err = h.VerifyCertifiedOutput(lyr, data)
+	r.Equal(errInvalidCert, err)
+
+	// the output set is not the certified one
+	data, err = types.InterfaceToBytes(&certifiedOutput{Blocks: []types.BlockID{value1}, Cert: buildTestCertificate(t, cfg.F+1, s)})
+	r.NoError(err)
+	_, err = h.VerifyCertifiedOutput(lyr, data)
+	r.Equal(errCertSetMismatch, err)
+
+	// no certificate
+	data, err = types.InterfaceToBytes(&certifiedOutput{Blocks: s.ToSlice()})
+	r.NoError(err)
+	_, err = h.VerifyCertifiedOutput(lyr, data)
+	r.Equal(errNoCertificate, err)
+	_, err = h.GetCertifiedOutput(lyr)
+	r.Error(err)
+}
+
+func TestHare_GetResultPersisted(t *testing.T) {
+	r := require.New(t)
+	sim := service.NewSimulator()
+	h := createHare(sim.NewNode(), log.NewDefault(t.Name()))
+
+	s := NewSetFromValues(value1)
+	r.NoError(h.collectOutput(mockReport{instanceID1, s, true}))
+	_, err := h.GetCertifiedOutput(types.LayerID(instanceID1))
+	r.Equal(errNoCertificate, err)
+
+	// the in-memory outputs are lost on restart
+	h.outputs = make(map[types.LayerID][]types.BlockID)
+	res, err := h.GetResult(types.LayerID(instanceID1))
+	r.NoError(err)
+	r.Equal([]types.BlockID{value1}, res)
+}
+
+func TestStoredOutputs(t *testing.T) {
+	r := require.New(t)
+	sim := service.NewSimulator()
+	h := createHare(sim.NewNode(), log.NewDefault(t.Name()))
+
+	r.NoError(h.collectOutput(mockReport{instanceID3, NewSetFromValues(value3), true}))
+	r.NoError(h.collectOutput(mockReport{instanceID1, NewSetFromValues(value1, value2), true}))
+	r.NoError(h.collectOutput(mockReport{instanceID2, NewSetFromValues(value1), true}))
+
+	outputs, err := StoredOutputs(h.store)
+	r.NoError(err)
+	r.Len(outputs, 3)
+	for i, out := range outputs {
+		r.Equal(types.LayerID(i+1), out.Layer)
+	}
+	r.True(NewSet(outputs[0].Blocks).Equals(NewSetFromValues(value1, value2)))
+	r.Equal([]types.BlockID{value3}, outputs[2].Blocks)
+}
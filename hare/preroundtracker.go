@@ -35,9 +35,9 @@ func (pre *preRoundTracker) OnPreRound(msg *Msg) {
 		sToTrack.Subtract(alreadyTracked) // subtract the already tracked Values
 	}
 
-	// record Values
+	// record Values, weighted by the eligibility count of the sender
 	for v := range sToTrack.values {
-		pre.tracker.Track(v)
+		pre.tracker.TrackCount(v, uint32(msg.InnerMsg.EligibilityCount))
 	}
 
 	// update the union to include new Values
@@ -45,7 +45,7 @@ func (pre *preRoundTracker) OnPreRound(msg *Msg) {
 }
 
 // CanProveValue returns true if the given value is provable, false otherwise.
-// a value is said to be provable if it has at least threshold pre-round messages to support it.
+// a value is said to be provable if it is supported by pre-round messages with a total eligibility count of at least threshold.
 func (pre *preRoundTracker) CanProveValue(value types.BlockID) bool {
 	// at least threshold occurrences of a given value
 	return pre.tracker.CountStatus(value) >= pre.threshold
@@ -119,3 +119,16 @@ func TestPreRoundTracker_FilterSet(t *testing.T) {
 	tracker.FilterSet(set)
 	assert.True(t, set.Equals(s1))
 }
+
+func TestPreRoundTracker_Weighted(t *testing.T) {
+	tracker := newPreRoundTracker(3, 3)
+	s1 := NewSetFromValues(value1, value2)
+	sgn := generateSigning(t)
+	tracker.OnPreRound(withEligibilityCount(sgn, BuildPreRoundMsg(sgn, s1), 2))
+	assert.False(t, tracker.CanProveValue(value1))
+
+	sgn = generateSigning(t)
+	tracker.OnPreRound(BuildPreRoundMsg(sgn, NewSetFromValues(value1)))
+	assert.True(t, tracker.CanProveValue(value1))
+	assert.False(t, tracker.CanProveValue(value2))
+}
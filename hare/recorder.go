@@ -0,0 +1,166 @@
+package hare
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RecordKind is the kind of a recorded hare event.
+type RecordKind int32
+
+const (
+	// RecordStart marks the start of the consensus processes of the layer, it holds the initial set.
+	RecordStart RecordKind = iota
+	// RecordReceived is a message received from the network.
+	RecordReceived
+	// RecordSent is a message sent by one of the local participants.
+	RecordSent
+)
+
+func (k RecordKind) String() string {
+	switch k {
+	case RecordStart:
+		return "start"
+	case RecordReceived:
+		return "received"
+	case RecordSent:
+		return "sent"
+	default:
+		return "unknown"
+	}
+}
+
+// Record is an entry of a hare recording.
+type Record struct {
+	Time    int64 // unix nano
+	Kind    RecordKind
+	Values  []types.BlockID // the initial set of a start record
+	Message []byte          // the encoded message of received and sent records
+}
+
+// RecordFileName returns the name of the recording file of the layer.
+func RecordFileName(layer types.LayerID) string {
+	return fmt.Sprintf("layer_%d.hrec", layer)
+}
+
+// recorder writes the hare events of every layer to a separate file in its directory.
+type recorder struct {
+	mu    sync.Mutex
+	dir   string
+	files map[instanceID]*os.File
+	log.Log
+}
+
+func newRecorder(dir string, logger log.Log) (*recorder, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &recorder{dir: dir, files: make(map[instanceID]*os.File), Log: logger}, nil
+}
+
+func (r *recorder) write(id instanceID, rec *Record) {
+	data, err := types.InterfaceToBytes(rec)
+	if err != nil {
+		r.With().Error("failed to encode hare record", log.LayerID(uint64(id)), log.Err(err))
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.files[id]
+	if !ok {
+		f, err = os.OpenFile(filepath.Join(r.dir, RecordFileName(types.LayerID(id))), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			r.With().Error("failed to open hare recording", log.LayerID(uint64(id)), log.Err(err))
+			return
+		}
+		r.files[id] = f
+	}
+	buf := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	if _, err := f.Write(append(buf, data...)); err != nil {
+		r.With().Error("failed to write hare record", log.LayerID(uint64(id)), log.Err(err))
+	}
+}
+
+func (r *recorder) recordStart(id instanceID, s *Set) {
+	r.write(id, &Record{Time: time.Now().UnixNano(), Kind: RecordStart, Values: s.ToSlice()})
+}
+
+func (r *recorder) recordMessage(kind RecordKind, m *Message) {
+	if m == nil || m.InnerMsg == nil {
+		return
+	}
+	data, err := types.InterfaceToBytes(m)
+	if err != nil {
+		r.With().Error("failed to encode hare message", log.Err(err))
+		return
+	}
+	r.write(m.InnerMsg.InstanceID, &Record{Time: time.Now().UnixNano(), Kind: kind, Message: data})
+}
+
+// closeLayer closes the recording of the layer, records that arrive later are appended to the same file.
+func (r *recorder) closeLayer(id instanceID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f, ok := r.files[id]; ok {
+		if err := f.Close(); err != nil {
+			r.With().Error("failed to close hare recording", log.LayerID(uint64(id)), log.Err(err))
+		}
+		delete(r.files, id)
+	}
+}
+
+func (r *recorder) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, f := range r.files {
+		f.Close()
+		delete(r.files, id)
+	}
+}
+
+// recordingNetwork records the messages broadcast by the local consensus processes.
+type recordingNetwork struct {
+	NetworkService
+	rec *recorder
+}
+
+func (n *recordingNetwork) Broadcast(protocol string, payload []byte) error {
+	if m, err := MessageFromBuffer(payload); err == nil {
+		n.rec.recordMessage(RecordSent, m)
+	}
+	return n.NetworkService.Broadcast(protocol, payload)
+}
+
+// ReadRecording reads all the records of a hare recording.
+func ReadRecording(r io.Reader) ([]Record, error) {
+	rd := bufio.NewReader(r)
+	var records []Record
+	lenBuf := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(rd, lenBuf); err != nil {
+			if err == io.EOF {
+				return records, nil
+			}
+			return nil, err
+		}
+		data := make([]byte, binary.BigEndian.Uint32(lenBuf))
+		if _, err := io.ReadFull(rd, data); err != nil {
+			return nil, err
+		}
+		var rec Record
+		if err := types.BytesToInterface(data, &rec); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+}
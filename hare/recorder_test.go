@@ -0,0 +1,95 @@
+package hare
+
+import (
+	"bytes"
+	"github.com/spacemeshos/amcl/BLS381"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/hare/config"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecorder_ReadRecording(t *testing.T) {
+	r := require.New(t)
+	dir, err := ioutil.TempDir("", "hare_recorder")
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	rec, err := newRecorder(dir, log.NewDefault(t.Name()))
+	r.NoError(err)
+	s := NewSetFromValues(value1, value2)
+	rec.recordStart(instanceID1, s)
+	rec.recordMessage(RecordReceived, BuildPreRoundMsg(signing.NewEdSigner(), s).Message)
+	sim := service.NewSimulator()
+	net := &recordingNetwork{sim.NewNode(), rec}
+	r.NoError(net.Broadcast(protoName, BuildCommitMsg(signing.NewEdSigner(), s).Bytes()))
+	rec.closeLayer(instanceID1)
+
+	f, err := os.Open(filepath.Join(dir, RecordFileName(types.LayerID(instanceID1))))
+	r.NoError(err)
+	defer f.Close()
+	records, err := ReadRecording(f)
+	r.NoError(err)
+	r.Len(records, 3)
+	r.Equal(RecordStart, records[0].Kind)
+	r.True(NewSet(records[0].Values).Equals(s))
+	r.Equal(RecordReceived, records[1].Kind)
+	r.Equal(RecordSent, records[2].Kind)
+	m := &Message{}
+	r.NoError(types.BytesToInterface(records[2].Message, m))
+	r.Equal(commit, m.InnerMsg.Type)
+}
+
+func TestHare_RecordAndReplay(t *testing.T) {
+	r := require.New(t)
+	dir, err := ioutil.TempDir("", "hare_replay")
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	totalNodes := 10
+	cfg := config.Config{N: totalNodes, F: totalNodes/2 - 1, RoundDuration: 1, ExpectedLeaders: 5, LimitIterations: 1000, LimitConcurrent: 100}
+	test := newHareWrapper(1)
+	rng := BLS381.DefaultSeed()
+	sim := service.NewSimulator()
+	for i := 0; i < totalNodes; i++ {
+		ncfg := cfg
+		if i == 0 {
+			ncfg.RecordDir = dir
+		}
+		test.lCh = append(test.lCh, make(chan types.LayerID, 1))
+		h := createMaatuf(ncfg, rng, test.lCh[i], sim.NewNode(), &trueOracle{}, t.Name())
+		test.hare = append(test.hare, h)
+		r.NoError(h.Start())
+	}
+	for i := range test.lCh {
+		test.lCh[i] <- 1
+	}
+	test.WaitForTimedTermination(t, 30*time.Second)
+	expected, err := test.hare[0].GetResult(1)
+	r.NoError(err)
+	for _, h := range test.hare {
+		h.Close()
+	}
+	time.Sleep(100 * time.Millisecond) // let the broker close the recording
+
+	f, err := os.Open(filepath.Join(dir, RecordFileName(1)))
+	r.NoError(err)
+	defer f.Close()
+	records, err := ReadRecording(f)
+	r.NoError(err)
+
+	out := &bytes.Buffer{}
+	set, err := Replay(cfg, 10, records, out, log.NewDefault(t.Name()))
+	r.NoError(err, out.String())
+	r.NotNil(set, out.String())
+	r.True(set.Equals(NewSet(expected)), out.String())
+	r.Contains(out.String(), "pre-round tracker")
+	r.Contains(out.String(), "commit tracker")
+}
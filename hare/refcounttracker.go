@@ -25,10 +25,10 @@ func (tracker *RefCountTracker) CountStatus(id interface{}) uint32 {
 
 // Track increases the count for the given object id.
 func (tracker *RefCountTracker) Track(id interface{}) {
-	if _, exist := tracker.table[id]; !exist {
-		tracker.table[id] = 1
-		return
-	}
+	tracker.TrackCount(id, 1)
+}
 
-	tracker.table[id]++
+// TrackCount increases the count for the given object id by count.
+func (tracker *RefCountTracker) TrackCount(id interface{}, count uint32) {
+	tracker.table[id] += count
 }
@@ -0,0 +1,248 @@
+package hare
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/hare/config"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/priorityq"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"io"
+	"sort"
+	"time"
+)
+
+// observerOracle makes the replayed consensus process a passive observer.
+type observerOracle struct{}
+
+func (observerOracle) Eligible(types.LayerID, int32, int, types.NodeID, []byte) (bool, error) {
+	return false, nil
+}
+
+func (observerOracle) Proof(types.LayerID, int32) ([]byte, error) {
+	return nil, nil
+}
+
+func (observerOracle) IsIdentityActiveOnConsensusView(string, types.LayerID) (bool, error) {
+	return false, nil
+}
+
+// recordedStateQuerier treats all senders as active, they were checked when the messages were recorded.
+type recordedStateQuerier struct{}
+
+func (recordedStateQuerier) IsIdentityActiveOnConsensusView(string, types.LayerID) (bool, error) {
+	return true, nil
+}
+
+// recordedRoleValidator treats all senders as eligible, they were checked when the messages were recorded.
+type recordedRoleValidator struct{}
+
+func (recordedRoleValidator) Validate(*Msg) bool {
+	return true
+}
+
+type nopNetwork struct{}
+
+func (nopNetwork) RegisterGossipProtocol(string, priorityq.Priority) chan service.GossipMessage {
+	return nil
+}
+
+func (nopNetwork) Broadcast(string, []byte) error {
+	return nil
+}
+
+type replayedMsg struct {
+	*Msg
+	time time.Time
+	kind RecordKind
+}
+
+var errEmptyRecording = errors.New("recording has no messages")
+
+// Replay feeds the messages of a single layer recording into a fresh consensus process. The rounds are advanced by the
+// recorded arrival times instead of a clock, relative to the recorded start of the layer. The process only observes and
+// all recorded senders are treated as active and eligible. The state of the trackers is written to w at the end of every
+// round. It returns the set the process terminated with, or nil if it didn't terminate.
+func Replay(cfg config.Config, layersPerEpoch uint16, records []Record, w io.Writer, logger log.Log) (*Set, error) {
+	var start time.Time
+	var initial *Set
+	var msgs []replayedMsg
+	seen := make(map[string]struct{})
+	for _, rec := range records {
+		if rec.Kind == RecordStart {
+			start = time.Unix(0, rec.Time)
+			initial = NewSet(rec.Values)
+			continue
+		}
+		m := &Message{}
+		if err := types.BytesToInterface(rec.Message, m); err != nil || m.InnerMsg == nil {
+			fmt.Fprintf(w, "skipping undecodable %v message\n", rec.Kind)
+			continue
+		}
+		if _, ok := seen[string(m.Sig)]; ok { // messages sent by the node are received from the network as well
+			continue
+		}
+		seen[string(m.Sig)] = struct{}{}
+		iMsg, err := newMsg(m, recordedStateQuerier{})
+		if err != nil {
+			fmt.Fprintf(w, "skipping %v message: %v\n", rec.Kind, err)
+			continue
+		}
+		msgs = append(msgs, replayedMsg{iMsg, time.Unix(0, rec.Time), rec.Kind})
+	}
+	if len(msgs) == 0 {
+		return nil, errEmptyRecording
+	}
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].time.Before(msgs[j].time) })
+	if start.IsZero() {
+		start = msgs[0].time
+	}
+	if initial == nil {
+		initial = recordedPreRoundSet(msgs)
+	}
+	if initial.Size() == 0 {
+		return nil, errors.New("could not determine the initial set")
+	}
+
+	layer := msgs[0].InnerMsg.InstanceID
+	proc := newConsensusProcess(cfg, layer, initial, observerOracle{}, recordedStateQuerier{}, layersPerEpoch,
+		signing.NewEdSigner(), types.NodeID{}, nopNetwork{}, make(chan TerminationOutput, 2), recordedRoleValidator{}, logger)
+	roundDuration := time.Duration(cfg.RoundDuration) * time.Second
+	roundEnd := func() time.Time { return start.Add(time.Duration(proc.k+2) * roundDuration) }
+
+	fmt.Fprintf(w, "replaying layer %v: %v messages, initial set %v\n", layer, len(msgs), initial)
+	stopped := false
+	for _, m := range msgs {
+		if m.InnerMsg.InstanceID != layer {
+			fmt.Fprintf(w, "skipping message of layer %v\n", m.InnerMsg.InstanceID)
+			continue
+		}
+		for !proc.terminating && !stopped && !m.time.Before(roundEnd()) {
+			stopped = replayRoundEnd(proc, w)
+		}
+		if proc.terminating || stopped {
+			break
+		}
+		fmt.Fprintf(w, "+%v %v %v from %v K=%v Ki=%v values=%v\n", m.time.Sub(start), m.kind, m.InnerMsg.Type,
+			m.PubKey.ShortString(), m.InnerMsg.K, m.InnerMsg.Ki, NewSet(m.InnerMsg.Values))
+		proc.handleMessage(m.Msg)
+	}
+	if !proc.terminating && !stopped {
+		replayRoundEnd(proc, w) // close the round of the last message
+	}
+
+	if !proc.terminating {
+		fmt.Fprintf(w, "did not terminate, current set %v\n", proc.s)
+		return nil, nil
+	}
+	fmt.Fprintf(w, "terminated with set %v\n", proc.s)
+	return proc.s, nil
+}
+
+// recordedPreRoundSet returns the union of the values the node sent in its pre-round messages, or of all the recorded
+// pre-round values if the node didn't send any.
+func recordedPreRoundSet(msgs []replayedMsg) *Set {
+	sent := NewDefaultEmptySet()
+	all := NewDefaultEmptySet()
+	for _, m := range msgs {
+		if m.InnerMsg.Type != pre {
+			continue
+		}
+		for _, v := range m.InnerMsg.Values {
+			all.Add(v)
+			if m.kind == RecordSent {
+				sent.Add(v)
+			}
+		}
+	}
+	if sent.Size() > 0 {
+		return sent
+	}
+	return all
+}
+
+// replayRoundEnd writes the state of the process, ends the current round and begins the next one the way the event
+// loop does. Pending messages are handled synchronously. It returns true if the iterations limit was reached.
+func replayRoundEnd(proc *consensusProcess, w io.Writer) bool {
+	fmt.Fprintf(w, "end of round %v (%v)\n", proc.k, roundName(proc.k))
+	proc.writeState(w)
+
+	if proc.k == preRound {
+		proc.preRoundTracker.FilterSet(proc.s)
+	} else {
+		proc.onRoundEnd()
+	}
+	proc.advanceToNextRound()
+	if proc.k/4 >= int32(proc.cfg.LimitIterations) {
+		fmt.Fprintln(w, "reached iterations limit")
+		return true
+	}
+
+	pending := proc.pending
+	proc.pending = make(map[string]*Msg, proc.cfg.N)
+	proc.onRoundBegin()
+	keys := make([]string, 0, len(pending))
+	for k := range pending {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if proc.terminating { // the event loop stops handling messages once the process terminates
+			break
+		}
+		proc.handleMessage(pending[k])
+	}
+	return false
+}
+
+func roundName(k int32) string {
+	if k == preRound {
+		return "pre-round"
+	}
+	switch k % 4 {
+	case statusRound:
+		return "status"
+	case proposalRound:
+		return "proposal"
+	case commitRound:
+		return "commit"
+	default:
+		return "notify"
+	}
+}
+
+// writeState writes the state of the process and the contents of its trackers.
+func (proc *consensusProcess) writeState(w io.Writer) {
+	fmt.Fprintf(w, "  state: K=%v Ki=%v set=%v\n", proc.k, proc.ki, proc.s)
+
+	pre := proc.preRoundTracker
+	fmt.Fprintf(w, "  pre-round tracker: %v senders, value counts %v\n", len(pre.preRound), refCounts(pre.tracker))
+
+	if st := proc.statusesTracker; st != nil {
+		fmt.Fprintf(w, "  status tracker: %v statuses, analyzed=%v maxKi=%v maxSet=%v\n", len(st.statuses), st.analyzed, st.maxKi, st.maxSet)
+	}
+	if pt, ok := proc.proposalTracker.(*proposalTracker); ok && pt != nil {
+		var proposed *Set
+		if pt.proposal != nil {
+			proposed = NewSet(pt.proposal.InnerMsg.Values)
+		}
+		fmt.Fprintf(w, "  proposal tracker: proposal=%v conflicting=%v\n", proposed, pt.isConflicting)
+	}
+	if ct, ok := proc.commitTracker.(*commitTracker); ok && ct != nil {
+		fmt.Fprintf(w, "  commit tracker: %v/%v commits for %v\n", len(ct.commits), ct.threshold, ct.proposedSet)
+	}
+
+	nt := proc.notifyTracker
+	fmt.Fprintf(w, "  notify tracker: %v notifications, %v certificates, set counts %v\n", len(nt.notifies), len(nt.certificates), refCounts(nt.tracker))
+}
+
+func refCounts(tracker *RefCountTracker) string {
+	counts := make([]string, 0, len(tracker.table))
+	for id, count := range tracker.table {
+		counts = append(counts, fmt.Sprintf("%v:%v", id, count))
+	}
+	sort.Strings(counts)
+	return fmt.Sprintf("%v", counts)
+}
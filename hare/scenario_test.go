@@ -0,0 +1,701 @@
+package hare
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/hare/config"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/priorityq"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/require"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"text/tabwriter"
+	"time"
+)
+
+// manualClock ends the rounds of all the consensus processes when tick is called.
+type manualClock struct {
+	mu      sync.Mutex
+	tickers map[*manualTicker]struct{}
+}
+
+func newManualClock() *manualClock {
+	return &manualClock{tickers: make(map[*manualTicker]struct{})}
+}
+
+func (mc *manualClock) newTicker(time.Duration) roundTicker {
+	t := &manualTicker{ch: make(chan time.Time, 1), clock: mc}
+	mc.mu.Lock()
+	mc.tickers[t] = struct{}{}
+	mc.mu.Unlock()
+	return t
+}
+
+// tick ends the current round of every running process. Like time.Ticker, ticks are dropped for slow receivers.
+func (mc *manualClock) tick() {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	now := time.Now()
+	for t := range mc.tickers {
+		select {
+		case t.ch <- now:
+		default:
+		}
+	}
+}
+
+type manualTicker struct {
+	ch    chan time.Time
+	clock *manualClock
+}
+
+func (t *manualTicker) c() <-chan time.Time {
+	return t.ch
+}
+
+func (t *manualTicker) stop() {
+	t.clock.mu.Lock()
+	delete(t.clock.tickers, t)
+	t.clock.mu.Unlock()
+}
+
+// roundRange selects messages by their round counter, both ends are inclusive.
+type roundRange struct {
+	from, to int32
+}
+
+var anyRound = roundRange{preRound, math.MaxInt32}
+
+func rounds(from, to int32) roundRange {
+	return roundRange{from, to}
+}
+
+func (r roundRange) contains(k int32) bool {
+	return k >= r.from && k <= r.to
+}
+
+// fault is an adversarial condition of a scenario. Faults that affect the delivery of messages implement dropper.
+type fault interface{}
+
+type dropper interface {
+	// drops returns true if the message sent by node from should not be delivered to node to.
+	drops(from, to int, m *Message, rng *rand.Rand) bool
+}
+
+// partition drops the messages between nodes of different groups. Nodes that are not listed form a group of their own.
+type partition struct {
+	rounds roundRange
+	groups [][]int
+}
+
+func (p partition) group(node int) int {
+	for i, g := range p.groups {
+		for _, n := range g {
+			if n == node {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func (p partition) drops(from, to int, m *Message, _ *rand.Rand) bool {
+	return p.rounds.contains(m.InnerMsg.K) && p.group(from) != p.group(to)
+}
+
+// drop drops a fraction of the messages of the given types, or of all types if none is given.
+type drop struct {
+	rounds roundRange
+	rate   float64
+	types  []messageType
+}
+
+func (d drop) drops(_, _ int, m *Message, rng *rand.Rand) bool {
+	if !d.rounds.contains(m.InnerMsg.K) {
+		return false
+	}
+	match := len(d.types) == 0
+	for _, t := range d.types {
+		match = match || t == m.InnerMsg.Type
+	}
+	return match && rng.Float64() < d.rate
+}
+
+// silent nodes send no messages at all.
+type silent struct {
+	nodes []int
+}
+
+func (s silent) drops(from, _ int, _ *Message, _ *rand.Rand) bool {
+	return containsNode(s.nodes, from)
+}
+
+// reorder holds the messages received in the rounds and delivers them in a random order, once window messages were
+// held or shortly after the first one was.
+type reorder struct {
+	rounds roundRange
+	window int
+}
+
+// equivocate makes the nodes send a second, conflicting message for every message they send. With split, half of
+// the nodes receive only the first messages and the other half only the second ones, so that the equivocation can't
+// be detected.
+type equivocate struct {
+	nodes []int
+	split bool
+}
+
+func containsNode(nodes []int, node int) bool {
+	for _, n := range nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
+// scenario declares the faults a set of hare nodes is subjected to and the properties expected to hold.
+type scenario struct {
+	nodes     int
+	layers    int
+	maxRounds int // the number of rounds a layer runs for before it is given up on, including the pre-round
+	faults    []fault
+	sets      map[int][]types.BlockID // the initial sets of the nodes, defaultScenarioSet if missing
+
+	expectTermination bool // all honest nodes produce an output for every layer
+}
+
+var defaultScenarioSet = []types.BlockID{value1, value2, value3}
+
+const (
+	reorderDelay   = 20 * time.Millisecond
+	settleDelay    = 50 * time.Millisecond
+	scenarioWait   = 5 * time.Second
+	scenarioRounds = 1 + 4*4
+)
+
+// scenarioRunner runs hare nodes on top of the simulator. The rounds are advanced by a manual clock and the messages
+// are filtered by the faults of the scenario.
+type scenarioRunner struct {
+	t     *testing.T
+	sc    scenario
+	sim   *service.Simulator
+	clock *manualClock
+	nodes []*scenarioNode
+	done  chan struct{}
+
+	mu     sync.Mutex
+	rng    *rand.Rand
+	byKey  map[string]int  // p2p public key -> node index
+	second map[string]bool // signatures of the conflicting messages of split view equivocators
+}
+
+// scenarioNode is the network of a single hare node, it filters the messages received from the simulator.
+type scenarioNode struct {
+	*service.Node
+	idx    int
+	run    *scenarioRunner
+	signer *signing.EdSigner
+	hare   *Hare
+	layers chan types.LayerID
+}
+
+type scenarioBlocks struct {
+	ids []types.BlockID
+}
+
+func (sb *scenarioBlocks) HandleValidatedLayer(types.LayerID, []types.BlockID) {
+}
+
+func (sb *scenarioBlocks) LayerBlockIds(types.LayerID) ([]types.BlockID, error) {
+	return sb.ids, nil
+}
+
+func newScenarioRunner(t *testing.T, sc scenario) *scenarioRunner {
+	if sc.maxRounds == 0 {
+		sc.maxRounds = scenarioRounds
+	}
+	r := &scenarioRunner{
+		t:      t,
+		sc:     sc,
+		sim:    service.NewSimulator(),
+		clock:  newManualClock(),
+		done:   make(chan struct{}),
+		rng:    rand.New(rand.NewSource(int64(len(t.Name())))),
+		byKey:  make(map[string]int),
+		second: make(map[string]bool),
+	}
+	cfg := config.Config{N: sc.nodes, F: sc.nodes/2 - 1, RoundDuration: 1, ExpectedLeaders: 5, LimitIterations: 1000, LimitConcurrent: 100}
+	for i := 0; i < sc.nodes; i++ {
+		n := &scenarioNode{Node: r.sim.NewNode(), idx: i, run: r, signer: signing.NewEdSigner(), layers: make(chan types.LayerID, 1)}
+		r.byKey[n.PublicKey().String()] = i
+		set, ok := sc.sets[i]
+		if !ok {
+			set = defaultScenarioSet
+		}
+		nodeID := types.NodeID{Key: n.signer.PublicKey().String(), VRFPublicKey: []byte{}}
+		n.hare = New(cfg, n, n.signer, nodeID, validateBlock, isSynced, &scenarioBlocks{set}, &trueOracle{}, 10,
+			&mockIdentityP{nid: nodeID}, &MockStateQuerier{true, nil}, database.NewMemDatabase(), n.layers,
+			log.NewDefault(fmt.Sprintf("%v_%v", t.Name(), i)))
+		n.hare.clock = r.clock
+		r.nodes = append(r.nodes, n)
+	}
+	return r
+}
+
+func (r *scenarioRunner) equivocation(node int) *equivocate {
+	for _, f := range r.sc.faults {
+		if e, ok := f.(equivocate); ok && containsNode(e.nodes, node) {
+			return &e
+		}
+	}
+	return nil
+}
+
+func (r *scenarioRunner) honest(node int) bool {
+	for _, f := range r.sc.faults {
+		switch f := f.(type) {
+		case equivocate:
+			if containsNode(f.nodes, node) {
+				return false
+			}
+		case silent:
+			if containsNode(f.nodes, node) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+type fate int
+
+const (
+	deliver fate = iota
+	discard
+	hold
+)
+
+// fate decides what happens to a message sent by node from to node to.
+func (r *scenarioRunner) fate(from, to int, m *Message) (fate, int) {
+	if from == to {
+		return deliver, 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e := r.equivocation(from); e != nil && e.split && r.second[string(m.Sig)] == (to < r.sc.nodes/2) {
+		return discard, 0
+	}
+	for _, f := range r.sc.faults {
+		if d, ok := f.(dropper); ok && d.drops(from, to, m, r.rng) {
+			return discard, 0
+		}
+	}
+	for _, f := range r.sc.faults {
+		if ro, ok := f.(reorder); ok && ro.rounds.contains(m.InnerMsg.K) {
+			return hold, ro.window
+		}
+	}
+	return deliver, 0
+}
+
+func (n *scenarioNode) RegisterGossipProtocol(protocol string, prio priorityq.Priority) chan service.GossipMessage {
+	in := n.Node.RegisterGossipProtocol(protocol, prio)
+	out := make(chan service.GossipMessage, 1000)
+	go n.receive(in, out)
+	return out
+}
+
+func (n *scenarioNode) sender(gm service.GossipMessage) int {
+	return n.run.byKey[gm.Sender().String()]
+}
+
+// receive applies the faults of the scenario to the messages received from the simulator.
+func (n *scenarioNode) receive(in, out chan service.GossipMessage) {
+	var held []service.GossipMessage
+	var flushTimer <-chan time.Time
+	flush := func() {
+		n.run.mu.Lock()
+		n.run.rng.Shuffle(len(held), func(i, j int) { held[i], held[j] = held[j], held[i] })
+		n.run.mu.Unlock()
+		for _, gm := range held {
+			out <- gm
+		}
+		held = nil
+		flushTimer = nil
+	}
+
+	for {
+		select {
+		case gm := <-in:
+			m, err := MessageFromBuffer(gm.Bytes())
+			if err != nil || m.InnerMsg == nil {
+				out <- gm
+				continue
+			}
+			switch f, window := n.run.fate(n.sender(gm), n.idx, m); f {
+			case discard:
+			case hold:
+				held = append(held, gm)
+				if flushTimer == nil {
+					flushTimer = time.After(reorderDelay)
+				}
+				if len(held) >= window {
+					flush()
+				}
+			default:
+				out <- gm
+			}
+		case <-flushTimer:
+			flush()
+		case <-n.run.done:
+			return
+		}
+	}
+}
+
+// Broadcast sends the message, and a conflicting one if the node equivocates.
+func (n *scenarioNode) Broadcast(protocol string, payload []byte) error {
+	if e := n.run.equivocation(n.idx); e != nil {
+		if m, err := MessageFromBuffer(payload); err == nil && m.InnerMsg != nil {
+			c := n.conflicting(m)
+			n.run.mu.Lock()
+			n.run.second[string(c.Sig)] = true
+			n.run.mu.Unlock()
+			data, err := types.InterfaceToBytes(c)
+			if err != nil {
+				return err
+			}
+			if err := n.Node.Broadcast(protocol, data); err != nil {
+				return err
+			}
+		}
+	}
+	return n.Node.Broadcast(protocol, payload)
+}
+
+// conflicting returns a copy of the message with an additional bogus value, signed by the node.
+func (n *scenarioNode) conflicting(m *Message) *Message {
+	inner := *m.InnerMsg
+	inner.Values = append(append([]types.BlockID(nil), m.InnerMsg.Values...), types.BlockID(types.CalcHash32(m.Sig).ToHash20()))
+	return &Message{Sig: n.signer.Sign(inner.Bytes()), InnerMsg: &inner}
+}
+
+// status returns the status of the process of the node for the layer, if it was started.
+func (n *scenarioNode) status(layer types.LayerID) (InstanceStatus, bool) {
+	for _, st := range n.hare.Instances() {
+		if st.Layer == layer {
+			return st, true
+		}
+	}
+	return InstanceStatus{}, false
+}
+
+// waitFor polls cond until it holds or the timeout expires.
+func (r *scenarioRunner) waitFor(cond func() bool, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return true
+}
+
+// tick ends the current round of the layer and waits for the processes to begin the next one.
+func (r *scenarioRunner) tick(layer types.LayerID) {
+	before := make([]int32, len(r.nodes))
+	for i, n := range r.nodes {
+		st, _ := n.status(layer)
+		before[i] = st.K
+	}
+	r.clock.tick()
+	advanced := r.waitFor(func() bool {
+		for i, n := range r.nodes {
+			if st, _ := n.status(layer); !st.Terminated && st.K <= before[i] {
+				return false
+			}
+		}
+		return true
+	}, scenarioWait)
+	require.True(r.t, advanced, "processes of layer %v did not advance", layer)
+	time.Sleep(settleDelay)
+}
+
+func (r *scenarioRunner) layerDone(layer types.LayerID) bool {
+	for _, n := range r.nodes {
+		if st, _ := n.status(layer); r.honest(n.idx) && !st.Terminated {
+			return false
+		}
+	}
+	return true
+}
+
+// run runs the layers of the scenario one after the other, then checks and reports the outcome.
+func (r *scenarioRunner) run() {
+	defer r.close()
+	for _, n := range r.nodes {
+		require.NoError(r.t, n.hare.Start())
+	}
+
+	for l := 1; l <= r.sc.layers; l++ {
+		layer := types.LayerID(l)
+		for _, n := range r.nodes {
+			n.layers <- layer
+		}
+		started := r.waitFor(func() bool {
+			for _, n := range r.nodes {
+				if _, ok := n.status(layer); !ok {
+					return false
+				}
+			}
+			return true
+		}, scenarioWait)
+		require.True(r.t, started, "processes of layer %v did not start", layer)
+		time.Sleep(settleDelay) // deliver the pre-round messages
+
+		for round := 0; round < r.sc.maxRounds && !r.layerDone(layer); round++ {
+			r.tick(layer)
+		}
+	}
+
+	// wait for the outputs of the terminated processes to be collected
+	r.waitFor(func() bool {
+		for _, n := range r.nodes {
+			for l := 1; l <= r.sc.layers; l++ {
+				st, _ := n.status(types.LayerID(l))
+				if _, err := n.hare.GetResult(types.LayerID(l)); st.Completed && err != nil {
+					return false
+				}
+			}
+		}
+		return true
+	}, scenarioWait)
+
+	r.t.Log("outcome matrix:\n" + r.matrix())
+	r.check()
+}
+
+func (r *scenarioRunner) close() {
+	close(r.done)
+	for _, n := range r.nodes {
+		n.hare.Close()
+	}
+}
+
+func (r *scenarioRunner) role(node int) string {
+	for _, f := range r.sc.faults {
+		switch f := f.(type) {
+		case equivocate:
+			if containsNode(f.nodes, node) {
+				return "equivocating"
+			}
+		case silent:
+			if containsNode(f.nodes, node) {
+				return "silent"
+			}
+		}
+	}
+	return "honest"
+}
+
+func (r *scenarioRunner) output(node int, layer types.LayerID) *Set {
+	blocks, err := r.nodes[node].hare.GetResult(layer)
+	if err != nil {
+		return nil
+	}
+	return NewSet(blocks)
+}
+
+// matrix returns the outcome of every node in every layer: the id of the output set and the iteration it terminated
+// in, or the state of the process if it has no output.
+func (r *scenarioRunner) matrix() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, "node\trole")
+	for l := 1; l <= r.sc.layers; l++ {
+		fmt.Fprintf(w, "\tlayer %v", l)
+	}
+	fmt.Fprintln(w)
+	for i, n := range r.nodes {
+		fmt.Fprintf(w, "%v\t%v", i, r.role(i))
+		for l := 1; l <= r.sc.layers; l++ {
+			st, started := n.status(types.LayerID(l))
+			switch s := r.output(i, types.LayerID(l)); {
+			case s != nil:
+				fmt.Fprintf(w, "\t%08x@%v", s.ID(), st.Iteration())
+			case !started:
+				fmt.Fprint(w, "\tnot started")
+			case st.Terminated:
+				fmt.Fprint(w, "\tno output")
+			default:
+				fmt.Fprintf(w, "\trunning@%v", st.Iteration())
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// check asserts that the honest nodes agree on the output of every layer, and that they all have one if termination
+// is expected.
+func (r *scenarioRunner) check() {
+	for l := 1; l <= r.sc.layers; l++ {
+		layer := types.LayerID(l)
+		var agreed *Set
+		for i := range r.nodes {
+			if !r.honest(i) {
+				continue
+			}
+			s := r.output(i, layer)
+			if s == nil {
+				if r.sc.expectTermination {
+					r.t.Errorf("termination: honest node %v has no output for layer %v", i, layer)
+				}
+				continue
+			}
+			if agreed == nil {
+				agreed = s
+			} else if !agreed.Equals(s) {
+				r.t.Errorf("agreement: honest node %v output %v for layer %v, others output %v", i, s, layer, agreed)
+			}
+		}
+	}
+}
+
+func (r *scenarioRunner) publicKey(node int) *signing.PublicKey {
+	return r.nodes[node].signer.PublicKey()
+}
+
+func TestScenario_NoFaults(t *testing.T) {
+	newScenarioRunner(t, scenario{nodes: 10, layers: 2, expectTermination: true}).run()
+}
+
+func TestScenario_Reorder(t *testing.T) {
+	newScenarioRunner(t, scenario{
+		nodes:             10,
+		layers:            2,
+		faults:            []fault{reorder{rounds: anyRound, window: 5}},
+		expectTermination: true,
+	}).run()
+}
+
+func TestScenario_PartitionFirstIteration(t *testing.T) {
+	newScenarioRunner(t, scenario{
+		nodes:  10,
+		layers: 1,
+		faults: []fault{partition{rounds: rounds(preRound, 3), groups: [][]int{{0, 1, 2, 3, 4, 5}, {6, 7, 8, 9}}}},
+		sets: map[int][]types.BlockID{
+			6: {value1, value2, value3, value4},
+			7: {value1, value2, value3, value4},
+			8: {value1, value2, value3, value4},
+			9: {value1, value2, value3, value4},
+		},
+	}).run()
+}
+
+func TestScenario_DropCommits(t *testing.T) {
+	newScenarioRunner(t, scenario{
+		nodes:  10,
+		layers: 2,
+		faults: []fault{drop{rounds: anyRound, rate: 0.3, types: []messageType{commit}}},
+	}).run()
+}
+
+func TestScenario_Equivocator(t *testing.T) {
+	r := require.New(t)
+	run := newScenarioRunner(t, scenario{
+		nodes:             10,
+		layers:            1,
+		faults:            []fault{equivocate{nodes: []int{3}}},
+		expectTermination: true,
+	})
+	run.run()
+
+	for i, n := range run.nodes {
+		if i != 3 {
+			r.True(n.hare.IsMalicious(run.publicKey(3).String()), "node %v did not detect the equivocation", i)
+		}
+	}
+}
+
+func TestScenario_SplitViewEquivocatorAndSilentNodes(t *testing.T) {
+	run := newScenarioRunner(t, scenario{
+		nodes:  10,
+		layers: 1,
+		faults: []fault{equivocate{nodes: []int{0}, split: true}, silent{nodes: []int{9}}},
+	})
+	run.run()
+
+	for i, n := range run.nodes {
+		if i != 0 && n.hare.IsMalicious(run.publicKey(0).String()) {
+			t.Errorf("node %v detected a split view equivocation", i)
+		}
+	}
+}
+
+func TestScenarioRunner_Fate(t *testing.T) {
+	r := require.New(t)
+	run := newScenarioRunner(t, scenario{
+		nodes: 4,
+		faults: []fault{
+			partition{rounds: rounds(2, 5), groups: [][]int{{0, 1}, {2, 3}}},
+			drop{rounds: anyRound, rate: 1, types: []messageType{commit}},
+			equivocate{nodes: []int{1}, split: true},
+		},
+	})
+	defer close(run.done)
+
+	status := BuildStatusMsg(run.nodes[0].signer, NewSetFromValues(value1)).Message
+	status.InnerMsg.K = 4
+	f, _ := run.fate(0, 2, status)
+	r.Equal(discard, f)
+	f, _ = run.fate(0, 1, status)
+	r.Equal(deliver, f)
+	status.InnerMsg.K = 8
+	f, _ = run.fate(0, 2, status)
+	r.Equal(deliver, f)
+
+	f, _ = run.fate(0, 1, BuildCommitMsg(run.nodes[0].signer, NewSetFromValues(value1)).Message)
+	r.Equal(discard, f)
+	f, _ = run.fate(0, 0, BuildCommitMsg(run.nodes[0].signer, NewSetFromValues(value1)).Message)
+	r.Equal(deliver, f)
+
+	first := BuildStatusMsg(run.nodes[1].signer, NewSetFromValues(value1)).Message
+	second := run.nodes[1].conflicting(first)
+	r.False(bytes.Equal(first.InnerMsg.Bytes(), second.InnerMsg.Bytes()))
+	run.second[string(second.Sig)] = true
+	f, _ = run.fate(1, 0, first)
+	r.Equal(deliver, f)
+	f, _ = run.fate(1, 0, second)
+	r.Equal(discard, f)
+	f, _ = run.fate(1, 3, second)
+	r.Equal(deliver, f)
+}
+
+func TestManualClock(t *testing.T) {
+	r := require.New(t)
+	clock := newManualClock()
+	t1 := clock.newTicker(time.Second)
+	t2 := clock.newTicker(time.Second)
+	clock.tick()
+	clock.tick() // dropped, the tickers weren't read
+	r.Len(t1.c(), 1)
+	r.Len(t2.c(), 1)
+	<-t1.c()
+	t2.stop()
+	<-t2.c()
+	clock.tick()
+	r.Len(t1.c(), 1)
+	r.Len(t2.c(), 0)
+}
@@ -0,0 +1,145 @@
+package hare
+
+import (
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"sync"
+	"time"
+)
+
+// RoundEligibility tells whether a participant was eligible to send a message in a round. It is only recorded for the
+// rounds in which the participant had a message to send.
+type RoundEligibility struct {
+	K        int32
+	Eligible bool
+}
+
+// InstanceStatus is a snapshot of the state of a consensus process.
+type InstanceStatus struct {
+	Layer  types.LayerID
+	NodeID string // the participant running the process
+	K      int32
+	Ki     int32
+	Set    []types.BlockID
+
+	// the number of messages held by each tracker
+	PreRoundMsgs int
+	StatusMsgs   int
+	ProposalMsgs int
+	CommitMsgs   int
+	NotifyMsgs   int
+	PendingMsgs  int
+
+	ConflictingProposal bool
+	Eligibility         []RoundEligibility
+
+	Started    time.Time
+	Ended      time.Time // zero while running
+	Terminated bool
+	Completed  bool // terminated with an agreed set
+}
+
+// Round returns the name of the current round.
+func (st *InstanceStatus) Round() string {
+	return roundName(st.K)
+}
+
+// Iteration returns the current iteration, or the iteration the process finished in if it terminated.
+func (st *InstanceStatus) Iteration() int32 {
+	if st.K < 0 {
+		return 0
+	}
+	return iterationFromCounter(st.K)
+}
+
+// Duration returns the time the process ran for.
+func (st *InstanceStatus) Duration() time.Duration {
+	if st.Ended.IsZero() {
+		return time.Since(st.Started)
+	}
+	return st.Ended.Sub(st.Started)
+}
+
+// instanceMonitor holds the latest status of a consensus process. The status is updated by the process and read by
+// the hare concurrently.
+type instanceMonitor struct {
+	mu     sync.Mutex
+	status InstanceStatus
+}
+
+func newInstanceMonitor(layer instanceID, nid types.NodeID) *instanceMonitor {
+	return &instanceMonitor{status: InstanceStatus{Layer: types.LayerID(layer), NodeID: nid.Key, K: -1, Ki: -1, Started: time.Now()}}
+}
+
+func (m *instanceMonitor) recordEligibility(k int32, eligible bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status.Eligibility = append(m.status.Eligibility, RoundEligibility{K: k, Eligible: eligible})
+}
+
+func (m *instanceMonitor) update(f func(st *InstanceStatus)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f(&m.status)
+}
+
+func (m *instanceMonitor) snapshot() InstanceStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.status
+	st.Set = append([]types.BlockID(nil), m.status.Set...)
+	st.Eligibility = append([]RoundEligibility(nil), m.status.Eligibility...)
+	return st
+}
+
+// Status returns a snapshot of the state of the consensus process.
+func (proc *consensusProcess) Status() InstanceStatus {
+	return proc.monitor.snapshot()
+}
+
+// updateStatus publishes the state of the process and its trackers to the monitor. It must be called from the goroutine
+// running the process.
+func (proc *consensusProcess) updateStatus() {
+	var set []types.BlockID
+	if proc.s != nil {
+		set = proc.s.ToSlice()
+	}
+	proc.updateCounters()
+	proc.monitor.update(func(cur *InstanceStatus) {
+		cur.Set = set
+	})
+}
+
+// updateCounters publishes the state of the process and its trackers except for the set, which is expensive to copy
+// and only changes between rounds. It must be called from the goroutine running the process.
+func (proc *consensusProcess) updateCounters() {
+	st := InstanceStatus{K: proc.k, Ki: proc.ki, PreRoundMsgs: len(proc.preRoundTracker.preRound), PendingMsgs: len(proc.pending)}
+	if proc.statusesTracker != nil {
+		st.StatusMsgs = len(proc.statusesTracker.statuses)
+	}
+	if pt, ok := proc.proposalTracker.(*proposalTracker); ok && pt != nil {
+		if pt.proposal != nil {
+			st.ProposalMsgs = 1
+		}
+		st.ConflictingProposal = pt.isConflicting
+	}
+	if ct, ok := proc.commitTracker.(*commitTracker); ok && ct != nil {
+		st.CommitMsgs = len(ct.commits)
+	}
+	st.NotifyMsgs = len(proc.notifyTracker.notifies)
+
+	proc.monitor.update(func(cur *InstanceStatus) {
+		cur.K, cur.Ki = st.K, st.Ki
+		cur.PreRoundMsgs, cur.StatusMsgs, cur.ProposalMsgs = st.PreRoundMsgs, st.StatusMsgs, st.ProposalMsgs
+		cur.CommitMsgs, cur.NotifyMsgs, cur.PendingMsgs = st.CommitMsgs, st.NotifyMsgs, st.PendingMsgs
+		cur.ConflictingProposal = st.ConflictingProposal
+	})
+}
+
+func (proc *consensusProcess) markTerminated(completed bool) {
+	proc.updateStatus()
+	proc.monitor.update(func(st *InstanceStatus) {
+		st.Terminated = true
+		st.Completed = completed
+		st.Ended = time.Now()
+	})
+}
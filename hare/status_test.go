@@ -0,0 +1,68 @@
+package hare
+
+import (
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConsensusProcess_Status(t *testing.T) {
+	r := require.New(t)
+	proc := generateConsensusProcess(t)
+	st := proc.Status()
+	r.Equal(types.LayerID(instanceID1), st.Layer)
+	r.Equal(int32(-1), st.K)
+	r.Equal("pre-round", st.Round())
+	r.False(st.Terminated)
+
+	proc.processMsg(BuildPreRoundMsg(signing.NewEdSigner(), NewSetFromValues(value1)))
+	proc.processMsg(BuildPreRoundMsg(signing.NewEdSigner(), NewSetFromValues(value1)))
+	proc.updateStatus()
+	r.True(proc.shouldParticipate())
+	st = proc.Status()
+	r.Equal(2, st.PreRoundMsgs)
+	r.Equal([]types.BlockID{value1}, st.Set)
+	r.Equal([]RoundEligibility{{K: -1, Eligible: true}}, st.Eligibility)
+
+	proc.advanceToNextRound()
+	proc.statusesTracker = newStatusTracker(cfg.F+1, cfg.N)
+	proc.processMsg(BuildStatusMsg(signing.NewEdSigner(), NewSetFromValues(value1)))
+	proc.updateStatus()
+	st = proc.Status()
+	r.Equal("status", st.Round())
+	r.Equal(1, st.StatusMsgs)
+	r.Equal(0, st.CommitMsgs)
+
+	proc.k = 9
+	proc.markTerminated(true)
+	st = proc.Status()
+	r.True(st.Terminated)
+	r.True(st.Completed)
+	r.False(st.Ended.IsZero())
+	r.Equal(int32(2), st.Iteration())
+	r.Equal(st.Ended.Sub(st.Started), st.Duration())
+}
+
+func TestHare_Instances(t *testing.T) {
+	r := require.New(t)
+	sim := service.NewSimulator()
+	h := createHare(sim.NewNode(), log.NewDefault(t.Name()))
+
+	h.addInstance(&mockConsensusProcess{id: 2})
+	h.addInstance(&mockConsensusProcess{id: 1})
+	h.addInstance(&mockConsensusProcess{id: 2})
+	instances := h.Instances()
+	r.Len(instances, 3)
+	r.Equal(types.LayerID(1), instances[0].Layer)
+	r.Equal(types.LayerID(2), instances[1].Layer)
+
+	// the processes of layers out of the buffer are dropped
+	h.lastLayer = types.LayerID(h.bufferSize + 10)
+	h.addInstance(&mockConsensusProcess{id: instanceID(h.bufferSize + 10)})
+	instances = h.Instances()
+	r.Len(instances, 1)
+	r.Equal(h.lastLayer, instances[0].Layer)
+}
@@ -40,12 +40,12 @@ func (st *statusTracker) RecordStatus(msg *Msg) {
 
 // AnalyzeStatuses analyzes the recorded status messages by the validation function.
 func (st *statusTracker) AnalyzeStatuses(isValid func(m *Msg) bool) {
-	count := 0
+	weight := 0
 	for key, m := range st.statuses {
-		if !isValid(m) || count == st.threshold { // only keep valid Messages
+		if !isValid(m) || weight >= st.threshold { // only keep valid Messages
 			delete(st.statuses, key)
 		} else {
-			count++
+			weight += int(m.InnerMsg.EligibilityCount)
 			if m.InnerMsg.Ki >= st.maxKi { // track max Ki & matching raw set
 				st.maxKi = m.InnerMsg.Ki
 				st.maxSet = NewSet(m.InnerMsg.Values)
@@ -58,7 +58,17 @@ func (st *statusTracker) AnalyzeStatuses(isValid func(m *Msg) bool) {
 
 // IsSVPReady returns true if theere are enough statuses to build an SVP, false otherwise.
 func (st *statusTracker) IsSVPReady() bool {
-	return st.analyzed && len(st.statuses) == st.threshold
+	return st.analyzed && st.weight() >= st.threshold
+}
+
+// weight returns the total eligibility count of the recorded status Messages.
+func (st *statusTracker) weight() int {
+	weight := 0
+	for _, m := range st.statuses {
+		weight += int(m.InnerMsg.EligibilityCount)
+	}
+
+	return weight
 }
 
 // ProposalSet returns the proposed set if available, nil otherwise.
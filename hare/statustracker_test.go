@@ -105,3 +105,18 @@ func TestStatusTracker_AnalyzeStatuses(t *testing.T) {
 	tracker.AnalyzeStatuses(validate)
 	assert.Equal(t, 2, len(tracker.statuses))
 }
+
+func TestStatusTracker_Weighted(t *testing.T) {
+	tracker := newStatusTracker(5, 5)
+	s := NewSetFromValues(value1)
+	sgn := generateSigning(t)
+	tracker.RecordStatus(withEligibilityCount(sgn, BuildStatusMsg(sgn, s), 4))
+	tracker.AnalyzeStatuses(validate)
+	assert.False(t, tracker.IsSVPReady())
+
+	sgn = generateSigning(t)
+	tracker.RecordStatus(withEligibilityCount(sgn, BuildStatusMsg(sgn, s), 2))
+	tracker.AnalyzeStatuses(validate)
+	assert.True(t, tracker.IsSVPReady())
+	assert.Equal(t, 2, len(tracker.BuildSVP().Messages))
+}
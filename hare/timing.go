@@ -0,0 +1,151 @@
+package hare
+
+import (
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/hare/config"
+	"github.com/spacemeshos/go-spacemesh/hare/metrics"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const timingSamples = 4096 // the number of latest lateness samples the recommendation is derived from
+
+// roundTiming collects the lateness of the messages received by the consensus processes relative to the start of their
+// round, and derives the round duration within which a given percentile of the messages arrive.
+type roundTiming struct {
+	mu      sync.Mutex
+	samples []time.Duration // a ring buffer of the latest samples
+	next    int
+	count   int
+}
+
+func newRoundTiming() *roundTiming {
+	return &roundTiming{samples: make([]time.Duration, timingSamples)}
+}
+
+// observe records the lateness of a message of the round. Messages that arrived early are on time.
+func (rt *roundTiming) observe(round string, lateness time.Duration) {
+	if lateness < 0 {
+		lateness = 0
+	}
+	metrics.MessageLateness.With("round", round).Observe(lateness.Seconds())
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.samples[rt.next] = lateness
+	rt.next = (rt.next + 1) % len(rt.samples)
+	if rt.count < len(rt.samples) {
+		rt.count++
+	}
+}
+
+// percentile returns the lateness within which the fraction p of the sampled messages arrived, and the number of
+// samples.
+func (rt *roundTiming) percentile(p float64) (time.Duration, int) {
+	rt.mu.Lock()
+	sorted := append([]time.Duration(nil), rt.samples[:rt.count]...)
+	rt.mu.Unlock()
+
+	if len(sorted) == 0 {
+		return 0, 0
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i], len(sorted)
+}
+
+// RoundTiming is a report of the observed message lateness and the round duration derived from it.
+type RoundTiming struct {
+	Samples    int           // the number of messages the report is based on
+	Percentile float64       // the fraction of the messages the recommended round duration covers
+	Lateness   time.Duration // the lateness within which the percentile of the messages arrived
+
+	RecommendedRoundDuration int // in seconds, zero if there are no samples
+	RoundDuration            int // the round duration of the latest layer, in seconds
+	WakeupDelta              time.Duration
+}
+
+// recommendedDuration rounds the lateness up to the whole seconds the round duration is configured in.
+func recommendedDuration(lateness time.Duration) int {
+	secs := int(math.Ceil(lateness.Seconds()))
+	if secs < 1 {
+		return 1
+	}
+	return secs
+}
+
+// RoundTiming reports the lateness of the messages received in the recent layers and the round duration that would
+// let the configured percentile of them arrive in time.
+func (h *Hare) RoundTiming() RoundTiming {
+	h.layerLock.RLock()
+	layer := h.lastLayer
+	h.layerLock.RUnlock()
+
+	res := RoundTiming{Percentile: h.config.TimingPercentile}
+	if res.Percentile <= 0 || res.Percentile > 1 {
+		res.Percentile = config.DefaultConfig().TimingPercentile
+	}
+	res.Lateness, res.Samples = h.timing.percentile(res.Percentile)
+	if res.Samples > 0 {
+		res.RecommendedRoundDuration = recommendedDuration(res.Lateness)
+	}
+	res.RoundDuration, res.WakeupDelta = h.layerTiming(layer)
+	return res
+}
+
+// reportTiming exports the recommended round duration and logs it when it differs from the one in use.
+func (h *Hare) reportTiming() {
+	t := h.RoundTiming()
+	if t.Samples == 0 {
+		return
+	}
+	metrics.RecommendedRoundDuration.Set(float64(t.RecommendedRoundDuration))
+	if t.RecommendedRoundDuration != t.RoundDuration {
+		h.With().Info("observed message lateness suggests a different hare round duration",
+			log.Int("recommended_sec", t.RecommendedRoundDuration),
+			log.Int("current_sec", t.RoundDuration),
+			log.String("lateness", t.Lateness.String()),
+			log.Int("samples", t.Samples))
+	}
+}
+
+// layerTiming returns the round duration and the wakeup delta of the layer. When the network agreed on parameter sets
+// for upcoming epochs, the latest set in effect in the epoch of the layer overrides the configured ones.
+func (h *Hare) layerTiming(layer types.LayerID) (int, time.Duration) {
+	roundDuration, wakeupDelta := h.config.RoundDuration, h.networkDelta
+	if len(h.config.EpochTimings) == 0 {
+		return roundDuration, wakeupDelta
+	}
+
+	epoch := layer.GetEpoch(h.layersPerEpoch)
+	var active *config.EpochTiming
+	for i, t := range h.config.EpochTimings {
+		if types.EpochID(t.Epoch) <= epoch && (active == nil || t.Epoch >= active.Epoch) {
+			active = &h.config.EpochTimings[i]
+		}
+	}
+	if active != nil {
+		roundDuration, wakeupDelta = active.RoundDuration, time.Duration(active.WakeupDelta)*time.Second
+	}
+	return roundDuration, wakeupDelta
+}
+
+// observeLateness records how long after the start of its round the message arrived. The process is assumed to have
+// started its rounds in time, so the lateness includes the clock offset between the sender and the node.
+func (proc *consensusProcess) observeLateness(m *Msg) {
+	if proc.timing == nil || proc.roundStart.IsZero() || m.PubKey.String() == proc.signing.PublicKey().String() {
+		return
+	}
+	roundDuration := time.Duration(proc.cfg.RoundDuration) * time.Second
+	lateness := time.Since(proc.roundStart) + time.Duration(proc.k-m.InnerMsg.K)*roundDuration
+	proc.timing.observe(roundName(m.InnerMsg.K), lateness)
+}
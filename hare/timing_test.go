@@ -0,0 +1,143 @@
+package hare
+
+import (
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/hare/config"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRoundTiming_Percentile(t *testing.T) {
+	r := require.New(t)
+	rt := newRoundTiming()
+	_, n := rt.percentile(0.95)
+	r.Equal(0, n)
+
+	for i := 1; i <= 100; i++ {
+		rt.observe("status", time.Duration(i)*time.Millisecond)
+	}
+	rt.observe("status", -time.Second) // early
+	lateness, n := rt.percentile(0.95)
+	r.Equal(101, n)
+	r.Equal(95*time.Millisecond, lateness)
+	lateness, _ = rt.percentile(0)
+	r.Equal(time.Duration(0), lateness)
+	lateness, _ = rt.percentile(1)
+	r.Equal(100*time.Millisecond, lateness)
+
+	// only the latest samples are kept
+	for i := 0; i < timingSamples; i++ {
+		rt.observe("commit", 3*time.Second)
+	}
+	lateness, n = rt.percentile(0.5)
+	r.Equal(timingSamples, n)
+	r.Equal(3*time.Second, lateness)
+}
+
+func TestRecommendedDuration(t *testing.T) {
+	r := require.New(t)
+	r.Equal(1, recommendedDuration(0))
+	r.Equal(1, recommendedDuration(300*time.Millisecond))
+	r.Equal(2, recommendedDuration(1100*time.Millisecond))
+	r.Equal(3, recommendedDuration(3*time.Second))
+}
+
+func TestHare_LayerTiming(t *testing.T) {
+	r := require.New(t)
+	h := createHare(service.NewSimulator().NewNode(), log.NewDefault(t.Name()))
+	h.networkDelta = 5 * time.Second
+
+	round, wakeup := h.layerTiming(25)
+	r.Equal(cfg.RoundDuration, round)
+	r.Equal(5*time.Second, wakeup)
+
+	h.config.EpochTimings = []config.EpochTiming{
+		{Epoch: 4, RoundDuration: 8, WakeupDelta: 2},
+		{Epoch: 2, RoundDuration: 6, WakeupDelta: 3},
+	}
+	round, wakeup = h.layerTiming(15) // epoch 1
+	r.Equal(cfg.RoundDuration, round)
+	r.Equal(5*time.Second, wakeup)
+	round, wakeup = h.layerTiming(25) // epoch 2
+	r.Equal(6, round)
+	r.Equal(3*time.Second, wakeup)
+	round, wakeup = h.layerTiming(types.LayerID(100)) // epoch 10
+	r.Equal(8, round)
+	r.Equal(2*time.Second, wakeup)
+}
+
+func TestConfig_ValidateEpochTimings(t *testing.T) {
+	r := require.New(t)
+	conf := config.DefaultConfig()
+	r.NoError(conf.ValidateEpochTimings())
+
+	conf.EpochTimings = []config.EpochTiming{{Epoch: 4, RoundDuration: 8, WakeupDelta: 0}, {Epoch: 2, RoundDuration: 6, WakeupDelta: 3}}
+	r.NoError(conf.ValidateEpochTimings())
+
+	for _, bad := range [][]config.EpochTiming{
+		{{Epoch: 2, RoundDuration: 0, WakeupDelta: 3}},
+		{{Epoch: 2, RoundDuration: -1, WakeupDelta: 3}},
+		{{Epoch: 2, RoundDuration: 6, WakeupDelta: -1}},
+		{{Epoch: 2, RoundDuration: 6, WakeupDelta: 3}, {Epoch: 2, RoundDuration: 8, WakeupDelta: 3}},
+	} {
+		conf.EpochTimings = bad
+		r.Error(conf.ValidateEpochTimings(), "%v", bad)
+	}
+}
+
+func TestHare_RoundTiming(t *testing.T) {
+	r := require.New(t)
+	h := createHare(service.NewSimulator().NewNode(), log.NewDefault(t.Name()))
+	h.config.TimingPercentile = 0.9
+
+	report := h.RoundTiming()
+	r.Equal(0, report.Samples)
+	r.Equal(0, report.RecommendedRoundDuration)
+	r.Equal(cfg.RoundDuration, report.RoundDuration)
+
+	for i := 0; i < 9; i++ {
+		h.timing.observe("status", 500*time.Millisecond)
+	}
+	h.timing.observe("status", 4*time.Second)
+	report = h.RoundTiming()
+	r.Equal(10, report.Samples)
+	r.Equal(0.9, report.Percentile)
+	r.Equal(500*time.Millisecond, report.Lateness)
+	r.Equal(1, report.RecommendedRoundDuration)
+
+	h.config.TimingPercentile = 1
+	r.Equal(4, h.RoundTiming().RecommendedRoundDuration)
+}
+
+func TestConsensusProcess_ObserveLateness(t *testing.T) {
+	r := require.New(t)
+	proc := generateConsensusProcess(t)
+	proc.timing = newRoundTiming()
+	proc.cfg.RoundDuration = 2
+	proc.k = 5
+	proc.roundStart = time.Now().Add(-time.Second)
+
+	// own messages are not sampled
+	own := BuildStatusMsg(proc.signing, NewSetFromValues(value1))
+	proc.observeLateness(own)
+	_, n := proc.timing.percentile(1)
+	r.Equal(0, n)
+
+	// a message of the previous round is late by the duration of the current round so far and a whole round
+	m := BuildStatusMsg(signing.NewEdSigner(), NewSetFromValues(value1))
+	m.InnerMsg.K = 4
+	proc.observeLateness(m)
+	lateness, n := proc.timing.percentile(1)
+	r.Equal(1, n)
+	r.True(lateness >= 3*time.Second && lateness < 4*time.Second, lateness)
+
+	// a message of the next round is early
+	m.InnerMsg.K = 6
+	proc.observeLateness(m)
+	lateness, _ = proc.timing.percentile(0)
+	r.Equal(time.Duration(0), lateness)
+}
@@ -0,0 +1,205 @@
+// Package keystore provides passphrase protected storage for the node's private keys
+package keystore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spacemeshos/go-spacemesh/crypto"
+	"github.com/spacemeshos/go-spacemesh/filesystem"
+)
+
+const (
+	// Version is the version of the key file format produced by this package.
+	Version = 1
+
+	// KeyFileName is the name of an encrypted key file inside an identity directory.
+	KeyFileName = "key.json"
+
+	// PassphraseEnvVar is the environment variable that may hold the keystore passphrase.
+	PassphraseEnvVar = "SPACEMESH_KEYSTORE_PASSPHRASE"
+
+	cipherName = "aes-128-ctr"
+	kdfName    = "scrypt"
+	ivLen      = 16
+)
+
+// TypeEd25519 is the type of ed25519 keys stored in key files.
+const TypeEd25519 = "ed25519"
+
+// ErrDecrypt is returned when a key file can't be decrypted with the provided passphrase.
+var ErrDecrypt = errors.New("could not decrypt key with given passphrase")
+
+// LightKDParams are cheap key derivation params. They are meant for tests and should not be used to protect real keys.
+var LightKDParams = crypto.KDParams{N: 4096, R: 8, P: 1, SaltLen: 16, DKLen: 32}
+
+// CipherParams holds the params of the symmetric cipher.
+type CipherParams struct {
+	IV string `json:"iv"` // hex encoded
+}
+
+// CryptoJSON describes how the key is encrypted and holds the encrypted key.
+type CryptoJSON struct {
+	Cipher       string          `json:"cipher"`
+	CipherText   string          `json:"ciphertext"` // hex encoded
+	CipherParams CipherParams    `json:"cipherparams"`
+	KDF          string          `json:"kdf"`
+	KDFParams    crypto.KDParams `json:"kdfparams"`
+	MAC          string          `json:"mac"` // hex encoded
+}
+
+// KeyFile is the json representation of an encrypted key.
+type KeyFile struct {
+	Version   int        `json:"version"`
+	Type      string     `json:"type"`
+	PublicKey string     `json:"publicKey"` // hex encoded
+	Crypto    CryptoJSON `json:"crypto"`
+}
+
+// Encrypt encrypts the private key with a key derived from passphrase using the provided key derivation params.
+func Encrypt(keyType string, privKey, pubKey []byte, passphrase string, params crypto.KDParams) (*KeyFile, error) {
+	salt, err := crypto.GetRandomBytes(params.SaltLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %v", err)
+	}
+	params.Salt = hex.EncodeToString(salt)
+
+	dk, err := crypto.DeriveKeyFromPassword(passphrase, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %v", err)
+	}
+
+	iv, err := crypto.GetRandomBytes(ivLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate iv: %v", err)
+	}
+
+	cipherText, err := crypto.AesCTRXOR(dk[:16], privKey, iv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt key: %v", err)
+	}
+
+	return &KeyFile{
+		Version:   Version,
+		Type:      keyType,
+		PublicKey: hex.EncodeToString(pubKey),
+		Crypto: CryptoJSON{
+			Cipher:       cipherName,
+			CipherText:   hex.EncodeToString(cipherText),
+			CipherParams: CipherParams{IV: hex.EncodeToString(iv)},
+			KDF:          kdfName,
+			KDFParams:    params,
+			MAC:          hex.EncodeToString(mac(dk, cipherText)),
+		},
+	}, nil
+}
+
+// Decrypt returns the private key stored in the key file. ErrDecrypt is returned if the passphrase is wrong.
+func (kf *KeyFile) Decrypt(passphrase string) ([]byte, error) {
+	if kf.Version != Version {
+		return nil, fmt.Errorf("unsupported key file version %v", kf.Version)
+	}
+	if kf.Crypto.Cipher != cipherName {
+		return nil, fmt.Errorf("unsupported cipher %v", kf.Crypto.Cipher)
+	}
+	if kf.Crypto.KDF != kdfName {
+		return nil, fmt.Errorf("unsupported kdf %v", kf.Crypto.KDF)
+	}
+
+	cipherText, err := hex.DecodeString(kf.Crypto.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cipher text: %v", err)
+	}
+	iv, err := hex.DecodeString(kf.Crypto.CipherParams.IV)
+	if err != nil {
+		return nil, fmt.Errorf("malformed iv: %v", err)
+	}
+	expectedMac, err := hex.DecodeString(kf.Crypto.MAC)
+	if err != nil {
+		return nil, fmt.Errorf("malformed mac: %v", err)
+	}
+
+	dk, err := crypto.DeriveKeyFromPassword(passphrase, kf.Crypto.KDFParams)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %v", err)
+	}
+
+	if !bytes.Equal(mac(dk, cipherText), expectedMac) {
+		return nil, ErrDecrypt
+	}
+
+	return crypto.AesCTRXOR(dk[:16], cipherText, iv)
+}
+
+// ChangePassphrase re-encrypts the key file with a key derived from the new passphrase.
+func (kf *KeyFile) ChangePassphrase(oldPassphrase, newPassphrase string) (*KeyFile, error) {
+	privKey, err := kf.Decrypt(oldPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	pubKey, err := hex.DecodeString(kf.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("malformed public key: %v", err)
+	}
+
+	params := kf.Crypto.KDFParams
+	params.Salt = ""
+	return Encrypt(kf.Type, privKey, pubKey, newPassphrase, params)
+}
+
+// mac authenticates the cipher text with the second half of the derived key.
+func mac(dk, cipherText []byte) []byte {
+	return crypto.Keccak256(dk[16:32], cipherText)
+}
+
+// WriteKeyFile writes the key file to path, creating the containing directory if needed.
+func WriteKeyFile(path string, kf *KeyFile) error {
+	data, err := json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal key file: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), filesystem.OwnerReadWriteExec); err != nil {
+		return fmt.Errorf("failed to create key file directory: %v", err)
+	}
+
+	// write to a temp file and rename it so that an existing key is never left half written
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, filesystem.OwnerReadWrite); err != nil {
+		return fmt.Errorf("failed to write key file: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// ReadKeyFile reads a key file from path.
+func ReadKeyFile(path string) (*KeyFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+	kf := &KeyFile{}
+	if err := json.Unmarshal(data, kf); err != nil {
+		return nil, fmt.Errorf("failed to parse key file: %v", err)
+	}
+	return kf, nil
+}
+
+// LoadPassphrase returns the keystore passphrase. It is read from file if a path is provided, otherwise from the
+// PassphraseEnvVar environment variable. ok is false if no passphrase was configured.
+func LoadPassphrase(file string) (passphrase string, ok bool, err error) {
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read passphrase file: %v", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	}
+	passphrase, ok = os.LookupEnv(PassphraseEnvVar)
+	return passphrase, ok, nil
+}
@@ -0,0 +1,107 @@
+package keystore
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyFile_EncryptDecrypt(t *testing.T) {
+	r := require.New(t)
+	sgn := signing.NewEdSigner()
+
+	kf, err := Encrypt(TypeEd25519, sgn.ToBuffer(), sgn.PublicKey().Bytes(), "beagles", LightKDParams)
+	r.NoError(err)
+	r.Equal(sgn.PublicKey().String(), kf.PublicKey)
+	r.NotEqual(hex.EncodeToString(sgn.ToBuffer()), kf.Crypto.CipherText)
+
+	priv, err := kf.Decrypt("beagles")
+	r.NoError(err)
+	r.Equal(sgn.ToBuffer(), priv)
+
+	_, err = kf.Decrypt("poodles")
+	r.Equal(ErrDecrypt, err)
+
+	kf.Version = 2
+	_, err = kf.Decrypt("beagles")
+	r.EqualError(err, "unsupported key file version 2")
+}
+
+func TestKeyFile_ChangePassphrase(t *testing.T) {
+	r := require.New(t)
+	sgn := signing.NewEdSigner()
+
+	kf, err := Encrypt(TypeEd25519, sgn.ToBuffer(), sgn.PublicKey().Bytes(), "beagles", LightKDParams)
+	r.NoError(err)
+
+	_, err = kf.ChangePassphrase("poodles", "collies")
+	r.Equal(ErrDecrypt, err)
+
+	kf2, err := kf.ChangePassphrase("beagles", "collies")
+	r.NoError(err)
+	r.NotEqual(kf.Crypto.KDFParams.Salt, kf2.Crypto.KDFParams.Salt)
+
+	_, err = kf2.Decrypt("beagles")
+	r.Equal(ErrDecrypt, err)
+	priv, err := kf2.Decrypt("collies")
+	r.NoError(err)
+	r.Equal(sgn.ToBuffer(), priv)
+}
+
+func TestWriteReadKeyFile(t *testing.T) {
+	r := require.New(t)
+	dir, err := ioutil.TempDir("", "keystore")
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	sgn := signing.NewEdSigner()
+	kf, err := Encrypt(TypeEd25519, sgn.ToBuffer(), sgn.PublicKey().Bytes(), "beagles", LightKDParams)
+	r.NoError(err)
+
+	path := filepath.Join(dir, sgn.PublicKey().String(), KeyFileName)
+	r.NoError(WriteKeyFile(path, kf))
+
+	kf2, err := ReadKeyFile(path)
+	r.NoError(err)
+	r.Equal(kf, kf2)
+
+	_, err = ReadKeyFile(filepath.Join(dir, "missing"))
+	r.Error(err)
+}
+
+func TestLoadPassphrase(t *testing.T) {
+	r := require.New(t)
+
+	r.NoError(os.Unsetenv(PassphraseEnvVar))
+	_, ok, err := LoadPassphrase("")
+	r.NoError(err)
+	r.False(ok)
+
+	r.NoError(os.Setenv(PassphraseEnvVar, "beagles"))
+	defer os.Unsetenv(PassphraseEnvVar)
+	pass, ok, err := LoadPassphrase("")
+	r.NoError(err)
+	r.True(ok)
+	r.Equal("beagles", pass)
+
+	f, err := ioutil.TempFile("", "passphrase")
+	r.NoError(err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("collies\n")
+	r.NoError(err)
+	r.NoError(f.Close())
+
+	// a passphrase file takes precedence over the environment
+	pass, ok, err = LoadPassphrase(f.Name())
+	r.NoError(err)
+	r.True(ok)
+	r.Equal("collies", pass)
+
+	_, _, err = LoadPassphrase(f.Name() + "missing")
+	r.Error(err)
+}
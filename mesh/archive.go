@@ -0,0 +1,419 @@
+package mesh
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// ArchiveVersion is the version of the mesh archive format written by ArchiveWriter
+const ArchiveVersion = 1
+
+var archiveMagic = []byte("SMMESH")
+
+// the archive is a magic and version followed by a stream of records, each made of a kind, a big endian length, the
+// payload and a crc32 of all of them. The end record holds the sha256 of everything written before it.
+const (
+	headerRecord uint8 = iota + 1
+	layerRecord
+	endRecord
+)
+
+// maxArchiveRecord bounds the size of a single record, a layer with all its dependencies
+const maxArchiveRecord = 1 << 30
+
+// ErrArchiveCorrupt is returned when an archive fails its checksums
+var ErrArchiveCorrupt = errors.New("mesh archive is corrupt")
+
+type archiveHeader struct {
+	From types.LayerID
+	To   types.LayerID
+}
+
+type archiveEnd struct {
+	Layers   uint64
+	Checksum []byte
+}
+
+// ArchiveLayer is a layer of a mesh archive: its blocks, along with the transactions, atxs and PoET proofs they
+// reference that were not part of a previous layer of the archive
+type ArchiveLayer struct {
+	Layer  types.LayerID
+	Blocks []*types.Block
+	Txs    []*types.Transaction
+	Atxs   []*types.ActivationTx
+	Proofs [][]byte // serialized types.PoetProofMessage
+}
+
+type archiveLayer struct {
+	Layer  types.LayerID
+	Blocks []types.Block
+	Txs    []*types.Transaction
+	Atxs   []types.ActivationTx
+	Proofs [][]byte
+}
+
+// ArchiveWriter streams layers to a mesh archive
+type ArchiveWriter struct {
+	w      *bufio.Writer
+	sum    hash.Hash
+	layers uint64
+}
+
+// NewArchiveWriter writes the header of an archive of layers from to to
+func NewArchiveWriter(w io.Writer, from, to types.LayerID) (*ArchiveWriter, error) {
+	a := &ArchiveWriter{w: bufio.NewWriter(w), sum: sha256.New()}
+	version := make([]byte, 4)
+	binary.BigEndian.PutUint32(version, ArchiveVersion)
+	if err := a.write(append(append([]byte{}, archiveMagic...), version...)); err != nil {
+		return nil, err
+	}
+	if err := a.writeRecord(headerRecord, &archiveHeader{From: from, To: to}); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *ArchiveWriter) write(b []byte) error {
+	a.sum.Write(b)
+	_, err := a.w.Write(b)
+	return err
+}
+
+func (a *ArchiveWriter) writeRecord(kind uint8, v interface{}) error {
+	payload, err := types.InterfaceToBytes(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode archive record: %v", err)
+	}
+	rec := make([]byte, 5, 5+len(payload)+4)
+	rec[0] = kind
+	binary.BigEndian.PutUint32(rec[1:], uint32(len(payload)))
+	rec = append(rec, payload...)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(rec))
+	return a.write(append(rec, crc...))
+}
+
+// WriteLayer appends a layer to the archive
+func (a *ArchiveWriter) WriteLayer(l *ArchiveLayer) error {
+	rec := &archiveLayer{Layer: l.Layer, Txs: l.Txs, Proofs: l.Proofs}
+	for _, b := range l.Blocks {
+		rec.Blocks = append(rec.Blocks, *b)
+	}
+	for _, atx := range l.Atxs {
+		rec.Atxs = append(rec.Atxs, *atx)
+	}
+	if err := a.writeRecord(layerRecord, rec); err != nil {
+		return err
+	}
+	a.layers++
+	return nil
+}
+
+// Close writes the end of the archive and flushes it, it does not close the underlying writer
+func (a *ArchiveWriter) Close() error {
+	if err := a.writeRecord(endRecord, &archiveEnd{Layers: a.layers, Checksum: a.sum.Sum(nil)}); err != nil {
+		return err
+	}
+	return a.w.Flush()
+}
+
+// ArchiveReader streams the layers of a mesh archive, verifying its checksums as it goes
+type ArchiveReader struct {
+	r      *bufio.Reader
+	sum    hash.Hash
+	layers uint64
+	header archiveHeader
+	done   bool
+}
+
+// NewArchiveReader reads the header of an archive
+func NewArchiveReader(r io.Reader) (*ArchiveReader, error) {
+	a := &ArchiveReader{r: bufio.NewReader(r), sum: sha256.New()}
+	prefix := make([]byte, len(archiveMagic)+4)
+	if err := a.read(prefix); err != nil {
+		return nil, fmt.Errorf("failed to read archive header: %v", err)
+	}
+	if !bytes.Equal(prefix[:len(archiveMagic)], archiveMagic) {
+		return nil, errors.New("not a mesh archive")
+	}
+	if version := binary.BigEndian.Uint32(prefix[len(archiveMagic):]); version != ArchiveVersion {
+		return nil, fmt.Errorf("unsupported mesh archive version %v, expected %v", version, ArchiveVersion)
+	}
+	if err := a.readRecord(headerRecord, &a.header); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// From returns the first layer of the archive
+func (a *ArchiveReader) From() types.LayerID {
+	return a.header.From
+}
+
+// To returns the last layer of the archive
+func (a *ArchiveReader) To() types.LayerID {
+	return a.header.To
+}
+
+func (a *ArchiveReader) read(b []byte) error {
+	if _, err := io.ReadFull(a.r, b); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	a.sum.Write(b)
+	return nil
+}
+
+func (a *ArchiveReader) nextRecord() (uint8, []byte, error) {
+	head := make([]byte, 5)
+	if err := a.read(head); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(head[1:])
+	if size > maxArchiveRecord {
+		return 0, nil, ErrArchiveCorrupt
+	}
+	// the payload is read as it arrives instead of allocated from a length that was not checked yet
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, a.r, int64(size)); err != nil {
+		if err == io.EOF {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	payload := buf.Bytes()
+	a.sum.Write(payload)
+	crc := make([]byte, 4)
+	if err := a.read(crc); err != nil {
+		return 0, nil, err
+	}
+	if binary.BigEndian.Uint32(crc) != crc32.ChecksumIEEE(append(head, payload...)) {
+		return 0, nil, ErrArchiveCorrupt
+	}
+	return head[0], payload, nil
+}
+
+func (a *ArchiveReader) readRecord(kind uint8, v interface{}) error {
+	k, payload, err := a.nextRecord()
+	if err != nil {
+		return err
+	}
+	if k != kind {
+		return fmt.Errorf("unexpected archive record %v, expected %v", k, kind)
+	}
+	return types.BytesToInterface(payload, v)
+}
+
+// Next returns the next layer of the archive. It returns io.EOF once the end of the archive was reached and its
+// checksum verified.
+func (a *ArchiveReader) Next() (*ArchiveLayer, error) {
+	if a.done {
+		return nil, io.EOF
+	}
+	// the checksum in the end record covers everything before it
+	checksum := a.sum.Sum(nil)
+	kind, payload, err := a.nextRecord()
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case layerRecord:
+		var rec archiveLayer
+		if err := types.BytesToInterface(payload, &rec); err != nil {
+			return nil, fmt.Errorf("failed to decode archive layer: %v", err)
+		}
+		a.layers++
+		return rec.toLayer()
+	case endRecord:
+		var end archiveEnd
+		if err := types.BytesToInterface(payload, &end); err != nil {
+			return nil, fmt.Errorf("failed to decode archive end: %v", err)
+		}
+		if end.Layers != a.layers || !bytes.Equal(end.Checksum, checksum) {
+			return nil, ErrArchiveCorrupt
+		}
+		a.done = true
+		return nil, io.EOF
+	default:
+		return nil, fmt.Errorf("unexpected archive record %v", kind)
+	}
+}
+
+func (rec *archiveLayer) toLayer() (*ArchiveLayer, error) {
+	l := &ArchiveLayer{Layer: rec.Layer, Txs: rec.Txs, Proofs: rec.Proofs}
+	for i := range rec.Blocks {
+		b := &rec.Blocks[i]
+		b.Initialize()
+		l.Blocks = append(l.Blocks, b)
+	}
+	for _, tx := range l.Txs {
+		if err := tx.CalcAndSetOrigin(); err != nil {
+			return nil, fmt.Errorf("failed to calc origin of tx %v: %v", tx.ID().ShortString(), err)
+		}
+	}
+	for i := range rec.Atxs {
+		atx := &rec.Atxs[i]
+		atx.CalcAndSetID()
+		l.Atxs = append(l.Atxs, atx)
+	}
+	return l, nil
+}
+
+type archiveAtxSource interface {
+	GetFullAtx(id types.ATXID) (*types.ActivationTx, error)
+	GetAtxHeader(id types.ATXID) (*types.ActivationTxHeader, error)
+}
+
+type archiveProofSource interface {
+	GetProofMessage(proofRef []byte) ([]byte, error)
+}
+
+// archiveExporter collects the layers of an archive, each atx, transaction and proof is written only with the first
+// layer that references it
+type archiveExporter struct {
+	mdb            *DB
+	atxdb          archiveAtxSource
+	poetDb         archiveProofSource
+	layersPerEpoch uint16
+	txs            map[types.TransactionID]struct{}
+	atxs           map[types.ATXID]struct{}
+	proofs         map[string]struct{}
+	exported       *ArchiveLayer
+}
+
+// ExportLayers writes the layers from to to of the mesh to w as a mesh archive, along with the transactions, atxs and
+// PoET proofs needed to validate their blocks. Pruned layers cannot be exported, nor layers whose atxs build on atxs of
+// epochs whose atx bodies were pruned.
+func ExportLayers(w io.Writer, mdb *DB, atxdb archiveAtxSource, poetDb archiveProofSource, layersPerEpoch uint16, from, to types.LayerID) error {
+	if from == 0 || from > to {
+		return fmt.Errorf("invalid layer range %v to %v, the genesis layer cannot be exported", from, to)
+	}
+	if pruned := mdb.PrunedLayer(); from < pruned {
+		return fmt.Errorf("layers up to %v were pruned", pruned-1)
+	}
+	aw, err := NewArchiveWriter(w, from, to)
+	if err != nil {
+		return err
+	}
+	e := &archiveExporter{
+		mdb:            mdb,
+		atxdb:          atxdb,
+		poetDb:         poetDb,
+		layersPerEpoch: layersPerEpoch,
+		txs:            make(map[types.TransactionID]struct{}),
+		atxs:           make(map[types.ATXID]struct{}),
+		proofs:         make(map[string]struct{}),
+	}
+	for l := from; l <= to; l++ {
+		layer, err := e.layer(l)
+		if err != nil {
+			return err
+		}
+		if err := aw.WriteLayer(layer); err != nil {
+			return err
+		}
+	}
+	return aw.Close()
+}
+
+func (e *archiveExporter) layer(l types.LayerID) (*ArchiveLayer, error) {
+	idsBytes, err := e.mdb.layers.Get(l.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("could not read layer %v: %v", l, err)
+	}
+	e.exported = &ArchiveLayer{Layer: l}
+	// layers without blocks are stored with an empty list of ids
+	if len(idsBytes) == 0 {
+		return e.exported, nil
+	}
+	ids, err := types.BytesToBlockIds(idsBytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode block ids of layer %v: %v", l, err)
+	}
+	for _, id := range ids {
+		blk, err := e.mdb.GetBlock(id)
+		if err == ErrPruned {
+			return nil, fmt.Errorf("block %v of layer %v was pruned", id, l)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not get block %v: %v", id, err)
+		}
+		if err := e.addAtx(blk.ATXID); err != nil {
+			return nil, err
+		}
+		for _, atxID := range blk.ATXIDs {
+			if err := e.addAtx(atxID); err != nil {
+				return nil, err
+			}
+		}
+		for _, txID := range blk.TxIDs {
+			if err := e.addTx(txID); err != nil {
+				return nil, err
+			}
+		}
+		e.exported.Blocks = append(e.exported.Blocks, blk)
+	}
+	return e.exported, nil
+}
+
+func (e *archiveExporter) addTx(id types.TransactionID) error {
+	if _, ok := e.txs[id]; ok {
+		return nil
+	}
+	tx, err := e.mdb.GetTransaction(id)
+	if err != nil {
+		return fmt.Errorf("could not get transaction %v: %v", id.ShortString(), err)
+	}
+	e.txs[id] = struct{}{}
+	e.exported.Txs = append(e.exported.Txs, tx)
+	return nil
+}
+
+// addAtx adds an atx after the atxs it builds on, so they can be validated in order
+func (e *archiveExporter) addAtx(id types.ATXID) error {
+	if id == *types.EmptyATXID {
+		return nil
+	}
+	if _, ok := e.atxs[id]; ok {
+		return nil
+	}
+	atx, err := e.atxdb.GetFullAtx(id)
+	if err != nil {
+		// the chain of previous and positioning atxs reaches back to atxs whose bodies may have been pruned
+		if header, herr := e.atxdb.GetAtxHeader(id); herr == nil {
+			return fmt.Errorf("could not get the body of atx %v of epoch %v, the archive needs the atxs of that epoch: %v",
+				id.ShortString(), header.PubLayerID.GetEpoch(e.layersPerEpoch), err)
+		}
+		return fmt.Errorf("could not get atx %v: %v", id.ShortString(), err)
+	}
+	e.atxs[id] = struct{}{}
+	if err := e.addAtx(atx.PrevATXID); err != nil {
+		return err
+	}
+	if err := e.addAtx(atx.PositioningATX); err != nil {
+		return err
+	}
+	if atx.Nipst != nil && atx.Nipst.PostProof != nil {
+		ref := atx.GetPoetProofRef()
+		if _, ok := e.proofs[string(ref)]; !ok {
+			proof, err := e.poetDb.GetProofMessage(ref)
+			if err != nil {
+				return fmt.Errorf("could not get PoET proof %x of atx %v: %v", atx.GetShortPoetProofRef(), id.ShortString(), err)
+			}
+			e.proofs[string(ref)] = struct{}{}
+			e.exported.Proofs = append(e.exported.Proofs, proof)
+		}
+	}
+	e.exported.Atxs = append(e.exported.Atxs, atx)
+	return nil
+}
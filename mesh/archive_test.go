@@ -0,0 +1,212 @@
+package mesh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/rand"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+const archiveLayersPerEpoch = 3
+
+type archiveSourceMock struct {
+	atxs   map[types.ATXID]*types.ActivationTx
+	pruned map[types.ATXID]struct{}
+	proofs map[string][]byte
+}
+
+func (m *archiveSourceMock) GetFullAtx(id types.ATXID) (*types.ActivationTx, error) {
+	if _, ok := m.pruned[id]; ok {
+		return nil, errors.New("atx body was pruned")
+	}
+	if atx, ok := m.atxs[id]; ok {
+		return atx, nil
+	}
+	return nil, errors.New("atx not found")
+}
+
+func (m *archiveSourceMock) GetAtxHeader(id types.ATXID) (*types.ActivationTxHeader, error) {
+	if atx, ok := m.atxs[id]; ok {
+		return atx.ActivationTxHeader, nil
+	}
+	return nil, errors.New("atx not found")
+}
+
+func (m *archiveSourceMock) GetProofMessage(proofRef []byte) ([]byte, error) {
+	if proof, ok := m.proofs[string(proofRef)]; ok {
+		return proof, nil
+	}
+	return nil, errors.New("proof not found")
+}
+
+// createArchiveMesh creates blocks in layers 1 and 3 and an empty layer 2, the atx of the blocks builds on an atx
+// that no block references
+func createArchiveMesh(r *require.Assertions, name string) (*DB, *archiveSourceMock, []*types.Block) {
+	mdb := NewMemMeshDB(log.New(name, "", ""))
+	src := &archiveSourceMock{atxs: make(map[types.ATXID]*types.ActivationTx), pruned: make(map[types.ATXID]struct{}), proofs: make(map[string][]byte)}
+	signer, _ := newSignerAndAddress(r, "archive")
+
+	var prev types.ATXID
+	for i := 0; i < 2; i++ {
+		ref := []byte(rand.String(8))
+		nipst := &types.NIPST{PostProof: &types.PostProof{Challenge: ref}}
+		atx := newActivationTx(types.NodeID{Key: "archive"}, uint64(i), prev, 1, 0, *types.EmptyATXID, types.Address{}, 1, nil, nipst)
+		src.atxs[atx.ID()] = atx
+		src.proofs[string(ref)] = []byte(rand.String(16))
+		prev = atx.ID()
+	}
+
+	var blocks []*types.Block
+	nonce := uint64(0)
+	for _, l := range []types.LayerID{1, 3} {
+		for i := 0; i < 2; i++ {
+			tx := newTx(r, signer, nonce, 100)
+			nonce++
+			r.NoError(mdb.writeTransactions(l, []*types.Transaction{tx}))
+			b := types.NewExistingBlock(l, []byte(rand.String(8)))
+			b.ATXID = prev
+			b.TxIDs = []types.TransactionID{tx.ID()}
+			b.Signature = signer.Sign(b.Bytes())
+			b.Initialize()
+			r.NoError(mdb.AddBlock(b))
+			blocks = append(blocks, b)
+		}
+	}
+	empty, err := types.BlockIdsToBytes(nil)
+	r.NoError(err)
+	r.NoError(mdb.layers.Put(types.LayerID(2).Bytes(), empty))
+	return mdb, src, blocks
+}
+
+func TestArchive_ExportImport(t *testing.T) {
+	r := require.New(t)
+	mdb, src, blocks := createArchiveMesh(r, t.Name())
+
+	var buf bytes.Buffer
+	r.NoError(ExportLayers(&buf, mdb, src, src, archiveLayersPerEpoch, 1, 3))
+
+	ar, err := NewArchiveReader(&buf)
+	r.NoError(err)
+	r.Equal(types.LayerID(1), ar.From())
+	r.Equal(types.LayerID(3), ar.To())
+
+	var layers []*ArchiveLayer
+	for {
+		l, err := ar.Next()
+		if err == io.EOF {
+			break
+		}
+		r.NoError(err)
+		layers = append(layers, l)
+	}
+	r.Len(layers, 3)
+
+	// the atxs and proofs are only in the first layer that references them, each atx after the one it builds on
+	r.Equal(types.LayerID(1), layers[0].Layer)
+	r.ElementsMatch(types.BlockIDs(blocks[:2]), types.BlockIDs(layers[0].Blocks))
+	r.Len(layers[0].Txs, 2)
+	r.Len(layers[0].Atxs, 2)
+	r.Equal(blocks[0].ATXID, layers[0].Atxs[1].ID())
+	r.Equal(layers[0].Atxs[0].ID(), layers[0].Atxs[1].PrevATXID)
+	r.Len(layers[0].Proofs, 2)
+
+	r.Equal(types.LayerID(2), layers[1].Layer)
+	r.Empty(layers[1].Blocks)
+
+	r.Equal(types.LayerID(3), layers[2].Layer)
+	r.ElementsMatch(types.BlockIDs(blocks[2:]), types.BlockIDs(layers[2].Blocks))
+	r.Len(layers[2].Txs, 2)
+	r.Empty(layers[2].Atxs)
+	r.Empty(layers[2].Proofs)
+
+	var txIDs []types.TransactionID
+	for _, tx := range layers[2].Txs {
+		txIDs = append(txIDs, tx.ID())
+		stored, err := mdb.GetTransaction(tx.ID())
+		r.NoError(err)
+		r.Equal(stored.Origin(), tx.Origin())
+	}
+	r.ElementsMatch([]types.TransactionID{blocks[2].TxIDs[0], blocks[3].TxIDs[0]}, txIDs)
+	for _, b := range layers[2].Blocks {
+		expected, err := mdb.GetBlock(b.ID())
+		r.NoError(err)
+		r.Equal(expected.MinerID().Bytes(), b.MinerID().Bytes())
+	}
+}
+
+func TestArchive_Corrupt(t *testing.T) {
+	r := require.New(t)
+	mdb, src, _ := createArchiveMesh(r, t.Name())
+
+	var buf bytes.Buffer
+	r.NoError(ExportLayers(&buf, mdb, src, src, archiveLayersPerEpoch, 1, 3))
+	archive := buf.Bytes()
+
+	readAll := func(b []byte) error {
+		ar, err := NewArchiveReader(bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		for {
+			if _, err := ar.Next(); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+	r.NoError(readAll(archive))
+
+	// flip a byte in the payload of the first layer record, after the header record
+	header := len(archiveMagic) + 4
+	first := header + 5 + int(binary.BigEndian.Uint32(archive[header+1:])) + 4
+	flipped := append([]byte{}, archive...)
+	flipped[first+5+1] ^= 0xff
+	r.Equal(ErrArchiveCorrupt, readAll(flipped))
+
+	r.Equal(io.ErrUnexpectedEOF, readAll(archive[:len(archive)-10]))
+
+	// a length that claims more than the archive holds
+	huge := append([]byte{}, archive[:first]...)
+	binary.BigEndian.PutUint32(huge[header+1:], maxArchiveRecord)
+	r.Equal(io.ErrUnexpectedEOF, readAll(huge))
+
+	version := append([]byte{}, archive...)
+	version[len(archiveMagic)+3]++
+	r.Error(readAll(version))
+}
+
+func TestArchive_ExportPruned(t *testing.T) {
+	r := require.New(t)
+	mdb, src, _ := createArchiveMesh(r, t.Name())
+
+	r.Error(ExportLayers(&bytes.Buffer{}, mdb, src, src, archiveLayersPerEpoch, 0, 3))
+	r.Error(ExportLayers(&bytes.Buffer{}, mdb, src, src, archiveLayersPerEpoch, 3, 1))
+	// layers that are not in the mesh cannot be exported
+	r.Error(ExportLayers(&bytes.Buffer{}, mdb, src, src, archiveLayersPerEpoch, 1, 4))
+
+	_, err := mdb.PruneLayers(2)
+	r.NoError(err)
+	r.Error(ExportLayers(&bytes.Buffer{}, mdb, src, src, archiveLayersPerEpoch, 1, 3))
+	r.NoError(ExportLayers(&bytes.Buffer{}, mdb, src, src, archiveLayersPerEpoch, 2, 3))
+}
+
+func TestArchive_ExportPrunedAtx(t *testing.T) {
+	r := require.New(t)
+	mdb, src, blocks := createArchiveMesh(r, t.Name())
+
+	// the previous atx of the atx of the blocks is only reached through the atx chain
+	atx := src.atxs[blocks[0].ATXID]
+	prev := src.atxs[atx.PrevATXID]
+	src.pruned[prev.ID()] = struct{}{}
+	err := ExportLayers(&bytes.Buffer{}, mdb, src, src, archiveLayersPerEpoch, 1, 3)
+	r.Error(err)
+	r.Contains(err.Error(), fmt.Sprintf("epoch %v", prev.PubLayerID.GetEpoch(archiveLayersPerEpoch)))
+}
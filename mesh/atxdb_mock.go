@@ -43,8 +43,11 @@ func (t *AtxDbMock) AddAtx(id types.ATXID, atx *types.ActivationTx) {
 	t.nipsts[id] = atx.Nipst
 }
 
-// ProcessAtxs counts how many ATXs were processed
+// ProcessAtxs stores the ATXs and counts how many were processed
 func (t *AtxDbMock) ProcessAtxs(atxs []*types.ActivationTx) error {
+	for _, atx := range atxs {
+		t.AddAtx(atx.ID(), atx)
+	}
 	t.ProcCnt += len(atxs)
 	return nil
 }
@@ -0,0 +1,182 @@
+package mesh
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+)
+
+// The ingestion of a block writes to several databases: the transactions and the unapplied transactions of the mesh,
+// the atxs of the activation database, the block with its reverse indexes and the layer index. The block and all the
+// data it references are first written to a journal entry, which is removed once every write succeeded. The block is
+// written last so that a stored block never references missing transactions or atxs. On startup, the journal entries
+// left by a crash are replayed, the writes are idempotent, and the ingestions that cannot be completed are rolled back.
+
+var journalPrefix = []byte("ingest_")
+
+func getJournalKey(id types.BlockID) []byte {
+	return append(append([]byte{}, journalPrefix...), id.Bytes()...)
+}
+
+// ingestStep is a write of the ingestion of a block, after which a crash can be injected by tests
+type ingestStep int
+
+const (
+	stepJournal ingestStep = iota + 1
+	stepTxs
+	stepAtxs
+	stepBlock
+	stepLayer
+)
+
+// errCrashed is returned by the ingestion when a crash is injected, nothing is cleaned up after it
+var errCrashed = errors.New("crash injected")
+
+// crashAfter is set by tests to inject a crash after the steps it returns true for
+var crashAfter func(step ingestStep) bool
+
+func crashed(step ingestStep) bool {
+	return crashAfter != nil && crashAfter(step)
+}
+
+// journalEntry holds everything a block ingestion writes, the txs keep their origin like in the transactions database
+type journalEntry struct {
+	Block types.Block
+	Txs   []dbTransaction
+	Atxs  []types.ActivationTx
+}
+
+func (m *DB) writeJournal(blk *types.Block, txs []*types.Transaction, atxs []*types.ActivationTx) error {
+	entry := journalEntry{Block: *blk}
+	for _, tx := range txs {
+		entry.Txs = append(entry.Txs, *newDbTransaction(tx))
+	}
+	for _, atx := range atxs {
+		entry.Atxs = append(entry.Atxs, *atx)
+	}
+	bytes, err := types.InterfaceToBytes(&entry)
+	if err != nil {
+		return fmt.Errorf("could not marshal journal entry of block %v: %v", blk.ID(), err)
+	}
+	if err := m.general.Put(getJournalKey(blk.ID()), bytes); err != nil {
+		return fmt.Errorf("could not write journal entry of block %v: %v", blk.ID(), err)
+	}
+	return nil
+}
+
+func (m *DB) deleteJournal(id types.BlockID) error {
+	if err := m.general.Delete(getJournalKey(id)); err != nil {
+		return fmt.Errorf("could not delete journal entry of block %v: %v", id, err)
+	}
+	return nil
+}
+
+func decodeJournalEntry(b []byte) (*types.Block, []*types.Transaction, []*types.ActivationTx, error) {
+	var entry journalEntry
+	if err := types.BytesToInterface(b, &entry); err != nil {
+		return nil, nil, nil, err
+	}
+	blk := &entry.Block
+	blk.Initialize()
+	txs := make([]*types.Transaction, 0, len(entry.Txs))
+	for _, tx := range entry.Txs {
+		txs = append(txs, tx.getTransaction())
+	}
+	atxs := make([]*types.ActivationTx, 0, len(entry.Atxs))
+	for i := range entry.Atxs {
+		atx := &entry.Atxs[i]
+		atx.CalcAndSetID()
+		atxs = append(atxs, atx)
+	}
+	return blk, txs, atxs, nil
+}
+
+// ingest writes the transactions, the atxs and then the block, none of the writes fail if they were already done
+func (msh *Mesh) ingest(blk *types.Block, txs []*types.Transaction, atxs []*types.ActivationTx) error {
+	if len(txs) > 0 {
+		if err := msh.writeTransactions(blk.LayerIndex, txs); err != nil {
+			return fmt.Errorf("could not write transactions of block %v database: %v", blk.ID(), err)
+		}
+
+		if err := msh.addToUnappliedTxs(txs, blk.LayerIndex); err != nil {
+			return fmt.Errorf("failed to add to unappliedTxs: %v", err)
+		}
+	}
+	if crashed(stepTxs) {
+		return errCrashed
+	}
+
+	if err := msh.AtxDB.ProcessAtxs(atxs); err != nil {
+		return fmt.Errorf("failed to process ATXs: %v", err)
+	}
+	if crashed(stepAtxs) {
+		return errCrashed
+	}
+
+	err := msh.DB.AddBlock(blk)
+	if err == ErrAlreadyExist {
+		// a previous ingestion of the block may have stopped before the block was added to its layer
+		err = msh.updateLayerWithBlock(blk)
+	}
+	if err != nil {
+		msh.With().Error("failed to add block", log.BlockID(blk.ID().String()), log.Err(err))
+		return err
+	}
+	if crashed(stepLayer) {
+		return errCrashed
+	}
+	return nil
+}
+
+// rollbackIngestion removes the journal entry of the block, and the block if it was written without being added to
+// its layer. The transactions and atxs are kept, they are valid on their own and other blocks may reference them.
+func (msh *Mesh) rollbackIngestion(blk *types.Block) error {
+	found, err := msh.layerHasBlock(blk)
+	if err != nil {
+		return err
+	}
+	if !found {
+		if err := msh.removeBlock(blk); err != nil {
+			return err
+		}
+	}
+	return msh.deleteJournal(blk.ID())
+}
+
+// recoverIngestions completes the block ingestions that were interrupted, the ones that fail again are rolled back
+func (msh *Mesh) recoverIngestions() error {
+	var keys, entries [][]byte
+	it := msh.general.Find(journalPrefix)
+	for it.Next() {
+		if it.Key() == nil {
+			break
+		}
+		keys = append(keys, append([]byte{}, it.Key()...))
+		entries = append(entries, append([]byte{}, it.Value()...))
+	}
+
+	for i, b := range entries {
+		blk, txs, atxs, err := decodeJournalEntry(b)
+		if err != nil {
+			msh.With().Error("dropping undecodable journal entry", log.Err(err))
+			if err := msh.general.Delete(keys[i]); err != nil {
+				return fmt.Errorf("could not delete journal entry: %v", err)
+			}
+			continue
+		}
+		if err := msh.ingest(blk, txs, atxs); err != nil {
+			msh.With().Error("rolling back interrupted block ingestion", log.BlockID(blk.ID().String()), log.Err(err))
+			if err := msh.rollbackIngestion(blk); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := msh.deleteJournal(blk.ID()); err != nil {
+			return err
+		}
+		msh.SetLatestLayer(blk.Layer())
+		msh.With().Info("completed interrupted block ingestion", blk.Fields()...)
+	}
+	return nil
+}
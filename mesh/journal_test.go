@@ -0,0 +1,133 @@
+package mesh
+
+import (
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/rand"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func newJournalMesh(mdb *DB, atxDb AtxDB) *Mesh {
+	return NewMesh(mdb, atxDb, ConfigTst(), &MeshValidatorMock{mdb: mdb}, MockTxMemPool{}, MockAtxMemPool{}, &MockState{}, log.New("journal", "", ""))
+}
+
+func newJournalBlock(r *require.Assertions) (*types.Block, []*types.Transaction, []*types.ActivationTx) {
+	signer, _ := newSignerAndAddress(r, "journal")
+	txs := []*types.Transaction{newTx(r, signer, 0, 100), newTx(r, signer, 1, 100)}
+	atx := newActivationTx(types.NodeID{Key: "journal"}, 0, *types.EmptyATXID, 1, 0, *types.EmptyATXID, types.Address{}, 1, nil, &types.NIPST{})
+	blk := types.NewExistingBlock(1, []byte(rand.String(8)))
+	blk.TxIDs = []types.TransactionID{txs[0].ID(), txs[1].ID()}
+	blk.ATXIDs = []types.ATXID{atx.ID()}
+	blk.Signature = signer.Sign(blk.Bytes())
+	blk.Initialize()
+	return blk, txs, []*types.ActivationTx{atx}
+}
+
+func journalEntries(mdb *DB) int {
+	n := 0
+	it := mdb.general.Find(journalPrefix)
+	for it.Next() {
+		if it.Key() == nil {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+func requireIngested(r *require.Assertions, msh *Mesh, atxDb *AtxDbMock, blk *types.Block, txs []*types.Transaction, atxs []*types.ActivationTx) {
+	got, err := msh.GetBlock(blk.ID())
+	r.NoError(err)
+	r.Equal(blk.ID(), got.ID())
+	ids, err := msh.LayerBlockIds(blk.Layer())
+	r.NoError(err)
+	r.Equal([]types.BlockID{blk.ID()}, ids)
+	for _, tx := range txs {
+		stored, err := msh.GetTransaction(tx.ID())
+		r.NoError(err)
+		r.Equal(tx.Origin(), stored.Origin())
+		blocks, err := msh.GetTransactionBlocks(tx.ID())
+		r.NoError(err)
+		r.Equal([]types.BlockID{blk.ID()}, blocks)
+	}
+	for _, atx := range atxs {
+		stored, err := atxDb.GetFullAtx(atx.ID())
+		r.NoError(err)
+		r.NotNil(stored)
+	}
+	r.Zero(journalEntries(msh.DB))
+}
+
+func TestMesh_AddBlockWithTxs_Crash(t *testing.T) {
+	for _, step := range []ingestStep{stepJournal, stepTxs, stepAtxs, stepBlock, stepLayer} {
+		r := require.New(t)
+		mdb := NewMemMeshDB(log.New(t.Name(), "", ""))
+		atxDb := NewAtxDbMock()
+		blk, txs, atxs := newJournalBlock(r)
+
+		crashAfter = func(s ingestStep) bool { return s == step }
+		r.Equal(errCrashed, newJournalMesh(mdb, atxDb).AddBlockWithTxs(blk, txs, atxs), "step %v", step)
+		r.Equal(1, journalEntries(mdb), "step %v", step)
+
+		// the restarted node completes the ingestion when it opens the mesh
+		crashAfter = nil
+		msh := newJournalMesh(mdb, atxDb)
+		requireIngested(r, msh, atxDb, blk, txs, atxs)
+		r.Equal(blk.Layer(), msh.LatestLayer())
+
+		// recovering and adding the block again change nothing
+		r.NoError(msh.recoverIngestions())
+		r.NoError(msh.AddBlockWithTxs(blk, txs, atxs))
+		requireIngested(r, msh, atxDb, blk, txs, atxs)
+	}
+}
+
+func TestMesh_AddBlockWithTxs_RollbackOnRecovery(t *testing.T) {
+	r := require.New(t)
+	mdb := NewMemMeshDB(log.New(t.Name(), "", ""))
+	blk, txs, atxs := newJournalBlock(r)
+
+	// the block was written but not added to its layer, and its atxs cannot be processed after the restart
+	crashAfter = func(s ingestStep) bool { return s == stepBlock }
+	r.Equal(errCrashed, newJournalMesh(mdb, NewAtxDbMock()).AddBlockWithTxs(blk, txs, atxs))
+	crashAfter = nil
+	r.True(mdb.blockExists(blk.ID()))
+
+	msh := newJournalMesh(mdb, &FailingAtxDbMock{})
+	r.False(mdb.blockExists(blk.ID()))
+	blocks, err := mdb.GetTransactionBlocks(txs[0].ID())
+	r.NoError(err)
+	r.Empty(blocks)
+	_, err = mdb.layers.Get(blk.Layer().Bytes())
+	r.Error(err)
+	r.Zero(journalEntries(mdb))
+
+	// an entry that cannot be decoded is dropped
+	r.NoError(mdb.general.Put(getJournalKey(blk.ID()), []byte{1, 2, 3}))
+	r.NoError(msh.recoverIngestions())
+	r.Zero(journalEntries(mdb))
+}
+
+func TestMesh_AddBlockWithTxs_Persistent(t *testing.T) {
+	r := require.New(t)
+	mdb, err := NewPersistentMeshDB(Path+"/journal/", 5, log.New(t.Name(), "", ""))
+	r.NoError(err)
+	defer teardown()
+	atxDb := NewAtxDbMock()
+	blk, txs, atxs := newJournalBlock(r)
+
+	crashAfter = func(s ingestStep) bool { return s == stepAtxs }
+	r.Equal(errCrashed, newJournalMesh(mdb, atxDb).AddBlockWithTxs(blk, txs, atxs))
+	crashAfter = nil
+	// the node knew of later layers before it crashed
+	r.NoError(mdb.general.Put(constLATEST, types.LayerID(5).Bytes()))
+	mdb.Close()
+
+	mdb, err = NewPersistentMeshDB(Path+"/journal/", 5, log.New(t.Name(), "", ""))
+	r.NoError(err)
+	defer mdb.Close()
+	msh := newJournalMesh(mdb, atxDb)
+	requireIngested(r, msh, atxDb, blk, txs, atxs)
+	r.Equal(types.LayerID(5), msh.LatestLayer())
+}
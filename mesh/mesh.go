@@ -33,9 +33,12 @@ var constLATEST = []byte("latest")
 var constLAYERHASH = []byte("layer hash")
 var constPROCESSED = []byte("processed")
 
-// TORTOISE key for tortoise persistence in database
+// TORTOISE key of the tortoise state saved as a single object by older versions, only read to migrate it
 var TORTOISE = []byte("tortoise")
 
+// VERIFYINGTORTOISE key for verifying tortoise persistence in database
+var VERIFYINGTORTOISE = []byte("verifying tortoise")
+
 // VERIFIED refers to layers we pushed into the state
 var VERIFIED = []byte("verified")
 
@@ -128,6 +131,15 @@ func NewMesh(db *DB, atxDb AtxDB, rewardConfig Config, mesh tortoise, txInvalida
 
 	ll.Validator = &validator{ll, 0}
 
+	// the ingestions interrupted by a crash are completed whenever the database is opened, the latest layer is read
+	// first so that completing them never lowers it
+	if latest, err := db.general.Get(constLATEST); err == nil {
+		ll.latestLayer = types.LayerID(util.BytesToUint64(latest))
+	}
+	if err := ll.recoverIngestions(); err != nil {
+		logger.Panic("could not recover interrupted block ingestions: %v", err)
+	}
+
 	return ll
 }
 
@@ -141,6 +153,10 @@ func NewRecoveredMesh(db *DB, atxDb AtxDB, rewardConfig Config, mesh tortoise, t
 	}
 	msh.latestLayer = types.LayerID(util.BytesToUint64(latest))
 
+	if err := db.indexExistingBlocks(msh.latestLayer); err != nil {
+		logger.Panic("could not index existing blocks: %v", err)
+	}
+
 	processed, err := db.general.Get(constPROCESSED)
 	if err != nil {
 		logger.Panic("could not recover processed layer: %v", err)
@@ -158,6 +174,10 @@ func NewRecoveredMesh(db *DB, atxDb AtxDB, rewardConfig Config, mesh tortoise, t
 	}
 	msh.latestLayerInState = types.LayerID(util.BytesToUint64(verified))
 
+	if err := msh.indexLayerHashes(); err != nil {
+		logger.Panic("could not index the hashes of the layers in state: %v", err)
+	}
+
 	err = pr.LoadState(msh.LatestLayerInState())
 	if err != nil {
 		logger.Panic("cannot load state for layer %v, message: %v", msh.LatestLayerInState(), err)
@@ -328,6 +348,10 @@ func (msh *Mesh) applyState(l *types.Layer) {
 
 // HandleValidatedLayer handles layer valid blocks as decided by hare
 func (msh *Mesh) HandleValidatedLayer(validatedLayer types.LayerID, layer []types.BlockID) {
+	if err := msh.SaveLayerInputVector(validatedLayer, layer); err != nil {
+		msh.With().Error("could not save the hare output of the layer", log.LayerID(validatedLayer.Uint64()), log.Err(err))
+	}
+
 	var blocks []*types.Block
 
 	for _, blockID := range layer {
@@ -408,13 +432,58 @@ func (msh *Mesh) logStateRoot(layerID types.LayerID) {
 	)
 }
 
+// setLayerHash hashes the valid blocks of the layer and chains the hash to the aggregated hash of the previous layers,
+// both hashes are stored per layer
 func (msh *Mesh) setLayerHash(layer *types.Layer) {
 	validBlocks, _ := msh.BlocksByValidity(layer.Blocks())
-	msh.layerHash = types.CalcBlocksHash32(types.BlockIDs(validBlocks), msh.layerHash).Bytes()
+	layerHash := types.CalcBlocksHash32(types.BlockIDs(validBlocks), nil)
+	aggregated := types.CalcAggregateHash32(types.BytesToHash(msh.layerHash), layerHash)
+	msh.layerHash = aggregated.Bytes()
+	if err := msh.writeLayerHashes(layer.Index(), layerHash, aggregated); err != nil {
+		msh.With().Error("failed to persist layer hashes", log.LayerID(layer.Index().Uint64()), log.Err(err))
+	}
 
 	msh.Event().Info("new layer hash",
 		log.LayerID(layer.Index().Uint64()),
-		log.String("layer_hash", util.Bytes2Hex(msh.layerHash)))
+		log.String("layer_hash", util.Bytes2Hex(layerHash.Bytes())),
+		log.String("aggregated_hash", util.Bytes2Hex(msh.layerHash)))
+}
+
+var constLAYERHASHESINDEXED = []byte("layer hashes indexed")
+
+// indexLayerHashes writes the layer hashes and the aggregated hashes of the layers applied to the state by versions
+// that did not store them per layer, so that the aggregated hashes can be compared with other nodes. These versions
+// chained the layer hashes in another way, so the whole chain is rewritten from genesis, with the current contextual
+// validity of the blocks. It runs once per database.
+func (msh *Mesh) indexLayerHashes() error {
+	if has, err := msh.general.Has(constLAYERHASHESINDEXED); err != nil || has {
+		return err
+	}
+
+	// the hashes of a node that didn't apply a layer yet are written when it does
+	if msh.latestLayerInState > 0 {
+		var aggregated types.Hash32
+		for l := types.LayerID(0); l <= msh.latestLayerInState; l++ {
+			// a layer without blocks has no ids, it is hashed as empty like when it is applied
+			ids, _ := msh.LayerBlockIds(l)
+			var valid []types.BlockID
+			for _, id := range ids {
+				if v, err := msh.ContextualValidity(id); err == nil && v {
+					valid = append(valid, id)
+				}
+			}
+			layerHash := types.CalcBlocksHash32(valid, nil)
+			aggregated = types.CalcAggregateHash32(aggregated, layerHash)
+			if err := msh.writeLayerHashes(l, layerHash, aggregated); err != nil {
+				return fmt.Errorf("could not write the hashes of layer %v: %v", l, err)
+			}
+		}
+		msh.layerHash = aggregated.Bytes()
+		msh.persistLayerHash()
+		msh.With().Info("indexed the hashes of the layers in state", log.Uint64("latest_layer_in_state", msh.latestLayerInState.Uint64()))
+	}
+
+	return msh.general.Put(constLAYERHASHESINDEXED, []byte{1})
 }
 
 func (msh *Mesh) persistLayerHash() {
@@ -562,30 +631,24 @@ func (msh *Mesh) SetZeroBlockLayer(lyr types.LayerID) error {
 func (msh *Mesh) AddBlockWithTxs(blk *types.Block, txs []*types.Transaction, atxs []*types.ActivationTx) error {
 	msh.With().Debug("adding block", blk.Fields()...)
 
-	// Store transactions (doesn't have to be rolled back if other writes fail)
-	if len(txs) > 0 {
-		if err := msh.writeTransactions(blk.LayerIndex, txs); err != nil {
-			return fmt.Errorf("could not write transactions of block %v database: %v", blk.ID(), err)
+	// the journal entry lets a crash in the middle of the writes be recovered from on startup
+	if err := msh.writeJournal(blk, txs, atxs); err != nil {
+		return err
+	}
+	if crashed(stepJournal) {
+		return errCrashed
+	}
+	if err := msh.ingest(blk, txs, atxs); err != nil {
+		if err == errCrashed {
+			return err
 		}
-
-		if err := msh.addToUnappliedTxs(txs, blk.LayerIndex); err != nil {
-			return fmt.Errorf("failed to add to unappliedTxs: %v", err)
+		if rerr := msh.rollbackIngestion(blk); rerr != nil {
+			msh.With().Warning("failed to roll back adding a block", log.Err(rerr), log.BlockID(blk.ID().String()))
 		}
-	}
-
-	// Store block (delete if storing ATXs fails)
-	if err := msh.DB.AddBlock(blk); err != nil && err != ErrAlreadyExist {
-		msh.With().Error("failed to add block", log.BlockID(blk.ID().String()), log.Err(err))
 		return err
 	}
-
-	// Store ATXs (atomically, delete the block on failure)
-	if err := msh.AtxDB.ProcessAtxs(atxs); err != nil {
-		// Roll back adding the block (delete it)
-		if err := msh.blocks.Delete(blk.ID().Bytes()); err != nil {
-			msh.With().Warning("failed to roll back adding a block", log.Err(err), log.BlockID(blk.ID().String()))
-		}
-		return fmt.Errorf("failed to process ATXs: %v", err)
+	if err := msh.deleteJournal(blk.ID()); err != nil {
+		return err
 	}
 
 	msh.SetLatestLayer(blk.Layer())
@@ -383,6 +383,87 @@ func TestMesh_AddBlockWithTxs_PushTransactions_UpdateUnappliedTxs(t *testing.T)
 	r.Empty(txns)
 }
 
+func TestMesh_AggregatedLayerHash(t *testing.T) {
+	r := require.New(t)
+	msh := getMesh(t.Name())
+	msh.txProcessor = &MockMapState{}
+	msh.SetBlockBuilder(&MockBlockBuilder{})
+
+	var valid [][]types.BlockID
+	for l := types.LayerID(1); l <= 3; l++ {
+		var ids []types.BlockID
+		for i := 0; i < 3; i++ {
+			b := types.NewExistingBlock(l, []byte(rand.String(8)))
+			r.NoError(msh.SaveContextualValidity(b.ID(), i < 2))
+			r.NoError(msh.AddBlock(b))
+			if i < 2 {
+				ids = append(ids, b.ID())
+			}
+		}
+		valid = append(valid, ids)
+	}
+	msh.pushLayersToState(1, 4)
+
+	var aggregated types.Hash32
+	for i, ids := range valid {
+		l := types.LayerID(i + 1)
+		layerHash, err := msh.GetLayerHash(l)
+		r.NoError(err)
+		r.Equal(types.CalcBlocksHash32(ids, nil), layerHash)
+
+		aggregated = types.CalcAggregateHash32(aggregated, layerHash)
+		got, err := msh.GetAggregatedLayerHash(l)
+		r.NoError(err)
+		r.Equal(aggregated, got)
+	}
+	r.Equal(aggregated.Bytes(), msh.layerHash)
+
+	_, err := msh.GetAggregatedLayerHash(4)
+	r.Error(err)
+}
+
+func TestMesh_IndexLayerHashes(t *testing.T) {
+	r := require.New(t)
+	msh := getMesh(t.Name())
+
+	var valid [][]types.BlockID
+	for l := types.LayerID(1); l <= 3; l++ {
+		var ids []types.BlockID
+		for i := 0; i < 3; i++ {
+			b := types.NewExistingBlock(l, []byte(rand.String(8)))
+			r.NoError(msh.SaveContextualValidity(b.ID(), i < 2))
+			r.NoError(msh.AddBlock(b))
+			if i < 2 {
+				ids = append(ids, b.ID())
+			}
+		}
+		valid = append(valid, ids)
+	}
+	// layers applied by an older version have no hashes, and their chain is of another kind
+	msh.latestLayerInState = 3
+	msh.layerHash = []byte("old chain")
+
+	r.NoError(msh.indexLayerHashes())
+	aggregated := types.CalcAggregateHash32(types.Hash32{}, types.CalcBlocksHash32(nil, nil))
+	for i, ids := range valid {
+		l := types.LayerID(i + 1)
+		layerHash, err := msh.GetLayerHash(l)
+		r.NoError(err)
+		r.Equal(types.CalcBlocksHash32(ids, nil), layerHash)
+		aggregated = types.CalcAggregateHash32(aggregated, layerHash)
+		got, err := msh.GetAggregatedLayerHash(l)
+		r.NoError(err)
+		r.Equal(aggregated, got)
+	}
+	r.Equal(aggregated.Bytes(), msh.layerHash)
+
+	// the hashes are indexed once per database
+	msh.latestLayerInState = 4
+	r.NoError(msh.indexLayerHashes())
+	_, err := msh.GetAggregatedLayerHash(4)
+	r.Error(err)
+}
+
 func TestMesh_AddBlockWithTxs_PushTransactions_getInvalidBlocksByHare(t *testing.T) {
 	r := require.New(t)
 
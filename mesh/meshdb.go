@@ -6,9 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
 	"github.com/spacemeshos/go-spacemesh/database"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/pendingtxs"
+	"github.com/spacemeshos/go-spacemesh/signing"
 	"math/big"
 	"path/filepath"
 	"strconv"
@@ -35,6 +37,7 @@ type DB struct {
 	orphanBlocks       map[types.LayerID]map[types.BlockID]struct{}
 	layerMutex         map[types.LayerID]*layerMutex
 	lhMutex            sync.Mutex
+	pruneMutex         sync.Mutex
 	exit               chan struct{}
 }
 
@@ -123,9 +126,12 @@ func (m *DB) Close() {
 // ErrAlreadyExist error returned when adding an existing value to the database
 var ErrAlreadyExist = errors.New("block already exist in database")
 
+// ErrPruned is returned when the requested data was removed by the retention policy
+var ErrPruned = errors.New("pruned")
+
 // AddBlock adds a block to the database
 func (m *DB) AddBlock(bl *types.Block) error {
-	if _, err := m.getBlockBytes(bl.ID()); err == nil {
+	if m.blockExists(bl.ID()) {
 		m.With().Warning(ErrAlreadyExist.Error(), log.BlockID(bl.ID().String()))
 		return ErrAlreadyExist
 	}
@@ -135,7 +141,7 @@ func (m *DB) AddBlock(bl *types.Block) error {
 	return nil
 }
 
-// GetBlock gets a block from the database by id
+// GetBlock gets a block from the database by id, it returns ErrPruned if only the header of the block is left
 func (m *DB) GetBlock(id types.BlockID) (*types.Block, error) {
 	if id == GenesisBlock.ID() {
 		// todo fit real genesis here
@@ -148,6 +154,9 @@ func (m *DB) GetBlock(id types.BlockID) (*types.Block, error) {
 
 	b, err := m.getBlockBytes(id)
 	if err != nil {
+		if found, herr := m.blocks.Has(getPrunedBlockKey(id)); herr == nil && found {
+			return nil, ErrPruned
+		}
 		return nil, err
 	}
 	mbk := &types.Block{}
@@ -156,6 +165,17 @@ func (m *DB) GetBlock(id types.BlockID) (*types.Block, error) {
 	return mbk, err
 }
 
+// GetBlockHeader gets a block from the database by id like GetBlock, but returns a block that only holds the header
+// if the block was pruned. It is meant for the users of the votes and the view of the blocks, like the tortoise, which
+// replays pruned layers.
+func (m *DB) GetBlockHeader(id types.BlockID) (*types.Block, error) {
+	blk, err := m.GetBlock(id)
+	if err == ErrPruned {
+		return m.getPrunedBlock(id)
+	}
+	return blk, err
+}
+
 // LayerBlocks retrieves all blocks from a layer by layer index
 func (m *DB) LayerBlocks(index types.LayerID) ([]*types.Block, error) {
 	ids, err := m.LayerBlockIds(index)
@@ -166,6 +186,9 @@ func (m *DB) LayerBlocks(index types.LayerID) ([]*types.Block, error) {
 	blocks := make([]*types.Block, 0, len(ids))
 	for _, k := range ids {
 		block, err := m.GetBlock(k)
+		if err == ErrPruned {
+			return nil, ErrPruned
+		}
 		if err != nil {
 			return nil, fmt.Errorf("could not retrieve block %s %s", k.String(), err)
 		}
@@ -186,7 +209,7 @@ func (m *DB) ForBlockInView(view map[types.BlockID]struct{}, layer types.LayerID
 	}
 	seenBlocks := make(map[types.BlockID]struct{})
 	for blocksToVisit.Len() > 0 {
-		block, err := m.GetBlock(blocksToVisit.Remove(blocksToVisit.Front()).(types.BlockID))
+		block, err := m.GetBlockHeader(blocksToVisit.Remove(blocksToVisit.Front()).(types.BlockID))
 		if err != nil {
 			return err
 		}
@@ -246,6 +269,14 @@ func (m *DB) getBlockBytes(id types.BlockID) ([]byte, error) {
 	return m.blocks.Get(id.Bytes())
 }
 
+func (m *DB) blockExists(id types.BlockID) bool {
+	if _, err := m.getBlockBytes(id); err == nil {
+		return true
+	}
+	found, err := m.blocks.Has(getPrunedBlockKey(id))
+	return err == nil && found
+}
+
 // ContextualValidity retrieves opinion on block from the database
 func (m *DB) ContextualValidity(id types.BlockID) (bool, error) {
 	b, err := m.contextualValidity.Get(id.Bytes())
@@ -267,17 +298,147 @@ func (m *DB) SaveContextualValidity(id types.BlockID, valid bool) error {
 	return m.contextualValidity.Put(id.Bytes(), v)
 }
 
+// GetProcessedLayer retrieves the latest layer the mesh handed to the tortoise
+func (m *DB) GetProcessedLayer() (types.LayerID, error) {
+	processed, err := m.general.Get(constPROCESSED)
+	if err != nil {
+		return 0, err
+	}
+	return types.LayerID(util.BytesToUint64(processed)), nil
+}
+
+// tortoiseStatePrefix prefixes the tortoise state records, they are kept with the contextual validity so both are
+// written in a single batch
+const tortoiseStatePrefix = "tortoise_state_"
+
+// SaveTortoiseState atomically persists the contextual validity of the blocks together with the tortoise state
+// records, a nil record is deleted
+func (m *DB) SaveTortoiseState(validity map[types.BlockID]bool, records map[string][]byte) error {
+	batch := m.contextualValidity.NewBatch()
+	for id, valid := range validity {
+		v := constFalse
+		if valid {
+			v = constTrue
+		}
+		if err := batch.Put(id.Bytes(), v); err != nil {
+			return err
+		}
+	}
+	for key, record := range records {
+		k := []byte(tortoiseStatePrefix + key)
+		if record == nil {
+			if err := batch.Delete(k); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := batch.Put(k, record); err != nil {
+			return err
+		}
+	}
+	m.Debug("save tortoise state validity of %v blocks %v records", len(validity), len(records))
+	return batch.Write()
+}
+
+// TortoiseState retrieves the tortoise state records
+func (m *DB) TortoiseState() (map[string][]byte, error) {
+	records := make(map[string][]byte)
+	it := m.contextualValidity.Find([]byte(tortoiseStatePrefix))
+	for it.Next() {
+		if it.Key() == nil {
+			break
+		}
+		key := string(it.Key()[len(tortoiseStatePrefix):])
+		records[key] = append([]byte(nil), it.Value()...)
+	}
+	return records, nil
+}
+
+func getLayerHashKey(l types.LayerID) []byte {
+	return []byte(fmt.Sprintf("lh_%d", l))
+}
+
+func getAggregatedHashKey(l types.LayerID) []byte {
+	return []byte(fmt.Sprintf("ah_%d", l))
+}
+
+func (m *DB) writeLayerHashes(l types.LayerID, layerHash, aggregated types.Hash32) error {
+	batch := m.general.NewBatch()
+	if err := batch.Put(getLayerHashKey(l), layerHash.Bytes()); err != nil {
+		return err
+	}
+	if err := batch.Put(getAggregatedHashKey(l), aggregated.Bytes()); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// GetLayerHash returns the hash of the valid blocks of a layer that was applied to the state
+func (m *DB) GetLayerHash(l types.LayerID) (types.Hash32, error) {
+	b, err := m.general.Get(getLayerHashKey(l))
+	if err != nil {
+		return types.Hash32{}, err
+	}
+	return types.BytesToHash(b), nil
+}
+
+// GetAggregatedLayerHash returns the hash of the valid blocks of all layers up to a layer that was applied to the
+// state, chained layer by layer. Two nodes that agree on the aggregated hash of a layer agree on all layers before it.
+func (m *DB) GetAggregatedLayerHash(l types.LayerID) (types.Hash32, error) {
+	b, err := m.general.Get(getAggregatedHashKey(l))
+	if err != nil {
+		return types.Hash32{}, err
+	}
+	return types.BytesToHash(b), nil
+}
+
+func getInputVectorKey(l types.LayerID) []byte {
+	return []byte("input_vector_" + strconv.FormatUint(l.Uint64(), 10))
+}
+
+// SaveLayerInputVector persists the hare output of the layer, the blocks the node considers valid until the tortoise
+// decides on the layer
+func (m *DB) SaveLayerInputVector(id types.LayerID, blks []types.BlockID) error {
+	w, err := types.BlockIdsToBytes(append([]types.BlockID(nil), blks...))
+	if err != nil {
+		return errors.New("could not encode layer input vector")
+	}
+	return m.general.Put(getInputVectorKey(id), w)
+}
+
+// GetLayerInputVector retrieves the hare output of the layer
+func (m *DB) GetLayerInputVector(id types.LayerID) ([]types.BlockID, error) {
+	w, err := m.general.Get(getInputVectorKey(id))
+	if err != nil {
+		return nil, err
+	}
+	return types.BytesToBlockIds(w)
+}
+
 func (m *DB) writeBlock(bl *types.Block) error {
 	bytes, err := types.InterfaceToBytes(bl)
 	if err != nil {
 		return fmt.Errorf("could not encode bl")
 	}
 
-	if err := m.blocks.Put(bl.ID().Bytes(), bytes); err != nil {
+	// the reverse indexes are written with the block so that a block is never stored without them
+	batch := m.blocks.NewBatch()
+	if err := batch.Put(bl.ID().Bytes(), bytes); err != nil {
 		return fmt.Errorf("could not add bl %v to database %v", bl.ID(), err)
 	}
+	if err := indexBlock(batch, bl); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("could not add bl %v to database %v", bl.ID(), err)
+	}
+	if crashed(stepBlock) {
+		return errCrashed
+	}
 
-	m.updateLayerWithBlock(bl)
+	if err := m.updateLayerWithBlock(bl); err != nil {
+		return fmt.Errorf("could not add bl %v to layer %v: %v", bl.ID(), bl.LayerIndex, err)
+	}
 
 	m.blockCache.put(bl)
 
@@ -300,13 +461,60 @@ func (m *DB) updateLayerWithBlock(blk *types.Block) error {
 			return errors.New("could not get all blocks from database ")
 		}
 	}
+	for _, id := range blockIds {
+		if id == blk.ID() {
+			return nil
+		}
+	}
 	m.Debug("added block %v to layer %v", blk.ID(), blk.LayerIndex)
 	blockIds = append(blockIds, blk.ID())
 	w, err := types.BlockIdsToBytes(blockIds)
 	if err != nil {
 		return errors.New("could not encode layer blk ids")
 	}
-	m.layers.Put(blk.LayerIndex.Bytes(), w)
+	return m.layers.Put(blk.LayerIndex.Bytes(), w)
+}
+
+func (m *DB) layerHasBlock(blk *types.Block) (bool, error) {
+	b, err := m.layers.Get(blk.LayerIndex.Bytes())
+	if err == database.ErrNotFound || len(b) == 0 {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	ids, err := types.BytesToBlockIds(b)
+	if err != nil {
+		return false, fmt.Errorf("could not decode block ids of layer %v: %v", blk.LayerIndex, err)
+	}
+	for _, id := range ids {
+		if id == blk.ID() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// removeBlock deletes the block and its reverse indexes, the block must not be in its layer
+func (m *DB) removeBlock(bl *types.Block) error {
+	batch := m.blocks.NewBatch()
+	if err := batch.Delete(bl.ID().Bytes()); err != nil {
+		return fmt.Errorf("could not delete bl %v: %v", bl.ID(), err)
+	}
+	for _, id := range bl.TxIDs {
+		if err := batch.Delete(getTransactionBlockKey(id, bl.ID())); err != nil {
+			return fmt.Errorf("could not delete index of tx %v of bl %v: %v", id.ShortString(), bl.ID(), err)
+		}
+	}
+	for _, id := range blockAtxs(bl) {
+		if err := batch.Delete(getAtxBlockKey(id, bl.ID())); err != nil {
+			return fmt.Errorf("could not delete index of atx %v of bl %v: %v", id.ShortString(), bl.ID(), err)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("could not delete bl %v: %v", bl.ID(), err)
+	}
+	m.blockCache.Remove(bl.ID())
 	return nil
 }
 
@@ -369,6 +577,53 @@ func getTransactionDestKeyPrefix(l types.LayerID, account types.Address) []byte
 	return []byte(str)
 }
 
+// the keys of the block indexes hold the raw ids, they have a fixed length so that the prefix of an id never matches
+// the keys of another one
+func getTransactionBlockKeyPrefix(id types.TransactionID) []byte {
+	return append([]byte("t_b_"), id.Bytes()...)
+}
+
+func getTransactionBlockKey(id types.TransactionID, block types.BlockID) []byte {
+	return append(getTransactionBlockKeyPrefix(id), block.Bytes()...)
+}
+
+func getAtxBlockKeyPrefix(id types.ATXID) []byte {
+	return append([]byte("a_b_"), id.Bytes()...)
+}
+
+func getAtxBlockKey(id types.ATXID, block types.BlockID) []byte {
+	return append(getAtxBlockKeyPrefix(id), block.Bytes()...)
+}
+
+// indexBlock adds the transaction and atx indexes of the block to the batch
+func indexBlock(batch database.Batch, bl *types.Block) error {
+	for _, id := range bl.TxIDs {
+		if err := batch.Put(getTransactionBlockKey(id, bl.ID()), bl.ID().Bytes()); err != nil {
+			return fmt.Errorf("could not index tx %v of bl %v: %v", id.ShortString(), bl.ID(), err)
+		}
+	}
+	for _, id := range blockAtxs(bl) {
+		if err := batch.Put(getAtxBlockKey(id, bl.ID()), bl.ID().Bytes()); err != nil {
+			return fmt.Errorf("could not index atx %v of bl %v: %v", id.ShortString(), bl.ID(), err)
+		}
+	}
+	return nil
+}
+
+// blockAtxs returns the atx of the block's miner and the atxs the block references, without duplicates
+func blockAtxs(bl *types.Block) []types.ATXID {
+	atxs := make([]types.ATXID, 0, len(bl.ATXIDs)+1)
+	seen := make(map[types.ATXID]struct{}, len(bl.ATXIDs)+1)
+	for _, id := range append([]types.ATXID{bl.ATXID}, bl.ATXIDs...) {
+		if _, found := seen[id]; found || id == *types.EmptyATXID {
+			continue
+		}
+		seen[id] = struct{}{}
+		atxs = append(atxs, id)
+	}
+	return atxs
+}
+
 type dbTransaction struct {
 	*types.Transaction
 	Origin types.Address
@@ -616,6 +871,9 @@ func (m *DB) GetTransactions(transactions []types.TransactionID) ([]*types.Trans
 func (m *DB) GetTransaction(id types.TransactionID) (*types.Transaction, error) {
 	tBytes, err := m.transactions.Get(id[:])
 	if err != nil {
+		if found, herr := m.transactions.Has(getPrunedTxKey(id)); herr == nil && found {
+			return nil, ErrPruned
+		}
 		return nil, fmt.Errorf("could not find transaction in database %v err=%v", hex.EncodeToString(id[:]), err)
 	}
 	var dbTx dbTransaction
@@ -662,6 +920,83 @@ func (m *DB) GetTransactionsByOrigin(l types.LayerID, account types.Address) (tx
 	return
 }
 
+// GetTransactionBlocks returns the ids of the blocks that include the transaction, the index is kept when the blocks
+// are pruned
+func (m *DB) GetTransactionBlocks(id types.TransactionID) ([]types.BlockID, error) {
+	return m.getIndexedBlocks(getTransactionBlockKeyPrefix(id))
+}
+
+// GetAtxBlocks returns the ids of the blocks that reference the atx, either as the atx of their miner or in their atx
+// list, the index is kept when the blocks are pruned
+func (m *DB) GetAtxBlocks(id types.ATXID) ([]types.BlockID, error) {
+	return m.getIndexedBlocks(getAtxBlockKeyPrefix(id))
+}
+
+func (m *DB) getIndexedBlocks(prefix []byte) (blocks []types.BlockID, err error) {
+	it := m.blocks.Find(prefix)
+	for it.Next() {
+		if it.Key() == nil {
+			break
+		}
+		// the value is the padded id the blocks are stored by
+		if len(it.Value()) != types.Hash32Length {
+			return nil, fmt.Errorf("invalid indexed block id %v", util.Bytes2Hex(it.Value()))
+		}
+		var id types.BlockID
+		copy(id[:], it.Value())
+		blocks = append(blocks, id)
+	}
+	return blocks, nil
+}
+
+var constBLOCKSINDEXED = []byte("blocks indexed")
+
+// indexExistingBlocks adds the transaction and atx indexes of the blocks of the layers up to latest that were stored
+// before these indexes were introduced. It runs once per database. The blocks pruned by then only keep their header,
+// they are not indexed.
+func (m *DB) indexExistingBlocks(latest types.LayerID) error {
+	if has, err := m.general.Has(constBLOCKSINDEXED); err != nil || has {
+		return err
+	}
+
+	indexed := 0
+	for l := types.LayerID(0); l <= latest; l++ {
+		ids, err := m.LayerBlockIds(l)
+		if err != nil {
+			// no blocks were stored in the layer
+			continue
+		}
+		batch := m.blocks.NewBatch()
+		for _, id := range ids {
+			b, err := m.getBlockBytes(id)
+			if err != nil {
+				// the block was pruned, or it is the genesis block which is not stored
+				continue
+			}
+			bl := &types.Block{}
+			if err := types.BytesToInterface(b, bl); err != nil {
+				return fmt.Errorf("could not decode bl %v: %v", id, err)
+			}
+			bl.Initialize()
+			if err := indexBlock(batch, bl); err != nil {
+				return err
+			}
+			indexed++
+		}
+		if err := batch.Write(); err != nil {
+			return fmt.Errorf("could not index the blocks of layer %v: %v", l, err)
+		}
+	}
+
+	if err := m.general.Put(constBLOCKSINDEXED, []byte{1}); err != nil {
+		return fmt.Errorf("could not mark blocks as indexed: %v", err)
+	}
+	if indexed > 0 {
+		m.With().Info("indexed blocks stored before the block indexes", log.Int("count", indexed))
+	}
+	return nil
+}
+
 // BlocksByValidity classifies a slice of blocks by validity
 func (m *DB) BlocksByValidity(blocks []*types.Block) (validBlocks, invalidBlocks []*types.Block) {
 	for _, b := range blocks {
@@ -748,6 +1083,11 @@ func (m *DB) Retrieve(key []byte, v interface{}) (interface{}, error) {
 	return v, nil
 }
 
+// Remove deletes the item with the given key from the database
+func (m *DB) Remove(key []byte) error {
+	return m.general.Delete(key)
+}
+
 func (m *DB) cacheWarmUpFromTo(from types.LayerID, to types.LayerID) error {
 	m.Info("warming up cache with layers %v to %v", from, to)
 	for i := from; i < to; i++ {
@@ -766,6 +1106,9 @@ func (m *DB) cacheWarmUpFromTo(from types.LayerID, to types.LayerID) error {
 
 		for _, b := range layer {
 			block, blockErr := m.GetBlock(b)
+			if blockErr == ErrPruned {
+				continue
+			}
 			if blockErr != nil {
 				return fmt.Errorf("could not get bl %v from database %v", b, blockErr)
 			}
@@ -777,3 +1120,143 @@ func (m *DB) cacheWarmUpFromTo(from types.LayerID, to types.LayerID) error {
 
 	return nil
 }
+
+var constPRUNED = []byte("pruned")
+
+func getPrunedBlockKey(id types.BlockID) []byte {
+	return append([]byte("p_"), id.Bytes()...)
+}
+
+func getPrunedTxKey(id types.TransactionID) []byte {
+	return append([]byte("p_"), id.Bytes()...)
+}
+
+// prunedBlock is what is kept of a block once its body is pruned: the header, which holds the votes the tortoise
+// needs to recover, and the miner, which cannot be extracted without the body
+type prunedBlock struct {
+	types.BlockHeader
+	MinerID []byte
+}
+
+func (m *DB) getPrunedBlock(id types.BlockID) (*types.Block, error) {
+	b, err := m.blocks.Get(getPrunedBlockKey(id))
+	if err != nil {
+		return nil, err
+	}
+	var pb prunedBlock
+	if err := types.BytesToInterface(b, &pb); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pruned block %v: %v", id, err)
+	}
+	return types.NewPrunedBlock(id, pb.BlockHeader, signing.NewPublicKey(pb.MinerID)), nil
+}
+
+// PrunedLayer returns the first layer whose blocks were not pruned
+func (m *DB) PrunedLayer() types.LayerID {
+	b, err := m.general.Get(constPRUNED)
+	if err != nil {
+		return 0
+	}
+	return types.LayerID(util.BytesToUint64(b))
+}
+
+// PruneLayers removes the bodies of the blocks of all layers before layer, their transaction ids and atx ids, along
+// with the bodies of the transactions they contain that no retained block includes. The block headers, the layer indexes and the contextual validity
+// are kept so that the tortoise can still be recovered. It returns the number of pruned blocks.
+func (m *DB) PruneLayers(layer types.LayerID) (int, error) {
+	m.pruneMutex.Lock()
+	defer m.pruneMutex.Unlock()
+
+	pruned := 0
+	from := m.PrunedLayer()
+	for l := from; l < layer; l++ {
+		// layers without blocks have nothing to prune
+		ids, _ := m.LayerBlockIds(l)
+		for _, id := range ids {
+			if id == GenesisBlock.ID() {
+				continue
+			}
+			ok, err := m.pruneBlock(id)
+			if err != nil {
+				return pruned, err
+			}
+			if ok {
+				pruned++
+			}
+		}
+		if err := m.general.Put(constPRUNED, (l + 1).Bytes()); err != nil {
+			return pruned, fmt.Errorf("failed to store pruned layer: %v", err)
+		}
+	}
+	if from < layer {
+		m.With().Info("pruned layers", log.Uint64("from_layer", from.Uint64()), log.Uint64("to_layer", layer.Uint64()), log.Int("blocks", pruned))
+	}
+	return pruned, nil
+}
+
+// txInRetainedBlock checks whether a block other than the given one still holds its body and includes the tx
+func (m *DB) txInRetainedBlock(tx types.TransactionID, pruned types.BlockID) (bool, error) {
+	blocks, err := m.GetTransactionBlocks(tx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get blocks of tx %v: %v", tx.ShortString(), err)
+	}
+	for _, b := range blocks {
+		if b == pruned {
+			continue
+		}
+		if found, err := m.blocks.Has(b.Bytes()); err == nil && found {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// pruneBlock replaces the block with its header and removes the bodies of its transactions that no other stored block
+// includes, it returns false if the block was already pruned
+func (m *DB) pruneBlock(id types.BlockID) (bool, error) {
+	b, err := m.getBlockBytes(id)
+	if err != nil {
+		if found, herr := m.blocks.Has(getPrunedBlockKey(id)); herr == nil && found {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get block %v: %v", id, err)
+	}
+	blk := &types.Block{}
+	if err := types.BytesToInterface(b, blk); err != nil {
+		return false, fmt.Errorf("failed to unmarshal block %v: %v", id, err)
+	}
+	blk.Initialize()
+
+	batch := m.transactions.NewBatch()
+	for _, tx := range blk.TxIDs {
+		retained, err := m.txInRetainedBlock(tx, id)
+		if err != nil {
+			return false, err
+		}
+		if retained {
+			continue // pruned with the last block that includes it
+		}
+		if err := batch.Delete(tx.Bytes()); err != nil {
+			return false, fmt.Errorf("failed to delete tx %v: %v", tx.ShortString(), err)
+		}
+		if err := batch.Put(getPrunedTxKey(tx), constTrue); err != nil {
+			return false, fmt.Errorf("failed to mark tx %v pruned: %v", tx.ShortString(), err)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return false, fmt.Errorf("failed to prune txs of block %v: %v", id, err)
+	}
+
+	header, err := types.InterfaceToBytes(&prunedBlock{BlockHeader: blk.BlockHeader, MinerID: blk.MinerID().Bytes()})
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal header of block %v: %v", id, err)
+	}
+	// the header is written before the body is deleted so that the block can always be found
+	if err := m.blocks.Put(getPrunedBlockKey(id), header); err != nil {
+		return false, fmt.Errorf("failed to write header of block %v: %v", id, err)
+	}
+	if err := m.blocks.Delete(id.Bytes()); err != nil {
+		return false, fmt.Errorf("failed to delete block %v: %v", id, err)
+	}
+	m.blockCache.Remove(id)
+	return true, nil
+}
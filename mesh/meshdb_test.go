@@ -519,3 +519,239 @@ func TestMeshDB_testGetRewards(t *testing.T) {
 	r.NoError(err)
 	r.Nil(rewards)
 }
+
+func TestMeshDB_LayerInputVector(t *testing.T) {
+	r := require.New(t)
+	mdb := getMeshDB()
+
+	_, err := mdb.GetLayerInputVector(1)
+	r.Error(err)
+
+	blks := []types.BlockID{types.NewExistingBlock(1, []byte("b")).ID(), types.NewExistingBlock(1, []byte("a")).ID()}
+	r.NoError(mdb.SaveLayerInputVector(1, blks))
+	r.NoError(mdb.SaveLayerInputVector(2, nil))
+
+	res, err := mdb.GetLayerInputVector(1)
+	r.NoError(err)
+	r.ElementsMatch(blks, res)
+	res, err = mdb.GetLayerInputVector(2)
+	r.NoError(err)
+	r.Empty(res)
+}
+
+func TestMeshDB_TortoiseState(t *testing.T) {
+	r := require.New(t)
+	mdb := getMeshDB()
+
+	blk := types.NewExistingBlock(1, []byte("a"))
+	r.NoError(mdb.SaveTortoiseState(map[types.BlockID]bool{blk.ID(): true}, map[string][]byte{"header": {1}, "layer_1": {2}}))
+	valid, err := mdb.ContextualValidity(blk.ID())
+	r.NoError(err)
+	r.True(valid)
+	records, err := mdb.TortoiseState()
+	r.NoError(err)
+	r.Equal(map[string][]byte{"header": {1}, "layer_1": {2}}, records)
+
+	r.NoError(mdb.SaveTortoiseState(map[types.BlockID]bool{blk.ID(): false}, map[string][]byte{"layer_1": nil, "layer_2": {3}}))
+	valid, err = mdb.ContextualValidity(blk.ID())
+	r.NoError(err)
+	r.False(valid)
+	records, err = mdb.TortoiseState()
+	r.NoError(err)
+	r.Equal(map[string][]byte{"header": {1}, "layer_2": {3}}, records)
+}
+
+func TestMeshDB_PruneLayers(t *testing.T) {
+	r := require.New(t)
+	mdb := NewMemMeshDB(log.New(t.Name(), "", ""))
+	signer, _ := newSignerAndAddress(r, "prune")
+
+	var blocks []*types.Block
+	var txs []*types.Transaction
+	for l := types.LayerID(1); l <= 3; l++ {
+		for i := 0; i < 2; i++ {
+			tx := newTx(r, signer, uint64(len(txs)), 100)
+			r.NoError(mdb.writeTransactions(l, []*types.Transaction{tx}))
+			txs = append(txs, tx)
+
+			b := types.NewExistingBlock(l, []byte(rand.String(8)))
+			b.TxIDs = []types.TransactionID{tx.ID()}
+			if l == 3 && i == 1 {
+				// a retained block includes the tx of a pruned block
+				b.TxIDs = append(b.TxIDs, txs[0].ID())
+			}
+			if len(blocks) > 0 {
+				b.AddVote(blocks[len(blocks)-1].ID())
+			}
+			b.Signature = signer.Sign(b.Bytes())
+			b.Initialize()
+			r.NoError(mdb.AddBlock(b))
+			r.NoError(mdb.SaveContextualValidity(b.ID(), true))
+			blocks = append(blocks, b)
+		}
+	}
+
+	r.Equal(types.LayerID(0), mdb.PrunedLayer())
+	pruned, err := mdb.PruneLayers(3)
+	r.NoError(err)
+	r.Equal(4, pruned)
+	r.Equal(types.LayerID(3), mdb.PrunedLayer())
+
+	for i, b := range blocks {
+		_, err := mdb.GetBlock(b.ID())
+		if b.Layer() < 3 {
+			r.Equal(ErrPruned, err)
+		} else {
+			r.NoError(err)
+		}
+		got, err := mdb.GetBlockHeader(b.ID())
+		r.NoError(err)
+		r.Equal(b.ID(), got.ID())
+		header, err := types.InterfaceToBytes(got.BlockHeader)
+		r.NoError(err)
+		expected, err := types.InterfaceToBytes(b.BlockHeader)
+		r.NoError(err)
+		r.Equal(expected, header)
+		r.Equal(b.MinerID().Bytes(), got.MinerID().Bytes())
+		valid, err := mdb.ContextualValidity(b.ID())
+		r.NoError(err)
+		r.True(valid)
+
+		tx, err := mdb.GetTransaction(txs[i].ID())
+		if b.Layer() < 3 {
+			r.True(got.Pruned())
+			r.Empty(got.TxIDs)
+			if i == 0 {
+				r.NoError(err, "the tx is included in a retained block")
+			} else {
+				r.Equal(ErrPruned, err)
+			}
+		} else {
+			r.False(got.Pruned())
+			r.Equal(b.TxIDs, got.TxIDs)
+			r.NoError(err)
+			r.Equal(txs[i].ID(), tx.ID())
+		}
+	}
+	ids, err := mdb.LayerBlockIds(1)
+	r.NoError(err)
+	r.ElementsMatch([]types.BlockID{blocks[0].ID(), blocks[1].ID()}, ids)
+	_, err = mdb.LayerBlocks(1)
+	r.Equal(ErrPruned, err)
+	r.Equal(ErrAlreadyExist, mdb.AddBlock(blocks[0]))
+
+	// pruning again only handles the new layers
+	pruned, err = mdb.PruneLayers(3)
+	r.NoError(err)
+	r.Zero(pruned)
+	pruned, err = mdb.PruneLayers(5)
+	r.NoError(err)
+	r.Equal(2, pruned)
+	r.Equal(types.LayerID(5), mdb.PrunedLayer())
+	_, err = mdb.GetTransaction(txs[0].ID())
+	r.Equal(ErrPruned, err, "the tx is pruned with the last block that includes it")
+}
+
+func TestMeshDB_ReverseIndexes_InMem(t *testing.T) {
+	testReverseIndexes(t, NewMemMeshDB(log.New(t.Name(), "", "")))
+}
+
+func TestMeshDB_ReverseIndexes_Persistent(t *testing.T) {
+	mdb, err := NewPersistentMeshDB(Path+"/mesh_db/", 5, log.New(t.Name(), "", ""))
+	require.NoError(t, err)
+	defer mdb.Close()
+	defer teardown()
+	testReverseIndexes(t, mdb)
+}
+
+func testReverseIndexes(t *testing.T, mdb *DB) {
+	r := require.New(t)
+	tx1, tx2 := types.TransactionID{1}, types.TransactionID{2}
+	atx1, atx2 := types.ATXID(types.Hash32{1}), types.ATXID(types.Hash32{2})
+
+	b1 := types.NewExistingBlock(1, []byte(rand.String(8)))
+	b1.ATXID = atx1
+	b1.TxIDs = []types.TransactionID{tx1, tx2}
+	b1.ATXIDs = []types.ATXID{atx1, atx2}
+	b1.Initialize()
+	b2 := types.NewExistingBlock(2, []byte(rand.String(8)))
+	b2.ATXID = atx2
+	b2.TxIDs = []types.TransactionID{tx2}
+	b2.Initialize()
+	r.NoError(mdb.AddBlock(b1))
+	r.NoError(mdb.AddBlock(b2))
+
+	ids, err := mdb.GetTransactionBlocks(tx1)
+	r.NoError(err)
+	r.Equal([]types.BlockID{b1.ID()}, ids)
+	ids, err = mdb.GetTransactionBlocks(tx2)
+	r.NoError(err)
+	r.ElementsMatch([]types.BlockID{b1.ID(), b2.ID()}, ids)
+	ids, err = mdb.GetTransactionBlocks(types.TransactionID{3})
+	r.NoError(err)
+	r.Empty(ids)
+
+	// the atx of a block that it also lists is indexed once
+	ids, err = mdb.GetAtxBlocks(atx1)
+	r.NoError(err)
+	r.Equal([]types.BlockID{b1.ID()}, ids)
+	ids, err = mdb.GetAtxBlocks(atx2)
+	r.NoError(err)
+	r.ElementsMatch([]types.BlockID{b1.ID(), b2.ID()}, ids)
+	ids, err = mdb.GetAtxBlocks(*types.EmptyATXID)
+	r.NoError(err)
+	r.Empty(ids)
+
+	// the indexes outlive the pruned block bodies
+	_, err = mdb.PruneLayers(2)
+	r.NoError(err)
+	ids, err = mdb.GetTransactionBlocks(tx1)
+	r.NoError(err)
+	r.Equal([]types.BlockID{b1.ID()}, ids)
+	ids, err = mdb.GetAtxBlocks(atx1)
+	r.NoError(err)
+	r.Equal([]types.BlockID{b1.ID()}, ids)
+}
+
+func TestMeshDB_IndexExistingBlocks(t *testing.T) {
+	r := require.New(t)
+	mdb := NewMemMeshDB(log.New(t.Name(), "", ""))
+	tx1, atx1 := types.TransactionID{1}, types.ATXID(types.Hash32{1})
+
+	var blocks []*types.Block
+	for l := types.LayerID(1); l <= 3; l++ {
+		b := types.NewExistingBlock(l, []byte(rand.String(8)))
+		b.ATXID = atx1
+		b.TxIDs = []types.TransactionID{tx1}
+		b.Initialize()
+		r.NoError(mdb.AddBlock(b))
+		blocks = append(blocks, b)
+	}
+	// blocks stored by an older version have no indexes
+	clearIndexes := func() {
+		for _, b := range blocks {
+			r.NoError(mdb.blocks.Delete(getTransactionBlockKey(tx1, b.ID())))
+			r.NoError(mdb.blocks.Delete(getAtxBlockKey(atx1, b.ID())))
+		}
+	}
+	clearIndexes()
+	ids, err := mdb.GetTransactionBlocks(tx1)
+	r.NoError(err)
+	r.Empty(ids)
+
+	// the blocks of layers after latest are not indexed
+	r.NoError(mdb.indexExistingBlocks(2))
+	ids, err = mdb.GetTransactionBlocks(tx1)
+	r.NoError(err)
+	r.ElementsMatch([]types.BlockID{blocks[0].ID(), blocks[1].ID()}, ids)
+	ids, err = mdb.GetAtxBlocks(atx1)
+	r.NoError(err)
+	r.ElementsMatch([]types.BlockID{blocks[0].ID(), blocks[1].ID()}, ids)
+
+	// the blocks are indexed once per database
+	clearIndexes()
+	r.NoError(mdb.indexExistingBlocks(3))
+	ids, err = mdb.GetTransactionBlocks(tx1)
+	r.NoError(err)
+	r.Empty(ids)
+}
@@ -0,0 +1,123 @@
+package mesh
+
+import (
+	"fmt"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+)
+
+// Opinion is the vote of a block on each block of the layers of its hdist window, grouped by layer. Blocks that are
+// not listed are abstained on.
+type Opinion map[types.LayerID]map[types.BlockID]types.Vote
+
+func (o Opinion) set(l types.LayerID, id types.BlockID, v types.Vote) {
+	if _, found := o[l]; !found {
+		o[l] = make(map[types.BlockID]types.Vote)
+	}
+	o[l][id] = v
+}
+
+// Vote returns the vote of the opinion on the block of the layer
+func (o Opinion) Vote(l types.LayerID, id types.BlockID) types.Vote {
+	return o[l][id]
+}
+
+// Support returns the blocks of the layer the opinion votes for
+func (o Opinion) Support(l types.LayerID) map[types.BlockID]struct{} {
+	res := make(map[types.BlockID]struct{})
+	for id, v := range o[l] {
+		if v == types.Support {
+			res[id] = struct{}{}
+		}
+	}
+	return res
+}
+
+type blockProvider interface {
+	GetBlockHeader(id types.BlockID) (*types.Block, error)
+}
+
+// WindowBottom returns the lowest layer a block of the layer votes on
+func WindowBottom(l types.LayerID, hdist types.LayerID) types.LayerID {
+	if l > hdist {
+		return l - hdist
+	}
+	return 0
+}
+
+// BlockOpinion resolves the votes of the block on the layers of its hdist window
+func BlockOpinion(blocks blockProvider, blk *types.Block, hdist types.LayerID) (Opinion, error) {
+	return ResolveVotes(blocks, blk.Votes, WindowBottom(blk.Layer(), hdist))
+}
+
+// ResolveVotes resolves votes to the opinion they express on the layers from bottom up: the opinion of the base block
+// overridden by the diffs. The base block only votes on the layers of its own window, so the chain of base blocks
+// followed is at most hdist long.
+func ResolveVotes(blocks blockProvider, votes types.Votes, bottom types.LayerID) (Opinion, error) {
+	res := make(Opinion)
+	if votes.BaseBlock != (types.BlockID{}) {
+		base, err := blocks.GetBlockHeader(votes.BaseBlock)
+		if err != nil {
+			return nil, fmt.Errorf("base block %v: %v", votes.BaseBlock, err)
+		}
+		if base.Layer() > bottom {
+			inherited, err := ResolveVotes(blocks, base.Votes, bottom)
+			if err != nil {
+				return nil, err
+			}
+			res = inherited
+		}
+	}
+
+	diffs := []struct {
+		ids  []types.BlockID
+		vote types.Vote
+	}{{votes.ForDiff, types.Support}, {votes.AgainstDiff, types.Against}, {votes.NeutralDiff, types.Abstain}}
+	for _, diff := range diffs {
+		for _, id := range diff.ids {
+			blk, err := blocks.GetBlockHeader(id)
+			if err != nil {
+				return nil, fmt.Errorf("voted block %v: %v", id, err)
+			}
+			if blk.Layer() < bottom {
+				continue
+			}
+			if diff.vote == types.Abstain {
+				delete(res[blk.Layer()], id)
+				continue
+			}
+			res.set(blk.Layer(), id, diff.vote)
+		}
+	}
+	return res, nil
+}
+
+// DiffVotes returns the votes expressing the desired opinion relative to the base block, whose opinion on the same
+// layers is given
+func DiffVotes(baseBlock types.BlockID, base, desired Opinion) types.Votes {
+	votes := types.Votes{BaseBlock: baseBlock}
+	for l, blocks := range desired {
+		for id, v := range blocks {
+			if base.Vote(l, id) == v {
+				continue
+			}
+			switch v {
+			case types.Support:
+				votes.ForDiff = append(votes.ForDiff, id)
+			case types.Against:
+				votes.AgainstDiff = append(votes.AgainstDiff, id)
+			}
+		}
+	}
+	// abstentions, whether explicit in the desired opinion or by omission
+	for l, blocks := range base {
+		for id, v := range blocks {
+			if v != types.Abstain && desired.Vote(l, id) == types.Abstain {
+				votes.NeutralDiff = append(votes.NeutralDiff, id)
+			}
+		}
+	}
+	types.SortBlockIDs(votes.ForDiff)
+	types.SortBlockIDs(votes.AgainstDiff)
+	types.SortBlockIDs(votes.NeutralDiff)
+	return votes
+}
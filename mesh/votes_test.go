@@ -0,0 +1,84 @@
+package mesh
+
+import (
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/rand"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestResolveVotes(t *testing.T) {
+	r := require.New(t)
+	mdb := NewMemMeshDB(log.New(t.Name(), "", ""))
+	newBlock := func(l types.LayerID, votes types.Votes) *types.Block {
+		b := types.NewExistingBlock(l, []byte(rand.String(8)))
+		b.Votes = votes
+		b.Initialize()
+		r.NoError(mdb.AddBlock(b))
+		return b
+	}
+	a1 := newBlock(1, types.Votes{})
+	a2 := newBlock(1, types.Votes{})
+	b1 := newBlock(2, types.Votes{ForDiff: []types.BlockID{a1.ID()}, AgainstDiff: []types.BlockID{a2.ID()}})
+	b2 := newBlock(2, types.Votes{})
+	c1 := newBlock(3, types.Votes{BaseBlock: b1.ID(), ForDiff: []types.BlockID{b1.ID()}, AgainstDiff: []types.BlockID{b2.ID()}})
+	d1 := newBlock(4, types.Votes{BaseBlock: c1.ID(), ForDiff: []types.BlockID{c1.ID(), b2.ID()}, NeutralDiff: []types.BlockID{a2.ID()}})
+
+	op, err := BlockOpinion(mdb, d1, 5)
+	r.NoError(err)
+	r.Equal(Opinion{
+		1: {a1.ID(): types.Support},
+		2: {b1.ID(): types.Support, b2.ID(): types.Support},
+		3: {c1.ID(): types.Support},
+	}, op)
+	r.Equal(types.Abstain, op.Vote(1, a2.ID()))
+	r.Equal(map[types.BlockID]struct{}{b1.ID(): {}, b2.ID(): {}}, op.Support(2))
+
+	// the votes inherited on layers below the window are dropped
+	op, err = BlockOpinion(mdb, d1, 2)
+	r.NoError(err)
+	r.Equal(Opinion{
+		2: {b1.ID(): types.Support, b2.ID(): types.Support},
+		3: {c1.ID(): types.Support},
+	}, op)
+
+	_, err = ResolveVotes(mdb, types.Votes{BaseBlock: types.BlockID{1}}, 0)
+	r.Error(err)
+	_, err = ResolveVotes(mdb, types.Votes{ForDiff: []types.BlockID{{1}}}, 0)
+	r.Error(err)
+}
+
+func TestDiffVotes(t *testing.T) {
+	r := require.New(t)
+	mdb := NewMemMeshDB(log.New(t.Name(), "", ""))
+	var ids []types.BlockID
+	for i := 0; i < 4; i++ {
+		b := types.NewExistingBlock(1, []byte(rand.String(8)))
+		r.NoError(mdb.AddBlock(b))
+		ids = append(ids, b.ID())
+	}
+	base := types.NewExistingBlock(2, []byte(rand.String(8)))
+	base.Votes = types.Votes{ForDiff: ids[:2], AgainstDiff: ids[2:]}
+	base.Initialize()
+	r.NoError(mdb.AddBlock(base))
+	inherited, err := ResolveVotes(mdb, types.Votes{BaseBlock: base.ID()}, 0)
+	r.NoError(err)
+
+	desired := Opinion{1: {ids[0]: types.Support, ids[2]: types.Support, ids[3]: types.Against}}
+	votes := DiffVotes(base.ID(), inherited, desired)
+	r.Equal(base.ID(), votes.BaseBlock)
+	r.Equal([]types.BlockID{ids[2]}, votes.ForDiff)
+	r.Empty(votes.AgainstDiff)
+	r.Equal([]types.BlockID{ids[1]}, votes.NeutralDiff)
+
+	resolved, err := ResolveVotes(mdb, votes, 0)
+	r.NoError(err)
+	r.Equal(desired, resolved)
+
+	// without a base block every vote is listed
+	votes = DiffVotes(types.BlockID{}, nil, desired)
+	r.Len(votes.ForDiff, 2)
+	r.Len(votes.AgainstDiff, 1)
+	r.Empty(votes.NeutralDiff)
+}
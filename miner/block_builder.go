@@ -33,7 +33,7 @@ const IncomingTxProtocol = "TxGossip"
 const AtxsPerBlockLimit = 100
 
 type signer interface {
-	Sign(m []byte) []byte
+	SignBlock(layer uint64, eligibilityCounter uint32, m []byte) ([]byte, error)
 }
 
 type txValidator interface {
@@ -65,13 +65,26 @@ type blockOracle interface {
 	BlockEligible(layerID types.LayerID) (types.ATXID, []types.BlockEligibilityProof, error)
 }
 
+// ErrIdentityExists is returned when adding an identity that already builds blocks.
+var ErrIdentityExists = errors.New("identity already exists")
+
+// ErrUnknownIdentity is returned when removing an identity that doesn't build blocks.
+var ErrUnknownIdentity = errors.New("unknown identity")
+
+// identity is a miner identity for which blocks are built, with its own signer and block eligibilities.
+type identity struct {
+	nodeID types.NodeID
+	signer signer
+	oracle blockOracle
+}
+
 // BlockBuilder is the struct that orchestrates the building of blocks, it is responsible for receiving hare results.
 // referencing txs and atxs from mem pool and referencing them in the created block
 // it is also responsible for listening to the clock and querying when a block should be created according to the block oracle
 type BlockBuilder struct {
 	log.Log
-	signer
-	minerID          types.NodeID
+	identitiesMu     sync.RWMutex
+	identities       []*identity
 	rnd              *rand.Rand
 	hdist            types.LayerID
 	beginRoundEvent  chan types.LayerID
@@ -85,7 +98,6 @@ type BlockBuilder struct {
 	network          p2p.Service
 	weakCoinToss     weakCoinProvider
 	meshProvider     meshProvider
-	blockOracle      blockOracle
 	txValidator      txValidator
 	atxValidator     atxValidator
 	syncer           syncer
@@ -104,8 +116,7 @@ func NewBlockBuilder(minerID types.NodeID, sgn signer, net p2p.Service, beginRou
 	seed := binary.BigEndian.Uint64(md5.New().Sum([]byte(minerID.Key)))
 
 	return &BlockBuilder{
-		minerID:          minerID,
-		signer:           sgn,
+		identities:       []*identity{{nodeID: minerID, signer: sgn, oracle: blockOracle}},
 		hdist:            types.LayerID(hdist),
 		Log:              lg,
 		rnd:              rand.New(rand.NewSource(int64(seed))),
@@ -120,7 +131,6 @@ func NewBlockBuilder(minerID types.NodeID, sgn signer, net p2p.Service, beginRou
 		network:          net,
 		weakCoinToss:     weakCoin,
 		meshProvider:     orph,
-		blockOracle:      blockOracle,
 		txValidator:      txValidator,
 		atxValidator:     atxValidator,
 		syncer:           syncer,
@@ -160,6 +170,49 @@ func (t *BlockBuilder) Close() error {
 	return nil
 }
 
+// AddIdentity adds an identity for which blocks are built starting from the next layer.
+func (t *BlockBuilder) AddIdentity(nodeID types.NodeID, sgn signer, oracle blockOracle) error {
+	t.identitiesMu.Lock()
+	defer t.identitiesMu.Unlock()
+	for _, idn := range t.identities {
+		if idn.nodeID.Key == nodeID.Key {
+			return ErrIdentityExists
+		}
+	}
+	t.identities = append(t.identities, &identity{nodeID: nodeID, signer: sgn, oracle: oracle})
+	return nil
+}
+
+// RemoveIdentity stops building blocks for an identity starting from the next layer.
+func (t *BlockBuilder) RemoveIdentity(nodeID types.NodeID) error {
+	t.identitiesMu.Lock()
+	defer t.identitiesMu.Unlock()
+	for i, idn := range t.identities {
+		if idn.nodeID.Key == nodeID.Key {
+			t.identities = append(t.identities[:i:i], t.identities[i+1:]...)
+			return nil
+		}
+	}
+	return ErrUnknownIdentity
+}
+
+// Identities returns the identities for which blocks are built.
+func (t *BlockBuilder) Identities() []types.NodeID {
+	t.identitiesMu.RLock()
+	defer t.identitiesMu.RUnlock()
+	ids := make([]types.NodeID, len(t.identities))
+	for i, idn := range t.identities {
+		ids[i] = idn.nodeID
+	}
+	return ids
+}
+
+func (t *BlockBuilder) getIdentities() []*identity {
+	t.identitiesMu.RLock()
+	defer t.identitiesMu.RUnlock()
+	return append([]*identity(nil), t.identities...)
+}
+
 type hareResultProvider interface {
 	GetResult(lid types.LayerID) ([]types.BlockID, error)
 }
@@ -172,6 +225,7 @@ type meshProvider interface {
 	LayerBlockIds(index types.LayerID) ([]types.BlockID, error)
 	GetOrphanBlocksBefore(l types.LayerID) ([]types.BlockID, error)
 	GetBlock(id types.BlockID) (*types.Block, error)
+	GetBlockHeader(id types.BlockID) (*types.Block, error)
 }
 
 //used from external API call to dd transaction
@@ -208,9 +262,10 @@ func filterUnknownBlocks(blocks []types.BlockID, validate func(id types.BlockID)
 	return filtered
 }
 
-func (t *BlockBuilder) getVotes(id types.LayerID) ([]types.BlockID, error) {
-	var votes []types.BlockID = nil
-
+// localOpinion returns the opinion of the node on the layers of the hdist window of a block of the layer: it supports
+// the hare output of each layer and votes against the other blocks of the layer. The whole bottom layer is supported
+// when the hare did not output it, and the other layers the hare did not output are abstained on.
+func (t *BlockBuilder) localOpinion(id types.LayerID) (mesh.Opinion, error) {
 	// if genesis
 	if id == config.Genesis {
 		return nil, errors.New("cannot create blockBytes in genesis layer")
@@ -218,43 +273,82 @@ func (t *BlockBuilder) getVotes(id types.LayerID) ([]types.BlockID, error) {
 
 	// if genesis+1
 	if id == config.Genesis+1 {
-		return append(votes, mesh.GenesisBlock.ID()), nil
+		return mesh.Opinion{config.Genesis: {mesh.GenesisBlock.ID(): types.Support}}, nil
 	}
 
 	// not genesis, get from hare
 	bottom, top := calcHdistRange(id, t.hdist)
+	opinion := make(mesh.Opinion, top-bottom+1)
+	for i := bottom; i <= top; i++ {
+		res, err := t.hareResult.GetResult(i)
+		if err != nil && i != bottom {
+			t.With().Warning("could not get result for layer in range", log.LayerID(uint64(i)), log.Err(err),
+				log.Uint64("bottom", uint64(bottom)), log.Uint64("top", uint64(top)), log.Uint64("hdist", uint64(t.hdist)))
+			continue
+		}
+		if err != nil { // no result for bottom, take the whole layer
+			t.With().Warning("Could not get result for bottom layer. Adding the whole layer instead.", log.Err(err),
+				log.Uint64("bottom", uint64(bottom)), log.Uint64("top", uint64(top)), log.Uint64("hdist", uint64(t.hdist)))
+			ids, e := t.meshProvider.LayerBlockIds(bottom)
+			if e != nil {
+				t.With().Error("Could not set votes to whole layer", log.Err(e))
+				return nil, e
+			}
+			res = ids
+		}
 
-	if res, err := t.hareResult.GetResult(bottom); err != nil { // no result for bottom, take the whole layer
-		t.With().Warning("Could not get result for bottom layer. Adding the whole layer instead.", log.Err(err),
-			log.Uint64("bottom", uint64(bottom)), log.Uint64("top", uint64(top)), log.Uint64("hdist", uint64(t.hdist)))
-		ids, e := t.meshProvider.LayerBlockIds(bottom)
-		if e != nil {
-			t.With().Error("Could not set votes to whole layer", log.Err(e))
-			return nil, e
+		votes := make(map[types.BlockID]types.Vote, len(res))
+		for _, b := range filterUnknownBlocks(res, t.meshProvider.GetBlock) {
+			votes[b] = types.Support
+		}
+		if ids, err := t.meshProvider.LayerBlockIds(i); err == nil {
+			for _, b := range ids {
+				if _, found := votes[b]; !found {
+					votes[b] = types.Against
+				}
+			}
 		}
+		opinion[i] = votes
+	}
+	return opinion, nil
+}
 
-		// set votes to whole layer
-		votes = ids
-	} else { // got result, just set
-		votes = res
+func voteCount(votes types.Votes) int {
+	return len(votes.ForDiff) + len(votes.AgainstDiff) + len(votes.NeutralDiff)
+}
+
+// getVotes returns the votes of a block of the layer relative to the block of the previous layer whose opinion is the
+// closest to the local opinion, which keeps the block small
+func (t *BlockBuilder) getVotes(id types.LayerID) (types.Votes, error) {
+	opinion, err := t.localOpinion(id)
+	if err != nil {
+		return types.Votes{}, err
+	}
+	best := mesh.DiffVotes(types.BlockID{}, nil, opinion)
+	if id == config.Genesis+1 {
+		return best, nil
 	}
 
-	// add rest of hdist range
-	for i := bottom + 1; i <= top; i++ {
-		res, err := t.hareResult.GetResult(i)
+	candidates, err := t.meshProvider.LayerBlockIds(id - 1)
+	if err != nil {
+		t.With().Debug("no base block candidates", log.LayerID(uint64(id-1)), log.Err(err))
+		return best, nil
+	}
+	bottom, _ := calcHdistRange(id, t.hdist)
+	for _, c := range candidates {
+		base, err := mesh.ResolveVotes(t.meshProvider, types.Votes{BaseBlock: c}, bottom)
 		if err != nil {
-			t.With().Warning("could not get result for layer in range", log.LayerID(uint64(i)), log.Err(err),
-				log.Uint64("bottom", uint64(bottom)), log.Uint64("top", uint64(top)), log.Uint64("hdist", uint64(t.hdist)))
+			t.With().Warning("could not resolve votes of base block candidate", log.BlockID(c.String()), log.Err(err))
 			continue
 		}
-		votes = append(votes, res...)
+		if votes := mesh.DiffVotes(c, base, opinion); voteCount(votes) < voteCount(best) {
+			best = votes
+		}
 	}
-
-	votes = filterUnknownBlocks(votes, t.meshProvider.GetBlock)
-	return votes, nil
+	return best, nil
 }
 
-func (t *BlockBuilder) createBlock(id types.LayerID, atxID types.ATXID, eligibilityProof types.BlockEligibilityProof,
+func (t *BlockBuilder) createBlock(idn *identity, id types.LayerID, atxID types.ATXID, eligibilityProof types.BlockEligibilityProof,
 	txids []types.TransactionID, atxids []types.ATXID) (*types.Block, error) {
 
 	votes, err := t.getVotes(id)
@@ -275,7 +369,7 @@ func (t *BlockBuilder) createBlock(id types.LayerID, atxID types.ATXID, eligibil
 			Data:             nil,
 			Coin:             t.weakCoinToss.GetResult(),
 			Timestamp:        time.Now().UnixNano(),
-			BlockVotes:       votes,
+			Votes:            votes,
 			ViewEdges:        viewEdges,
 		},
 		ATXIDs: selectAtxs(atxids, t.atxsPerBlock),
@@ -287,7 +381,12 @@ func (t *BlockBuilder) createBlock(id types.LayerID, atxID types.ATXID, eligibil
 		return nil, err
 	}
 
-	bl := &types.Block{MiniBlock: b, Signature: t.signer.Sign(blockBytes)}
+	sig, err := idn.signer.SignBlock(uint64(id), eligibilityProof.J, blockBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign block: %v", err)
+	}
+
+	bl := &types.Block{MiniBlock: b, Signature: sig}
 
 	bl.Initialize()
 
@@ -299,7 +398,10 @@ func (t *BlockBuilder) createBlock(id types.LayerID, atxID types.ATXID, eligibil
 		log.Int("tx_count", len(bl.TxIDs)),
 		log.Int("atx_count", len(bl.ATXIDs)),
 		log.Int("view_edges", len(bl.ViewEdges)),
-		log.Int("vote_count", len(bl.BlockVotes)),
+		log.String("base_block", bl.BaseBlock.String()),
+		log.Int("for_count", len(bl.ForDiff)),
+		log.Int("against_count", len(bl.AgainstDiff)),
+		log.Int("neutral_count", len(bl.NeutralDiff)),
 		bl.ATXID,
 		log.Uint32("eligibility_counter", bl.EligibilityProof.J),
 	)
@@ -452,51 +554,58 @@ func (t *BlockBuilder) acceptBlockData() {
 			}
 
 			t.Debug("builder got layer %v", layerID)
-			atxID, proofs, err := t.blockOracle.BlockEligible(layerID)
-			if err != nil {
-				events.Publish(events.DoneCreatingBlock{Eligible: true, Layer: uint64(layerID), Error: "failed to check for block eligibility"})
-				t.With().Error("failed to check for block eligibility", log.LayerID(uint64(layerID)), log.Err(err))
-				continue
+			for _, idn := range t.getIdentities() {
+				t.buildBlocks(idn, layerID)
 			}
-			if len(proofs) == 0 {
-				events.Publish(events.DoneCreatingBlock{Eligible: false, Layer: uint64(layerID), Error: ""})
-				t.With().Info("Notice: not eligible for blocks in layer", log.LayerID(uint64(layerID)))
-				continue
-			}
-			// TODO: include multiple proofs in each block and weigh blocks where applicable
+		}
+	}
+}
 
-			var atxList []types.ATXID
-			for _, atx := range t.AtxPool.GetAllItems() {
-				atxList = append(atxList, atx.ID())
-			}
+// buildBlocks creates and broadcasts a block for every eligibility the identity has in the layer.
+func (t *BlockBuilder) buildBlocks(idn *identity, layerID types.LayerID) {
+	atxID, proofs, err := idn.oracle.BlockEligible(layerID)
+	if err != nil {
+		events.Publish(events.DoneCreatingBlock{Eligible: true, Layer: uint64(layerID), Error: "failed to check for block eligibility"})
+		t.With().Error("failed to check for block eligibility", log.LayerID(uint64(layerID)), log.NodeID(idn.nodeID.ShortString()), log.Err(err))
+		return
+	}
+	if len(proofs) == 0 {
+		events.Publish(events.DoneCreatingBlock{Eligible: false, Layer: uint64(layerID), Error: ""})
+		t.With().Info("Notice: not eligible for blocks in layer", log.LayerID(uint64(layerID)), log.NodeID(idn.nodeID.ShortString()))
+		return
+	}
+	// TODO: include multiple proofs in each block and weigh blocks where applicable
 
-			for _, eligibilityProof := range proofs {
-				txList, err := t.TransactionPool.GetTxsForBlock(MaxTransactionsPerBlock, t.projector.GetProjection)
-				if err != nil {
-					events.Publish(events.DoneCreatingBlock{Eligible: true, Layer: uint64(layerID), Error: "failed to get txs for block"})
-					t.With().Error("failed to get txs for block", log.LayerID(uint64(layerID)), log.Err(err))
-					continue
-				}
-				blk, err := t.createBlock(layerID, atxID, eligibilityProof, txList, atxList)
-				if err != nil {
-					events.Publish(events.DoneCreatingBlock{Eligible: true, Layer: uint64(layerID), Error: "cannot create new block"})
-					t.Error("cannot create new block, %v ", err)
-					continue
-				}
-				go func() {
-					bytes, err := types.InterfaceToBytes(blk)
-					if err != nil {
-						t.Log.Error("cannot serialize block %v", err)
-						events.Publish(events.DoneCreatingBlock{Eligible: true, Layer: uint64(layerID), Error: "cannot serialize block"})
-						return
-					}
-					err = t.network.Broadcast(config.NewBlockProtocol, bytes)
-					if err != nil {
-						t.Log.Error("cannot send block %v", err)
-					}
-					events.Publish(events.DoneCreatingBlock{Eligible: true, Layer: uint64(layerID), Error: ""})
-				}()
-			}
+	var atxList []types.ATXID
+	for _, atx := range t.AtxPool.GetAllItems() {
+		atxList = append(atxList, atx.ID())
+	}
+
+	for _, eligibilityProof := range proofs {
+		txList, err := t.TransactionPool.GetTxsForBlock(MaxTransactionsPerBlock, t.projector.GetProjection)
+		if err != nil {
+			events.Publish(events.DoneCreatingBlock{Eligible: true, Layer: uint64(layerID), Error: "failed to get txs for block"})
+			t.With().Error("failed to get txs for block", log.LayerID(uint64(layerID)), log.Err(err))
+			continue
+		}
+		blk, err := t.createBlock(idn, layerID, atxID, eligibilityProof, txList, atxList)
+		if err != nil {
+			events.Publish(events.DoneCreatingBlock{Eligible: true, Layer: uint64(layerID), Error: "cannot create new block"})
+			t.Error("cannot create new block, %v ", err)
+			continue
 		}
+		go func() {
+			bytes, err := types.InterfaceToBytes(blk)
+			if err != nil {
+				t.Log.Error("cannot serialize block %v", err)
+				events.Publish(events.DoneCreatingBlock{Eligible: true, Layer: uint64(layerID), Error: "cannot serialize block"})
+				return
+			}
+			err = t.network.Broadcast(config.NewBlockProtocol, bytes)
+			if err != nil {
+				t.Log.Error("cannot send block %v", err)
+			}
+			events.Publish(events.DoneCreatingBlock{Eligible: true, Layer: uint64(layerID), Error: ""})
+		}()
 	}
 }
@@ -159,9 +159,9 @@ func TestBlockBuilder_BlockIdGeneration(t *testing.T) {
 	builder1 := NewBlockBuilder(types.NodeID{Key: "a"}, signing.NewEdSigner(), n1, beginRound, 5, NewTxMemPool(), NewAtxMemPool(), MockCoin{}, &mockMesh{b: st}, hare, &mockBlockOracle{}, mockTxProcessor{}, &mockAtxValidator{}, &mockSyncer{}, selectCount, layersPerEpoch, mockProjector, log.New(n1.Info.ID.String(), "", ""))
 	builder2 := NewBlockBuilder(types.NodeID{Key: "b"}, signing.NewEdSigner(), n2, beginRound, 5, NewTxMemPool(), NewAtxMemPool(), MockCoin{}, &mockMesh{b: st}, hare, &mockBlockOracle{}, mockTxProcessor{}, &mockAtxValidator{}, &mockSyncer{}, selectCount, layersPerEpoch, mockProjector, log.New(n2.Info.ID.String(), "", ""))
 
-	b1, _ := builder1.createBlock(1, types.ATXID{}, types.BlockEligibilityProof{}, nil, nil)
+	b1, _ := builder1.createBlock(builder1.identities[0], 1, types.ATXID{}, types.BlockEligibilityProof{}, nil, nil)
 
-	b2, _ := builder2.createBlock(1, types.ATXID{}, types.BlockEligibilityProof{}, nil, nil)
+	b2, _ := builder2.createBlock(builder2.identities[0], 1, types.ATXID{}, types.BlockEligibilityProof{}, nil, nil)
 
 	assert.True(t, b1.ID() != b2.ID(), "ids are identical")
 }
@@ -219,7 +219,7 @@ func TestBlockBuilder_CreateBlock(t *testing.T) {
 		b := types.MiniBlock{}
 		_, _ = xdr.Unmarshal(bytes.NewBuffer(output.Bytes()), &b)
 
-		assert.NotEqual(t, hareRes, b.BlockVotes)
+		assert.NotEqual(t, hareRes, b.ForDiff)
 		assert.Equal(t, []types.BlockID{block1.ID(), block2.ID(), block3.ID()}, b.ViewEdges)
 
 		assert.True(t, ContainsTx(b.TxIDs, transids[0]))
@@ -237,6 +237,49 @@ func TestBlockBuilder_CreateBlock(t *testing.T) {
 
 }
 
+func TestBlockBuilder_MultipleIdentities(t *testing.T) {
+	r := require.New(t)
+	net := service.NewSimulator()
+	beginRound := make(chan types.LayerID)
+	n := net.NewNode()
+	receiver := net.NewNode()
+
+	hare := MockHare{res: map[types.LayerID][]types.BlockID{}}
+	st := []*types.Block{types.NewExistingBlock(0, []byte(rand.String(8)))}
+	hare.res[1] = []types.BlockID{st[0].ID()}
+
+	sgn1, sgn2 := signing.NewEdSigner(), signing.NewEdSigner()
+	id1 := types.NodeID{Key: sgn1.PublicKey().String()}
+	id2 := types.NodeID{Key: sgn2.PublicKey().String()}
+	builder := NewBlockBuilder(id1, sgn1, n, beginRound, 5, NewTxMemPool(), NewAtxMemPool(), MockCoin{}, &mockMesh{b: st}, hare, &mockBlockOracle{}, mockTxProcessor{}, &mockAtxValidator{}, &mockSyncer{}, selectCount, layersPerEpoch, mockProjector, log.New(n.String(), "", ""))
+	r.NoError(builder.AddIdentity(id2, sgn2, &mockBlockOracle{}))
+	r.Equal(ErrIdentityExists, builder.AddIdentity(id2, sgn2, &mockBlockOracle{}))
+	r.Equal([]types.NodeID{id1, id2}, builder.Identities())
+
+	r.NoError(builder.Start())
+	defer builder.Close()
+
+	blocks := receiver.RegisterGossipProtocol(config.NewBlockProtocol, priorityq.High)
+	go func() { beginRound <- 2 }()
+	miners := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		select {
+		case output := <-blocks:
+			b := &types.Block{}
+			r.NoError(types.BytesToInterface(output.Bytes(), b))
+			b.Initialize()
+			miners[b.MinerID().String()] = true
+		case <-time.After(1 * time.Second):
+			r.Fail("timeout on receiving block")
+		}
+	}
+	r.Equal(map[string]bool{id1.Key: true, id2.Key: true}, miners)
+
+	r.NoError(builder.RemoveIdentity(id1))
+	r.Equal(ErrUnknownIdentity, builder.RemoveIdentity(id1))
+	r.Equal([]types.NodeID{id2}, builder.Identities())
+}
+
 func NewTx(t *testing.T, nonce uint64, recipient types.Address, signer *signing.EdSigner) *types.Transaction {
 	tx, err := mesh.NewSignedTx(nonce, recipient, 1, defaultGasLimit, defaultFee, signer)
 	assert.NoError(t, err)
@@ -518,6 +561,10 @@ func (m *mockMesh) GetBlock(id types.BlockID) (*types.Block, error) {
 	return nil, errors.New("not exist")
 }
 
+func (m *mockMesh) GetBlockHeader(id types.BlockID) (*types.Block, error) {
+	return m.GetBlock(id)
+}
+
 func (m *mockMesh) LayerBlockIds(index types.LayerID) ([]types.BlockID, error) {
 	if m.err != nil {
 		return nil, m.err
@@ -541,7 +588,15 @@ func (m *mockMesh) GetOrphanBlocksBefore(types.LayerID) ([]types.BlockID, error)
 	return r, nil
 }
 
-func TestBlockBuilder_getVotes(t *testing.T) {
+func supportedBlocks(ids []types.BlockID) map[types.BlockID]struct{} {
+	res := make(map[types.BlockID]struct{})
+	for _, id := range ids {
+		res[id] = struct{}{}
+	}
+	return res
+}
+
+func TestBlockBuilder_localOpinion(t *testing.T) {
 	rand.Seed(0)
 
 	r := require.New(t)
@@ -549,13 +604,13 @@ func TestBlockBuilder_getVotes(t *testing.T) {
 	n1 := service.NewSimulator().NewNode()
 	allblocks := []*types.Block{b1, b2, b3, b4, b5, b6, b7}
 	bb := NewBlockBuilder(types.NodeID{Key: "a"}, signing.NewEdSigner(), n1, beginRound, 5, NewTxMemPool(), NewAtxMemPool(), MockCoin{}, &mockMesh{b: allblocks}, &mockResult{}, &mockBlockOracle{}, mockTxProcessor{true}, &mockAtxValidator{}, &mockSyncer{}, selectCount, layersPerEpoch, mockProjector, log.NewDefault(t.Name()))
-	b, err := bb.getVotes(config.Genesis)
+	b, err := bb.localOpinion(config.Genesis)
 	r.EqualError(err, "cannot create blockBytes in genesis layer")
 	r.Nil(b)
 
-	b, err = bb.getVotes(config.Genesis + 1)
+	b, err = bb.localOpinion(config.Genesis + 1)
 	r.Nil(err)
-	r.Equal(1, len(b))
+	r.Equal(mesh.Opinion{config.Genesis: {mesh.GenesisBlock.ID(): types.Support}}, b)
 
 	id := types.LayerID(100)
 	bb.hdist = 5
@@ -566,9 +621,11 @@ func TestBlockBuilder_getVotes(t *testing.T) {
 	barr := mh.set(bottom)
 	tarr := mh.set(top)
 	bb.hareResult = mh
-	b, err = bb.getVotes(id)
+	b, err = bb.localOpinion(id)
 	r.Nil(err)
-	r.Equal(append(barr, tarr...), b)
+	r.Equal(supportedBlocks(barr), b.Support(bottom))
+	r.Empty(b.Support(bottom + 1))
+	r.Equal(supportedBlocks(tarr), b.Support(top))
 
 	// no bottom
 	bb.meshProvider = &mockMesh{b: allblocks} // assume all blocks exist in DB --> no filtering applied
@@ -578,20 +635,83 @@ func TestBlockBuilder_getVotes(t *testing.T) {
 	b1arr := mh.set(bottom + 1)
 	tarr = mh.set(top)
 	bb.hareResult = mh
-	b, err = bb.getVotes(id)
+	b, err = bb.localOpinion(id)
 	r.Nil(err)
-	var exp []types.BlockID
-	exp = append(exp, allids...)
-	exp = append(exp, b1arr...)
-	exp = append(exp, tarr...)
-	r.Equal(exp, b)
+	r.Len(b, 3)
+	r.Equal(supportedBlocks(allids), b.Support(bottom))
+	r.Equal(supportedBlocks(b1arr), b.Support(bottom+1))
+	r.Equal(supportedBlocks(tarr), b.Support(top))
 
 	// errExample on layer request
 	bb.meshProvider = &mockMesh{b: nil, err: errExample}
-	b, err = bb.getVotes(id)
+	b, err = bb.localOpinion(id)
 	r.Equal(errExample, err)
 }
 
+type meshDBProvider struct {
+	*mesh.DB
+}
+
+func (m meshDBProvider) GetOrphanBlocksBefore(types.LayerID) ([]types.BlockID, error) {
+	return nil, nil
+}
+
+func TestBlockBuilder_getVotes(t *testing.T) {
+	r := require.New(t)
+	lg := log.NewDefault(t.Name())
+	mdb := mesh.NewMemMeshDB(lg)
+	r.NoError(mdb.AddBlock(mesh.GenesisBlock))
+	a1 := types.NewExistingBlock(1, []byte(rand.String(8)))
+	a2 := types.NewExistingBlock(1, []byte(rand.String(8)))
+	a3 := types.NewExistingBlock(1, []byte(rand.String(8)))
+	c1 := types.NewExistingBlock(2, []byte(rand.String(8)))
+	c1.AddVote(a1.ID())
+	c1.AddVote(a2.ID())
+	c1.AddVoteAgainst(a3.ID())
+	c1.Initialize()
+	c2 := types.NewExistingBlock(2, []byte(rand.String(8)))
+	c2.AddVote(a1.ID())
+	c2.AddVoteAgainst(a2.ID())
+	c2.AddVoteAgainst(a3.ID())
+	c2.Initialize()
+	for _, b := range []*types.Block{a1, a2, a3, c1, c2} {
+		r.NoError(mdb.AddBlock(b))
+	}
+
+	mh := newMockResult()
+	mh.err = errors.New("no result")
+	mh.ids[1] = []types.BlockID{a1.ID(), a2.ID()}
+	mh.ids[2] = []types.BlockID{c1.ID()}
+	bb := NewBlockBuilder(types.NodeID{Key: "a"}, signing.NewEdSigner(), service.NewSimulator().NewNode(), make(chan types.LayerID), 5, NewTxMemPool(), NewAtxMemPool(), MockCoin{}, meshDBProvider{mdb}, mh, &mockBlockOracle{}, mockTxProcessor{true}, &mockAtxValidator{}, &mockSyncer{}, selectCount, layersPerEpoch, mockProjector, lg)
+
+	// c1 agrees with the local opinion on layer 1, only the other layers are listed
+	votes, err := bb.getVotes(3)
+	r.NoError(err)
+	r.Equal(c1.ID(), votes.BaseBlock)
+	r.ElementsMatch([]types.BlockID{mesh.GenesisBlock.ID(), c1.ID()}, votes.ForDiff)
+	r.Equal([]types.BlockID{c2.ID()}, votes.AgainstDiff)
+	r.Empty(votes.NeutralDiff)
+	opinion, err := bb.localOpinion(3)
+	r.NoError(err)
+	resolved, err := mesh.ResolveVotes(mdb, votes, 0)
+	r.NoError(err)
+	r.Equal(opinion, resolved)
+
+	// without a hare output for layer 1 the node abstains on it, which is cheaper without a base block
+	delete(mh.ids, 1)
+	votes, err = bb.getVotes(3)
+	r.NoError(err)
+	r.Equal(types.BlockID{}, votes.BaseBlock)
+	r.Len(votes.ForDiff, 2)
+	r.Len(votes.AgainstDiff, 1)
+	r.Empty(votes.NeutralDiff)
+	opinion, err = bb.localOpinion(3)
+	r.NoError(err)
+	resolved, err = mesh.ResolveVotes(mdb, votes, 0)
+	r.NoError(err)
+	r.Equal(opinion, resolved)
+}
+
 func TestBlockBuilder_CalcHdistRange(t *testing.T) {
 	rand.Seed(0)
 	r := require.New(t)
@@ -619,14 +739,17 @@ func TestBlockBuilder_createBlock(t *testing.T) {
 	builder1 := NewBlockBuilder(types.NodeID{Key: "a"}, signing.NewEdSigner(), n1, beginRound, 5, NewTxMemPool(), NewAtxMemPool(), MockCoin{}, &mockMesh{b: bs}, hare, &mockBlockOracle{}, mockTxProcessor{true}, &mockAtxValidator{}, &mockSyncer{}, selectCount, layersPerEpoch, mockProjector, log.NewDefault(t.Name()))
 
 	builder1.hareResult = &mockResult{err: errExample, ids: nil}
-	b, err := builder1.createBlock(5, types.ATXID{}, types.BlockEligibilityProof{}, nil, nil)
+	b, err := builder1.createBlock(builder1.identities[0], 5, types.ATXID{}, types.BlockEligibilityProof{}, nil, nil)
 	r.Nil(err)
-	r.Equal(st, b.BlockVotes)
+	r.ElementsMatch(st, b.ForDiff)
+	r.Empty(b.AgainstDiff)
 
 	builder1.hareResult = &mockResult{err: nil, ids: nil}
-	b, err = builder1.createBlock(5, types.ATXID{}, types.BlockEligibilityProof{}, nil, nil)
+	b, err = builder1.createBlock(builder1.identities[0], 5, types.ATXID{}, types.BlockEligibilityProof{}, nil, nil)
 	r.Nil(err)
-	r.Equal([]types.BlockID(nil), b.BlockVotes)
+	r.Empty(b.ForDiff)
+	r.Subset(b.AgainstDiff, st) // the mock mesh has the same blocks in every layer
+	r.Subset(st, b.AgainstDiff)
 	emptyID := types.BlockID{}
 	r.NotEqual(b.ID(), emptyID)
 }
@@ -691,10 +814,9 @@ func Test_getVotesFiltered(t *testing.T) {
 	mh.set(5)
 	bb.hareResult = mh
 	bb.hdist = 2
-	b, err := bb.getVotes(5)
+	b, err := bb.localOpinion(5)
 	r.Nil(err)
-	r.Equal(1, len(b))
-	r.Equal(b5.ID(), b[0])
+	r.Equal(map[types.BlockID]struct{}{b5.ID(): {}}, b.Support(4))
 }
 
 func newActivationTx(nodeID types.NodeID, sequence uint64, prevATX types.ATXID, pubLayerID types.LayerID,
@@ -3,6 +3,7 @@ package gossip
 
 import (
 	"sync"
+	"time"
 
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/common/util"
@@ -17,6 +18,8 @@ import (
 
 const oldMessageCacheSize = 10000
 const propagateHandleBufferSize = 5000 // number of MessageValidation that we allow buffering, above this number protocols will get stuck
+const invalidMessagesLimit = 20        // number of invalid messages relayed by a peer within the window before it is disconnected
+const invalidMessagesWindow = 10 * time.Minute
 
 type peersManager interface {
 	GetPeers() []peers.Peer
@@ -28,6 +31,7 @@ type baseNetwork interface {
 	SendMessage(peerPubkey p2pcrypto.PublicKey, protocol string, payload []byte) error
 	SubscribePeerEvents() (conn chan p2pcrypto.PublicKey, disc chan p2pcrypto.PublicKey)
 	ProcessGossipProtocolMessage(sender p2pcrypto.PublicKey, protocol string, data service.Data, validationCompletedChan chan service.MessageValidation) error
+	Disconnect(peer p2pcrypto.PublicKey)
 }
 
 type prioQ interface {
@@ -53,6 +57,14 @@ type Protocol struct {
 	propagateQ chan service.MessageValidation
 	pq         prioQ
 	priorities map[string]priorityq.Priority
+
+	invalid map[p2pcrypto.PublicKey]*invalidCount // the invalid messages relayed by every peer, used by the event loop only
+}
+
+// invalidCount is the number of invalid messages relayed by a peer since the start of its current window
+type invalidCount struct {
+	count int
+	since time.Time
 }
 
 // NewProtocol creates a new gossip protocol instance.
@@ -69,6 +81,7 @@ func NewProtocol(config config.SwarmConfig, base baseNetwork, peersManager peers
 		propagateQ:      make(chan service.MessageValidation, propagateHandleBufferSize),
 		pq:              priorityq.New(propagateHandleBufferSize),
 		priorities:      make(map[string]priorityq.Priority),
+		invalid:         make(map[p2pcrypto.PublicKey]*invalidCount),
 	}
 }
 
@@ -179,13 +192,45 @@ func (p *Protocol) getPriority(protoName string) priorityq.Priority {
 	return v
 }
 
-// pushes messages that passed validation into the priority queue
+// penalize records an invalid message relayed by the sender and disconnects it once it relayed too many within
+// invalidMessagesWindow. Honest peers relaying an occasional invalid message are never disconnected.
+func (p *Protocol) penalize(msgV service.MessageValidation, now time.Time) {
+	metrics.InvalidGossipMessages.With(metrics.ProtocolLabel, msgV.Protocol()).Add(1)
+	sender := msgV.Sender()
+	if sender == nil || sender == p.localNodePubkey {
+		return
+	}
+	c, exist := p.invalid[sender]
+	if !exist || now.Sub(c.since) > invalidMessagesWindow {
+		c = &invalidCount{since: now}
+		p.invalid[sender] = c
+	}
+	c.count++
+	p.With().Debug("invalid_gossip_message",
+		sender.Field("from"),
+		log.String("protocol", msgV.Protocol()),
+		log.Int("invalid_count", c.count))
+	if c.count < invalidMessagesLimit {
+		return
+	}
+	p.With().Warning("disconnecting peer that relayed too many invalid messages",
+		sender.Field("peer"),
+		log.String("protocol", msgV.Protocol()))
+	delete(p.invalid, sender)
+	p.net.Disconnect(sender)
+}
+
+// pushes messages that passed validation into the priority queue, penalizes the senders of invalid ones
 func (p *Protocol) propagationEventLoop() {
 	go p.handlePQ()
 
 	for {
 		select {
 		case msgV := <-p.propagateQ:
+			if msgV.Invalid() {
+				p.penalize(msgV, time.Now())
+				continue
+			}
 			if err := p.pq.Write(p.getPriority(msgV.Protocol()), msgV); err != nil {
 				p.With().Error("fatal: could not write to priority queue",
 					log.Err(err),
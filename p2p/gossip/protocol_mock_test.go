@@ -130,6 +130,18 @@ func (mr *MockbaseNetworkMockRecorder) ProcessGossipProtocolMessage(sender, prot
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessGossipProtocolMessage", reflect.TypeOf((*MockbaseNetwork)(nil).ProcessGossipProtocolMessage), sender, protocol, data, validationCompletedChan)
 }
 
+// Disconnect mocks base method
+func (m *MockbaseNetwork) Disconnect(peer p2pcrypto.PublicKey) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Disconnect", peer)
+}
+
+// Disconnect indicates an expected call of Disconnect
+func (mr *MockbaseNetworkMockRecorder) Disconnect(peer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Disconnect", reflect.TypeOf((*MockbaseNetwork)(nil).Disconnect), peer)
+}
+
 // MockprioQ is a mock of prioQ interface
 type MockprioQ struct {
 	ctrl     *gomock.Controller
@@ -120,3 +120,73 @@ func TestPropagationEventLoop(t *testing.T) {
 	assert.Equal(t, true, isClosed, "listener should be shut down")
 
 }
+
+func TestPropagationEventLoop_InvalidMessages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	net := NewMockbaseNetwork(ctrl)
+	local := p2pcrypto.NewRandomPubkey()
+	protocol := NewProtocol(config.SwarmConfig{}, net, nil, local, logger)
+	pq := NewMockprioQ(ctrl)
+	protocol.pq = pq
+
+	pq.EXPECT().Read().Do(func() { time.Sleep(time.Minute * 5) }).AnyTimes()
+	pq.EXPECT().Close().AnyTimes()
+	// invalid messages are not propagated
+	pq.EXPECT().Write(gomock.Any(), gomock.Any()).Times(0)
+
+	sender := p2pcrypto.NewRandomPubkey()
+	disconnected := make(chan p2pcrypto.PublicKey, 1)
+	net.EXPECT().Disconnect(sender).Do(func(peer p2pcrypto.PublicKey) { disconnected <- peer })
+
+	go protocol.propagationEventLoop()
+	defer close(protocol.shutdown)
+
+	// the node's own messages are never penalized
+	for i := 0; i < invalidMessagesLimit; i++ {
+		protocol.propagateQ <- service.NewInvalidMessageValidation(local, []byte("test"), "test")
+	}
+	for i := 0; i < invalidMessagesLimit-1; i++ {
+		protocol.propagateQ <- service.NewInvalidMessageValidation(sender, []byte("test"), "test")
+	}
+	select {
+	case <-disconnected:
+		t.Fatal("peer disconnected before reaching the limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	protocol.propagateQ <- service.NewInvalidMessageValidation(sender, []byte("test"), "test")
+	select {
+	case peer := <-disconnected:
+		assert.Equal(t, sender, peer)
+	case <-time.After(2 * time.Second):
+		t.Fatal("peer was not disconnected")
+	}
+}
+
+func TestProtocol_PenalizeWindow(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	net := NewMockbaseNetwork(ctrl)
+	protocol := NewProtocol(config.SwarmConfig{}, net, nil, p2pcrypto.NewRandomPubkey(), logger)
+	sender := p2pcrypto.NewRandomPubkey()
+	msg := service.NewInvalidMessageValidation(sender, []byte("test"), "test")
+
+	// invalid messages spread over several windows never disconnect the peer
+	now := time.Now()
+	for i := 0; i < 3*invalidMessagesLimit; i++ {
+		if i%(invalidMessagesLimit-1) == 0 {
+			now = now.Add(invalidMessagesWindow + time.Second)
+		}
+		protocol.penalize(msg, now)
+	}
+
+	net.EXPECT().Disconnect(sender).Times(1)
+	for i := 0; i < invalidMessagesLimit; i++ {
+		protocol.penalize(msg, now.Add(invalidMessagesWindow+time.Second))
+	}
+	_, exist := protocol.invalid[sender]
+	assert.False(t, exist)
+}
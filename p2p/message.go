@@ -57,6 +57,12 @@ func (pm gossipProtocolMessage) ReportValidation(protocol string) {
 	}
 }
 
+func (pm gossipProtocolMessage) ReportInvalid(protocol string) {
+	if pm.validationChan != nil {
+		pm.validationChan <- service.NewInvalidMessageValidation(pm.sender, pm.Bytes(), protocol)
+	}
+}
+
 // ProtocolMessageMetadata is a general p2p message wrapper
 type ProtocolMessageMetadata struct {
 	NextProtocol  string
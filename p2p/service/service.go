@@ -9,9 +9,10 @@ import (
 
 // MessageValidation is a gossip message validation event.
 type MessageValidation struct {
-	sender p2pcrypto.PublicKey
-	msg    []byte
-	prot   string
+	sender  p2pcrypto.PublicKey
+	msg     []byte
+	prot    string
+	invalid bool
 }
 
 // Message returns the message as bytes
@@ -29,6 +30,12 @@ func (mv MessageValidation) Protocol() string {
 	return mv.prot
 }
 
+// Invalid returns true if the protocol rejected the message, in which case it is not propagated and the sender is
+// penalized.
+func (mv MessageValidation) Invalid() bool {
+	return mv.invalid
+}
+
 // P2PMetadata is a generic metadata interface
 type P2PMetadata struct {
 	FromAddress net.Addr
@@ -37,7 +44,12 @@ type P2PMetadata struct {
 
 // NewMessageValidation creates a message validation struct to pass to the protocol.
 func NewMessageValidation(sender p2pcrypto.PublicKey, msg []byte, prot string) MessageValidation {
-	return MessageValidation{sender, msg, prot}
+	return MessageValidation{sender, msg, prot, false}
+}
+
+// NewInvalidMessageValidation creates a message validation struct that reports the message as invalid.
+func NewInvalidMessageValidation(sender p2pcrypto.PublicKey, msg []byte, prot string) MessageValidation {
+	return MessageValidation{sender, msg, prot, true}
 }
 
 // DirectMessage is an interface that represents a simple direct message structure
@@ -53,6 +65,7 @@ type GossipMessage interface {
 	Bytes() []byte
 	ValidationCompletedChan() chan MessageValidation
 	ReportValidation(protocol string)
+	ReportInvalid(protocol string)
 }
 
 // Service is an interface that represents a networking service (ideally p2p) that we can use to send messages or listen to incoming messages
@@ -190,6 +190,13 @@ func (sm simGossipMessage) ReportValidation(protocol string) {
 	}
 }
 
+// ReportInvalid reports sm as an invalid message for protocol.
+func (sm simGossipMessage) ReportInvalid(protocol string) {
+	if sm.validationCompletedChan != nil {
+		sm.validationCompletedChan <- NewInvalidMessageValidation(sm.sender, sm.Bytes(), protocol)
+	}
+}
+
 // Start is here to satisfy the Service interface.
 func (sn *Node) Start() error {
 	// on simulation this doesn't really matter yet.
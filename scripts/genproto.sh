@@ -35,3 +35,6 @@ echo "Generating protobuf for api/pb"
 compile -I. -I$googleapis_path --go_out=plugins=grpc:. api/pb/api.proto
 compile -I. -I$googleapis_path --grpc-gateway_out=logtostderr=true:. api/pb/api.proto
 compile -I. -I$googleapis_path --swagger_out=logtostderr=true:. api/pb/api.proto
+
+echo "Generating protobuf for signing/remote"
+compile -I. --go_out=. signing/remote/signer.proto
@@ -11,3 +11,5 @@ ECHO Generating protobuf for api/pb
 %USERPROFILE%\protoc-3.6.1\bin\protoc -I%CD%\api\pb -I %grpc_gateway_path%\third_party\googleapis --grpc-gateway_out=logtostderr=true:%CD%\api\pb %CD%\api\pb\api.proto
 %USERPROFILE%\protoc-3.6.1\bin\protoc -I%CD%\api\pb -I %grpc_gateway_path%\third_party\googleapis --swagger_out=logtostderr=true:%CD%\api\pb %CD%\api\pb\api.proto
 
+ECHO Generating protobuf for signing/remote
+%USERPROFILE%\protoc-3.6.1\bin\protoc -I%CD%\signing\remote --go_out=%CD%\signing\remote %CD%\signing\remote\signer.proto
@@ -0,0 +1,168 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/spacemeshos/amcl/BLS381"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/net/wire/delimited"
+	"github.com/spacemeshos/go-spacemesh/signing"
+)
+
+const requestTimeout = 10 * time.Second
+
+// Client is a signing.Signer that forwards every request to a remote signer.
+type Client struct {
+	socketPath     string
+	layersPerEpoch uint16
+	pubKey         *signing.PublicKey
+	vrfPub         []byte
+	conn           net.Conn
+	rd             *delimited.Reader
+	wr             *delimited.Writer
+	mu             sync.Mutex
+	log            log.Log
+}
+
+var _ signing.Signer = (*Client)(nil)
+
+// NewClient connects to the remote signer at socketPath and fetches its public keys. The remote signer refuses to
+// serve a node whose layers per epoch differ from its own.
+func NewClient(socketPath string, layersPerEpoch uint16, lg log.Log) (*Client, error) {
+	c := &Client{socketPath: socketPath, layersPerEpoch: layersPerEpoch, log: lg}
+	resp, err := c.request(&SignRequest{Domain: Domain_PUBLIC_KEY})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get public key from remote signer: %v", err)
+	}
+	c.pubKey = signing.NewPublicKey(resp.PublicKey)
+	c.vrfPub = resp.VrfPublicKey
+	return c, nil
+}
+
+// PublicKey returns the public key of the remote signer.
+func (c *Client) PublicKey() *signing.PublicKey {
+	return c.pubKey
+}
+
+// VRFPublicKey returns the public vrf key of the remote signer.
+func (c *Client) VRFPublicKey() []byte {
+	return c.vrfPub
+}
+
+// VRFSigner returns a signer of vrf messages, the vrf key itself is kept by the remote signer.
+func (c *Client) VRFSigner() *VRFSigner {
+	return &VRFSigner{c: c}
+}
+
+// Sign signs data that is neither a block nor an atx, e.g. hare messages. It returns nil if the remote signer could
+// not sign, callers check for it and must not publish unsigned data.
+func (c *Client) Sign(m []byte) []byte {
+	sig, err := c.sign(&SignRequest{Domain: Domain_GENERIC, Data: m})
+	if err != nil {
+		c.log.With().Error("remote signer failed to sign", log.Err(err))
+		return nil
+	}
+	return sig
+}
+
+// SignBlock asks the remote signer to sign block bytes. The remote signer refuses if it already signed a different
+// block for the same layer and eligibility counter.
+func (c *Client) SignBlock(layer uint64, eligibilityCounter uint32, m []byte) ([]byte, error) {
+	return c.sign(&SignRequest{Domain: Domain_BLOCK, Layer: layer, EligibilityCounter: eligibilityCounter, Data: m})
+}
+
+// SignActivation asks the remote signer to sign atx bytes. The remote signer refuses if it already signed a different
+// atx for the same epoch.
+func (c *Client) SignActivation(pubLayer uint64, m []byte) ([]byte, error) {
+	return c.sign(&SignRequest{Domain: Domain_ACTIVATION, Layer: pubLayer, Data: m})
+}
+
+// VRFSigner signs vrf messages with the vrf key of a remote signer.
+type VRFSigner struct {
+	c *Client
+}
+
+// Sign asks the remote signer for the vrf signature of m.
+func (v *VRFSigner) Sign(m []byte) ([]byte, error) {
+	resp, err := v.c.request(&SignRequest{Domain: Domain_VRF, Data: m})
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := BLS381.Verify2(m, resp.Signature, v.c.vrfPub); err != nil || !ok {
+		return nil, errors.New("remote signer returned an invalid vrf signature")
+	}
+	return resp.Signature, nil
+}
+
+// Close closes the connection to the remote signer.
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeConn()
+}
+
+func (c *Client) sign(req *SignRequest) ([]byte, error) {
+	resp, err := c.request(req)
+	if err != nil {
+		return nil, err
+	}
+	if !signing.Verify(c.pubKey, req.Data, resp.Signature) {
+		return nil, errors.New("remote signer returned an invalid signature")
+	}
+	return resp.Signature, nil
+}
+
+// request sends req and waits for the response. A broken connection is re-dialed once.
+func (c *Client) request(req *SignRequest) (*SignResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	req.LayersPerEpoch = uint32(c.layersPerEpoch)
+	resp, err := c.roundTrip(req)
+	if err != nil {
+		c.closeConn()
+		resp, err = c.roundTrip(req)
+		if err != nil {
+			c.closeConn()
+			return nil, err
+		}
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+func (c *Client) roundTrip(req *SignRequest) (*SignResponse, error) {
+	if c.conn == nil {
+		conn, err := net.DialTimeout("unix", c.socketPath, requestTimeout)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+		c.rd = delimited.NewReader(conn)
+		c.wr = delimited.NewWriter(conn)
+	}
+	if err := c.conn.SetDeadline(time.Now().Add(requestTimeout)); err != nil {
+		return nil, err
+	}
+	if err := c.wr.PutProto(req); err != nil {
+		return nil, err
+	}
+	resp := &SignResponse{}
+	if err := c.rd.NextProto(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Client) closeConn() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
@@ -0,0 +1,114 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+
+	xdr "github.com/nullstyle/go-xdr/xdr3"
+)
+
+// decodesAs returns true if data is exactly the xdr encoding of a value of v's type. The xdr decoder allocates slices
+// by their encoded length before reading them, so the lengths are first checked against the data to avoid huge
+// allocations when arbitrary data is tried as a block or an atx.
+func decodesAs(data []byte, v interface{}) bool {
+	n, ok := xdrSize(data, reflect.TypeOf(v).Elem())
+	if !ok || n != len(data) {
+		return false
+	}
+	n, err := xdr.Unmarshal(bytes.NewReader(data), v)
+	return err == nil && n == len(data)
+}
+
+// xdrSize returns the number of bytes a value of type t would consume from the start of data, or false if data is too
+// short for it. It doesn't validate the values themselves, that is left to the decoder.
+func xdrSize(data []byte, t reflect.Type) (int, bool) {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint, reflect.Float32:
+		return fixedSize(data, 4)
+	case reflect.Int64, reflect.Uint64, reflect.Float64:
+		return fixedSize(data, 8)
+	case reflect.String:
+		return opaqueSize(data)
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return fixedSize(data, padded(t.Len()))
+		}
+		return elemsSize(data, t.Elem(), t.Len())
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return opaqueSize(data)
+		}
+		if len(data) < 4 {
+			return 0, false
+		}
+		count := binary.BigEndian.Uint32(data)
+		// every supported element takes at least 4 bytes
+		if uint64(count)*4 > uint64(len(data)-4) {
+			return 0, false
+		}
+		n, ok := elemsSize(data[4:], t.Elem(), int(count))
+		return n + 4, ok
+	case reflect.Ptr:
+		if len(data) < 4 {
+			return 0, false
+		}
+		if binary.BigEndian.Uint32(data) == 0 {
+			return 4, true
+		}
+		n, ok := xdrSize(data[4:], t.Elem())
+		return n + 4, ok
+	case reflect.Struct:
+		total := 0
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			n, ok := xdrSize(data[total:], f.Type)
+			if !ok {
+				return 0, false
+			}
+			total += n
+		}
+		return total, true
+	default:
+		return 0, false
+	}
+}
+
+func elemsSize(data []byte, t reflect.Type, count int) (int, bool) {
+	total := 0
+	for i := 0; i < count; i++ {
+		n, ok := xdrSize(data[total:], t)
+		if !ok {
+			return 0, false
+		}
+		total += n
+	}
+	return total, true
+}
+
+func opaqueSize(data []byte) (int, bool) {
+	if len(data) < 4 {
+		return 0, false
+	}
+	l := uint64(binary.BigEndian.Uint32(data))
+	if l > uint64(len(data)-4) {
+		return 0, false
+	}
+	n, ok := fixedSize(data[4:], padded(int(l)))
+	return n + 4, ok
+}
+
+func fixedSize(data []byte, size int) (int, bool) {
+	if size > len(data) {
+		return 0, false
+	}
+	return size, true
+}
+
+func padded(size int) int {
+	return size + (4-size%4)%4
+}
@@ -0,0 +1,66 @@
+package remote
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/go-spacemesh/crypto"
+	"github.com/spacemeshos/go-spacemesh/database"
+)
+
+// ErrDoubleSign is returned when signing would produce two conflicting signatures for the same slot.
+var ErrDoubleSign = errors.New("refusing to double sign")
+
+var (
+	blockPrefix      = []byte("b_")
+	activationPrefix = []byte("a_")
+)
+
+// Guard records the hash of every block and atx signed and refuses to sign a different block for the same layer and
+// eligibility counter, or a different atx for the same epoch. Signing the exact same data again is allowed, so that a
+// node that crashed after requesting a signature can safely request it again.
+type Guard struct {
+	db             database.Database
+	layersPerEpoch uint16
+	mu             sync.Mutex
+}
+
+// NewGuard returns a guard that persists its records in db.
+func NewGuard(db database.Database, layersPerEpoch uint16) *Guard {
+	return &Guard{db: db, layersPerEpoch: layersPerEpoch}
+}
+
+// CheckBlock records the block data and returns ErrDoubleSign if a different block was signed for the same layer and
+// eligibility counter.
+func (g *Guard) CheckBlock(layer uint64, eligibilityCounter uint32, data []byte) error {
+	key := append(append(append([]byte{}, blockPrefix...), util.Uint64ToBytes(layer)...), util.Uint32ToBytes(eligibilityCounter)...)
+	return g.check(key, data)
+}
+
+// CheckActivation records the atx data and returns ErrDoubleSign if a different atx was signed for the epoch of
+// pubLayer.
+func (g *Guard) CheckActivation(pubLayer uint64, data []byte) error {
+	epoch := pubLayer / uint64(g.layersPerEpoch)
+	key := append(append([]byte{}, activationPrefix...), util.Uint64ToBytes(epoch)...)
+	return g.check(key, data)
+}
+
+func (g *Guard) check(key, data []byte) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	hash := crypto.Sha256(data)
+	prev, err := g.db.Get(key)
+	if err == nil {
+		if !bytes.Equal(prev, hash) {
+			return ErrDoubleSign
+		}
+		return nil
+	}
+	if err != database.ErrNotFound {
+		return err
+	}
+	return g.db.Put(key, hash)
+}
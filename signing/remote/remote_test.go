@@ -0,0 +1,225 @@
+package remote
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/require"
+)
+
+const layersPerEpoch = 10
+
+func startServer(t *testing.T, sgn *signing.EdSigner, db database.Database) (*Server, string, func()) {
+	dir, err := ioutil.TempDir("", "signer")
+	require.NoError(t, err)
+	socket := filepath.Join(dir, "signer.sock")
+
+	srv := NewServer(sgn, NewGuard(db, layersPerEpoch), log.NewDefault("remote_signer"))
+	require.NoError(t, srv.Listen(socket))
+	return srv, socket, func() {
+		srv.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func blockBytes(t *testing.T, layer types.LayerID, j uint32, data []byte) []byte {
+	b := types.MiniBlock{BlockHeader: types.BlockHeader{
+		LayerIndex:       layer,
+		EligibilityProof: types.BlockEligibilityProof{J: j, Sig: []byte{1, 2, 3}},
+		Data:             data,
+	}}
+	bts, err := types.InterfaceToBytes(b)
+	require.NoError(t, err)
+	return bts
+}
+
+func atxBytes(t *testing.T, pubLayer types.LayerID, sequence uint64) []byte {
+	atx := types.NewActivationTx(types.NIPSTChallenge{PubLayerID: pubLayer, Sequence: sequence}, types.Address{}, 0, nil, nil, nil)
+	bts, err := atx.InnerBytes()
+	require.NoError(t, err)
+	return bts
+}
+
+func TestClient_PublicKey(t *testing.T) {
+	r := require.New(t)
+	sgn := signing.NewEdSigner()
+	_, socket, cleanup := startServer(t, sgn, database.NewMemDatabase())
+	defer cleanup()
+
+	c, err := NewClient(socket, layersPerEpoch, log.NewDefault("client"))
+	r.NoError(err)
+	defer c.Close()
+	r.Equal(sgn.PublicKey().Bytes(), c.PublicKey().Bytes())
+
+	// a node with other epochs would make the guard record its atxs by the wrong epoch
+	_, err = NewClient(socket, layersPerEpoch+1, log.NewDefault("client"))
+	r.Error(err)
+
+	_, err = NewClient(socket+"missing", layersPerEpoch, log.NewDefault("client"))
+	r.Error(err)
+}
+
+func TestClient_SignBlock(t *testing.T) {
+	r := require.New(t)
+	sgn := signing.NewEdSigner()
+	_, socket, cleanup := startServer(t, sgn, database.NewMemDatabase())
+	defer cleanup()
+
+	c, err := NewClient(socket, layersPerEpoch, log.NewDefault("client"))
+	r.NoError(err)
+	defer c.Close()
+
+	blk := blockBytes(t, 5, 1, []byte("a"))
+	sig, err := c.SignBlock(5, 1, blk)
+	r.NoError(err)
+	r.True(signing.Verify(sgn.PublicKey(), blk, sig))
+
+	// signing the same block again is allowed
+	_, err = c.SignBlock(5, 1, blk)
+	r.NoError(err)
+
+	// a different block for the same layer and counter is refused
+	_, err = c.SignBlock(5, 1, blockBytes(t, 5, 1, []byte("b")))
+	r.EqualError(err, ErrDoubleSign.Error())
+
+	// a different counter in the same layer is fine
+	_, err = c.SignBlock(5, 2, blockBytes(t, 5, 2, []byte("b")))
+	r.NoError(err)
+
+	// the requested layer must match the block
+	_, err = c.SignBlock(6, 1, blockBytes(t, 5, 1, []byte("c")))
+	r.Error(err)
+}
+
+func TestClient_SignActivation(t *testing.T) {
+	r := require.New(t)
+	sgn := signing.NewEdSigner()
+	_, socket, cleanup := startServer(t, sgn, database.NewMemDatabase())
+	defer cleanup()
+
+	c, err := NewClient(socket, layersPerEpoch, log.NewDefault("client"))
+	r.NoError(err)
+	defer c.Close()
+
+	atx := atxBytes(t, 10, 1)
+	sig, err := c.SignActivation(10, atx)
+	r.NoError(err)
+	r.True(signing.Verify(sgn.PublicKey(), atx, sig))
+
+	// another atx in the same epoch is refused, even for another layer
+	_, err = c.SignActivation(10, atxBytes(t, 10, 2))
+	r.EqualError(err, ErrDoubleSign.Error())
+	_, err = c.SignActivation(15, atxBytes(t, 15, 2))
+	r.EqualError(err, ErrDoubleSign.Error())
+
+	// the next epoch is fine
+	_, err = c.SignActivation(20, atxBytes(t, 20, 2))
+	r.NoError(err)
+
+	// an atx can't be signed as a block
+	_, err = c.SignBlock(30, 0, atxBytes(t, 30, 3))
+	r.Error(err)
+}
+
+func TestClient_Sign(t *testing.T) {
+	r := require.New(t)
+	sgn := signing.NewEdSigner()
+	_, socket, cleanup := startServer(t, sgn, database.NewMemDatabase())
+	defer cleanup()
+
+	c, err := NewClient(socket, layersPerEpoch, log.NewDefault("client"))
+	r.NoError(err)
+	defer c.Close()
+
+	msg := []byte("hare message")
+	r.True(signing.Verify(sgn.PublicKey(), msg, c.Sign(msg)))
+
+	// blocks can't bypass the guard through generic signing
+	r.Nil(c.Sign(blockBytes(t, 5, 1, []byte("a"))))
+
+	// the signature of the public key seeds the vrf key
+	r.Nil(c.Sign(sgn.PublicKey().Bytes()))
+}
+
+func TestClient_VRFSigner(t *testing.T) {
+	r := require.New(t)
+	sgn := signing.NewEdSigner()
+	_, socket, cleanup := startServer(t, sgn, database.NewMemDatabase())
+	defer cleanup()
+
+	c, err := NewClient(socket, layersPerEpoch, log.NewDefault("client"))
+	r.NoError(err)
+	defer c.Close()
+
+	// the remote identity keeps the vrf key of the local one
+	vrfSigner, vrfPub := signing.NewVRFSigner(sgn)
+	r.Equal(vrfPub, c.VRFPublicKey())
+
+	msg := []byte("vrf message")
+	sig, err := c.VRFSigner().Sign(msg)
+	r.NoError(err)
+	expected, err := vrfSigner.Sign(msg)
+	r.NoError(err)
+	r.Equal(expected, sig)
+}
+
+func TestGuard_PersistsAcrossRestarts(t *testing.T) {
+	r := require.New(t)
+	sgn := signing.NewEdSigner()
+	db := database.NewMemDatabase()
+
+	_, socket, cleanup := startServer(t, sgn, db)
+	c, err := NewClient(socket, layersPerEpoch, log.NewDefault("client"))
+	r.NoError(err)
+	_, err = c.SignBlock(5, 1, blockBytes(t, 5, 1, []byte("a")))
+	r.NoError(err)
+	c.Close()
+	cleanup()
+
+	_, socket, cleanup = startServer(t, sgn, db)
+	defer cleanup()
+	c, err = NewClient(socket, layersPerEpoch, log.NewDefault("client"))
+	r.NoError(err)
+	defer c.Close()
+	_, err = c.SignBlock(5, 1, blockBytes(t, 5, 1, []byte("b")))
+	r.EqualError(err, ErrDoubleSign.Error())
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	r := require.New(t)
+	sgn := signing.NewEdSigner()
+	srv, socket, cleanup := startServer(t, sgn, database.NewMemDatabase())
+	defer cleanup()
+
+	c, err := NewClient(socket, layersPerEpoch, log.NewDefault("client"))
+	r.NoError(err)
+	defer c.Close()
+
+	// drop the open connection, the client should re-dial
+	srv.mu.Lock()
+	for conn := range srv.conns {
+		conn.Close()
+	}
+	srv.mu.Unlock()
+
+	msg := []byte("hare message")
+	r.True(signing.Verify(sgn.PublicKey(), msg, c.Sign(msg)))
+}
+
+func TestDecodesAs_HugeLength(t *testing.T) {
+	r := require.New(t)
+	// a length prefix far beyond the data must be rejected without allocating it
+	data := make([]byte, 64)
+	for i := range data {
+		data[i] = 0x7f
+	}
+	r.False(decodesAs(data, &types.MiniBlock{}))
+	r.False(decodesAs(data, &types.InnerActivationTx{}))
+	r.True(decodesAs(blockBytes(t, 5, 1, []byte("a")), &types.MiniBlock{}))
+}
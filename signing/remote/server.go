@@ -0,0 +1,192 @@
+// Package remote implements a signer that keeps the node identity key in a separate process. The node talks to the
+// signer over a local unix socket, and the signer refuses to sign conflicting blocks or atxs.
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/spacemeshos/amcl/BLS381"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/filesystem"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/net/wire/delimited"
+	"github.com/spacemeshos/go-spacemesh/signing"
+)
+
+// Server serves signing requests received over a unix socket.
+type Server struct {
+	signer    *signing.EdSigner
+	vrfSigner *BLS381.BlsSigner
+	vrfPub    []byte
+	guard     *Guard
+	listener  net.Listener
+	conns     map[net.Conn]struct{}
+	mu        sync.Mutex
+	wg        sync.WaitGroup
+	log       log.Log
+}
+
+// NewServer returns a server that signs with signer after checking every block and atx request with guard. The vrf key
+// of the identity is derived from signer and never leaves the server.
+func NewServer(signer *signing.EdSigner, guard *Guard, lg log.Log) *Server {
+	vrfSigner, vrfPub := signing.NewVRFSigner(signer)
+	return &Server{
+		signer:    signer,
+		vrfSigner: vrfSigner,
+		vrfPub:    vrfPub,
+		guard:     guard,
+		conns:     make(map[net.Conn]struct{}),
+		log:       lg,
+	}
+}
+
+// Listen starts serving on a unix socket at socketPath. A stale socket file at that path is removed.
+func (s *Server) Listen(socketPath string) error {
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale socket: %v", err)
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %v: %v", socketPath, err)
+	}
+	if err := os.Chmod(socketPath, filesystem.OwnerReadWrite); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to restrict socket permissions: %v", err)
+	}
+	s.listener = listener
+	s.log.Info("remote signer listening on %v for identity %v", socketPath, s.signer.PublicKey().ShortString())
+
+	s.wg.Add(1)
+	go s.acceptLoop()
+	return nil
+}
+
+// Close stops accepting connections and closes all open connections.
+func (s *Server) Close() {
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			s.log.Debug("remote signer stopped accepting connections: %v", err)
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	rd := delimited.NewReader(conn)
+	wr := delimited.NewWriter(conn)
+	for {
+		req := &SignRequest{}
+		if err := rd.NextProto(req); err != nil {
+			return
+		}
+		if err := wr.PutProto(s.handleRequest(req)); err != nil {
+			s.log.Warning("failed to write sign response: %v", err)
+			return
+		}
+	}
+}
+
+func (s *Server) handleRequest(req *SignRequest) *SignResponse {
+	resp := &SignResponse{PublicKey: s.signer.PublicKey().Bytes(), VrfPublicKey: s.vrfPub}
+	if err := s.checkRequest(req); err != nil {
+		s.log.With().Warning("refused to sign", log.String("domain", req.Domain.String()),
+			log.Uint64("layer", req.Layer), log.Uint32("eligibility_counter", req.EligibilityCounter), log.Err(err))
+		resp.Error = err.Error()
+		return resp
+	}
+	switch req.Domain {
+	case Domain_PUBLIC_KEY:
+	case Domain_VRF:
+		sig, err := s.vrfSigner.Sign(req.Data)
+		if err != nil {
+			resp.Error = fmt.Sprintf("failed to sign vrf message: %v", err)
+			return resp
+		}
+		resp.Signature = sig
+	default:
+		resp.Signature = s.signer.Sign(req.Data)
+	}
+	return resp
+}
+
+// checkRequest validates that the data matches the requested domain and that signing it won't double sign.
+func (s *Server) checkRequest(req *SignRequest) error {
+	switch req.Domain {
+	case Domain_PUBLIC_KEY:
+		// the node connects with a public key request, its epochs must be the ones the guard records atxs by
+		return s.checkLayersPerEpoch(req)
+	case Domain_GENERIC:
+		// the signature of the public key seeds the vrf key, it must not leave the signer
+		if bytes.Equal(req.Data, s.signer.PublicKey().Bytes()) {
+			return fmt.Errorf("refusing to sign the public key")
+		}
+		// generic data must not be usable as a block or an atx, otherwise the guard could be bypassed
+		if decodesAs(req.Data, &types.MiniBlock{}) || decodesAs(req.Data, &types.InnerActivationTx{}) {
+			return fmt.Errorf("generic data decodes as a block or an atx")
+		}
+		return nil
+	case Domain_VRF:
+		return nil
+	case Domain_BLOCK:
+		blk := &types.MiniBlock{}
+		if !decodesAs(req.Data, blk) {
+			return fmt.Errorf("data is not a block")
+		}
+		if uint64(blk.LayerIndex) != req.Layer || blk.EligibilityProof.J != req.EligibilityCounter {
+			return fmt.Errorf("block layer %v and counter %v don't match request", blk.LayerIndex, blk.EligibilityProof.J)
+		}
+		return s.guard.CheckBlock(req.Layer, req.EligibilityCounter, req.Data)
+	case Domain_ACTIVATION:
+		atx := &types.InnerActivationTx{}
+		if !decodesAs(req.Data, atx) || atx.ActivationTxHeader == nil {
+			return fmt.Errorf("data is not an atx")
+		}
+		if err := s.checkLayersPerEpoch(req); err != nil {
+			return err
+		}
+		if uint64(atx.PubLayerID) != req.Layer {
+			return fmt.Errorf("atx publication layer %v doesn't match request", atx.PubLayerID)
+		}
+		return s.guard.CheckActivation(req.Layer, req.Data)
+	default:
+		return fmt.Errorf("unknown domain %v", req.Domain)
+	}
+}
+
+func (s *Server) checkLayersPerEpoch(req *SignRequest) error {
+	if req.LayersPerEpoch != uint32(s.guard.layersPerEpoch) {
+		return fmt.Errorf("node has %v layers per epoch, the signer has %v", req.LayersPerEpoch, s.guard.layersPerEpoch)
+	}
+	return nil
+}
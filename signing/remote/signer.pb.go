@@ -0,0 +1,221 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: signing/remote/signer.proto
+
+package remote
+
+import (
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type Domain int32
+
+const (
+	Domain_PUBLIC_KEY Domain = 0
+	Domain_GENERIC    Domain = 1
+	Domain_BLOCK      Domain = 2
+	Domain_ACTIVATION Domain = 3
+	Domain_VRF        Domain = 4
+)
+
+var Domain_name = map[int32]string{
+	0: "PUBLIC_KEY",
+	1: "GENERIC",
+	2: "BLOCK",
+	3: "ACTIVATION",
+	4: "VRF",
+}
+
+var Domain_value = map[string]int32{
+	"PUBLIC_KEY": 0,
+	"GENERIC":    1,
+	"BLOCK":      2,
+	"ACTIVATION": 3,
+	"VRF":        4,
+}
+
+func (x Domain) String() string {
+	return proto.EnumName(Domain_name, int32(x))
+}
+
+func (Domain) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_c287aec34d89a760, []int{0}
+}
+
+type SignRequest struct {
+	Domain               Domain   `protobuf:"varint,1,opt,name=domain,proto3,enum=remote.Domain" json:"domain,omitempty"`
+	Layer                uint64   `protobuf:"varint,2,opt,name=layer,proto3" json:"layer,omitempty"`
+	EligibilityCounter   uint32   `protobuf:"varint,3,opt,name=eligibilityCounter,proto3" json:"eligibilityCounter,omitempty"`
+	Data                 []byte   `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	LayersPerEpoch       uint32   `protobuf:"varint,5,opt,name=layersPerEpoch,proto3" json:"layersPerEpoch,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SignRequest) Reset()         { *m = SignRequest{} }
+func (m *SignRequest) String() string { return proto.CompactTextString(m) }
+func (*SignRequest) ProtoMessage()    {}
+func (*SignRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_c287aec34d89a760, []int{0}
+}
+
+func (m *SignRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SignRequest.Unmarshal(m, b)
+}
+func (m *SignRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SignRequest.Marshal(b, m, deterministic)
+}
+func (m *SignRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SignRequest.Merge(m, src)
+}
+func (m *SignRequest) XXX_Size() int {
+	return xxx_messageInfo_SignRequest.Size(m)
+}
+func (m *SignRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SignRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SignRequest proto.InternalMessageInfo
+
+func (m *SignRequest) GetDomain() Domain {
+	if m != nil {
+		return m.Domain
+	}
+	return Domain_PUBLIC_KEY
+}
+
+func (m *SignRequest) GetLayer() uint64 {
+	if m != nil {
+		return m.Layer
+	}
+	return 0
+}
+
+func (m *SignRequest) GetEligibilityCounter() uint32 {
+	if m != nil {
+		return m.EligibilityCounter
+	}
+	return 0
+}
+
+func (m *SignRequest) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *SignRequest) GetLayersPerEpoch() uint32 {
+	if m != nil {
+		return m.LayersPerEpoch
+	}
+	return 0
+}
+
+type SignResponse struct {
+	Signature            []byte   `protobuf:"bytes,1,opt,name=signature,proto3" json:"signature,omitempty"`
+	PublicKey            []byte   `protobuf:"bytes,2,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
+	Error                string   `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	VrfPublicKey         []byte   `protobuf:"bytes,4,opt,name=vrfPublicKey,proto3" json:"vrfPublicKey,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SignResponse) Reset()         { *m = SignResponse{} }
+func (m *SignResponse) String() string { return proto.CompactTextString(m) }
+func (*SignResponse) ProtoMessage()    {}
+func (*SignResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_c287aec34d89a760, []int{1}
+}
+
+func (m *SignResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SignResponse.Unmarshal(m, b)
+}
+func (m *SignResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SignResponse.Marshal(b, m, deterministic)
+}
+func (m *SignResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SignResponse.Merge(m, src)
+}
+func (m *SignResponse) XXX_Size() int {
+	return xxx_messageInfo_SignResponse.Size(m)
+}
+func (m *SignResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SignResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SignResponse proto.InternalMessageInfo
+
+func (m *SignResponse) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+func (m *SignResponse) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *SignResponse) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *SignResponse) GetVrfPublicKey() []byte {
+	if m != nil {
+		return m.VrfPublicKey
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterEnum("remote.Domain", Domain_name, Domain_value)
+	proto.RegisterType((*SignRequest)(nil), "remote.SignRequest")
+	proto.RegisterType((*SignResponse)(nil), "remote.SignResponse")
+}
+
+func init() { proto.RegisterFile("signing/remote/signer.proto", fileDescriptor_c287aec34d89a760) }
+
+var fileDescriptor_c287aec34d89a760 = []byte{
+	// 317 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x91, 0xc1, 0x6a, 0xe3, 0x30,
+	0x10, 0x86, 0x57, 0x89, 0xe3, 0x90, 0x89, 0xd7, 0x98, 0x61, 0x0f, 0x86, 0xdd, 0x83, 0xc9, 0x21,
+	0x98, 0x3d, 0x38, 0xd0, 0x3e, 0x41, 0xe2, 0xba, 0xc5, 0x24, 0x24, 0x41, 0x4d, 0x03, 0x3d, 0x15,
+	0x27, 0x51, 0x5d, 0x81, 0x63, 0xb9, 0xb2, 0x5c, 0xc8, 0xbd, 0xef, 0xd4, 0xd7, 0x2b, 0x96, 0x4a,
+	0x43, 0x4b, 0x6f, 0x9a, 0xef, 0x9f, 0x11, 0xdf, 0x48, 0xf0, 0xb7, 0xe6, 0x79, 0xc9, 0xcb, 0x7c,
+	0x22, 0xd9, 0x51, 0x28, 0x36, 0x69, 0x4b, 0x26, 0xa3, 0x4a, 0x0a, 0x25, 0xd0, 0x36, 0x70, 0xf4,
+	0x46, 0x60, 0x78, 0xcb, 0xf3, 0x92, 0xb2, 0xe7, 0x86, 0xd5, 0x0a, 0xc7, 0x60, 0x1f, 0xc4, 0x31,
+	0xe3, 0xa5, 0x4f, 0x02, 0x12, 0xba, 0x17, 0x6e, 0x64, 0x1a, 0xa3, 0x2b, 0x4d, 0xe9, 0x47, 0x8a,
+	0x7f, 0xa0, 0x57, 0x64, 0x27, 0x26, 0xfd, 0x4e, 0x40, 0x42, 0x8b, 0x9a, 0x02, 0x23, 0x40, 0x56,
+	0xf0, 0x9c, 0xef, 0x78, 0xc1, 0xd5, 0x29, 0x16, 0x4d, 0xa9, 0x98, 0xf4, 0xbb, 0x01, 0x09, 0x7f,
+	0xd3, 0x1f, 0x12, 0x44, 0xb0, 0x0e, 0x99, 0xca, 0x7c, 0x2b, 0x20, 0xa1, 0x43, 0xf5, 0x19, 0xc7,
+	0xe0, 0xea, 0xcb, 0xea, 0x35, 0x93, 0x49, 0x25, 0xf6, 0x4f, 0x7e, 0x4f, 0xcf, 0x7f, 0xa3, 0xa3,
+	0x57, 0x02, 0x8e, 0x31, 0xaf, 0x2b, 0x51, 0xd6, 0x0c, 0xff, 0xc1, 0xa0, 0x5d, 0x31, 0x53, 0x8d,
+	0x64, 0xda, 0xde, 0xa1, 0x67, 0xd0, 0xa6, 0x55, 0xb3, 0x2b, 0xf8, 0x7e, 0xce, 0x4e, 0x5a, 0xda,
+	0xa1, 0x67, 0xd0, 0xae, 0xc3, 0xa4, 0x14, 0xc6, 0x75, 0x40, 0x4d, 0x81, 0x23, 0x70, 0x5e, 0xe4,
+	0xe3, 0xfa, 0x73, 0xcc, 0x68, 0x7e, 0x61, 0xff, 0x53, 0xb0, 0xcd, 0xd3, 0xa0, 0x0b, 0xb0, 0xbe,
+	0x9b, 0x2d, 0xd2, 0xf8, 0x61, 0x9e, 0xdc, 0x7b, 0xbf, 0x70, 0x08, 0xfd, 0x9b, 0x64, 0x99, 0xd0,
+	0x34, 0xf6, 0x08, 0x0e, 0xa0, 0x37, 0x5b, 0xac, 0xe2, 0xb9, 0xd7, 0x69, 0xfb, 0xa6, 0xf1, 0x26,
+	0xdd, 0x4e, 0x37, 0xe9, 0x6a, 0xe9, 0x75, 0xb1, 0x0f, 0xdd, 0x2d, 0xbd, 0xf6, 0xac, 0x9d, 0xad,
+	0xbf, 0xe6, 0xf2, 0x7d, 0x00, 0xaa, 0x6c, 0x3f, 0x83, 0xb9, 0x01, 0x00, 0x00,
+}
@@ -54,6 +54,17 @@ func (p *PublicKey) Equals(o *PublicKey) bool {
 	return bytes.Equal(p.Bytes(), o.Bytes())
 }
 
+// Signer signs data on behalf of a node identity. Blocks and ATXs are signed through dedicated methods, so that an
+// implementation that keeps the private key outside the node (e.g. a remote signer) can refuse to sign conflicting
+// blocks or ATXs. Sign is used for any other data, such as hare messages, it returns nil if the data could not be
+// signed and callers must not use an empty signature.
+type Signer interface {
+	PublicKey() *PublicKey
+	Sign(m []byte) []byte
+	SignBlock(layer uint64, eligibilityCounter uint32, m []byte) ([]byte, error)
+	SignActivation(pubLayer uint64, m []byte) ([]byte, error)
+}
+
 // EdSigner represents an ED25519 signer
 type EdSigner struct {
 	privKey ed25519.PrivateKey // the pub & private key
@@ -93,6 +104,16 @@ func (es *EdSigner) Sign(m []byte) []byte {
 	return ed25519.Sign2(es.privKey, m)
 }
 
+// SignBlock signs the provided block bytes. A local signer doesn't track what it signed so it never refuses.
+func (es *EdSigner) SignBlock(layer uint64, eligibilityCounter uint32, m []byte) ([]byte, error) {
+	return es.Sign(m), nil
+}
+
+// SignActivation signs the provided ATX bytes. A local signer doesn't track what it signed so it never refuses.
+func (es *EdSigner) SignActivation(pubLayer uint64, m []byte) ([]byte, error) {
+	return es.Sign(m), nil
+}
+
 // Verify verifies the provided message
 func Verify(pubkey *PublicKey, message []byte, sign []byte) bool {
 	return ed25519.Verify2(ed25519.PublicKey(pubkey.Bytes()), message, sign)
@@ -0,0 +1,18 @@
+package signing
+
+import (
+	"github.com/spacemeshos/amcl"
+	"github.com/spacemeshos/amcl/BLS381"
+)
+
+// NewVRFSigner derives the vrf key of an identity from its ed key, so that it doesn't have to be stored separately.
+// The key is seeded by the signature of the public key, so a signer holding the identity key must never sign it for
+// anything else.
+func NewVRFSigner(es *EdSigner) (*BLS381.BlsSigner, []byte) {
+	rng := amcl.NewRAND()
+	pub := es.PublicKey().Bytes()
+	seed := es.Sign(pub) // assuming ed.private is random, the sig can be used as seed
+	rng.Seed(len(pub), seed)
+	vrfPriv, vrfPub := BLS381.GenKeyPair(rng)
+	return BLS381.NewBlsSigner(vrfPriv), vrfPub
+}
@@ -0,0 +1,186 @@
+package sync
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	p2pconf "github.com/spacemeshos/go-spacemesh/p2p/config"
+	"github.com/spacemeshos/go-spacemesh/p2p/server"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+)
+
+// ArchiveServer serves a layer of a mesh archive over the sync protocol. A syncer that has it as its only peer imports
+// the archive through the same fetching and validation as when syncing from the network.
+type ArchiveServer struct {
+	log.Log
+	*server.MessageServer
+
+	mu     sync.RWMutex
+	layer  *mesh.ArchiveLayer
+	blocks map[types.BlockID]*types.Block
+	txs    map[types.TransactionID]*types.Transaction
+	atxs   map[types.ATXID]*types.ActivationTx
+	proofs map[string][]byte
+}
+
+// NewArchiveServer registers the sync protocol handlers on srv, the server serves nothing until SetLayer is called
+func NewArchiveServer(srv service.Service, requestTimeout time.Duration, logger log.Log) *ArchiveServer {
+	a := &ArchiveServer{
+		Log:           logger,
+		MessageServer: server.NewMsgServer(srv.(server.Service), syncProtocol, requestTimeout, make(chan service.DirectMessage, p2pconf.Values.BufferSize), logger),
+	}
+	a.RegisterBytesMsgHandler(layerHashMsg, a.handleLayerHash)
+	a.RegisterBytesMsgHandler(layerIdsMsg, a.handleLayerIds)
+	a.RegisterBytesMsgHandler(blockMsg, a.handleBlocks)
+	a.RegisterBytesMsgHandler(txMsg, a.handleTxs)
+	a.RegisterBytesMsgHandler(atxMsg, a.handleAtxs)
+	a.RegisterBytesMsgHandler(poetMsg, a.handlePoetProof)
+	// the archive holds no certified hare outputs, the importing node decides the validity of blocks on its own
+	a.RegisterBytesMsgHandler(hareOutputMsg, func([]byte) []byte { return nil })
+	return a
+}
+
+// SetLayer replaces the served layer. The data of previous layers is not served anymore, it is expected to have been
+// imported already.
+func (a *ArchiveServer) SetLayer(l *mesh.ArchiveLayer) error {
+	blocks := make(map[types.BlockID]*types.Block, len(l.Blocks))
+	for _, b := range l.Blocks {
+		blocks[b.ID()] = b
+	}
+	txs := make(map[types.TransactionID]*types.Transaction, len(l.Txs))
+	for _, tx := range l.Txs {
+		txs[tx.ID()] = tx
+	}
+	atxs := make(map[types.ATXID]*types.ActivationTx, len(l.Atxs))
+	for _, atx := range l.Atxs {
+		atxs[atx.ID()] = atx
+	}
+	proofs := make(map[string][]byte, len(l.Proofs))
+	for _, msg := range l.Proofs {
+		var proofMessage types.PoetProofMessage
+		if err := types.BytesToInterface(msg, &proofMessage); err != nil {
+			return fmt.Errorf("could not decode PoET proof of layer %v: %v", l.Layer, err)
+		}
+		ref, err := proofMessage.Ref()
+		if err != nil {
+			return fmt.Errorf("could not calc PoET proof ref of layer %v: %v", l.Layer, err)
+		}
+		proofs[string(ref)] = msg
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.layer = l
+	a.blocks = blocks
+	a.txs = txs
+	a.atxs = atxs
+	a.proofs = proofs
+	return nil
+}
+
+func (a *ArchiveServer) blockIds(lyr types.LayerID) ([]types.BlockID, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.layer == nil || a.layer.Layer != lyr {
+		return nil, false
+	}
+	return types.BlockIDs(a.layer.Blocks), true
+}
+
+func (a *ArchiveServer) handleLayerHash(msg []byte) []byte {
+	ids, ok := a.blockIds(types.LayerID(util.BytesToUint64(msg)))
+	if !ok {
+		return nil
+	}
+	return types.CalcBlocksHash32(ids, nil).Bytes()
+}
+
+func (a *ArchiveServer) handleLayerIds(msg []byte) []byte {
+	ids, ok := a.blockIds(types.LayerID(util.BytesToUint64(msg)))
+	if !ok {
+		return nil
+	}
+	idbytes, err := types.BlockIdsToBytes(ids)
+	if err != nil {
+		a.Error("could not marshal archive block ids: %v", err)
+		return nil
+	}
+	return idbytes
+}
+
+func (a *ArchiveServer) handleBlocks(msg []byte) []byte {
+	var blockids []types.Hash32
+	if err := types.BytesToInterface(msg, &blockids); err != nil {
+		a.Error("could not unmarshal block request: %v", err)
+		return nil
+	}
+	a.mu.RLock()
+	var blocks []types.Block
+	for _, bid := range blockids {
+		if blk, ok := a.blocks[types.BlockID(bid.ToHash20())]; ok {
+			blocks = append(blocks, *blk)
+		}
+	}
+	a.mu.RUnlock()
+	bbytes, err := types.InterfaceToBytes(blocks)
+	if err != nil {
+		a.Error("could not marshal archive blocks: %v", err)
+		return nil
+	}
+	return bbytes
+}
+
+func (a *ArchiveServer) handleTxs(msg []byte) []byte {
+	var txids []types.TransactionID
+	if err := types.BytesToInterface(msg, &txids); err != nil {
+		a.Error("could not unmarshal tx request: %v", err)
+		return nil
+	}
+	a.mu.RLock()
+	var txs []*types.Transaction
+	for _, id := range txids {
+		if tx, ok := a.txs[id]; ok {
+			txs = append(txs, tx)
+		}
+	}
+	a.mu.RUnlock()
+	bbytes, err := types.InterfaceToBytes(txs)
+	if err != nil {
+		a.Error("could not marshal archive transactions: %v", err)
+		return nil
+	}
+	return bbytes
+}
+
+func (a *ArchiveServer) handleAtxs(msg []byte) []byte {
+	var atxids []types.ATXID
+	if err := types.BytesToInterface(msg, &atxids); err != nil {
+		a.Error("could not unmarshal atx request: %v", err)
+		return nil
+	}
+	a.mu.RLock()
+	var atxs []types.ActivationTx
+	for _, id := range atxids {
+		if atx, ok := a.atxs[id]; ok {
+			atxs = append(atxs, *atx)
+		}
+	}
+	a.mu.RUnlock()
+	bbytes, err := types.InterfaceToBytes(atxs)
+	if err != nil {
+		a.Error("could not marshal archive atxs: %v", err)
+		return nil
+	}
+	return bbytes
+}
+
+func (a *ArchiveServer) handlePoetProof(proofRef []byte) []byte {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.proofs[string(proofRef)]
+}
@@ -0,0 +1,75 @@
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/sha256-simd"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spacemeshos/go-spacemesh/activation"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"github.com/spacemeshos/go-spacemesh/miner"
+	p2ppeers "github.com/spacemeshos/go-spacemesh/p2p/peers"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/rand"
+	"github.com/spacemeshos/go-spacemesh/signing"
+)
+
+func TestSyncer_ImportLayer(t *testing.T) {
+	r := require.New(t)
+	sim := service.NewSimulator()
+	lg := log.New(t.Name(), "", "")
+	s := NewSync(sim.NewNode(), getMesh(memoryDB, Path+t.Name()+"_"+time.Now().String()), miner.NewTxMemPool(), miner.NewAtxMemPool(), blockEligibilityValidatorMock{}, newMemPoetDb(), conf, &mockClock{Layer: 5}, lg)
+	defer s.Close()
+	s.ValidateLayer(mesh.GenesisLayer())
+
+	srvNode := sim.NewNode()
+	srv := NewArchiveServer(srvNode, conf.RequestTimeout, lg.WithName("archive"))
+	defer srv.Close()
+	s.peers = getPeersMock([]p2ppeers.Peer{srvNode.PublicKey()})
+
+	// the atx of the layer references a PoET proof that is only in the archive
+	signer := signing.NewEdSigner()
+	proofMessage := makePoetProofMessage(t)
+	proofBytes, err := types.InterfaceToBytes(&proofMessage)
+	r.NoError(err)
+	poetProofBytes, err := types.InterfaceToBytes(&proofMessage.PoetProof)
+	r.NoError(err)
+	poetRef := sha256.Sum256(poetProofBytes)
+	atx1 := atx(signer.PublicKey().String())
+	atx1.Nipst.PostProof.Challenge = poetRef[:]
+	r.NoError(activation.SignAtx(signer, atx1))
+
+	layer := &mesh.ArchiveLayer{Layer: 1, Txs: []*types.Transaction{tx1, tx2}, Atxs: []*types.ActivationTx{atx1}, Proofs: [][]byte{proofBytes}}
+	for i := 0; i < 2; i++ {
+		b := types.NewExistingBlock(1, []byte(rand.String(8)))
+		b.TxIDs = []types.TransactionID{tx1.ID(), tx2.ID()}
+		b.ATXIDs = []types.ATXID{atx1.ID()}
+		b.Signature = signer.Sign(b.Bytes())
+		b.Initialize()
+		layer.Blocks = append(layer.Blocks, b)
+	}
+
+	// layers must be imported in order
+	r.Error(s.ImportLayer(2))
+
+	r.NoError(srv.SetLayer(layer))
+	r.NoError(s.ImportLayer(1))
+	r.Equal(types.LayerID(1), s.ProcessedLayer())
+	ids, err := s.LayerBlockIds(1)
+	r.NoError(err)
+	r.ElementsMatch(types.BlockIDs(layer.Blocks), ids)
+	_, err = s.GetTransaction(tx1.ID())
+	r.NoError(err)
+	_, err = s.GetAtxHeader(atx1.ID())
+	r.NoError(err)
+	r.True(s.poetDb.HasProof(poetRef[:]))
+
+	// a layer without blocks is imported as a zero block layer
+	r.NoError(srv.SetLayer(&mesh.ArchiveLayer{Layer: 2}))
+	r.NoError(s.ImportLayer(2))
+	r.Equal(types.LayerID(2), s.ProcessedLayer())
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/config"
 	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
 	"github.com/spacemeshos/go-spacemesh/p2p/service"
 	"github.com/spacemeshos/go-spacemesh/priorityq"
 	"sync"
@@ -93,7 +94,7 @@ func (bl *BlockListener) handleBlock(data service.GossipMessage) {
 
 	bl.Log.With().Info("got new block", blk.Fields()...)
 	//check if known
-	if _, err := bl.GetBlock(blk.ID()); err == nil {
+	if _, err := bl.GetBlock(blk.ID()); err == nil || err == mesh.ErrPruned {
 		bl.With().Info("we already know this block", log.BlockID(blk.ID().String()))
 		return
 	}
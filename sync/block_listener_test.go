@@ -362,6 +362,52 @@ func TestBlockListener_ValidateVotesBadFlow(t *testing.T) {
 	assert.False(t, valid)
 }
 
+func TestBlockListener_ValidateVotesBaseBlock(t *testing.T) {
+	block1 := types.NewExistingBlock(3, []byte(rand.String(8)))
+	block2 := types.NewExistingBlock(2, []byte(rand.String(8)))
+	block3 := types.NewExistingBlock(1, []byte(rand.String(8)))
+	block4 := types.NewExistingBlock(1, []byte(rand.String(8)))
+	block5 := types.NewExistingBlock(3, []byte(rand.String(8)))
+
+	block2.AddView(block3.ID())
+	block2.AddView(block4.ID())
+	block2.AddVote(block3.ID())
+	block2.AddVoteAgainst(block4.ID())
+	block2.Initialize()
+	block1.AddView(block2.ID())
+	block1.AddView(block5.ID())
+	block1.BaseBlock = block2.ID()
+	block1.AddVote(block2.ID())
+	block1.AddAbstain(block4.ID())
+
+	sim := service.NewSimulator()
+	n1 := sim.NewNode()
+	n2 := sim.NewNode()
+	bl1 := ListenerFactory(n1, PeersMock{func() []p2ppeers.Peer { return []p2ppeers.Peer{n2.PublicKey()} }}, "TestBlockListener_ValidateVotesBaseBlock", 2)
+	defer bl1.Close()
+	bl1.AddBlock(block2)
+	bl1.AddBlock(block3)
+	bl1.AddBlock(block4)
+	bl1.AddBlock(block5)
+
+	valid, err := validateVotes(block1, bl1.ForBlockInView, bl1.Hdist, log.New("", "", ""))
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	// a block can only get one vote
+	block1.AddVoteAgainst(block2.ID())
+	valid, err = validateVotes(block1, bl1.ForBlockInView, bl1.Hdist, log.New("", "", ""))
+	assert.Error(t, err)
+	assert.False(t, valid)
+	block1.AgainstDiff = nil
+
+	// the base block must be in an earlier layer
+	block1.BaseBlock = block5.ID()
+	valid, err = validateVotes(block1, bl1.ForBlockInView, bl1.Hdist, log.New("", "", ""))
+	assert.Error(t, err)
+	assert.False(t, valid)
+}
+
 func TestBlockListenerViewTraversal(t *testing.T) {
 
 	t.Log("TestBlockListener2 start")
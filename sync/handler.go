@@ -26,6 +26,19 @@ func newLayerHashRequestHandler(layers *mesh.Mesh, logger log.Log) func(msg []by
 	}
 }
 
+func newAggregatedHashRequestHandler(layers *mesh.Mesh, logger log.Log) func(msg []byte) []byte {
+	return func(msg []byte) []byte {
+		lyrid := util.BytesToUint64(msg)
+		logger.With().Debug("handle aggregated hash request", log.LayerID(lyrid))
+		h, err := layers.GetAggregatedLayerHash(types.LayerID(lyrid))
+		if err != nil {
+			logger.With().Warning("aggregated hash requested for layer not in state", log.LayerID(lyrid), log.Err(err))
+			return nil
+		}
+		return h.Bytes()
+	}
+}
+
 func newLayerBlockIdsRequestHandler(layers *mesh.Mesh, logger log.Log) func(msg []byte) []byte {
 	return func(msg []byte) []byte {
 		logger.Debug("handle blockIds request")
@@ -73,6 +86,11 @@ func newBlockRequestHandler(msh *mesh.Mesh, logger log.Log) func(msg []byte) []b
 			logger.Info("handle block %s request", bid.ShortString())
 			blk, err := msh.GetBlock(types.BlockID(bid.ToHash20()))
 			if err != nil {
+				if err == mesh.ErrPruned {
+					// only the header is left, peers could not validate it
+					logger.With().Warning("pruned block was requested", log.BlockID(bid.ShortString()))
+					continue
+				}
 				if err == database.ErrNotFound {
 					logger.With().Warning("unfamiliar block was requested (id: %s)", log.BlockID(bid.ShortString()), log.Err(err))
 					continue
@@ -80,7 +98,6 @@ func newBlockRequestHandler(msh *mesh.Mesh, logger log.Log) func(msg []byte) []b
 				logger.With().Error("Error handling block request message", log.BlockID(bid.ShortString()), log.Err(err))
 				continue
 			}
-
 			blocks = append(blocks, *blk)
 		}
 		bbytes, err := types.InterfaceToBytes(blocks)
@@ -171,3 +188,19 @@ func newPoetRequestHandler(s *Syncer, logger log.Log) func(msg []byte) []byte {
 		return proofMessage
 	}
 }
+
+func newHareOutputRequestHandler(s *Syncer, logger log.Log) func(msg []byte) []byte {
+	return func(msg []byte) []byte {
+		lyrid := util.BytesToUint64(msg)
+		if s.certifiedOutputs == nil {
+			return nil
+		}
+		out, err := s.certifiedOutputs.GetCertifiedOutput(types.LayerID(lyrid))
+		if err != nil {
+			logger.With().Debug("no certified hare output for requested layer", log.LayerID(lyrid), log.Err(err))
+			return nil
+		}
+		logger.With().Info("returning certified hare output to neighbor", log.LayerID(lyrid))
+		return out
+	}
+}
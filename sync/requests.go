@@ -67,6 +67,28 @@ func hashReqFactory(lyr types.LayerID) requestFactory {
 
 }
 
+func aggregatedHashReqFactory(lyr types.LayerID) requestFactory {
+	return func(s networker, peer p2ppeers.Peer) (chan interface{}, error) {
+		ch := make(chan interface{}, 1)
+		foo := func(msg []byte) {
+			defer close(ch)
+			if len(msg) == 0 || msg == nil {
+				s.Warning("peer %v responded with nil to aggregated hash request layer %v", peer, lyr)
+				return
+			}
+			if len(msg) != types.Hash32Length {
+				s.Error("received aggregated hash in wrong length, len %v", len(msg))
+				return
+			}
+			ch <- types.BytesToHash(msg)
+		}
+		if err := s.SendRequest(aggregatedHashMsg, lyr.Bytes(), peer, foo); err != nil {
+			return nil, err
+		}
+		return ch, nil
+	}
+}
+
 func newFetchReqFactory(msgtype server.MessageType, asItems func(msg []byte) ([]item, error)) batchRequestFactory {
 	//convert to chan
 	return func(infra networker, peer p2ppeers.Peer, ids []types.Hash32) (chan []item, error) {
@@ -190,6 +212,29 @@ func poetReqFactory(poetProofRef []byte) requestFactory {
 	}
 }
 
+func hareOutputReqFactory(lyr types.LayerID, verify func(layer types.LayerID, data []byte) ([]types.BlockID, error)) requestFactory {
+	return func(s networker, peer p2ppeers.Peer) (chan interface{}, error) {
+		ch := make(chan interface{}, 1)
+		foo := func(msg []byte) {
+			defer close(ch)
+			if len(msg) == 0 || msg == nil {
+				s.Warning("peer %v responded with nil to hare output request layer %v", peer, lyr)
+				return
+			}
+			blocks, err := verify(lyr, msg)
+			if err != nil {
+				s.Warning("peer %v responded with an invalid hare output for layer %v: %v", peer, lyr, err)
+				return
+			}
+			ch <- blocks
+		}
+		if err := s.SendRequest(hareOutputMsg, lyr.Bytes(), peer, foo); err != nil {
+			return nil, err
+		}
+		return ch, nil
+	}
+}
+
 func validatePoetRef(proofMessage types.PoetProofMessage, poetProofRef []byte) (bool, error) {
 	poetProofBytes, err := types.InterfaceToBytes(&proofMessage.PoetProof)
 	if err != nil {
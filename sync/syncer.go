@@ -36,6 +36,12 @@ type poetDb interface {
 	GetProofMessage(proofRef []byte) ([]byte, error)
 }
 
+// CertifiedOutputs provides the certified hare outputs of layers and verifies certified outputs received from peers.
+type CertifiedOutputs interface {
+	GetCertifiedOutput(layer types.LayerID) ([]byte, error)
+	VerifyCertifiedOutput(layer types.LayerID, data []byte) ([]types.BlockID, error)
+}
+
 type blockEligibilityValidator interface {
 	BlockSignedAndEligible(block *types.Block) (bool, error)
 }
@@ -112,6 +118,8 @@ const (
 	txMsg               server.MessageType = 4
 	atxMsg              server.MessageType = 5
 	poetMsg             server.MessageType = 6
+	hareOutputMsg       server.MessageType = 7
+	aggregatedHashMsg   server.MessageType = 8
 	syncProtocol                           = "/sync/1.0/"
 	validatingLayerNone types.LayerID      = 0
 )
@@ -131,6 +139,8 @@ type Syncer struct {
 	txpool  txMemPool
 	atxpool atxMemPool
 
+	certifiedOutputs CertifiedOutputs
+
 	validatingLayer      types.LayerID
 	validatingLayerMutex sync.Mutex
 	syncLock             types.TryMutex
@@ -141,6 +151,9 @@ type Syncer struct {
 	gossipLock           sync.RWMutex
 	gossipSynced         status
 	awaitCh              chan struct{}
+	divergedMu           sync.RWMutex
+	diverged             bool
+	divergedLayer        types.LayerID
 
 	blockQueue *blockQueue
 	txQueue    *txQueue
@@ -188,10 +201,18 @@ func NewSync(srv service.Service, layers *mesh.Mesh, txpool txMemPool, atxpool a
 	srvr.RegisterBytesMsgHandler(txMsg, newTxsRequestHandler(s, logger))
 	srvr.RegisterBytesMsgHandler(atxMsg, newAtxsRequestHandler(s, logger))
 	srvr.RegisterBytesMsgHandler(poetMsg, newPoetRequestHandler(s, logger))
+	srvr.RegisterBytesMsgHandler(hareOutputMsg, newHareOutputRequestHandler(s, logger))
+	srvr.RegisterBytesMsgHandler(aggregatedHashMsg, newAggregatedHashRequestHandler(layers, logger))
 
 	return s
 }
 
+// SetCertifiedOutputs sets the provider of certified hare outputs, it must be called before the syncer is started.
+// Certified outputs are served to syncing peers and the ones received while syncing are adopted as the layer's result.
+func (s *Syncer) SetCertifiedOutputs(outputs CertifiedOutputs) {
+	s.certifiedOutputs = outputs
+}
+
 //ForceSync signals syncer to run the synchronise flow
 func (s *Syncer) ForceSync() {
 	s.forceSync <- true
@@ -310,6 +331,15 @@ func (s *Syncer) synchronise() {
 
 	//release synchronise lock
 	defer s.syncLock.Unlock()
+
+	// a node whose state diverged from most peers doesn't listen to gossip until it agrees with them again
+	if _, diverged := s.Diverged(); diverged {
+		if _, diverged := s.checkDivergence(); diverged {
+			s.setGossipBufferingStatus(pending)
+			return
+		}
+	}
+
 	curr := s.GetCurrentLayer()
 
 	//node is synced and blocks from current layer have already been validated
@@ -406,20 +436,13 @@ func (s *Syncer) handleNotSynced(currentSyncLayer types.LayerID) {
 			return
 		}
 
-		lyr, err := s.getLayerFromNeighbors(currentSyncLayer)
-		if err != nil {
-			s.With().Info("could not get layer from neighbors", log.LayerID(currentSyncLayer.Uint64()), log.Err(err))
+		if err := s.syncAndValidateLayer(currentSyncLayer); err != nil {
+			s.With().Info("could not sync layer", log.LayerID(currentSyncLayer.Uint64()), log.Err(err))
 			return
 		}
-
-		if len(lyr.Blocks()) == 0 {
-			if err := s.SetZeroBlockLayer(currentSyncLayer); err != nil {
-				s.With().Error("handleNotSynced failed ", log.LayerID(currentSyncLayer.Uint64()), log.Err(err))
-				return
-			}
-		}
-
-		s.ValidateLayer(lyr) // wait for layer validation
+	}
+	if _, diverged := s.checkDivergence(); diverged {
+		return
 	}
 
 	// wait for two ticks to ensure we are fully synced before we open gossip or validate the current layer
@@ -429,6 +452,37 @@ func (s *Syncer) handleNotSynced(currentSyncLayer types.LayerID) {
 	}
 }
 
+// syncAndValidateLayer fetches the layer's blocks and their data from neighbors and validates the layer
+func (s *Syncer) syncAndValidateLayer(currentSyncLayer types.LayerID) error {
+	lyr, err := s.getLayerFromNeighbors(currentSyncLayer)
+	if err != nil {
+		return fmt.Errorf("could not get layer from neighbors: %v", err)
+	}
+
+	if len(lyr.Blocks()) == 0 {
+		if err := s.SetZeroBlockLayer(currentSyncLayer); err != nil {
+			return fmt.Errorf("could not set zero block layer: %v", err)
+		}
+	} else if blocks, err := s.fetchCertifiedOutput(currentSyncLayer); err == nil {
+		s.With().Info("adopting certified hare output", log.LayerID(currentSyncLayer.Uint64()), log.Int("blocks", len(blocks)))
+		s.HandleValidatedLayer(currentSyncLayer, blocks)
+	} else {
+		s.With().Info("no certified hare output for layer, using local view", log.LayerID(currentSyncLayer.Uint64()), log.Err(err))
+	}
+
+	s.ValidateLayer(lyr) // wait for layer validation
+	return nil
+}
+
+// ImportLayer syncs and validates the layer following the processed layer from the syncer's peers, without waiting
+// for the clock. It is used to import a mesh archive served by an ArchiveServer, the syncer must not be started.
+func (s *Syncer) ImportLayer(lyr types.LayerID) error {
+	if processed := s.ProcessedLayer(); lyr != processed+1 {
+		return fmt.Errorf("layer %v does not follow the processed layer %v", lyr, processed)
+	}
+	return s.syncAndValidateLayer(lyr)
+}
+
 // Waits two ticks (while weakly-synced) in order to ensure that we listened to gossip for one full layer
 // after that we are assumed to have all the data required for validation so we can validate and open gossip
 // opening gossip in weakly-synced transition us to fully-synced
@@ -659,12 +713,23 @@ func validateVotes(blk *types.Block, forBlockfunc forBlockInView, depth int, lg
 		view[b] = struct{}{}
 	}
 
+	// each block can only get one vote, and the base block and every block voted on must be in view
 	vote := map[types.BlockID]struct{}{}
-	for _, b := range blk.BlockVotes {
-		vote[b] = struct{}{}
+	for _, diff := range [][]types.BlockID{blk.ForDiff, blk.AgainstDiff, blk.NeutralDiff} {
+		for _, b := range diff {
+			if _, found := vote[b]; found {
+				return false, fmt.Errorf("block %v voted on more than once", b)
+			}
+			vote[b] = struct{}{}
+		}
+	}
+	if blk.BaseBlock != (types.BlockID{}) {
+		vote[blk.BaseBlock] = struct{}{}
 	}
-
 	traverse := func(b *types.Block) (stop bool, err error) {
+		if b.ID() == blk.BaseBlock && b.Layer() >= blk.Layer() {
+			return true, fmt.Errorf("base block %v is not in an earlier layer", b.ID())
+		}
 		if _, ok := vote[b.ID()]; ok {
 			delete(vote, b.ID())
 		}
@@ -810,6 +875,110 @@ func (s *Syncer) fetchLayerHashes(lyr types.LayerID) (map[types.Hash32][]p2ppeer
 	return m, nil
 }
 
+// FindDivergentLayer binary searches the layers up to lyr for the first layer whose aggregated hash differs from the one
+// of the peer. Aggregated hashes chain the hashes of all previous layers, so the node and the peer agree on every layer
+// before it. It returns false if they agree on lyr.
+func (s *Syncer) FindDivergentLayer(peer p2ppeers.Peer, lyr types.LayerID) (types.LayerID, bool, error) {
+	diverged := func(l types.LayerID) (bool, error) {
+		ours, err := s.GetAggregatedLayerHash(l)
+		if err != nil {
+			return false, fmt.Errorf("no aggregated hash for layer %v: %v", l, err)
+		}
+		theirs, err := s.fetchAggregatedHash(peer, l)
+		if err != nil {
+			return false, err
+		}
+		return ours != theirs, nil
+	}
+
+	d, err := diverged(lyr)
+	if err != nil || !d {
+		return 0, false, err
+	}
+	// the genesis layer is common to all nodes
+	lo, hi := types.LayerID(1), lyr
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		d, err := diverged(mid)
+		if err != nil {
+			return 0, false, err
+		}
+		if d {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return hi, true, nil
+}
+
+func (s *Syncer) fetchAggregatedHash(peer p2ppeers.Peer, lyr types.LayerID) (types.Hash32, error) {
+	wrk := newPeersWorker(s, []p2ppeers.Peer{peer}, &sync.Once{}, aggregatedHashReqFactory(lyr))
+	go wrk.Work()
+	out := <-wrk.output
+	if out == nil {
+		return types.Hash32{}, fmt.Errorf("could not get aggregated hash of layer %v from peer %v", lyr, peer)
+	}
+	return out.(types.Hash32), nil
+}
+
+// divergencePeers is the number of peers the aggregated hash of the latest layer in state is compared with
+const divergencePeers = 5
+
+// checkDivergence compares the aggregated hash of the latest layer in state with several peers. The node diverged if
+// most of the peers that answered disagree with it, from the earliest layer reported by them. The result is kept until
+// the next check, the state is unchanged if no peer answered.
+func (s *Syncer) checkDivergence() (types.LayerID, bool) {
+	peers := s.GetPeers()
+	lyr := s.LatestLayerInState()
+	if len(peers) == 0 || lyr == 0 {
+		return s.Diverged()
+	}
+	if len(peers) > divergencePeers {
+		peers = peers[:divergencePeers]
+	}
+
+	answered, disagreed := 0, 0
+	var first types.LayerID
+	for _, peer := range peers {
+		layer, diverged, err := s.FindDivergentLayer(peer, lyr)
+		if err != nil {
+			s.With().Warning("could not compare aggregated hashes with peer", log.String("peer", peer.String()), log.Err(err))
+			continue
+		}
+		answered++
+		if diverged {
+			if disagreed == 0 || layer < first {
+				first = layer
+			}
+			disagreed++
+		}
+	}
+	if answered == 0 {
+		return s.Diverged()
+	}
+
+	diverged := 2*disagreed > answered
+	if diverged {
+		s.With().Error("node diverged from most peers", log.LayerID(first.Uint64()),
+			log.Uint64("latest_layer", lyr.Uint64()), log.Int("peers", answered), log.Int("disagreed", disagreed))
+	} else {
+		first = 0
+	}
+	s.divergedMu.Lock()
+	s.diverged, s.divergedLayer = diverged, first
+	s.divergedMu.Unlock()
+	return first, diverged
+}
+
+// Diverged returns the first layer on which the state of the node differs from most of its peers, as found by the
+// latest check. The node doesn't listen to gossip while it diverged.
+func (s *Syncer) Diverged() (types.LayerID, bool) {
+	s.divergedMu.RLock()
+	defer s.divergedMu.RUnlock()
+	return s.divergedLayer, s.diverged
+}
+
 func fetchWithFactory(wrk worker) chan interface{} {
 	// each worker goroutine tries to fetch a block iteratively from each peer
 	go wrk.Work()
@@ -819,6 +988,19 @@ func fetchWithFactory(wrk worker) chan interface{} {
 	return wrk.output
 }
 
+// fetchCertifiedOutput fetches the certified hare output of the layer from neighbors and returns the blocks of the
+// first output whose certificate is valid.
+func (s *Syncer) fetchCertifiedOutput(lyr types.LayerID) ([]types.BlockID, error) {
+	if s.certifiedOutputs == nil {
+		return nil, fmt.Errorf("certified outputs are not supported")
+	}
+	out := <-fetchWithFactory(newNeighborhoodWorker(s, 1, hareOutputReqFactory(lyr, s.certifiedOutputs.VerifyCertifiedOutput)))
+	if out == nil {
+		return nil, fmt.Errorf("could not get a certified hare output from any neighbor")
+	}
+	return out.([]types.BlockID), nil
+}
+
 //FetchPoetProof fetches a poet proof from network peers
 func (s *Syncer) FetchPoetProof(poetProofRef []byte) error {
 	if !s.poetDb.HasProof(poetProofRef) {
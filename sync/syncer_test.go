@@ -1,6 +1,7 @@
 package sync
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math/big"
@@ -257,6 +258,53 @@ func TestSyncer_FetchPoetProofAvailableAndValid(t *testing.T) {
 	r.NoError(err)
 }
 
+type mockCertifiedOutputs struct {
+	outputs map[types.LayerID][]byte
+	blocks  []types.BlockID
+}
+
+func (m *mockCertifiedOutputs) GetCertifiedOutput(layer types.LayerID) ([]byte, error) {
+	if out, ok := m.outputs[layer]; ok {
+		return out, nil
+	}
+	return nil, errors.New("no certified output")
+}
+
+func (m *mockCertifiedOutputs) VerifyCertifiedOutput(layer types.LayerID, data []byte) ([]types.BlockID, error) {
+	if !bytes.Equal(data, []byte("valid")) {
+		return nil, errors.New("invalid certificate")
+	}
+	return m.blocks, nil
+}
+
+func TestSyncer_FetchCertifiedOutput(t *testing.T) {
+	r := require.New(t)
+
+	syncs, nodes, _ := SyncMockFactory(2, conf, t.Name(), memoryDB, newMemPoetDb)
+	s0 := syncs[0]
+	s1 := syncs[1]
+	defer s0.Close()
+	defer s1.Close()
+	s1.peers = getPeersMock([]p2ppeers.Peer{nodes[0].PublicKey()})
+
+	_, err := s1.fetchCertifiedOutput(1)
+	r.Error(err) // no provider set
+
+	blocks := []types.BlockID{types.NewExistingBlock(1, []byte("a")).ID()}
+	s0.SetCertifiedOutputs(&mockCertifiedOutputs{outputs: map[types.LayerID][]byte{1: []byte("valid"), 2: []byte("forged")}})
+	s1.SetCertifiedOutputs(&mockCertifiedOutputs{blocks: blocks})
+
+	res, err := s1.fetchCertifiedOutput(1)
+	r.NoError(err)
+	r.Equal(blocks, res)
+
+	_, err = s1.fetchCertifiedOutput(2) // fails verification
+	r.Error(err)
+
+	_, err = s1.fetchCertifiedOutput(3) // not available
+	r.Error(err)
+}
+
 func TestSyncer_SyncAtxs_FetchPoetProof(t *testing.T) {
 	r := require.New(t)
 	signer := signing.NewEdSigner()
@@ -1693,3 +1741,79 @@ func closed(ch chan struct{}) bool {
 		return false
 	}
 }
+
+// stateValidatorMock pushes every validated layer to the state
+type stateValidatorMock struct {
+	meshValidatorMock
+}
+
+func (m *stateValidatorMock) HandleIncomingLayer(lyr *types.Layer) (types.LayerID, types.LayerID) {
+	return lyr.Index(), lyr.Index() + 1
+}
+
+func TestSyncer_FindDivergentLayer(t *testing.T) {
+	r := require.New(t)
+	sim := service.NewSimulator()
+	var syncs []*Syncer
+	var nodes []*service.Node
+	for i := 0; i < 3; i++ {
+		net := sim.NewNode()
+		lg := log.New(fmt.Sprintf("%v_%d", t.Name(), i), "", "")
+		mshdb := mesh.NewMemMeshDB(lg)
+		atxdb := activation.NewDB(database.NewMemDatabase(), &mockIStore{}, mshdb, 10, &validatorMock{}, lg.WithOptions(log.Nop))
+		msh := mesh.NewMesh(mshdb, atxdb, rewardConf, &stateValidatorMock{}, &mockTxMemPool{}, &mockAtxMemPool{}, &mockState{}, lg)
+		s := NewSync(net, msh, miner.NewTxMemPool(), miner.NewAtxMemPool(), blockEligibilityValidatorMock{}, newMockPoetDb(), conf, timesync.NewClock(timesync.RealClock{}, time.Second, time.Now(), lg), lg)
+		defer s.Close()
+		syncs = append(syncs, s)
+		nodes = append(nodes, net)
+	}
+
+	// the nodes see the same blocks, the second one considers a block of layer 3 invalid
+	for l := types.LayerID(1); l <= 5; l++ {
+		lyr := types.NewLayer(l)
+		for i := 0; i < 2; i++ {
+			b := types.NewExistingBlock(l, []byte(rand.String(8)))
+			lyr.AddBlock(b)
+			for j, s := range syncs {
+				r.NoError(s.SaveContextualValidity(b.ID(), !(l == 3 && i == 0 && j == 1)))
+				r.NoError(s.AddBlock(b))
+			}
+		}
+		for _, s := range syncs {
+			s.ValidateLayer(lyr)
+		}
+	}
+
+	peer := nodes[0].PublicKey()
+	_, diverged, err := syncs[1].FindDivergentLayer(peer, 2)
+	r.NoError(err)
+	r.False(diverged)
+
+	layer, diverged, err := syncs[1].FindDivergentLayer(peer, 5)
+	r.NoError(err)
+	r.True(diverged)
+	r.Equal(types.LayerID(3), layer)
+
+	// layers that were not applied to the state cannot be compared
+	_, _, err = syncs[1].FindDivergentLayer(peer, 6)
+	r.Error(err)
+
+	for _, s := range syncs {
+		s := s
+		r.Eventually(func() bool { return len(s.GetPeers()) == 2 }, time.Second, 10*time.Millisecond)
+	}
+
+	// the second node disagrees with both of its peers
+	layer, diverged = syncs[1].checkDivergence()
+	r.True(diverged)
+	r.Equal(types.LayerID(3), layer)
+	layer, diverged = syncs[1].Diverged()
+	r.True(diverged)
+	r.Equal(types.LayerID(3), layer)
+
+	// a single disagreeing peer is not a majority
+	_, diverged = syncs[0].checkDivergence()
+	r.False(diverged)
+	_, diverged = syncs[0].Diverged()
+	r.False(diverged)
+}
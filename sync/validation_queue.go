@@ -220,7 +220,7 @@ func (vq *blockQueue) addDependencies(jobID interface{}, blks []types.BlockID, f
 			dependencies[bid] = struct{}{}
 		} else {
 			//	check database
-			if _, err := vq.GetBlock(id); err != nil {
+			if _, err := vq.GetBlock(id); err != nil && err != mesh.ErrPruned {
 				// add unknown block to queue
 				vq.reverseDepMap[bid] = append(vq.reverseDepMap[bid], jobID)
 				vq.With().Debug("adding unknown block to pending map",
@@ -12,6 +12,8 @@ type Tortoise interface {
 	HandleIncomingLayer(ll *types.Layer) (types.LayerID, types.LayerID)
 	LatestComplete() types.LayerID
 	Persist() error
+	BlockInfo(id types.BlockID) (*BlockInfo, error)
+	LayerInfo(l types.LayerID) (*LayerInfo, error)
 }
 
 type tortoise struct {
@@ -77,6 +79,20 @@ func (trtl *tortoise) LatestComplete() types.LayerID {
 	return trtl.latestComplete()
 }
 
+//BlockInfo returns the votes the tortoise counted for the block and its opinion on it
+func (trtl *tortoise) BlockInfo(id types.BlockID) (*BlockInfo, error) {
+	trtl.mutex.Lock()
+	defer trtl.mutex.Unlock()
+	return trtl.blockInfo(id)
+}
+
+//LayerInfo returns the number of valid and invalid blocks of the layer and whether it is verified
+func (trtl *tortoise) LayerInfo(l types.LayerID) (*LayerInfo, error) {
+	trtl.mutex.Lock()
+	defer trtl.mutex.Unlock()
+	return layerInfo(trtl.db, l, trtl.latestComplete())
+}
+
 func updateMetrics(alg *tortoise, ll *types.Layer) {
 	pbaseCount.Set(float64(alg.latestComplete()))
 	processedCount.Set(float64(ll.Index()))
@@ -0,0 +1,168 @@
+package tortoise
+
+import (
+	"bytes"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"sort"
+)
+
+// Pattern is a set of blocks of a layer voted for together
+type Pattern struct {
+	Layer  types.LayerID
+	ID     uint32
+	Blocks []types.BlockID
+}
+
+// BlockInfo explains the opinion of the tortoise on a block
+type BlockInfo struct {
+	ID        types.BlockID
+	Layer     types.LayerID
+	Effective *Pattern // the latest pattern the block votes for explicitly, nil when unknown
+	Good      *Pattern // the good pattern of the block's layer, nil when there is none
+	// Support and Against are the votes counted for the block by the tortoise, under the current pbase for the ninja
+	// tortoise and by the good blocks for the verifying tortoise
+	Support      int
+	Against      int
+	VotesFor     []types.BlockID // later blocks voting for the block
+	VotesAgainst []types.BlockID // later blocks voting against the block
+	Decided      bool
+	Valid        bool
+}
+
+// LayerInfo summarizes the opinion of the tortoise on a layer
+type LayerInfo struct {
+	Layer     types.LayerID
+	Valid     int
+	Invalid   int
+	Undecided int
+	Verified  bool // the layer is below the latest complete layer
+}
+
+// blockVoters returns the blocks of the layers after the block's layer, up to hdist layers after it, that vote for it
+// and the ones that vote against it
+func blockVoters(db database, blk *types.Block, hdist, last types.LayerID) (votesFor, votesAgainst []types.BlockID, err error) {
+	for l := blk.Layer() + 1; l <= blk.Layer()+hdist && l <= last; l++ {
+		ids, err := db.LayerBlockIds(l)
+		if err != nil {
+			continue // empty layer
+		}
+		for _, id := range ids {
+			b, err := db.GetBlockHeader(id)
+			if err != nil {
+				return nil, nil, err
+			}
+			votes, err := mesh.BlockOpinion(db, b, hdist)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch votes.Vote(blk.Layer(), blk.ID()) {
+			case types.Support:
+				votesFor = append(votesFor, id)
+			case types.Against:
+				votesAgainst = append(votesAgainst, id)
+			}
+		}
+	}
+	return votesFor, votesAgainst, nil
+}
+
+func blockValidity(db database, info *BlockInfo) {
+	valid, err := db.ContextualValidity(info.ID)
+	info.Decided = err == nil
+	info.Valid = valid && err == nil
+}
+
+func layerInfo(db database, l, latestComplete types.LayerID) (*LayerInfo, error) {
+	ids, err := db.LayerBlockIds(l)
+	if err != nil {
+		return nil, err
+	}
+	info := &LayerInfo{Layer: l, Verified: l < latestComplete}
+	for _, id := range ids {
+		valid, err := db.ContextualValidity(id)
+		switch {
+		case err != nil:
+			info.Undecided++
+		case valid:
+			info.Valid++
+		default:
+			info.Invalid++
+		}
+	}
+	return info, nil
+}
+
+func sortBlockIDs(ids []types.BlockID) {
+	sort.Slice(ids, func(i, j int) bool { return bytes.Compare(ids[i].Bytes(), ids[j].Bytes()) < 0 })
+}
+
+func (ni *ninjaTortoise) pattern(p votingPattern) *Pattern {
+	res := &Pattern{Layer: p.Layer(), ID: uint32(p.id)}
+	for id := range ni.TPattern[p] {
+		res.Blocks = append(res.Blocks, id)
+	}
+	sortBlockIDs(res.Blocks)
+	return res
+}
+
+func (ni *ninjaTortoise) blockInfo(id types.BlockID) (*BlockInfo, error) {
+	blk, err := ni.db.GetBlockHeader(id)
+	if err != nil {
+		return nil, err
+	}
+	info := &BlockInfo{ID: id, Layer: blk.Layer()}
+	if eff, found := ni.TEffective[id]; found && eff != zeroPattern {
+		info.Effective = ni.pattern(eff)
+	}
+	if good, found := ni.TGood[blk.Layer()]; found {
+		info.Good = ni.pattern(good)
+	}
+	tally := ni.TTally[ni.PBase][blockIDLayerTuple{BlockID: id, LayerID: blk.Layer()}]
+	info.Support, info.Against = tally[0], tally[1]
+	if info.VotesFor, info.VotesAgainst, err = blockVoters(ni.db, blk, ni.Hdist, ni.Last); err != nil {
+		return nil, err
+	}
+	blockValidity(ni.db, info)
+	return info, nil
+}
+
+func (vt *verifyingTortoise) blockInfo(id types.BlockID) (*BlockInfo, error) {
+	blk, err := vt.db.GetBlockHeader(id)
+	if err != nil {
+		return nil, err
+	}
+	info := &BlockInfo{ID: id, Layer: blk.Layer()}
+	votes, err := mesh.BlockOpinion(vt.db, blk, vt.Hdist)
+	if err != nil {
+		return nil, err
+	}
+	for l := range votes {
+		support := votes.Support(l)
+		if len(support) == 0 || (info.Effective != nil && l < info.Effective.Layer) {
+			continue
+		}
+		info.Effective = &Pattern{Layer: l}
+		for v := range support {
+			info.Effective.Blocks = append(info.Effective.Blocks, v)
+		}
+		sortBlockIDs(info.Effective.Blocks)
+	}
+	if op, known := vt.opinion(blk.Layer(), make(map[types.LayerID]map[types.BlockID]struct{})); known {
+		info.Good = &Pattern{Layer: blk.Layer()}
+		for id := range op {
+			info.Good.Blocks = append(info.Good.Blocks, id)
+		}
+		sortBlockIDs(info.Good.Blocks)
+	}
+	if tally := vt.Tally[blk.Layer()][id]; tally > 0 {
+		info.Support = tally
+	} else {
+		info.Against = -tally
+	}
+	if info.VotesFor, info.VotesAgainst, err = blockVoters(vt.db, blk, vt.Hdist, vt.Last); err != nil {
+		return nil, err
+	}
+	blockValidity(vt.db, info)
+	return info, nil
+}
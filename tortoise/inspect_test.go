@@ -0,0 +1,72 @@
+package tortoise
+
+import (
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTortoise_Inspect(t *testing.T) {
+	layers, layerSize, hdist := 8, 10, 5
+	lyrs, outputs := generateMesh(layers, layerSize, 7, hdist, 0)
+	lg := log.New(t.Name(), "", "").WithOptions(log.Nop)
+
+	for _, alg := range []string{Ninja, Verifying} {
+		t.Run(alg, func(t *testing.T) {
+			r := require.New(t)
+			mdb := getInMemMesh()
+			for _, lyr := range lyrs {
+				r.NoError(AddLayer(mdb, lyr))
+			}
+			for l, output := range outputs {
+				r.NoError(mdb.SaveLayerInputVector(l, output))
+			}
+			var trtl Tortoise
+			if alg == Ninja {
+				trtl = NewTortoise(layerSize, mdb, hdist, lg)
+			} else {
+				trtl = NewVerifyingTortoise(layerSize, mdb, hdist, lg)
+			}
+			for _, lyr := range lyrs[1:] {
+				trtl.HandleIncomingLayer(lyr)
+				r.NoError(trtl.Persist())
+			}
+
+			valid := make(map[types.BlockID]struct{})
+			for _, id := range outputs[3] {
+				valid[id] = struct{}{}
+			}
+			for _, b := range lyrs[3].Blocks() {
+				info, err := trtl.BlockInfo(b.ID())
+				r.NoError(err)
+				r.Equal(types.LayerID(3), info.Layer)
+				r.NotNil(info.Good)
+				r.ElementsMatch(outputs[3], info.Good.Blocks)
+				r.NotNil(info.Effective)
+				r.Equal(types.LayerID(2), info.Effective.Layer)
+				r.ElementsMatch(outputs[2], info.Effective.Blocks)
+				r.True(info.Decided)
+				_, isValid := valid[b.ID()]
+				r.Equal(isValid, info.Valid)
+				if isValid {
+					r.Len(info.VotesFor, layerSize*(layers-3))
+					r.Empty(info.VotesAgainst)
+				} else {
+					r.Empty(info.VotesFor)
+					r.Len(info.VotesAgainst, layerSize*(layers-3))
+				}
+			}
+
+			_, err := trtl.BlockInfo(types.BlockID{1})
+			r.Error(err)
+
+			info, err := trtl.LayerInfo(3)
+			r.NoError(err)
+			r.Equal(&LayerInfo{Layer: 3, Valid: 7, Invalid: 3, Verified: true}, info)
+			info, err = trtl.LayerInfo(types.LayerID(layers))
+			r.NoError(err)
+			r.Equal(&LayerInfo{Layer: types.LayerID(layers), Undecided: layerSize}, info)
+		})
+	}
+}
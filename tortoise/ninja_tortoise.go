@@ -3,7 +3,6 @@ package tortoise
 import (
 	"fmt"
 	"github.com/spacemeshos/go-spacemesh/common/types"
-	"github.com/spacemeshos/go-spacemesh/events"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/mesh"
 	"hash/fnv"
@@ -75,18 +74,28 @@ func (vp votingPattern) Layer() types.LayerID {
 }
 
 type database interface {
-	GetBlock(id types.BlockID) (*types.Block, error)
+	GetBlockHeader(id types.BlockID) (*types.Block, error)
 	LayerBlockIds(id types.LayerID) ([]types.BlockID, error)
 	ForBlockInView(view map[types.BlockID]struct{}, layer types.LayerID, foo func(block *types.Block) (bool, error)) error
 	SaveContextualValidity(id types.BlockID, valid bool) error
+	ContextualValidity(id types.BlockID) (bool, error)
+	GetLayerInputVector(id types.LayerID) ([]types.BlockID, error)
+	SaveTortoiseState(validity map[types.BlockID]bool, records map[string][]byte) error
+	TortoiseState() (map[string][]byte, error)
 	Persist(key []byte, v interface{}) error
 	Retrieve(key []byte, v interface{}) (interface{}, error)
+	Remove(key []byte) error
 }
 
 type ninjaTortoise struct {
 	db           database //block cache
 	logger       log.Log
 	mutex        sync.Mutex
+	written      map[types.LayerID]uint64 // digests of the persisted layer records
+	dirty        bool                     // the state of the layers from dirtyFrom up to Last changed since persisted
+	dirtyFrom    types.LayerID
+	votes        map[types.BlockID]mesh.Opinion // resolved block votes, rebuilt from the database when missing
+	onComplete   func(p votingPattern)          // optional, called when p becomes the latest complete pattern
 	Last         types.LayerID
 	Hdist        types.LayerID
 	Evict        types.LayerID
@@ -132,39 +141,13 @@ func newNinjaTortoise(layerSize int, blocks database, hdist int, log log.Log) *n
 		TComplete:          map[votingPattern]struct{}{},
 		TEffectiveToBlocks: map[votingPattern][]blockIDLayerTuple{},
 		TPatSupport:        map[votingPattern]map[types.LayerID]votingPattern{},
+		written:            map[types.LayerID]uint64{},
+		votes:              map[types.BlockID]mesh.Opinion{},
 	}
 
 	return trtl
 }
 
-func (ni *ninjaTortoise) saveOpinion() error {
-	for b, vec := range ni.TVote[ni.PBase] {
-		valid := vec == support
-		if err := ni.db.SaveContextualValidity(b.id(), valid); err != nil {
-			return err
-		}
-
-		if !valid {
-			ni.logger.With().Warning("block is contextually invalid", log.BlockID(b.id().String()))
-		}
-		events.Publish(events.ValidBlock{ID: b.id().String(), Valid: valid})
-	}
-	return nil
-}
-
-//Persist saves the current tortoise state to the database
-func (ni *ninjaTortoise) persist() error {
-	if err := ni.saveOpinion(); err != nil {
-		return err
-	}
-	return ni.db.Persist(mesh.TORTOISE, ni)
-}
-
-//RecoverTortoise retrieve latest saved tortoise from the database
-func RecoverTortoise(mdb database) (interface{}, error) {
-	return mdb.Retrieve(mesh.TORTOISE, &ninjaTortoise{})
-}
-
 func (ni *ninjaTortoise) evictOutOfPbase() {
 	wg := sync.WaitGroup{}
 	if ni.PBase == zeroPattern || ni.PBase.Layer() <= ni.Hdist {
@@ -200,6 +183,7 @@ func (ni *ninjaTortoise) evictOutOfPbase() {
 				delete(ni.TEffective, id)
 				delete(ni.TCorrect, id)
 				delete(ni.TExplicit, id)
+				delete(ni.votes, id)
 				ni.logger.Debug("evict block %v from maps ", id)
 			}
 		}()
@@ -216,16 +200,11 @@ func (ni *ninjaTortoise) processBlock(b *types.Block) {
 	}
 
 	patternMap := make(map[types.LayerID]map[types.BlockID]struct{})
-	for _, bid := range b.BlockVotes {
-		ni.logger.Debug("block votes %s", bid)
-		bl, err := ni.db.GetBlock(bid)
-		if err != nil || bl == nil {
-			ni.logger.Panic(fmt.Sprintf("error block not found ID %s , %v!!!!!", bid, err))
+	votes := ni.blockVotes(b)
+	for l := range votes {
+		if support := votes.Support(l); len(support) > 0 {
+			patternMap[l] = support
 		}
-		if _, found := patternMap[bl.Layer()]; !found {
-			patternMap[bl.Layer()] = map[types.BlockID]struct{}{}
-		}
-		patternMap[bl.Layer()][bl.ID()] = struct{}{}
 	}
 
 	var effective votingPattern
@@ -273,6 +252,19 @@ func (ni *ninjaTortoise) processBlock(b *types.Block) {
 	return
 }
 
+// blockVotes returns the votes of the block resolved from its base block and diffs
+func (ni *ninjaTortoise) blockVotes(b *types.Block) mesh.Opinion {
+	if votes, found := ni.votes[b.ID()]; found {
+		return votes
+	}
+	votes, err := mesh.BlockOpinion(ni.db, b, ni.Hdist)
+	if err != nil {
+		ni.logger.Panic(fmt.Sprintf("could not resolve votes of block %s: %v", b.ID(), err))
+	}
+	ni.votes[b.ID()] = votes
+	return votes
+}
+
 func getID(bids []types.BlockID) patternID {
 	bids = types.SortBlockIDs(bids)
 	// calc
@@ -428,7 +420,7 @@ func (ni *ninjaTortoise) addPatternVote(p votingPattern, view map[types.BlockID]
 	addPatternVote := func(b types.BlockID) {
 		var vp map[types.LayerID]votingPattern
 		var found bool
-		blk, err := ni.db.GetBlock(b)
+		blk, err := ni.db.GetBlockHeader(b)
 		if err != nil {
 			ni.logger.Panic(fmt.Sprintf("error block not found ID %s %v", b, err))
 		}
@@ -441,6 +433,7 @@ func (ni *ninjaTortoise) addPatternVote(p votingPattern, view map[types.BlockID]
 			ni.logger.Panic(fmt.Sprintf("block %s from layer %v has no explicit voting, something went wrong ", b, blk.Layer()))
 		}
 
+		votes := ni.blockVotes(blk)
 		for _, ex := range vp {
 			blocks, err := ni.db.LayerBlockIds(ex.Layer())
 			if err != nil {
@@ -457,7 +450,7 @@ func (ni *ninjaTortoise) addPatternVote(p votingPattern, view map[types.BlockID]
 				blt := blockIDLayerTuple{bl, ex.Layer()}
 				if found {
 					ni.TTally[p][blt] = ni.TTally[p][blt].Add(support)
-				} else if _, inSet := view[bl]; inSet { //in view but not in pattern
+				} else if _, inSet := view[bl]; inSet && votes.Vote(ex.Layer(), bl) == types.Against { //in view and explicitly against
 					ni.TTally[p][blt] = ni.TTally[p][blt].Add(against)
 				}
 			}
@@ -520,6 +513,7 @@ func (ni *ninjaTortoise) handleIncomingLayer(newlyr *types.Layer) {
 	if newlyr.Index() > ni.Last {
 		ni.Last = newlyr.Index()
 	}
+	ni.markDirty(newlyr.Index())
 
 	defer ni.evictOutOfPbase()
 	ni.processBlocks(newlyr)
@@ -606,6 +600,9 @@ func (ni *ninjaTortoise) handleIncomingLayer(newlyr *types.Layer) {
 				ni.TComplete[p] = struct{}{}
 				ni.PBase = p
 				ni.logger.Info("found new complete and good pattern for layer %d pattern %d with %d support ", p.Layer().Uint64(), p.id, ni.TSupport[p])
+				if ni.onComplete != nil {
+					ni.onComplete(p)
+				}
 			}
 		}
 	}
@@ -931,11 +931,22 @@ func createLayerWithCorruptedPattern(index types.LayerID, prev *types.Layer, blo
 	return l
 }
 
-func addPattern(bl *types.Block, goodPattern []int, prev *types.Layer) *types.Block {
-	for _, id := range goodPattern {
-		b := prev.Blocks()[id]
-		bl.AddVote(types.BlockID(b.ID()))
+// addVotes makes the block vote for the blocks of the layer in the pattern and against the others
+func addVotes(bl *types.Block, pattern []int, lyr *types.Layer) {
+	inPattern := make(map[int]struct{}, len(pattern))
+	for _, id := range pattern {
+		inPattern[id] = struct{}{}
+		bl.AddVote(lyr.Blocks()[id].ID())
+	}
+	for i, b := range lyr.Blocks() {
+		if _, found := inPattern[i]; !found {
+			bl.AddVoteAgainst(b.ID())
+		}
 	}
+}
+
+func addPattern(bl *types.Block, goodPattern []int, prev *types.Layer) *types.Block {
+	addVotes(bl, goodPattern, prev)
 	for _, prevBloc := range prev.Blocks() {
 		bl.AddView(types.BlockID(prevBloc.ID()))
 	}
@@ -955,10 +966,7 @@ func createLayerWithRandVoting(index types.LayerID, prev []*types.Layer, blocksI
 		bl := types.NewExistingBlock(index, []byte(rand.String(8)))
 		layerBlocks = append(layerBlocks, bl.ID())
 		for idx, pat := range patterns {
-			for _, id := range pat {
-				b := prev[idx].Blocks()[id]
-				bl.AddVote(types.BlockID(b.ID()))
-			}
+			addVotes(bl, pat, prev[idx])
 		}
 		for _, prevBloc := range prev[0].Blocks() {
 			bl.AddView(types.BlockID(prevBloc.ID()))
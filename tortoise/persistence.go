@@ -0,0 +1,532 @@
+package tortoise
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/events"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The ninja tortoise state is persisted as a header record and a record per layer. A layer record holds the state of the
+// patterns of the layer and of the blocks of the layer. Handling a layer marks the layers whose state it can change as
+// dirty, so after each layer only the dirty layers are encoded, only the records whose content changed are rewritten,
+// the records of evicted layers are deleted, and all of them are written in a single batch with the contextual validity
+// of the blocks. Older versions saved the whole state as a single object, it is migrated to records on recovery.
+
+const (
+	headerKey      = "header"
+	layerKeyPrefix = "layer_"
+)
+
+func layerKey(l types.LayerID) string {
+	return layerKeyPrefix + strconv.FormatUint(l.Uint64(), 10)
+}
+
+// patternKey is the persisted form of a voting pattern
+type patternKey struct {
+	ID    uint32
+	Layer types.LayerID
+}
+
+func toPatternKey(p votingPattern) patternKey {
+	return patternKey{ID: uint32(p.id), Layer: p.Layer()}
+}
+
+func (k patternKey) pattern() votingPattern {
+	return votingPattern{id: patternID(k.ID), LayerID: k.Layer}
+}
+
+func lessPattern(a, b votingPattern) bool {
+	if a.Layer() != b.Layer() {
+		return a.Layer() < b.Layer()
+	}
+	return a.id < b.id
+}
+
+type blockLayerRecord struct {
+	Block types.BlockID
+	Layer types.LayerID
+}
+
+type voteRecord struct {
+	Block types.BlockID
+	Layer types.LayerID
+	Vote  vec
+}
+
+type correctionRecord struct {
+	Block types.BlockID
+	Vote  vec
+}
+
+type layerPatternRecord struct {
+	Layer   types.LayerID
+	Pattern patternKey
+}
+
+// patternRecord holds the entries of a voting pattern in the tortoise tables, the Has fields tell apart missing and
+// empty entries
+type patternRecord struct {
+	Pattern            patternKey
+	Listed             bool // listed in the patterns of its layer
+	HasSupport         bool
+	Support            int
+	Complete           bool
+	HasEffectiveBlocks bool
+	EffectiveBlocks    []blockLayerRecord
+	HasTally           bool
+	Tally              []voteRecord
+	HasBlocks          bool
+	Blocks             []types.BlockID
+	HasPatSupport      bool
+	PatSupport         []layerPatternRecord
+	HasVote            bool
+	Vote               []voteRecord
+}
+
+// blockRecord holds the entries of a block in the tortoise tables
+type blockRecord struct {
+	Block        types.BlockID
+	HasEffective bool
+	Effective    patternKey
+	HasCorrect   bool
+	Correct      []correctionRecord
+	HasExplicit  bool
+	Explicit     []layerPatternRecord
+}
+
+type layerRecord struct {
+	Layer    types.LayerID
+	HasGood  bool
+	Good     patternKey
+	Patterns []patternRecord
+	Blocks   []blockRecord
+}
+
+type headerRecord struct {
+	Last         types.LayerID
+	Hdist        types.LayerID
+	Evict        types.LayerID
+	AvgLayerSize int
+	PBase        patternKey
+}
+
+func digest(record []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(record)
+	return h.Sum64()
+}
+
+func sortVotes(votes []voteRecord) {
+	sort.Slice(votes, func(i, j int) bool {
+		if votes[i].Layer != votes[j].Layer {
+			return votes[i].Layer < votes[j].Layer
+		}
+		return bytes.Compare(votes[i].Block.Bytes(), votes[j].Block.Bytes()) < 0
+	})
+}
+
+func sortLayerPatterns(lps []layerPatternRecord) {
+	sort.Slice(lps, func(i, j int) bool { return lps[i].Layer < lps[j].Layer })
+}
+
+func toVoteRecords(votes map[blockIDLayerTuple]vec) []voteRecord {
+	res := make([]voteRecord, 0, len(votes))
+	for b, v := range votes {
+		res = append(res, voteRecord{Block: b.id(), Layer: b.layer(), Vote: v})
+	}
+	sortVotes(res)
+	return res
+}
+
+func toLayerPatternRecords(pats map[types.LayerID]votingPattern) []layerPatternRecord {
+	res := make([]layerPatternRecord, 0, len(pats))
+	for l, p := range pats {
+		res = append(res, layerPatternRecord{Layer: l, Pattern: toPatternKey(p)})
+	}
+	sortLayerPatterns(res)
+	return res
+}
+
+// layerRecords groups the tortoise tables of the layers from from up to to into layer records, the patterns by their
+// layer and the blocks by the layer they belong to
+func (ni *ninjaTortoise) layerRecords(from, to types.LayerID) (map[types.LayerID]*layerRecord, error) {
+	inRange := func(l types.LayerID) bool {
+		return l >= from && l <= to
+	}
+	layers := make(map[types.LayerID]*layerRecord)
+	layer := func(l types.LayerID) *layerRecord {
+		if _, found := layers[l]; !found {
+			layers[l] = &layerRecord{Layer: l}
+		}
+		return layers[l]
+	}
+
+	patterns := make(map[votingPattern]*patternRecord)
+	pattern := func(p votingPattern) *patternRecord {
+		if _, found := patterns[p]; !found {
+			patterns[p] = &patternRecord{Pattern: toPatternKey(p)}
+		}
+		return patterns[p]
+	}
+	for l, ps := range ni.Patterns {
+		if !inRange(l) {
+			continue
+		}
+		for p := range ps {
+			pattern(p).Listed = true
+		}
+	}
+	for p, s := range ni.TSupport {
+		if !inRange(p.Layer()) {
+			continue
+		}
+		pr := pattern(p)
+		pr.HasSupport, pr.Support = true, s
+	}
+	for p := range ni.TComplete {
+		if !inRange(p.Layer()) {
+			continue
+		}
+		pattern(p).Complete = true
+	}
+	for p, bls := range ni.TEffectiveToBlocks {
+		if !inRange(p.Layer()) {
+			continue
+		}
+		pr := pattern(p)
+		pr.HasEffectiveBlocks = true
+		for _, b := range bls {
+			pr.EffectiveBlocks = append(pr.EffectiveBlocks, blockLayerRecord{Block: b.id(), Layer: b.layer()})
+		}
+	}
+	for p, t := range ni.TTally {
+		if !inRange(p.Layer()) {
+			continue
+		}
+		pr := pattern(p)
+		pr.HasTally, pr.Tally = true, toVoteRecords(t)
+	}
+	for p, bls := range ni.TPattern {
+		if !inRange(p.Layer()) {
+			continue
+		}
+		pr := pattern(p)
+		pr.HasBlocks = true
+		for b := range bls {
+			pr.Blocks = append(pr.Blocks, b)
+		}
+		sortBlockIDs(pr.Blocks)
+	}
+	for p, s := range ni.TPatSupport {
+		if !inRange(p.Layer()) {
+			continue
+		}
+		pr := pattern(p)
+		pr.HasPatSupport, pr.PatSupport = true, toLayerPatternRecords(s)
+	}
+	for p, v := range ni.TVote {
+		if !inRange(p.Layer()) {
+			continue
+		}
+		pr := pattern(p)
+		pr.HasVote, pr.Vote = true, toVoteRecords(v)
+	}
+
+	ordered := make([]votingPattern, 0, len(patterns))
+	for p := range patterns {
+		ordered = append(ordered, p)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return lessPattern(ordered[i], ordered[j]) })
+	for _, p := range ordered {
+		lr := layer(p.Layer())
+		lr.Patterns = append(lr.Patterns, *patterns[p])
+	}
+
+	for l, p := range ni.TGood {
+		if !inRange(l) {
+			continue
+		}
+		lr := layer(l)
+		lr.HasGood, lr.Good = true, toPatternKey(p)
+	}
+
+	for l := from; l <= to; l++ {
+		ids, err := ni.db.LayerBlockIds(l)
+		if err != nil {
+			continue // no blocks in the layer
+		}
+		ids = append([]types.BlockID(nil), ids...)
+		sortBlockIDs(ids)
+		for _, id := range ids {
+			br := blockRecord{Block: id}
+			if p, found := ni.TEffective[id]; found {
+				br.HasEffective, br.Effective = true, toPatternKey(p)
+			}
+			if c, found := ni.TCorrect[id]; found {
+				br.HasCorrect = true
+				for b, v := range c {
+					br.Correct = append(br.Correct, correctionRecord{Block: b, Vote: v})
+				}
+				sort.Slice(br.Correct, func(i, j int) bool { return bytes.Compare(br.Correct[i].Block.Bytes(), br.Correct[j].Block.Bytes()) < 0 })
+			}
+			if e, found := ni.TExplicit[id]; found {
+				br.HasExplicit, br.Explicit = true, toLayerPatternRecords(e)
+			}
+			if br.HasEffective || br.HasCorrect || br.HasExplicit {
+				lr := layer(l)
+				lr.Blocks = append(lr.Blocks, br)
+			}
+		}
+	}
+
+	return layers, nil
+}
+
+// markDirty marks the layers whose state can change while handling layer newlyr, the patterns its blocks vote for,
+// which are at most hdist layers below it, and the patterns above pbase with the blocks that vote for them
+func (ni *ninjaTortoise) markDirty(newlyr types.LayerID) {
+	from := ni.PBase.Layer()
+	if newlyr < ni.Hdist {
+		from = 0
+	} else if newlyr-ni.Hdist < from {
+		from = newlyr - ni.Hdist
+	}
+	if !ni.dirty || from < ni.dirtyFrom {
+		ni.dirtyFrom = from
+	}
+	ni.dirty = true
+}
+
+func (ni *ninjaTortoise) opinion() map[types.BlockID]bool {
+	validity := make(map[types.BlockID]bool, len(ni.TVote[ni.PBase]))
+	for b, vec := range ni.TVote[ni.PBase] {
+		validity[b.id()] = vec == support
+	}
+	return validity
+}
+
+//Persist saves the records of the layers whose tortoise state changed and the current opinion to the database
+func (ni *ninjaTortoise) persist() error {
+	return ni.save(ni.opinion())
+}
+
+// save writes the records of the layers whose tortoise state changed in a single batch with the given validity
+func (ni *ninjaTortoise) save(validity map[types.BlockID]bool) error {
+	records := make(map[string][]byte)
+	var evicted []types.LayerID
+	for l := range ni.written {
+		if ni.dirty && l >= ni.dirtyFrom && l <= ni.Last {
+			continue // deleted below if evicted
+		}
+		if l < ni.Evict || l > ni.Last {
+			records[layerKey(l)] = nil
+			evicted = append(evicted, l)
+		}
+	}
+
+	digests := make(map[types.LayerID]uint64)
+	if ni.dirty {
+		layers, err := ni.layerRecords(ni.dirtyFrom, ni.Last)
+		if err != nil {
+			return err
+		}
+		for l := ni.dirtyFrom; l <= ni.Last; l++ {
+			lr, found := layers[l]
+			if !found {
+				if _, written := ni.written[l]; written {
+					records[layerKey(l)] = nil // evicted
+					evicted = append(evicted, l)
+				}
+				continue
+			}
+			record, err := types.InterfaceToBytes(lr)
+			if err != nil {
+				return err
+			}
+			d := digest(record)
+			if w, found := ni.written[l]; found && w == d {
+				continue
+			}
+			records[layerKey(l)] = record
+			digests[l] = d
+		}
+	}
+
+	header, err := types.InterfaceToBytes(headerRecord{
+		Last:         ni.Last,
+		Hdist:        ni.Hdist,
+		Evict:        ni.Evict,
+		AvgLayerSize: ni.AvgLayerSize,
+		PBase:        toPatternKey(ni.PBase),
+	})
+	if err != nil {
+		return err
+	}
+	records[headerKey] = header
+
+	if err := ni.db.SaveTortoiseState(validity, records); err != nil {
+		return err
+	}
+	ni.logger.With().Debug("persisted tortoise state", log.Int("n_records", len(records)), log.Bool("dirty", ni.dirty), log.Uint64("dirty_from", uint64(ni.dirtyFrom)))
+
+	for _, l := range evicted {
+		delete(ni.written, l)
+	}
+	for l, d := range digests {
+		ni.written[l] = d
+	}
+	ni.dirty = false
+
+	for id, valid := range validity {
+		if !valid {
+			ni.logger.With().Warning("block is contextually invalid", log.BlockID(id.String()))
+		}
+		events.Publish(events.ValidBlock{ID: id.String(), Valid: valid})
+	}
+	return nil
+}
+
+//RecoverTortoise rebuilds the latest saved tortoise from the records in the database
+func RecoverTortoise(mdb database) (interface{}, error) {
+	records, err := mdb.TortoiseState()
+	if err != nil {
+		return nil, err
+	}
+
+	h, found := records[headerKey]
+	if !found {
+		return migrateTortoise(mdb)
+	}
+	var header headerRecord
+	if err := types.BytesToInterface(h, &header); err != nil {
+		return nil, err
+	}
+
+	ni := newNinjaTortoise(header.AvgLayerSize, mdb, int(header.Hdist), log.NewDefault("tortoise"))
+	ni.Last = header.Last
+	ni.Evict = header.Evict
+	ni.PBase = header.PBase.pattern()
+
+	for key, record := range records {
+		if !strings.HasPrefix(key, layerKeyPrefix) {
+			continue
+		}
+		var lr layerRecord
+		if err := types.BytesToInterface(record, &lr); err != nil {
+			return nil, err
+		}
+		ni.restoreLayer(&lr)
+		ni.written[lr.Layer] = digest(record)
+	}
+	return ni, nil
+}
+
+// migrateTortoise converts the tortoise state saved as a single object by older versions into records and deletes it.
+// The object lost the ids of the voting patterns, so only its parameters are kept and the tables are rebuilt by replaying
+// the stored layers up to its last layer.
+func migrateTortoise(mdb database) (*ninjaTortoise, error) {
+	legacy := &ninjaTortoise{}
+	if _, err := mdb.Retrieve(mesh.TORTOISE, legacy); err != nil {
+		return nil, errors.New("no tortoise state in database")
+	}
+
+	lg := log.NewDefault("tortoise")
+	ni := newNinjaTortoise(legacy.AvgLayerSize, mdb, int(legacy.Hdist), lg)
+	ni.handleIncomingLayer(mesh.GenesisLayer())
+	for l := types.LayerID(1); l <= legacy.Last; l++ {
+		ni.handleIncomingLayer(storedLayer(mdb, l, lg))
+	}
+	if err := ni.persist(); err != nil {
+		return nil, fmt.Errorf("could not save the migrated tortoise state: %v", err)
+	}
+	if err := mdb.Remove(mesh.TORTOISE); err != nil {
+		return nil, err
+	}
+	lg.With().Info("migrated the tortoise state to layer records", log.LayerID(uint64(ni.Last)))
+	return ni, nil
+}
+
+func (ni *ninjaTortoise) restoreLayer(lr *layerRecord) {
+	if lr.HasGood {
+		ni.TGood[lr.Layer] = lr.Good.pattern()
+	}
+
+	for _, pr := range lr.Patterns {
+		p := pr.Pattern.pattern()
+		if pr.Listed {
+			if _, found := ni.Patterns[p.Layer()]; !found {
+				ni.Patterns[p.Layer()] = map[votingPattern]struct{}{}
+			}
+			ni.Patterns[p.Layer()][p] = struct{}{}
+		}
+		if pr.HasSupport {
+			ni.TSupport[p] = pr.Support
+		}
+		if pr.Complete {
+			ni.TComplete[p] = struct{}{}
+		}
+		if pr.HasEffectiveBlocks {
+			bls := make([]blockIDLayerTuple, 0, len(pr.EffectiveBlocks))
+			for _, b := range pr.EffectiveBlocks {
+				bls = append(bls, blockIDLayerTuple{BlockID: b.Block, LayerID: b.Layer})
+			}
+			ni.TEffectiveToBlocks[p] = bls
+		}
+		if pr.HasTally {
+			ni.TTally[p] = fromVoteRecords(pr.Tally)
+		}
+		if pr.HasBlocks {
+			bls := make(map[types.BlockID]struct{}, len(pr.Blocks))
+			for _, b := range pr.Blocks {
+				bls[b] = struct{}{}
+			}
+			ni.TPattern[p] = bls
+		}
+		if pr.HasPatSupport {
+			ni.TPatSupport[p] = fromLayerPatternRecords(pr.PatSupport)
+		}
+		if pr.HasVote {
+			ni.TVote[p] = fromVoteRecords(pr.Vote)
+		}
+	}
+
+	for _, br := range lr.Blocks {
+		if br.HasEffective {
+			ni.TEffective[br.Block] = br.Effective.pattern()
+		}
+		if br.HasCorrect {
+			c := make(map[types.BlockID]vec, len(br.Correct))
+			for _, cr := range br.Correct {
+				c[cr.Block] = cr.Vote
+			}
+			ni.TCorrect[br.Block] = c
+		}
+		if br.HasExplicit {
+			ni.TExplicit[br.Block] = fromLayerPatternRecords(br.Explicit)
+		}
+	}
+}
+
+func fromVoteRecords(votes []voteRecord) map[blockIDLayerTuple]vec {
+	res := make(map[blockIDLayerTuple]vec, len(votes))
+	for _, v := range votes {
+		res[blockIDLayerTuple{BlockID: v.Block, LayerID: v.Layer}] = v.Vote
+	}
+	return res
+}
+
+func fromLayerPatternRecords(lps []layerPatternRecord) map[types.LayerID]votingPattern {
+	res := make(map[types.LayerID]votingPattern, len(lps))
+	for _, lp := range lps {
+		res[lp.Layer] = lp.Pattern.pattern()
+	}
+	return res
+}
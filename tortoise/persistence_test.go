@@ -0,0 +1,226 @@
+package tortoise
+
+import (
+	"errors"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// crashingDB fails the tortoise state writes once killed, as if the node was killed before writing them
+type crashingDB struct {
+	*mesh.DB
+	killed  bool
+	records int
+}
+
+func (c *crashingDB) SaveTortoiseState(validity map[types.BlockID]bool, records map[string][]byte) error {
+	if c.killed {
+		return errors.New("node killed")
+	}
+	c.records = len(records)
+	return c.DB.SaveTortoiseState(validity, records)
+}
+
+func persistenceMesh(t *testing.T, layers, layerSize int) []*types.Layer {
+	l := mesh.GenesisLayer()
+	lyrs := []*types.Layer{l}
+	l = createLayerWithRandVoting(1, []*types.Layer{l}, layerSize, 1)
+	lyrs = append(lyrs, l)
+	for i := 2; i <= layers; i++ {
+		l = createLayerWithCorruptedPattern(types.LayerID(i), l, layerSize, layerSize, 0)
+		lyrs = append(lyrs, l)
+	}
+	return lyrs
+}
+
+func addLayers(t *testing.T, mdb *mesh.DB, lyrs []*types.Layer) {
+	for _, lyr := range lyrs {
+		require.NoError(t, AddLayer(mdb, lyr))
+	}
+}
+
+func requireSameState(t *testing.T, expected, actual *ninjaTortoise) {
+	r := require.New(t)
+	r.Equal(expected.PBase, actual.PBase)
+	r.Equal(expected.Last, actual.Last)
+	r.Equal(expected.Evict, actual.Evict)
+	r.Equal(expected.opinion(), actual.opinion())
+	exp, err := expected.layerRecords(0, expected.Last)
+	r.NoError(err)
+	act, err := actual.layerRecords(0, actual.Last)
+	r.NoError(err)
+	r.Equal(exp, act)
+}
+
+func TestNinjaTortoise_PersistRecover(t *testing.T) {
+	r := require.New(t)
+	layerSize := 10
+	lyrs := persistenceMesh(t, 20, layerSize)
+	mdb := getInMemMesh()
+	addLayers(t, mdb, lyrs)
+
+	alg := newNinjaTortoise(layerSize, mdb, 5, log.New(t.Name(), "", "").WithOptions(log.Nop))
+	for _, lyr := range lyrs {
+		alg.handleIncomingLayer(lyr)
+		r.NoError(alg.persist())
+
+		tmp, err := RecoverTortoise(mdb)
+		r.NoError(err)
+		requireSameState(t, alg, tmp.(*ninjaTortoise))
+	}
+	r.True(alg.Evict > 0, "pbase %v evict %v", alg.PBase.Layer(), alg.Evict)
+
+	records, err := mdb.TortoiseState()
+	r.NoError(err)
+	_, found := records[layerKey(0)]
+	r.False(found, "evicted layers are deleted")
+	for b, v := range alg.TVote[alg.PBase] {
+		valid, err := mdb.ContextualValidity(b.id())
+		r.NoError(err)
+		r.Equal(v == support, valid)
+	}
+}
+
+func TestNinjaTortoise_PersistIncremental(t *testing.T) {
+	r := require.New(t)
+	layerSize := 10
+	lyrs := persistenceMesh(t, 10, layerSize)
+	mdb := &crashingDB{DB: getInMemMesh()}
+	addLayers(t, mdb.DB, lyrs)
+
+	alg := newNinjaTortoise(layerSize, mdb, 5, log.New(t.Name(), "", "").WithOptions(log.Nop))
+	for _, lyr := range lyrs {
+		alg.handleIncomingLayer(lyr)
+		evicted := 0
+		for l := range alg.written {
+			if l < alg.Evict {
+				evicted++
+			}
+		}
+		dirty := int(alg.Last-alg.dirtyFrom) + 1
+		r.NoError(alg.persist())
+		r.True(mdb.records <= dirty+evicted+1, "only dirty layers are written")
+	}
+	layers, err := alg.layerRecords(0, alg.Last)
+	r.NoError(err)
+	r.True(mdb.records < len(layers)+1, "only changed layers are written")
+
+	r.NoError(alg.persist())
+	r.Equal(1, mdb.records, "only the header is written when nothing changed")
+}
+
+func TestNinjaTortoise_MigrateLegacyState(t *testing.T) {
+	r := require.New(t)
+	layerSize := 10
+	lyrs := persistenceMesh(t, 12, layerSize)
+	mdb := getInMemMesh()
+	addLayers(t, mdb, lyrs)
+
+	lg := log.New(t.Name(), "", "").WithOptions(log.Nop)
+	alg := newNinjaTortoise(layerSize, mdb, 5, lg)
+	alg.handleIncomingLayer(lyrs[0])
+	for l := types.LayerID(1); l <= types.LayerID(len(lyrs)-1); l++ {
+		alg.handleIncomingLayer(storedLayer(mdb, l, lg))
+	}
+	// older versions saved the whole state as a single object
+	r.NoError(mdb.Persist(mesh.TORTOISE, alg))
+
+	tmp, err := RecoverTortoise(mdb)
+	r.NoError(err)
+	requireSameState(t, alg, tmp.(*ninjaTortoise))
+
+	_, err = mdb.Retrieve(mesh.TORTOISE, &ninjaTortoise{})
+	r.Error(err, "the legacy state is deleted")
+	tmp, err = RecoverTortoise(mdb)
+	r.NoError(err)
+	requireSameState(t, alg, tmp.(*ninjaTortoise))
+	for b, v := range alg.TVote[alg.PBase] {
+		valid, err := mdb.ContextualValidity(b.id())
+		r.NoError(err)
+		r.Equal(v == support, valid)
+	}
+
+	_, err = RecoverTortoise(getInMemMesh())
+	r.Error(err)
+}
+
+func TestNinjaTortoise_RecoverAfterCrash(t *testing.T) {
+	r := require.New(t)
+	layerSize := 10
+	lyrs := persistenceMesh(t, 16, layerSize)
+	lg := log.New(t.Name(), "", "").WithOptions(log.Nop)
+
+	refDB := getInMemMesh()
+	addLayers(t, refDB, lyrs)
+	ref := newNinjaTortoise(layerSize, refDB, 5, lg)
+	for _, lyr := range lyrs {
+		ref.handleIncomingLayer(lyr)
+		r.NoError(ref.persist())
+	}
+
+	for _, crash := range []int{1, 4, 7, 12} {
+		mdb := &crashingDB{DB: getInMemMesh()}
+		addLayers(t, mdb.DB, lyrs)
+		alg := newNinjaTortoise(layerSize, mdb, 5, lg)
+		for _, lyr := range lyrs[:crash] {
+			alg.handleIncomingLayer(lyr)
+			r.NoError(alg.persist())
+		}
+		// the node is killed while handling the next layers, before their state is written
+		mdb.killed = true
+		for _, lyr := range lyrs[crash : crash+2] {
+			alg.handleIncomingLayer(lyr)
+			r.Error(alg.persist())
+		}
+
+		tmp, err := RecoverTortoise(mdb.DB)
+		r.NoError(err)
+		rec := tmp.(*ninjaTortoise)
+		rec.logger = lg
+		r.Equal(types.LayerID(crash-1), rec.Last)
+		for _, lyr := range lyrs[crash:] {
+			rec.handleIncomingLayer(lyr)
+			r.NoError(rec.persist())
+		}
+		requireSameState(t, ref, rec)
+
+		for _, lyr := range lyrs[:ref.PBase.Layer()] {
+			for _, b := range lyr.Blocks() {
+				expected, err := refDB.ContextualValidity(b.ID())
+				r.NoError(err)
+				valid, err := mdb.ContextualValidity(b.ID())
+				r.NoError(err)
+				r.Equal(expected, valid, "crash at %v layer %v", crash, lyr.Index())
+			}
+		}
+	}
+}
+
+func TestNinjaTortoise_RecoverPersistentDB(t *testing.T) {
+	r := require.New(t)
+	defer persistenceTeardown()
+	layerSize := 10
+	lyrs := persistenceMesh(t, 10, layerSize)
+	lg := log.New(t.Name(), "", "").WithOptions(log.Nop)
+
+	mdb, err := mesh.NewPersistentMeshDB(Path+"persistence/", 10, lg)
+	r.NoError(err)
+	addLayers(t, mdb, lyrs)
+	alg := newNinjaTortoise(layerSize, mdb, 5, lg)
+	for _, lyr := range lyrs {
+		alg.handleIncomingLayer(lyr)
+		r.NoError(alg.persist())
+	}
+	mdb.Close()
+
+	mdb, err = mesh.NewPersistentMeshDB(Path+"persistence/", 10, lg)
+	r.NoError(err)
+	defer mdb.Close()
+	alg.db = mdb
+	trtl := NewRecoveredTortoise(mdb, lg)
+	rec := trtl.(*tortoise).ninjaTortoise
+	requireSameState(t, alg, rec)
+}
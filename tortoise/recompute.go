@@ -0,0 +1,168 @@
+package tortoise
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidityDiff is a block whose recomputed contextual validity differs from the stored one
+type ValidityDiff struct {
+	Block      types.BlockID
+	Layer      types.LayerID
+	Stored     bool
+	HasStored  bool // false when the validity of the block was never stored
+	Recomputed bool
+}
+
+// RecomputeReport summarizes a recomputation of the tortoise state
+type RecomputeReport struct {
+	Last     types.LayerID // the last layer replayed
+	Complete types.LayerID // the latest complete layer after the replay
+	Blocks   int           // the number of blocks whose validity was recomputed
+	Diffs    []ValidityDiff
+}
+
+// Recompute builds a fresh tortoise of the given kind from genesis, replays the stored layers up to last and compares
+// the resulting contextual validity with the stored one. Like the node, any kind but the verifying tortoise replays the
+// ninja tortoise. Unless dryRun is set, the stored validity and the state of that tortoise are replaced by the
+// recomputed ones. progress, if not nil, is called after each replayed layer.
+func Recompute(mdb database, kind string, layerSize, hdist int, last types.LayerID, dryRun bool, progress func(l, complete types.LayerID), lg log.Log) (*RecomputeReport, error) {
+	if kind == Verifying {
+		return recomputeVerifying(mdb, layerSize, hdist, last, dryRun, progress, lg)
+	}
+	return recomputeNinja(mdb, layerSize, hdist, last, dryRun, progress, lg)
+}
+
+func recomputeNinja(mdb database, layerSize, hdist int, last types.LayerID, dryRun bool, progress func(l, complete types.LayerID), lg log.Log) (*RecomputeReport, error) {
+	ni := newNinjaTortoise(layerSize, mdb, hdist, lg)
+	ni.handleIncomingLayer(mesh.GenesisLayer())
+
+	// blocks leave the tortoise state once their layer is evicted, keep the latest opinion on each of them
+	decided := make(map[blockIDLayerTuple]bool)
+	for l := types.LayerID(1); l <= last; l++ {
+		ni.handleIncomingLayer(storedLayer(mdb, l, lg))
+		for b, vec := range ni.TVote[ni.PBase] {
+			decided[b] = vec == support
+		}
+		if progress != nil {
+			progress(l, ni.PBase.Layer())
+		}
+	}
+
+	report, validity := newRecomputeReport(mdb, last, ni.PBase.Layer(), decided)
+	if dryRun {
+		return report, nil
+	}
+
+	// mark the stored layer records as written with an impossible digest, so that the records still in the state are
+	// rewritten and the others are deleted, in the same batch as the validity
+	records, err := mdb.TortoiseState()
+	if err != nil {
+		return nil, err
+	}
+	for key := range records {
+		if !strings.HasPrefix(key, layerKeyPrefix) {
+			continue
+		}
+		l, err := strconv.ParseUint(key[len(layerKeyPrefix):], 10, 64)
+		if err != nil {
+			continue
+		}
+		ni.written[types.LayerID(l)] = 0
+	}
+	if err := ni.save(validity); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// validityOverlay keeps the contextual validity saved by a replayed tortoise in memory, so that the replay reads its
+// own decisions instead of the stored ones
+type validityOverlay struct {
+	database
+	validity map[types.BlockID]bool
+}
+
+func (o *validityOverlay) SaveContextualValidity(id types.BlockID, valid bool) error {
+	o.validity[id] = valid
+	return nil
+}
+
+func (o *validityOverlay) ContextualValidity(id types.BlockID) (bool, error) {
+	valid, found := o.validity[id]
+	if !found {
+		return false, fmt.Errorf("no recomputed validity for block %v", id)
+	}
+	return valid, nil
+}
+
+func recomputeVerifying(mdb database, layerSize, hdist int, last types.LayerID, dryRun bool, progress func(l, complete types.LayerID), lg log.Log) (*RecomputeReport, error) {
+	overlay := &validityOverlay{database: mdb, validity: make(map[types.BlockID]bool)}
+	vt := newVerifyingTortoise(layerSize, overlay, hdist, lg)
+	vt.handleIncomingLayer(mesh.GenesisLayer())
+	for l := types.LayerID(1); l <= last; l++ {
+		vt.handleIncomingLayer(storedLayer(overlay, l, lg))
+		if progress != nil {
+			progress(l, vt.Verified)
+		}
+	}
+
+	decided := make(map[blockIDLayerTuple]bool, len(overlay.validity))
+	for l := types.LayerID(0); l <= last; l++ {
+		for _, id := range vt.layerBlocks(l) {
+			if valid, found := overlay.validity[id]; found {
+				decided[blockIDLayerTuple{BlockID: id, LayerID: l}] = valid
+			}
+		}
+	}
+
+	report, validity := newRecomputeReport(mdb, last, vt.Verified, decided)
+	if dryRun {
+		return report, nil
+	}
+
+	if err := mdb.SaveTortoiseState(validity, nil); err != nil {
+		return nil, err
+	}
+	// the state is not in the batch of the validity, it is written last as it refers to the validity of the verified
+	// layers
+	vt.db = mdb
+	if err := mdb.Persist(mesh.VERIFYINGTORTOISE, vt); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// newRecomputeReport compares the recomputed validity with the stored one, it returns the report and the recomputed
+// validity by block
+func newRecomputeReport(mdb database, last, complete types.LayerID, decided map[blockIDLayerTuple]bool) (*RecomputeReport, map[types.BlockID]bool) {
+	report := &RecomputeReport{Last: last, Complete: complete, Blocks: len(decided)}
+	validity := make(map[types.BlockID]bool, len(decided))
+	for b, valid := range decided {
+		validity[b.id()] = valid
+		stored, err := mdb.ContextualValidity(b.id())
+		if err == nil && stored == valid {
+			continue
+		}
+		report.Diffs = append(report.Diffs, ValidityDiff{
+			Block:      b.id(),
+			Layer:      b.layer(),
+			Stored:     stored && err == nil,
+			HasStored:  err == nil,
+			Recomputed: valid,
+		})
+	}
+	sort.Slice(report.Diffs, func(i, j int) bool {
+		if report.Diffs[i].Layer != report.Diffs[j].Layer {
+			return report.Diffs[i].Layer < report.Diffs[j].Layer
+		}
+		return bytes.Compare(report.Diffs[i].Block.Bytes(), report.Diffs[j].Block.Bytes()) < 0
+	})
+	return report, validity
+}
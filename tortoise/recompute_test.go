@@ -0,0 +1,143 @@
+package tortoise
+
+import (
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRecompute(t *testing.T) {
+	r := require.New(t)
+	layers, layerSize, hdist := 16, 10, 5
+	lyrs := persistenceMesh(t, layers, layerSize)
+	lg := log.New(t.Name(), "", "").WithOptions(log.Nop)
+	last := types.LayerID(layers)
+
+	mdb := getInMemMesh()
+	addLayers(t, mdb, lyrs)
+	ref := newNinjaTortoise(layerSize, mdb, hdist, lg)
+	ref.handleIncomingLayer(lyrs[0])
+	for l := types.LayerID(1); l <= last; l++ {
+		ref.handleIncomingLayer(storedLayer(mdb, l, lg))
+		r.NoError(ref.persist())
+	}
+
+	// corrupt the stored validity of two blocks and the state records
+	flipped := []types.BlockID{lyrs[3].Blocks()[0].ID(), lyrs[12].Blocks()[1].ID()}
+	corrupted := make(map[types.BlockID]bool)
+	for _, id := range flipped {
+		valid, err := mdb.ContextualValidity(id)
+		r.NoError(err)
+		corrupted[id] = !valid
+	}
+	junk := layerKey(ref.PBase.Layer())
+	stale := layerKey(last + 100)
+	r.NoError(mdb.SaveTortoiseState(corrupted, map[string][]byte{junk: {1, 2, 3}, stale: {4, 5, 6}}))
+
+	var progress []types.LayerID
+	report, err := Recompute(mdb, Ninja, layerSize, hdist, last, true, func(l, complete types.LayerID) {
+		progress = append(progress, l)
+	}, lg)
+	r.NoError(err)
+	r.Len(progress, layers)
+	r.Equal(last, report.Last)
+	r.Equal(ref.PBase.Layer(), report.Complete)
+	r.NotZero(report.Blocks)
+	r.Len(report.Diffs, len(flipped))
+	for i, diff := range report.Diffs {
+		r.Equal(flipped[i], diff.Block)
+		r.True(diff.HasStored)
+		r.Equal(corrupted[diff.Block], diff.Stored)
+		r.Equal(!corrupted[diff.Block], diff.Recomputed)
+	}
+	r.Equal(types.LayerID(3), report.Diffs[0].Layer)
+	r.Equal(types.LayerID(12), report.Diffs[1].Layer)
+
+	// the dry run leaves the database untouched
+	for id, v := range corrupted {
+		valid, err := mdb.ContextualValidity(id)
+		r.NoError(err)
+		r.Equal(v, valid)
+	}
+	records, err := mdb.TortoiseState()
+	r.NoError(err)
+	r.Equal([]byte{1, 2, 3}, records[junk])
+
+	report, err = Recompute(mdb, Ninja, layerSize, hdist, last, false, nil, lg)
+	r.NoError(err)
+	r.Len(report.Diffs, len(flipped))
+	report, err = Recompute(mdb, Ninja, layerSize, hdist, last, true, nil, lg)
+	r.NoError(err)
+	r.Empty(report.Diffs)
+
+	records, err = mdb.TortoiseState()
+	r.NoError(err)
+	_, found := records[stale]
+	r.False(found, "records of layers not in the state are deleted")
+	tmp, err := RecoverTortoise(mdb)
+	r.NoError(err)
+	requireSameState(t, ref, tmp.(*ninjaTortoise))
+}
+
+func TestRecompute_NoStoredValidity(t *testing.T) {
+	r := require.New(t)
+	layers, layerSize := 8, 10
+	lyrs := persistenceMesh(t, layers, layerSize)
+	mdb := getInMemMesh()
+	addLayers(t, mdb, lyrs)
+
+	report, err := Recompute(mdb, Ninja, layerSize, 5, types.LayerID(layers), true, nil, log.New(t.Name(), "", "").WithOptions(log.Nop))
+	r.NoError(err)
+	r.NotZero(report.Blocks)
+	r.Len(report.Diffs, report.Blocks)
+	for _, diff := range report.Diffs {
+		r.False(diff.HasStored)
+	}
+}
+
+func TestRecompute_Verifying(t *testing.T) {
+	r := require.New(t)
+	layers, layerSize, hdist := 12, 10, 5
+	lyrs, outputs := generateMesh(layers, layerSize, 7, hdist, 0)
+	lg := log.New(t.Name(), "", "").WithOptions(log.Nop)
+	last := types.LayerID(layers)
+	mdb := getInMemMesh()
+	for _, lyr := range lyrs {
+		r.NoError(AddLayer(mdb, lyr))
+	}
+	for l, output := range outputs {
+		r.NoError(mdb.SaveLayerInputVector(l, output))
+	}
+	ref := NewVerifyingTortoise(layerSize, mdb, hdist, lg)
+	for _, lyr := range lyrs[1:] {
+		ref.HandleIncomingLayer(lyr)
+	}
+	verified := ref.LatestComplete()
+	r.True(verified > 3)
+
+	// corrupt the stored validity and the verifying tortoise state
+	flipped := lyrs[3].Blocks()[0].ID()
+	valid, err := mdb.ContextualValidity(flipped)
+	r.NoError(err)
+	r.NoError(mdb.SaveContextualValidity(flipped, !valid))
+	r.NoError(mdb.Persist(mesh.VERIFYINGTORTOISE, newVerifyingTortoise(layerSize, mdb, hdist, lg)))
+
+	report, err := Recompute(mdb, Verifying, layerSize, hdist, last, true, nil, lg)
+	r.NoError(err)
+	r.Equal(verified, report.Complete)
+	r.Len(report.Diffs, 1)
+	r.Equal(flipped, report.Diffs[0].Block)
+	r.Equal(types.LayerID(3), report.Diffs[0].Layer)
+	r.Equal(valid, report.Diffs[0].Recomputed)
+
+	_, err = Recompute(mdb, Verifying, layerSize, hdist, last, false, nil, lg)
+	r.NoError(err)
+	stored, err := mdb.ContextualValidity(flipped)
+	r.NoError(err)
+	r.Equal(valid, stored)
+	rec := NewRecoveredVerifyingTortoise(mdb, lg)
+	r.Equal(verified, rec.LatestComplete())
+	r.Equal(last, rec.(*verifyingTortoise).Last)
+}
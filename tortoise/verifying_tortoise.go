@@ -0,0 +1,447 @@
+package tortoise
+
+import (
+	"fmt"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/events"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"sync"
+	"time"
+)
+
+// the vote counting algorithms the node can be configured with
+const (
+	Ninja     = "ninja"
+	Verifying = "verifying"
+)
+
+// verifyingTortoise decides the layers in order. A layer is verified once the blocks that agree with the local opinion
+// (good blocks) vote for its hare output, and against the rest of its blocks, with a global threshold. When the votes
+// disagree with the local opinion, or a layer can no longer be verified, the ninja tortoise is replayed from the mesh
+// and decides the layers until the contested layer is decided.
+type verifyingTortoise struct {
+	db     database
+	logger log.Log
+	mutex  sync.Mutex
+	ninja  *ninjaTortoise // the fallback, nil while the votes agree with the local opinion
+	// the decisions of the fallback on the blocks of the layers that are not verified, collected as its patterns complete
+	decided map[types.BlockID]bool
+
+	Last          types.LayerID
+	Verified      types.LayerID // all the layers below it are decided
+	Hdist         types.LayerID
+	AvgLayerSize  int
+	Fallback      types.LayerID                                // the ninja tortoise decides the layers below it, zero when not falling back
+	Processed     map[types.LayerID]map[types.BlockID]struct{} // the blocks whose votes were counted, by layer
+	Tally         map[types.LayerID]map[types.BlockID]int      // the votes of good blocks for the blocks of the layers that are not verified
+	Disagreements map[types.LayerID]int                        // the number of blocks whose votes for the layer disagree with the local opinion
+}
+
+func newVerifyingTortoise(layerSize int, db database, hdist int, lg log.Log) *verifyingTortoise {
+	return &verifyingTortoise{
+		db:            db,
+		logger:        lg,
+		Hdist:         types.LayerID(hdist),
+		AvgLayerSize:  layerSize,
+		Processed:     map[types.LayerID]map[types.BlockID]struct{}{},
+		Tally:         map[types.LayerID]map[types.BlockID]int{},
+		Disagreements: map[types.LayerID]int{},
+	}
+}
+
+//NewVerifyingTortoise returns a new verifying Tortoise instance
+func NewVerifyingTortoise(layerSize int, mdb *mesh.DB, hdist int, lg log.Log) Tortoise {
+	alg := newVerifyingTortoise(layerSize, mdb, hdist, lg)
+	alg.HandleIncomingLayer(mesh.GenesisLayer())
+	return alg
+}
+
+//NewRecoveredVerifyingTortoise recovers a previously persisted verifying tortoise copy from mesh.DB
+func NewRecoveredVerifyingTortoise(mdb *mesh.DB, lg log.Log) Tortoise {
+	tmp, err := mdb.Retrieve(mesh.VERIFYINGTORTOISE, &verifyingTortoise{})
+	if err != nil {
+		lg.Panic("could not recover verifying tortoise state from disc ", err)
+	}
+
+	vt := tmp.(*verifyingTortoise)
+
+	lg.Info("recovered verifying tortoise from disc")
+	vt.db = mdb
+	vt.logger = lg
+	if vt.Processed == nil {
+		vt.Processed = map[types.LayerID]map[types.BlockID]struct{}{}
+	}
+	if vt.Tally == nil {
+		vt.Tally = map[types.LayerID]map[types.BlockID]int{}
+	}
+	if vt.Disagreements == nil {
+		vt.Disagreements = map[types.LayerID]int{}
+	}
+
+	return vt
+}
+
+//HandleLateBlock processes a late blocks votes (for late block definition see white paper)
+//returns the old verified layer and new verified layer after taking into account the blocks votes
+func (vt *verifyingTortoise) HandleLateBlock(b *types.Block) (types.LayerID, types.LayerID) {
+	l := types.NewLayer(b.Layer())
+	l.AddBlock(b)
+	oldVerified, newVerified := vt.HandleIncomingLayer(l)
+	log.With().Info("late block ", log.LayerID(uint64(b.Layer())), log.BlockID(b.ID().String()))
+	return oldVerified, newVerified
+}
+
+//Persist saves a copy of the current verifying tortoise state to the database
+func (vt *verifyingTortoise) Persist() error {
+	vt.mutex.Lock()
+	defer vt.mutex.Unlock()
+	log.Info("persist verifying tortoise ")
+	return vt.db.Persist(mesh.VERIFYINGTORTOISE, vt)
+}
+
+//HandleIncomingLayer processes all layer block votes
+//returns the old verified layer and new verified layer after taking into account the blocks votes
+func (vt *verifyingTortoise) HandleIncomingLayer(ll *types.Layer) (types.LayerID, types.LayerID) {
+	vt.mutex.Lock()
+	defer vt.mutex.Unlock()
+	oldVerified := vt.Verified
+	vt.handleIncomingLayer(ll)
+	pbaseCount.Set(float64(vt.Verified))
+	processedCount.Set(float64(ll.Index()))
+	return oldVerified, vt.Verified
+}
+
+//LatestComplete returns the latest complete (a.k.a irreversible) layer
+func (vt *verifyingTortoise) LatestComplete() types.LayerID {
+	vt.mutex.Lock()
+	defer vt.mutex.Unlock()
+	return vt.Verified
+}
+
+//BlockInfo returns the votes the tortoise counted for the block and its opinion on it
+func (vt *verifyingTortoise) BlockInfo(id types.BlockID) (*BlockInfo, error) {
+	vt.mutex.Lock()
+	defer vt.mutex.Unlock()
+	return vt.blockInfo(id)
+}
+
+//LayerInfo returns the number of valid and invalid blocks of the layer and whether it is verified
+func (vt *verifyingTortoise) LayerInfo(l types.LayerID) (*LayerInfo, error) {
+	vt.mutex.Lock()
+	defer vt.mutex.Unlock()
+	return layerInfo(vt.db, l, vt.Verified)
+}
+
+func (vt *verifyingTortoise) handleIncomingLayer(newlyr *types.Layer) {
+	vt.logger.With().Info("verifying tortoise update tables", log.LayerID(uint64(newlyr.Index())), log.Int("n_blocks", len(newlyr.Blocks())))
+	start := time.Now()
+	if newlyr.Index() > vt.Last {
+		vt.Last = newlyr.Index()
+	}
+
+	if vt.Fallback != 0 {
+		vt.handleFallback(newlyr)
+	} else {
+		opinions := make(map[types.LayerID]map[types.BlockID]struct{})
+		for _, b := range newlyr.Blocks() {
+			vt.countVotes(b, opinions)
+		}
+		vt.verifyLayers(opinions)
+		if vt.disagree() {
+			vt.startFallback()
+		}
+	}
+
+	vt.logger.With().Info(fmt.Sprintf("verifying tortoise finished layer in %v", time.Since(start)), log.LayerID(uint64(newlyr.Index())), log.Uint64("verified", uint64(vt.Verified)))
+}
+
+// layerBlocks returns the blocks of the layer, the genesis layer always holds the genesis block
+func (vt *verifyingTortoise) layerBlocks(l types.LayerID) []types.BlockID {
+	ids, err := vt.db.LayerBlockIds(l)
+	if err != nil {
+		ids = nil // empty layer
+	}
+	if l == genesis {
+		for _, id := range ids {
+			if id == mesh.GenesisBlock.ID() {
+				return ids
+			}
+		}
+		ids = append(ids, mesh.GenesisBlock.ID())
+	}
+	return ids
+}
+
+// opinion returns the blocks the node considers valid in the layer: the decided blocks of the verified layers and the
+// hare output of the layers that are not verified. The opinions are cached for the duration of a single update.
+func (vt *verifyingTortoise) opinion(l types.LayerID, cache map[types.LayerID]map[types.BlockID]struct{}) (map[types.BlockID]struct{}, bool) {
+	if op, ok := cache[l]; ok {
+		return op, op != nil
+	}
+
+	op := make(map[types.BlockID]struct{})
+	switch {
+	case l == genesis:
+		for _, id := range vt.layerBlocks(l) {
+			op[id] = struct{}{}
+		}
+	case l < vt.Verified:
+		for _, id := range vt.layerBlocks(l) {
+			valid, err := vt.db.ContextualValidity(id)
+			if err != nil {
+				vt.logger.With().Error("could not read contextual validity", log.BlockID(id.String()), log.Err(err))
+				op = nil
+				break
+			}
+			if valid {
+				op[id] = struct{}{}
+			}
+		}
+	default:
+		ids, err := vt.db.GetLayerInputVector(l)
+		if err != nil {
+			op = nil // hare did not terminate on the layer
+			break
+		}
+		for _, id := range ids {
+			op[id] = struct{}{}
+		}
+	}
+
+	cache[l] = op
+	return op, op != nil
+}
+
+// countVotes adds the votes of the block for the layers that are not verified to the tally if the block is good, i.e.
+// the blocks it supports are the local opinion on every layer of its window, abstentions are not counted
+func (vt *verifyingTortoise) countVotes(b *types.Block, opinions map[types.LayerID]map[types.BlockID]struct{}) {
+	if b.Layer() <= vt.Verified {
+		return // votes only for decided layers
+	}
+	if _, found := vt.Processed[b.Layer()][b.ID()]; found {
+		return
+	}
+	if _, found := vt.Processed[b.Layer()]; !found {
+		vt.Processed[b.Layer()] = make(map[types.BlockID]struct{}, vt.AvgLayerSize)
+	}
+	vt.Processed[b.Layer()][b.ID()] = struct{}{}
+
+	votes, err := mesh.BlockOpinion(vt.db, b, vt.Hdist)
+	if err != nil {
+		vt.logger.With().Error("could not resolve block votes", log.BlockID(b.ID().String()), log.Err(err))
+		return
+	}
+
+	bottom := types.LayerID(0)
+	if b.Layer() > vt.Hdist {
+		bottom = b.Layer() - vt.Hdist
+	}
+
+	good := true
+	for l := bottom; l < b.Layer(); l++ {
+		op, known := vt.opinion(l, opinions)
+		if !known || sameBlocks(votes.Support(l), op) {
+			continue
+		}
+		good = false
+		if l >= vt.Verified {
+			vt.Disagreements[l]++
+		}
+	}
+	if !good {
+		vt.logger.With().Debug("block votes disagree with local opinion", log.BlockID(b.ID().String()), log.LayerID(uint64(b.Layer())))
+		return
+	}
+
+	for l := max(bottom, vt.Verified); l < b.Layer(); l++ {
+		if _, known := vt.opinion(l, opinions); !known {
+			continue
+		}
+		if _, found := vt.Tally[l]; !found {
+			vt.Tally[l] = make(map[types.BlockID]int, vt.AvgLayerSize)
+		}
+		for _, id := range vt.layerBlocks(l) {
+			switch votes.Vote(l, id) {
+			case types.Support:
+				vt.Tally[l][id]++
+			case types.Against:
+				vt.Tally[l][id]--
+			}
+		}
+	}
+}
+
+func sameBlocks(a, b map[types.BlockID]struct{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id := range a {
+		if _, found := b[id]; !found {
+			return false
+		}
+	}
+	return true
+}
+
+// threshold returns the number of good votes needed to decide a block of the layer
+func (vt *verifyingTortoise) threshold(l types.LayerID) float64 {
+	top := vt.Last
+	if l+vt.Hdist < top {
+		top = l + vt.Hdist
+	}
+	return globalThreshold * float64(vt.AvgLayerSize) * float64(top-l)
+}
+
+// verifyLayers verifies the layers in order as long as the good votes agree with the local opinion on every block
+func (vt *verifyingTortoise) verifyLayers(opinions map[types.LayerID]map[types.BlockID]struct{}) {
+	for l := vt.Verified; l < vt.Last; l++ {
+		op, known := vt.opinion(l, opinions)
+		if !known {
+			return
+		}
+
+		threshold := vt.threshold(l)
+		blocks := vt.layerBlocks(l)
+		for _, id := range blocks {
+			tally := float64(vt.Tally[l][id])
+			if _, valid := op[id]; valid && tally <= threshold || !valid && tally >= -threshold {
+				return
+			}
+		}
+
+		for _, id := range blocks {
+			_, valid := op[id]
+			vt.saveValidity(id, valid)
+		}
+		vt.logger.With().Info("verified layer", log.LayerID(uint64(l)))
+		delete(vt.Tally, l)
+		delete(vt.Disagreements, l)
+		delete(vt.Processed, l)
+		vt.Verified = l + 1
+	}
+}
+
+func (vt *verifyingTortoise) saveValidity(id types.BlockID, valid bool) {
+	if err := vt.db.SaveContextualValidity(id, valid); err != nil {
+		vt.logger.With().Error("could not save contextual validity", log.BlockID(id.String()), log.Err(err))
+		return
+	}
+	if valid {
+		validBlocks.Add(1)
+	} else {
+		invalidBlocks.Add(1)
+		vt.logger.With().Warning("block is contextually invalid", log.BlockID(id.String()))
+	}
+	events.Publish(events.ValidBlock{ID: id.String(), Valid: valid})
+}
+
+// disagree reports whether the first layer that is not verified needs the ninja tortoise to be decided, either because
+// enough blocks vote against the local opinion or because new blocks no longer vote for it
+func (vt *verifyingTortoise) disagree() bool {
+	if vt.Last > vt.Verified+vt.Hdist {
+		vt.logger.With().Warning("verifying tortoise is stuck", log.LayerID(uint64(vt.Verified)), log.Uint64("last", uint64(vt.Last)))
+		return true
+	}
+	if float64(vt.Disagreements[vt.Verified]) > vt.threshold(vt.Verified) {
+		vt.logger.With().Warning("block votes disagree with local opinion", log.LayerID(uint64(vt.Verified)), log.Int("n_blocks", vt.Disagreements[vt.Verified]))
+		return true
+	}
+	return false
+}
+
+// startFallback replays the mesh to the ninja tortoise, which decides the layers until all the layers that were
+// received when the verification stopped are decided
+func (vt *verifyingTortoise) startFallback() {
+	vt.logger.With().Warning("falling back to ninja tortoise", log.LayerID(uint64(vt.Verified)))
+	vt.Fallback = vt.Last
+	vt.ninja = newNinjaTortoise(vt.AvgLayerSize, vt.db, int(vt.Hdist), vt.logger)
+	vt.decided = make(map[types.BlockID]bool)
+	vt.ninja.onComplete = vt.collectDecisions
+	vt.ninja.handleIncomingLayer(mesh.GenesisLayer())
+	for l := types.LayerID(1); l <= vt.Last && vt.ninja != nil; l++ {
+		vt.ninja.handleIncomingLayer(storedLayer(vt.db, l, vt.logger))
+		vt.adoptFallback()
+	}
+}
+
+// storedLayer returns the blocks of the layer from the database
+func storedLayer(db database, l types.LayerID, lg log.Log) *types.Layer {
+	ids, _ := db.LayerBlockIds(l) // empty layer on error
+	blocks := make([]*types.Block, 0, len(ids))
+	for _, id := range ids {
+		blk, err := db.GetBlockHeader(id)
+		if err != nil {
+			lg.With().Error("could not retrieve block", log.BlockID(id.String()), log.Err(err))
+			continue
+		}
+		blocks = append(blocks, blk)
+	}
+	return types.NewExistingLayer(l, blocks)
+}
+
+func (vt *verifyingTortoise) handleFallback(newlyr *types.Layer) {
+	if vt.ninja == nil {
+		// the ninja tortoise is not persisted, replay the mesh after recovery
+		vt.startFallback()
+		return
+	}
+	vt.ninja.handleIncomingLayer(newlyr)
+	vt.adoptFallback()
+}
+
+// collectDecisions records the votes of a complete pattern of the ninja tortoise on the layers that are not verified.
+// A pattern only votes on the layers above the complete pattern it was counted from, so the decisions on the lower
+// layers are the ones of the previous patterns, like the ninja tortoise opinion persisted after every layer.
+func (vt *verifyingTortoise) collectDecisions(p votingPattern) {
+	for b, v := range vt.ninja.TVote[p] {
+		if b.layer() >= vt.Verified && b.layer() < p.Layer() {
+			vt.decided[b.id()] = v == support
+		}
+	}
+}
+
+// adoptFallback saves the ninja tortoise opinion on the layers it decided and stops falling back once the contested
+// layer is decided
+func (vt *verifyingTortoise) adoptFallback() {
+	vt.collectDecisions(vt.ninja.PBase)
+	pbase := vt.ninja.latestComplete()
+	if pbase <= vt.Verified {
+		return
+	}
+
+	for l := vt.Verified; l < pbase; l++ {
+		for _, id := range vt.layerBlocks(l) {
+			// the ninja tortoise votes against the blocks that are not in the view of its complete patterns
+			vt.saveValidity(id, vt.decided[id] || l == genesis)
+			delete(vt.decided, id)
+		}
+	}
+	vt.logger.With().Info("ninja tortoise decided layers", log.LayerID(uint64(vt.Verified)), log.Uint64("pbase", uint64(pbase)))
+	vt.Verified = pbase
+
+	if vt.Verified < vt.Fallback {
+		return
+	}
+
+	vt.logger.With().Info("ninja tortoise decided contested layers, resuming verification", log.LayerID(uint64(vt.Verified)))
+	vt.ninja = nil
+	vt.decided = nil
+	vt.Fallback = 0
+	vt.recount()
+}
+
+// recount counts the votes of the blocks of the layers that are not verified from scratch
+func (vt *verifyingTortoise) recount() {
+	vt.Processed = map[types.LayerID]map[types.BlockID]struct{}{}
+	vt.Tally = map[types.LayerID]map[types.BlockID]int{}
+	vt.Disagreements = map[types.LayerID]int{}
+
+	opinions := make(map[types.LayerID]map[types.BlockID]struct{})
+	for l := vt.Verified + 1; l <= vt.Last; l++ {
+		for _, b := range storedLayer(vt.db, l, vt.logger).Blocks() {
+			vt.countVotes(b, opinions)
+		}
+	}
+	vt.verifyLayers(opinions)
+}
@@ -0,0 +1,306 @@
+package tortoise
+
+import (
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"github.com/spacemeshos/go-spacemesh/rand"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// generateMesh creates a mesh whose good blocks explicitly vote for the hare outputs of all the layers of their window
+// and against the other blocks, the way honest miners build blocks, while the bad blocks vote for a random pattern of
+// the previous layer. The hare output of each layer is a random pattern of its good blocks, like the one of honest
+// participants, so the ninja tortoise never abstains on a whole pattern. It returns the layers and the hare outputs.
+func generateMesh(layers, layerSize, patternSize, hdist int, badBlocks float64) ([]*types.Layer, map[types.LayerID][]types.BlockID) {
+	lyrs := []*types.Layer{mesh.GenesisLayer()}
+	outputs := map[types.LayerID][]types.BlockID{0: {mesh.GenesisBlock.ID()}}
+	for i := 1; i <= layers; i++ {
+		index := types.LayerID(i)
+		prev := lyrs[i-1]
+		l := types.NewLayer(index)
+		gbs := int(float64(layerSize) * (1 - badBlocks))
+		for j := 0; j < layerSize; j++ {
+			bl := types.NewExistingBlock(index, []byte(rand.String(8)))
+			if j < gbs {
+				for w := i - hdist; w < i; w++ {
+					if w < 0 {
+						continue
+					}
+					valid := make(map[types.BlockID]struct{})
+					for _, id := range outputs[types.LayerID(w)] {
+						valid[id] = struct{}{}
+						bl.AddVote(id)
+					}
+					for _, b := range lyrs[w].Blocks() {
+						if _, found := valid[b.ID()]; !found {
+							bl.AddVoteAgainst(b.ID())
+						}
+					}
+				}
+				for _, b := range prev.Blocks() {
+					bl.AddView(b.ID())
+				}
+			} else {
+				addPattern(bl, chooseRandomPattern(len(prev.Blocks()), 1), prev)
+			}
+			bl.Initialize()
+			l.AddBlock(bl)
+		}
+
+		var output []types.BlockID
+		for _, idx := range chooseRandomPattern(gbs, patternSize) {
+			output = append(output, l.Blocks()[idx].ID())
+		}
+		outputs[index] = output
+		lyrs = append(lyrs, l)
+	}
+	return lyrs, outputs
+}
+
+// generateBaseBlockMesh creates a mesh like generateMesh without bad blocks, where the blocks vote relative to the first
+// block of the previous layer, so they only list their votes on the previous layer
+func generateBaseBlockMesh(t *testing.T, layers, layerSize, patternSize, hdist int) ([]*types.Layer, map[types.LayerID][]types.BlockID) {
+	r := require.New(t)
+	mdb := getInMemMesh()
+	lyrs := []*types.Layer{mesh.GenesisLayer()}
+	outputs := map[types.LayerID][]types.BlockID{0: {mesh.GenesisBlock.ID()}}
+	r.NoError(AddLayer(mdb, lyrs[0]))
+	for i := 1; i <= layers; i++ {
+		index := types.LayerID(i)
+		desired := make(mesh.Opinion)
+		for w := mesh.WindowBottom(index, types.LayerID(hdist)); w < index; w++ {
+			desired[w] = make(map[types.BlockID]types.Vote)
+			for _, b := range lyrs[w].Blocks() {
+				desired[w][b.ID()] = types.Against
+			}
+			for _, id := range outputs[w] {
+				desired[w][id] = types.Support
+			}
+		}
+		var base types.BlockID
+		var inherited mesh.Opinion
+		if i > 1 {
+			base = lyrs[i-1].Blocks()[0].ID()
+			var err error
+			inherited, err = mesh.ResolveVotes(mdb, types.Votes{BaseBlock: base}, mesh.WindowBottom(index, types.LayerID(hdist)))
+			r.NoError(err)
+		}
+
+		l := types.NewLayer(index)
+		for j := 0; j < layerSize; j++ {
+			bl := types.NewExistingBlock(index, []byte(rand.String(8)))
+			bl.Votes = mesh.DiffVotes(base, inherited, desired)
+			for _, b := range lyrs[i-1].Blocks() {
+				bl.AddView(b.ID())
+			}
+			bl.Initialize()
+			l.AddBlock(bl)
+		}
+		r.NoError(AddLayer(mdb, l))
+
+		var output []types.BlockID
+		for _, idx := range chooseRandomPattern(layerSize, patternSize) {
+			output = append(output, l.Blocks()[idx].ID())
+		}
+		outputs[index] = output
+		lyrs = append(lyrs, l)
+	}
+	return lyrs, outputs
+}
+
+// differential runs the ninja tortoise and the verifying tortoise on the same mesh, the verifying tortoise uses the
+// hare outputs returned by localOutput, and checks that both agree on the contextual validity of all the blocks of the
+// layers they both decided
+func differential(t *testing.T, lyrs []*types.Layer, outputs map[types.LayerID][]types.BlockID, layerSize, hdist int,
+	localOutput func(types.LayerID, []types.BlockID) []types.BlockID) *verifyingTortoise {
+	r := require.New(t)
+	lg := log.New(t.Name(), "", "").WithOptions(log.Nop)
+	ninjaDB := getInMemMesh()
+	verifyingDB := getInMemMesh()
+	for _, lyr := range lyrs {
+		r.NoError(AddLayer(ninjaDB, lyr))
+		r.NoError(AddLayer(verifyingDB, lyr))
+	}
+	for l, output := range outputs {
+		if output = localOutput(l, output); output != nil {
+			r.NoError(verifyingDB.SaveLayerInputVector(l, output))
+		}
+	}
+
+	ninja := NewTortoise(layerSize, ninjaDB, hdist, lg)
+	verifying := NewVerifyingTortoise(layerSize, verifyingDB, hdist, lg)
+	for _, lyr := range lyrs[1:] {
+		ninja.HandleIncomingLayer(lyr)
+		r.NoError(ninja.Persist())
+		verifying.HandleIncomingLayer(lyr)
+		r.NoError(verifying.Persist())
+	}
+
+	decided := ninja.LatestComplete()
+	if verifying.LatestComplete() < decided {
+		decided = verifying.LatestComplete()
+	}
+	r.True(decided > 0)
+	for _, lyr := range lyrs[:decided] {
+		for _, b := range lyr.Blocks() {
+			expected, err := ninjaDB.ContextualValidity(b.ID())
+			r.NoError(err)
+			valid, err := verifyingDB.ContextualValidity(b.ID())
+			r.NoError(err)
+			r.Equal(expected, valid, "layer %v block %v", lyr.Index(), b.ID())
+		}
+	}
+	return verifying.(*verifyingTortoise)
+}
+
+func hareOutput(_ types.LayerID, output []types.BlockID) []types.BlockID {
+	return output
+}
+
+func TestVerifyingTortoise_Agreement(t *testing.T) {
+	r := require.New(t)
+	layers, layerSize, hdist := 12, 10, 5
+	lyrs, outputs := generateMesh(layers, layerSize, 7, hdist, 0)
+
+	vt := differential(t, lyrs, outputs, layerSize, hdist, hareOutput)
+	r.Equal(types.LayerID(layers), vt.Verified)
+	r.Zero(vt.Fallback)
+	r.Nil(vt.ninja)
+	r.Empty(vt.Tally)
+}
+
+func TestVerifyingTortoise_BadBlocks(t *testing.T) {
+	r := require.New(t)
+	layers, layerSize, hdist := 12, 10, 5
+	lyrs, outputs := generateMesh(layers, layerSize, 7, hdist, 0.2)
+
+	vt := differential(t, lyrs, outputs, layerSize, hdist, hareOutput)
+	r.Zero(vt.Fallback)
+	r.True(vt.Verified >= types.LayerID(layers-1))
+}
+
+func TestVerifyingTortoise_HareDisagreement(t *testing.T) {
+	r := require.New(t)
+	layers, layerSize, hdist := 14, 10, 5
+	lyrs, outputs := generateMesh(layers, layerSize, 7, hdist, 0)
+
+	// the local hare output of layer 4 differs from the one the blocks vote for
+	vt := differential(t, lyrs, outputs, layerSize, hdist, func(l types.LayerID, output []types.BlockID) []types.BlockID {
+		if l == 4 {
+			return output[1:]
+		}
+		return output
+	})
+	r.Zero(vt.Fallback)
+	r.Nil(vt.ninja)
+	r.True(vt.Verified >= types.LayerID(layers-1))
+}
+
+func TestVerifyingTortoise_MissingHareOutput(t *testing.T) {
+	r := require.New(t)
+	layers, layerSize, hdist := 16, 10, 5
+	lyrs, outputs := generateMesh(layers, layerSize, 7, hdist, 0)
+
+	vt := differential(t, lyrs, outputs, layerSize, hdist, func(l types.LayerID, output []types.BlockID) []types.BlockID {
+		if l == 3 {
+			return nil
+		}
+		return output
+	})
+	r.Zero(vt.Fallback)
+	r.True(vt.Verified >= types.LayerID(layers-1))
+}
+
+func TestVerifyingTortoise_AdoptFallback(t *testing.T) {
+	r := require.New(t)
+	layers, layerSize, hdist := 8, 10, 5
+	lyrs, _ := generateMesh(layers, layerSize, 7, hdist, 0)
+	lg := log.New(t.Name(), "", "").WithOptions(log.Nop)
+	mdb := getInMemMesh()
+	for _, lyr := range lyrs {
+		r.NoError(AddLayer(mdb, lyr))
+	}
+
+	// the ninja tortoise completed a pattern of layer 6 while the layers from 2 were not verified, it decided some of
+	// the blocks of layer 3 and the other blocks were not in the view of its patterns
+	vt := newVerifyingTortoise(layerSize, mdb, hdist, lg)
+	vt.Verified, vt.Last, vt.Fallback = 2, types.LayerID(layers), types.LayerID(layers)
+	vt.ninja = newNinjaTortoise(layerSize, mdb, hdist, lg)
+	vt.ninja.PBase = votingPattern{id: 1, LayerID: 6}
+	vt.decided = map[types.BlockID]bool{lyrs[3].Blocks()[0].ID(): true, lyrs[3].Blocks()[1].ID(): false}
+	vt.adoptFallback()
+
+	r.Equal(types.LayerID(6), vt.Verified)
+	r.NotNil(vt.ninja)
+	for _, lyr := range lyrs[2:6] {
+		for i, b := range lyr.Blocks() {
+			valid, err := mdb.ContextualValidity(b.ID())
+			r.NoError(err, "layer %v block %v", lyr.Index(), b.ID())
+			r.Equal(lyr.Index() == 3 && i == 0, valid, "layer %v block %v", lyr.Index(), b.ID())
+		}
+	}
+	r.Empty(vt.decided)
+}
+
+func TestVerifyingTortoise_Recover(t *testing.T) {
+	r := require.New(t)
+	layers, layerSize, hdist := 12, 10, 5
+	lyrs, outputs := generateMesh(layers, layerSize, 7, hdist, 0)
+	lg := log.New(t.Name(), "", "").WithOptions(log.Nop)
+	mdb := getInMemMesh()
+	for _, lyr := range lyrs {
+		r.NoError(AddLayer(mdb, lyr))
+	}
+	for l, output := range outputs {
+		if l != 4 {
+			r.NoError(mdb.SaveLayerInputVector(l, output))
+		}
+	}
+
+	alg := NewVerifyingTortoise(layerSize, mdb, hdist, lg)
+	for _, lyr := range lyrs[1:6] {
+		alg.HandleIncomingLayer(lyr)
+	}
+	r.Equal(types.LayerID(4), alg.LatestComplete())
+	r.NoError(alg.Persist())
+
+	rec := NewRecoveredVerifyingTortoise(mdb, lg)
+	r.Equal(types.LayerID(4), rec.LatestComplete())
+	r.NoError(mdb.SaveLayerInputVector(4, outputs[4]))
+	for _, lyr := range lyrs[6:] {
+		rec.HandleIncomingLayer(lyr)
+	}
+	r.Equal(types.LayerID(layers), rec.LatestComplete())
+	r.Zero(rec.(*verifyingTortoise).Fallback)
+}
+
+func TestTortoise_BaseBlockVotes(t *testing.T) {
+	r := require.New(t)
+	layers, layerSize, hdist := 12, 10, 5
+	lyrs, outputs := generateBaseBlockMesh(t, layers, layerSize, 7, hdist)
+	for _, lyr := range lyrs[2:] {
+		for _, b := range lyr.Blocks() {
+			r.Len(b.ForDiff, 7, "only the votes on the previous layer are listed")
+			r.Len(b.AgainstDiff, layerSize-7)
+			r.Empty(b.NeutralDiff)
+		}
+	}
+
+	vt := differential(t, lyrs, outputs, layerSize, hdist, hareOutput)
+	r.Equal(types.LayerID(layers), vt.Verified)
+	r.Zero(vt.Fallback)
+	for _, lyr := range lyrs[1 : layers-1] {
+		valid := make(map[types.BlockID]struct{})
+		for _, id := range outputs[lyr.Index()] {
+			valid[id] = struct{}{}
+		}
+		for _, b := range lyr.Blocks() {
+			v, err := vt.db.ContextualValidity(b.ID())
+			r.NoError(err)
+			_, expected := valid[b.ID()]
+			r.Equal(expected, v, "layer %v block %v", lyr.Index(), b.ID())
+		}
+	}
+}
@@ -39,6 +39,10 @@ func (h *SuperHare) GetResult(id types.LayerID) ([]types.BlockID, error) {
 		log.Error("WTF SUPERHARE?? %v err: %v", id, err)
 		return nil, err
 	}
-	sort.Slice(blks, func(i, j int) bool { return bytes.Compare(blks[i].Bytes(), blks[j].Bytes()) == -1 })
+	sortBlocks(blks)
 	return blks, nil
 }
+
+func sortBlocks(blks []types.BlockID) {
+	sort.Slice(blks, func(i, j int) bool { return bytes.Compare(blks[i].Bytes(), blks[j].Bytes()) == -1 })
+}
@@ -0,0 +1,91 @@
+package turbohare
+
+import (
+	"bytes"
+	"errors"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type layerBlocks map[types.LayerID][]types.BlockID
+
+func (lb layerBlocks) LayerBlockIds(id types.LayerID) ([]types.BlockID, error) {
+	blks, ok := lb[id]
+	if !ok {
+		return nil, errors.New("layer not found")
+	}
+	return append([]types.BlockID(nil), blks...), nil
+}
+
+func blockIDs(n int, seed byte) []types.BlockID {
+	ids := make([]types.BlockID, n)
+	for i := range ids {
+		ids[i] = types.BlockID(types.CalcHash32([]byte{seed, byte(i)}).ToHash20())
+	}
+	return ids
+}
+
+func hashes(ids []types.BlockID) []types.Hash20 {
+	res := make([]types.Hash20, len(ids))
+	for i, id := range ids {
+		res[i] = types.Hash20(id)
+	}
+	return res
+}
+
+func TestRecordedHare_GetResult(t *testing.T) {
+	r := require.New(t)
+	captured := blockIDs(4, 1)
+	other := blockIDs(8, 2)
+	blocks := layerBlocks{1: captured, 2: other, 3: other, 4: other}
+	outputs := []LayerOutput{
+		{Layer: 1, Seen: 4, Blocks: hashes(captured[:3])},
+		{Layer: 2, Seen: 4, Blocks: hashes(captured[:2])},
+		{Layer: 3, Seen: 4},
+	}
+
+	var buf bytes.Buffer
+	r.NoError(WriteOutputs(&buf, outputs))
+	read, err := ReadOutputs(&buf)
+	r.NoError(err)
+	r.Equal(outputs, read)
+	h := NewRecorded(blocks, read, log.NewDefault(t.Name()))
+
+	// the blocks of the recording are known, the recorded output is returned
+	res, err := h.GetResult(1)
+	r.NoError(err)
+	expected := append([]types.BlockID(nil), captured[:3]...)
+	sortBlocks(expected)
+	r.Equal(expected, res)
+
+	// the blocks are of another run, the recorded fraction of the blocks is kept
+	res, err = h.GetResult(2)
+	r.NoError(err)
+	sorted := append([]types.BlockID(nil), other...)
+	sortBlocks(sorted)
+	r.Equal(sorted[:4], res)
+
+	// empty layers stay empty
+	res, err = h.GetResult(3)
+	r.NoError(err)
+	r.Empty(res)
+
+	// layers that were not recorded output all the blocks
+	res, err = h.GetResult(4)
+	r.NoError(err)
+	r.Equal(sorted, res)
+
+	_, err = h.GetResult(5)
+	r.Error(err)
+}
+
+func TestKeptBlocks(t *testing.T) {
+	r := require.New(t)
+	r.Equal(8, keptBlocks(8, 4, 4))
+	r.Equal(8, keptBlocks(8, 3, 0))
+	r.Equal(6, keptBlocks(8, 3, 4))
+	r.Equal(1, keptBlocks(8, 1, 100))
+	r.Equal(0, keptBlocks(0, 1, 4))
+}